@@ -68,11 +68,12 @@ function AdminReportsPage() {
         }
 
         const response = await axios.get(`${API_URL}/reports`, {
-          headers: { Authorization: `Bearer ${token}` }
+          headers: { Authorization: `Bearer ${token}` },
+          params: { status: 'all', sort: 'newest', limit: 100 }
         });
 
         if (response.data) {
-          setReports(response.data);
+          setReports(response.data.reports || []);
         }
         setLoading(false);
       } catch (err) {
@@ -70,6 +70,7 @@ function MatchesPage() {
   const [newMatches, setNewMatches] = useState([]);
   const [selectedMatch, setSelectedMatch] = useState(null);
   const [messages, setMessages] = useState([]);
+  const [icebreakers, setIcebreakers] = useState([]);
   const [newMessage, setNewMessage] = useState('');
   const [loading, setLoading] = useState(true);
   const [loadingMatches, setLoadingMatches] = useState(true);
@@ -156,6 +157,19 @@ function MatchesPage() {
     }
   }, [navigate, conversations]);
 
+  // Icebreaker prompts suggested for a match, shown while the conversation is empty
+  const fetchIcebreakers = useCallback(async (matchId, token) => {
+    try {
+      const response = await axios.get(`${API_URL}/messages/${matchId}/icebreakers`, {
+        headers: { Authorization: `Bearer ${token}` }
+      });
+      setIcebreakers(response.data?.icebreakers || []);
+    } catch (err) {
+      console.error('Error fetching icebreakers:', err);
+      setIcebreakers([]);
+    }
+  }, []);
+
   const fetchMessages = useCallback(async (matchId) => {
     try {
       setLoadingMessages(true);
@@ -175,8 +189,25 @@ function MatchesPage() {
           new Date(a.created_at) - new Date(b.created_at)
         );
         setMessages(sortedMessages);
+        if (sortedMessages.length === 0) {
+          fetchIcebreakers(matchId, token);
+        } else {
+          setIcebreakers([]);
+        }
+
+        // Opening the conversation reads everything the partner has sent so far
+        const lastReceived = [...sortedMessages].reverse().find(msg => msg.sender_id === Number(matchId));
+        if (lastReceived && !lastReceived.read) {
+          await axios.post(`${API_URL}/messages/read`, {
+            partner_id: Number(matchId),
+            up_to_message_id: lastReceived.id
+          }, {
+            headers: { Authorization: `Bearer ${token}` }
+          });
+        }
       } else {
         setMessages([]);
+        fetchIcebreakers(matchId, token);
       }
       setLoadingMessages(false);
 
@@ -191,7 +222,7 @@ function MatchesPage() {
         navigate('/login');
       }
     }
-  }, [navigate, fetchConversations]);
+  }, [navigate, fetchConversations, fetchIcebreakers]);
 
   // Fetch all data on component mount
   useEffect(() => {
@@ -212,8 +243,10 @@ function MatchesPage() {
     scrollToBottom();
   }, [messages]);
 
-  const sendMessage = async () => {
-    if (!newMessage.trim() || !selectedMatch) return;
+  // Sends the typed message, or one of the match's icebreakers when one is given
+  const sendMessage = async (icebreaker) => {
+    const content = icebreaker ? icebreaker.text : newMessage;
+    if (!content.trim() || !selectedMatch) return;
 
     try {
       const token = localStorage.getItem('token');
@@ -229,19 +262,21 @@ function MatchesPage() {
         id: `temp-${Date.now()}`,
         sender_id: Number(userId),
         receiver_id: selectedMatch.id,
-        content: newMessage,
+        content,
         created_at: new Date().toISOString(),
         read: false,
       };
 
       setMessages([...messages, tempMessage]);
-      setNewMessage('');      // Send message to server
+      if (!icebreaker) {
+        setNewMessage('');
+      }
+      // Send message to server
       const response = await axios.post(
         `${API_URL}/messages`,
-        {
-          receiver_id: selectedMatch.id,
-          content: newMessage
-        },
+        icebreaker
+          ? { receiver_id: selectedMatch.id, prompt_id: icebreaker.promptId }
+          : { receiver_id: selectedMatch.id, content },
         {
           headers: { Authorization: `Bearer ${token}` }
         }
@@ -646,6 +681,19 @@ function MatchesPage() {
               <Box sx={{ textAlign: 'center', mt: 4, color: 'text.secondary' }}>
                 <Typography>You matched with {selectedMatch.firstName}.</Typography>
                 <Typography variant="body2">Send a message to start the conversation!</Typography>
+                {icebreakers.some(icebreaker => !icebreaker.sentAt) && (
+                  <Stack direction="row" spacing={1} useFlexGap flexWrap="wrap" justifyContent="center" sx={{ mt: 2 }}>
+                    {icebreakers.filter(icebreaker => !icebreaker.sentAt).map(icebreaker => (
+                      <Chip
+                        key={icebreaker.promptId}
+                        label={icebreaker.text}
+                        onClick={() => sendMessage(icebreaker)}
+                        color="primary"
+                        variant="outlined"
+                      />
+                    ))}
+                  </Stack>
+                )}
               </Box>
             ) : (
               messages.map((message) => {
@@ -67,7 +67,10 @@ const NotificationDropdown = () => {
 
     // Handle navigation based on notification type
     try {
-      const data = JSON.parse(notification.data || '{}');
+      // The API sends data as an object; older responses carried it as a JSON string
+      const data = typeof notification.data === 'string'
+        ? JSON.parse(notification.data || '{}')
+        : notification.data || {};
       switch (notification.type) {
         case 'match':
           if (data.action === 'view_match') {
@@ -5,6 +5,9 @@ import (
 	"datingapp/models"
 	"fmt"
 	"os"
+	"os/user"
+
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -46,7 +49,13 @@ func makeUserAdmin(email string) error {
 		return fmt.Errorf("user with email %s not found: %v", email, err)
 	}
 
-	if err := database.DB.Model(&user).Update("is_admin", true).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("is_admin", true).Error; err != nil {
+			return err
+		}
+		return auditAdminChange(tx, "admin.grant", user, false)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update user admin status: %v", err)
 	}
 
@@ -61,10 +70,38 @@ func removeUserAdmin(email string) error {
 		return fmt.Errorf("user with email %s not found: %v", email, err)
 	}
 
-	if err := database.DB.Model(&user).Update("is_admin", false).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("is_admin", false).Error; err != nil {
+			return err
+		}
+		return auditAdminChange(tx, "admin.revoke", user, true)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update user admin status: %v", err)
 	}
 
 	fmt.Printf("✅ Admin privileges removed from user %s (ID: %d)\n", email, user.ID)
 	return nil
 }
+
+// auditAdminChange records an admin grant or revocation made from the command line in the audit log
+func auditAdminChange(tx *gorm.DB, action string, target models.User, wasAdmin bool) error {
+	actor := "cli"
+	if current, err := user.Current(); err == nil {
+		actor = "cli:" + current.Username
+	}
+	targetID := target.ID
+	entry := models.AdminAuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: "user",
+		TargetID:   &targetID,
+		Before:     map[string]interface{}{"isAdmin": wasAdmin},
+		After:      map[string]interface{}{"isAdmin": !wasAdmin},
+		Details:    models.AuditFingerprint(target.Email), // The audit log never holds a plain email
+	}
+	if host, err := os.Hostname(); err == nil {
+		entry.Details = fmt.Sprintf("%s on %s", entry.Details, host)
+	}
+	return models.AppendAuditLog(tx, &entry)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"datingapp/push"
+	"fmt"
+	"os"
+)
+
+// Generates a VAPID key pair for Web Push. Add the output to the backend .env file.
+func main() {
+	publicKey, privateKey, err := push.GenerateVAPIDKeys()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("VAPID_PUBLIC_KEY=%s\n", publicKey)
+	fmt.Printf("VAPID_PRIVATE_KEY=%s\n", privateKey)
+	fmt.Println("VAPID_SUBJECT=mailto:support@campuscupid.com")
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	gormlogger "gorm.io/gorm/logger"
 )
 
@@ -115,3 +116,29 @@ func Connect() {
 	// Print a success message if migration is completed successfully
 	logger.Info("Database migration completed")
 }
+
+// EnsureSearchIndexes creates the full-text search indexes that AutoMigrate cannot express.
+// It is a no-op on databases other than PostgreSQL.
+func EnsureSearchIndexes(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_content_fts ON messages USING GIN (to_tsvector('english', content))`).Error
+}
+
+// RunOnce applies a one-time data migration and records it by name so it never runs again. The
+// record is written first and committed with the migration, so instances starting together wait
+// for each other instead of both running it.
+func RunOnce(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) error {
+	if err := db.AutoMigrate(&models.DataMigration{}); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.DataMigration{Name: name, AppliedAt: time.Now()})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		logger.Info("Applying data migration %s", name)
+		return migrate(tx)
+	})
+}
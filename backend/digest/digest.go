@@ -0,0 +1,153 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"datingapp/i18n"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*
+var templateFiles embed.FS
+
+var funcs = map[string]interface{}{
+	"sub":   func(a int64, b int) int64 { return a - int64(b) },
+	"upper": strings.ToUpper,
+	"t":     translator(i18n.DefaultLocale), // Replaced with the recipient's locale in Render
+}
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.New("digest.txt").Funcs(funcs).ParseFS(templateFiles, "templates/digest.txt"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Funcs(funcs).ParseFS(templateFiles, "templates/digest.html"))
+)
+
+// Item is one unread notification in the digest
+type Item struct {
+	Title     string
+	Message   string
+	CreatedAt time.Time
+}
+
+// Conversation summarizes unread messages from one person
+type Conversation struct {
+	Name  string
+	Count int64
+}
+
+// Data is everything a digest email shows
+type Data struct {
+	FirstName         string
+	Locale            string // Language to write the email in; empty means i18n.DefaultLocale
+	Frequency         string // "daily" or "weekly"
+	Notifications     []Item // The most recent unread notifications
+	NotificationCount int64  // All unread notifications, which may exceed len(Notifications)
+	Conversations     []Conversation
+	MessageCount      int64
+	AppURL            string
+	UnsubscribeURL    string
+	Subject           string // Filled in by Render
+}
+
+// Empty reports whether there is nothing worth emailing about
+func (d Data) Empty() bool {
+	return d.NotificationCount == 0 && d.MessageCount == 0
+}
+
+// Render produces the subject line and both bodies in the digest's locale
+func Render(d Data) (subject, text, html string, err error) {
+	if !i18n.Supported(d.Locale) {
+		d.Locale = i18n.DefaultLocale
+	}
+	d.Subject = subjectFor(d)
+
+	t := map[string]interface{}{"t": translator(d.Locale)}
+	textTmpl, err := textTemplate.Clone()
+	if err != nil {
+		return "", "", "", err
+	}
+	htmlTmpl, err := htmlTemplate.Clone()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var textBody, htmlBody bytes.Buffer
+	if err := textTmpl.Funcs(t).Execute(&textBody, d); err != nil {
+		return "", "", "", fmt.Errorf("failed to render text digest: %v", err)
+	}
+	if err := htmlTmpl.Funcs(t).Execute(&htmlBody, d); err != nil {
+		return "", "", "", fmt.Errorf("failed to render HTML digest: %v", err)
+	}
+	return d.Subject, textBody.String(), htmlBody.String(), nil
+}
+
+// translator returns the template function t, called as {{t "key" "var" value ...}}
+func translator(locale string) func(key string, pairs ...interface{}) (string, error) {
+	return func(key string, pairs ...interface{}) (string, error) {
+		if len(pairs)%2 != 0 {
+			return "", fmt.Errorf("t %s: variables must be name/value pairs", key)
+		}
+		vars := i18n.Vars{}
+		for i := 0; i < len(pairs); i += 2 {
+			name, ok := pairs[i].(string)
+			if !ok {
+				return "", fmt.Errorf("t %s: variable name %v is not a string", key, pairs[i])
+			}
+			vars[name] = pairs[i+1]
+		}
+		return i18n.T(locale, key, vars), nil
+	}
+}
+
+func subjectFor(d Data) string {
+	var parts []string
+	if d.NotificationCount > 0 {
+		parts = append(parts, i18n.T(d.Locale, "digest.subject.notifications", i18n.Vars{"count": d.NotificationCount}))
+	}
+	if d.MessageCount > 0 {
+		parts = append(parts, i18n.T(d.Locale, "digest.subject.messages", i18n.Vars{"count": d.MessageCount}))
+	}
+	summary := strings.Join(parts, i18n.T(d.Locale, "digest.subject.and", nil))
+	return i18n.T(d.Locale, "digest.subject", i18n.Vars{"summary": summary})
+}
+
+// unsubscribePurpose is mixed into the signature so tokens can't be reused for other actions
+const unsubscribePurpose = "digest-unsubscribe"
+
+// UnsubscribeToken signs a user ID for the one-click unsubscribe link. Tokens don't expire,
+// because unsubscribe links in old emails must keep working.
+func UnsubscribeToken(secret []byte, userID uint) string {
+	id := strconv.FormatUint(uint64(userID), 10)
+	return id + "." + base64.RawURLEncoding.EncodeToString(sign(secret, id))
+}
+
+// ParseUnsubscribeToken verifies a token and returns the user ID it was issued for
+func ParseUnsubscribeToken(secret []byte, token string) (uint, error) {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, errors.New("malformed unsubscribe token")
+	}
+	userID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, errors.New("malformed unsubscribe token")
+	}
+	given, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(given, sign(secret, id)) {
+		return 0, errors.New("invalid unsubscribe token")
+	}
+	return uint(userID), nil
+}
+
+func sign(secret []byte, id string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsubscribePurpose + ":" + id))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,97 @@
+package digest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data := Data{
+		FirstName: "Alice",
+		Frequency: "daily",
+		Notifications: []Item{
+			{Title: "New Match! 💕", Message: "You matched with Bob! Start chatting now."},
+			{Title: "Someone Liked You! ❤️", Message: "<script>alert(1)</script> liked your profile"},
+		},
+		NotificationCount: 5,
+		Conversations:     []Conversation{{Name: "Bob", Count: 1}, {Name: "Carol", Count: 3}},
+		MessageCount:      4,
+		AppURL:            "https://campuscupid.example/app",
+		UnsubscribeURL:    "https://campuscupid.example/email/unsubscribe?token=1.abc",
+	}
+
+	subject, text, html, err := Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, "You have 5 new notifications and 4 unread messages on CampusCupid", subject)
+
+	assert.Contains(t, text, "Hi Alice,")
+	assert.Contains(t, text, "what you missed on CampusCupid today")
+	assert.Contains(t, text, "- New Match! 💕: You matched with Bob!")
+	assert.Contains(t, text, "...and 3 more")
+	assert.Contains(t, text, "Bob sent you 1 message\n")
+	assert.Contains(t, text, "Carol sent you 3 messages")
+	assert.Contains(t, text, "Unsubscribe: "+data.UnsubscribeURL)
+
+	assert.Contains(t, html, "<strong>Carol</strong> sent you 3 messages")
+	assert.Contains(t, html, `href="https://campuscupid.example/email/unsubscribe?token=1.abc"`)
+	assert.NotContains(t, html, "<script>", "notification text is escaped")
+}
+
+func TestRenderSubject(t *testing.T) {
+	subject, _, _, err := Render(Data{Frequency: "weekly", MessageCount: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "You have 1 unread message on CampusCupid", subject)
+
+	assert.True(t, Data{}.Empty())
+	assert.False(t, Data{MessageCount: 1}.Empty())
+}
+
+func TestUnsubscribeToken(t *testing.T) {
+	secret := []byte("digest-secret")
+	token := UnsubscribeToken(secret, 42)
+
+	userID, err := ParseUnsubscribeToken(secret, token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), userID)
+
+	_, err = ParseUnsubscribeToken([]byte("other-secret"), token)
+	assert.Error(t, err)
+
+	// Changing the user ID invalidates the signature
+	_, err = ParseUnsubscribeToken(secret, "43"+token[2:])
+	assert.Error(t, err)
+
+	for _, bad := range []string{"", "42", "abc.def", "42.!!!"} {
+		_, err = ParseUnsubscribeToken(secret, bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestRenderLocalized(t *testing.T) {
+	data := Data{
+		FirstName:         "Lucía",
+		Locale:            "es",
+		Frequency:         "weekly",
+		NotificationCount: 1,
+		Notifications:     []Item{{Title: "¡Nuevo match! 💕", Message: "¡Hiciste match con Bob!"}},
+		Conversations:     []Conversation{{Name: "Bob", Count: 2}},
+		MessageCount:      2,
+	}
+
+	subject, text, html, err := Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Tienes 1 notificación nueva y 2 mensajes sin leer en CampusCupid", subject)
+	assert.Contains(t, text, "Hola, Lucía:")
+	assert.Contains(t, text, "esta semana")
+	assert.Contains(t, text, "Bob te envió 2 mensajes")
+	assert.Contains(t, html, `<html lang="es">`)
+	assert.Contains(t, html, "Cancelar suscripción")
+
+	// Unknown locales fall back to English
+	data.Locale = "xx"
+	subject, _, _, err = Render(data)
+	require.NoError(t, err)
+	assert.Equal(t, "You have 1 new notification and 2 unread messages on CampusCupid", subject)
+}
@@ -0,0 +1,195 @@
+package escalation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Action is what happens automatically to a reported account when a rule fires
+type Action string
+
+const (
+	ActionPriorityBump      Action = "priority_bump"       // Raise the severity of the open reports in the moderation queue
+	ActionHideFromDiscovery Action = "hide_from_discovery" // Leave the account out of matches until the reports are reviewed
+	ActionSuspend           Action = "suspend"             // Suspend the account for a while, pending review
+)
+
+// Report is one unresolved report against the account being evaluated
+type Report struct {
+	ReporterID    uint
+	Category      string
+	CreatedAt     time.Time
+	ReporterTrust float64 // Between 0 and 1, see TrustScore
+}
+
+// Input is the report history of one reported account
+type Input struct {
+	Reports []Report
+	Now     time.Time
+}
+
+// Rule fires when enough trusted people report an account in a short time
+type Rule struct {
+	Name         string   `json:"name"`
+	Categories   []string `json:"categories,omitempty"` // Only count reports in these categories; empty counts all
+	WindowHours  int      `json:"windowHours"`          // Only count reports this recent
+	MinReports   int      `json:"minReports,omitempty"`
+	MinReporters int      `json:"minReporters,omitempty"` // Distinct reporters
+	MinTrust     float64  `json:"minTrust,omitempty"`     // Combined trust of the distinct reporters
+	Action       Action   `json:"action"`
+	SuspendHours int      `json:"suspendHours,omitempty"` // How long an ActionSuspend lasts
+}
+
+// Validate checks that a configured rule can fire and has a known action
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("escalation rule without a name")
+	}
+	if r.WindowHours <= 0 {
+		return fmt.Errorf("escalation rule %s: windowHours must be positive", r.Name)
+	}
+	if r.MinReports <= 0 && r.MinReporters <= 0 && r.MinTrust <= 0 {
+		return fmt.Errorf("escalation rule %s: needs minReports, minReporters or minTrust", r.Name)
+	}
+	switch r.Action {
+	case ActionPriorityBump, ActionHideFromDiscovery:
+	case ActionSuspend:
+		if r.SuspendHours <= 0 {
+			return fmt.Errorf("escalation rule %s: suspendHours must be positive", r.Name)
+		}
+	default:
+		return fmt.Errorf("escalation rule %s: unknown action %q", r.Name, r.Action)
+	}
+	return nil
+}
+
+// Window is how far back the rule looks
+func (r Rule) Window() time.Duration {
+	return time.Duration(r.WindowHours) * time.Hour
+}
+
+func (r Rule) counts(category string) bool {
+	if len(r.Categories) == 0 {
+		return true
+	}
+	for _, c := range r.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// Decision is a rule that fired and the action to take
+type Decision struct {
+	Rule         string `json:"rule"`
+	Action       Action `json:"action"`
+	Reason       string `json:"reason"`
+	WindowHours  int    `json:"windowHours"` // The rule's window; it shouldn't fire again for the account within it
+	SuspendHours int    `json:"suspendHours,omitempty"`
+}
+
+// Engine evaluates an account's reports against the configured rules
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine creates an engine with the given rules
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Rules returns the configured rules
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// MaxWindow is the longest window of any rule, so callers know how much report history to load
+func (e *Engine) MaxWindow() time.Duration {
+	var longest time.Duration
+	for _, rule := range e.rules {
+		if rule.Window() > longest {
+			longest = rule.Window()
+		}
+	}
+	return longest
+}
+
+// Evaluate returns a decision for every rule the reports satisfy, in rule order
+func (e *Engine) Evaluate(input Input) []Decision {
+	var decisions []Decision
+	for _, rule := range e.rules {
+		since := input.Now.Add(-rule.Window())
+		reports := 0
+		trust := map[uint]float64{}
+		for _, report := range input.Reports {
+			if report.CreatedAt.Before(since) || !rule.counts(report.Category) {
+				continue
+			}
+			reports++
+			// A reporter counts once, with the trust they had at their most trusted
+			if t, ok := trust[report.ReporterID]; !ok || report.ReporterTrust > t {
+				trust[report.ReporterID] = report.ReporterTrust
+			}
+		}
+		combined := 0.0
+		for _, t := range trust {
+			combined += t
+		}
+		if reports == 0 || reports < rule.MinReports || len(trust) < rule.MinReporters || combined < rule.MinTrust {
+			continue
+		}
+		decisions = append(decisions, Decision{
+			Rule:         rule.Name,
+			Action:       rule.Action,
+			Reason:       fmt.Sprintf("%d reports from %d reporters (trust %.1f) in %dh", reports, len(trust), combined, rule.WindowHours),
+			WindowHours:  rule.WindowHours,
+			SuspendHours: rule.SuspendHours,
+		})
+	}
+	return decisions
+}
+
+// TrustScore rates a reporter from how their past reports were resolved, between 0 and 1. Someone
+// with no history scores 0.5; reports that were actioned raise it and dismissed ones lower it.
+// Accounts younger than a week count for half.
+func TrustScore(actioned, dismissed int64, accountAge time.Duration) float64 {
+	score := float64(actioned+1) / float64(actioned+dismissed+2)
+	if accountAge < 7*24*time.Hour {
+		score /= 2
+	}
+	return score
+}
+
+// DefaultRules returns the built-in escalation rules
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "report_burst", WindowHours: 24, MinReporters: 2, MinTrust: 0.8, Action: ActionPriorityBump},
+		{Name: "underage_report", Categories: []string{"underage"}, WindowHours: 24 * 7, MinReporters: 1, MinTrust: 0.5, Action: ActionHideFromDiscovery},
+		{Name: "many_reporters", WindowHours: 24, MinReporters: 3, MinTrust: 1.5, Action: ActionHideFromDiscovery},
+		{Name: "harassment_wave", Categories: []string{"harassment", "underage"}, WindowHours: 24, MinReporters: 5, MinTrust: 3,
+			Action: ActionSuspend, SuspendHours: 48},
+	}
+}
+
+// NewEngineFromEnv builds the engine from the JSON rule list in ESCALATION_RULES, or the built-in
+// rules if it isn't set. An empty list turns automatic escalation off. An invalid list is an error
+// and the built-in rules are returned with it.
+func NewEngineFromEnv() (*Engine, error) {
+	value := os.Getenv("ESCALATION_RULES")
+	if value == "" {
+		return NewEngine(DefaultRules()...), nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(value), &rules); err != nil {
+		return NewEngine(DefaultRules()...), fmt.Errorf("ESCALATION_RULES: %w", err)
+	}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return NewEngine(DefaultRules()...), err
+		}
+	}
+	return NewEngine(rules...), nil
+}
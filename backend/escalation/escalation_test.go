@@ -0,0 +1,81 @@
+package escalation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateCountsDistinctTrustedReporters(t *testing.T) {
+	now := time.Now()
+	engine := NewEngine(Rule{Name: "burst", WindowHours: 24, MinReporters: 2, MinTrust: 1, Action: ActionPriorityBump})
+
+	// The same reporter twice is still one reporter
+	input := Input{Now: now, Reports: []Report{
+		{ReporterID: 1, Category: "spam", CreatedAt: now.Add(-time.Hour), ReporterTrust: 0.5},
+		{ReporterID: 1, Category: "spam", CreatedAt: now, ReporterTrust: 0.5},
+	}}
+	assert.Empty(t, engine.Evaluate(input))
+
+	// Two reporters, but not trusted enough together
+	input.Reports = append(input.Reports, Report{ReporterID: 2, Category: "spam", CreatedAt: now, ReporterTrust: 0.25})
+	assert.Empty(t, engine.Evaluate(input))
+
+	input.Reports[2].ReporterTrust = 0.75
+	decisions := engine.Evaluate(input)
+	if assert.Len(t, decisions, 1) {
+		assert.Equal(t, "burst", decisions[0].Rule)
+		assert.Equal(t, ActionPriorityBump, decisions[0].Action)
+	}
+
+	// Reports outside the window don't count
+	input.Reports[2].CreatedAt = now.Add(-25 * time.Hour)
+	assert.Empty(t, engine.Evaluate(input))
+}
+
+func TestEvaluateFiltersByCategory(t *testing.T) {
+	now := time.Now()
+	engine := NewEngine(DefaultRules()...)
+
+	spam := Input{Now: now, Reports: []Report{{ReporterID: 1, Category: "spam", CreatedAt: now, ReporterTrust: 0.5}}}
+	assert.Empty(t, engine.Evaluate(spam))
+
+	underage := Input{Now: now, Reports: []Report{{ReporterID: 1, Category: "underage", CreatedAt: now, ReporterTrust: 0.5}}}
+	decisions := engine.Evaluate(underage)
+	if assert.Len(t, decisions, 1) {
+		assert.Equal(t, ActionHideFromDiscovery, decisions[0].Action)
+	}
+}
+
+func TestTrustScore(t *testing.T) {
+	month := 30 * 24 * time.Hour
+	assert.Equal(t, 0.5, TrustScore(0, 0, month))
+	assert.Greater(t, TrustScore(4, 0, month), 0.8)
+	assert.Less(t, TrustScore(0, 4, month), 0.2)
+	assert.Equal(t, 0.25, TrustScore(0, 0, time.Hour), "new accounts count for half")
+}
+
+func TestNewEngineFromEnv(t *testing.T) {
+	t.Setenv("ESCALATION_RULES", "")
+	engine, err := NewEngineFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultRules(), engine.Rules())
+
+	t.Setenv("ESCALATION_RULES", `[{"name":"spam_wave","categories":["spam"],"windowHours":1,"minReports":10,"action":"suspend","suspendHours":2}]`)
+	engine, err = NewEngineFromEnv()
+	assert.NoError(t, err)
+	if assert.Len(t, engine.Rules(), 1) {
+		assert.Equal(t, ActionSuspend, engine.Rules()[0].Action)
+	}
+
+	t.Setenv("ESCALATION_RULES", `[]`)
+	engine, err = NewEngineFromEnv()
+	assert.NoError(t, err)
+	assert.Empty(t, engine.Rules())
+
+	t.Setenv("ESCALATION_RULES", `[{"name":"broken","windowHours":1,"minReports":1,"action":"delete"}]`)
+	engine, err = NewEngineFromEnv()
+	assert.Error(t, err)
+	assert.Equal(t, DefaultRules(), engine.Rules())
+}
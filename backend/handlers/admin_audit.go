@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxAuditExportRows caps a CSV export of the audit log
+const maxAuditExportRows = 10000
+
+// auditBatchSize is how many entries are checked at a time when verifying the hash chain
+const auditBatchSize = 500
+
+// errAuditChainBroken stops verification at the first entry that doesn't match
+var errAuditChainBroken = errors.New("audit hash chain is broken")
+
+// errAuditHeadReached stops verification at the recorded head of the chain
+var errAuditHeadReached = errors.New("reached the audit chain head")
+
+// newAuditEntry starts an audit entry for the signed-in admin's request
+func newAuditEntry(c *gin.Context, db *gorm.DB, action, targetType string, targetID uint) (models.AdminAuditLog, error) {
+	entry := models.AdminAuditLog{
+		Action:     action,
+		TargetType: targetType,
+		IP:         c.ClientIP(),
+	}
+	if targetID != 0 {
+		entry.TargetID = &targetID
+	}
+	if adminID, ok := c.Get("userID"); ok {
+		id := adminID.(uint)
+		var admin models.User
+		if err := db.Select("id", "email").First(&admin, id).Error; err != nil {
+			return entry, err
+		}
+		entry.ActorID = &id
+		entry.Actor = admin.Email
+	}
+	return entry, nil
+}
+
+// recordAdminAction appends what the signed-in admin did to the audit log. Pass the transaction
+// that makes the change so the two are committed together. before and after are snapshots of the
+// record, nil if it didn't exist; only the fields that differ are kept.
+func recordAdminAction(c *gin.Context, tx *gorm.DB, action, targetType string, targetID uint, before, after interface{}) error {
+	entry, err := newAuditEntry(c, tx, action, targetType, targetID)
+	if err != nil {
+		return err
+	}
+	entry.Before, entry.After = models.AuditDiff(before, after)
+	return models.AppendAuditLog(tx, &entry)
+}
+
+// recordAdminView audits an admin looking at data, with the query string as details. If the
+// entry can't be written it responds with an error and returns false, so nothing is shown that
+// wasn't recorded.
+func recordAdminView(c *gin.Context, action, targetType string, targetID uint) bool {
+	entry, err := newAuditEntry(c, database.DB, action, targetType, targetID)
+	if err == nil {
+		entry.Details = c.Request.URL.RawQuery
+		err = models.AppendAuditLog(database.DB, &entry)
+	}
+	if err != nil {
+		logger.Printf("Failed to audit %s: %v", action, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to record audit log")
+		return false
+	}
+	return true
+}
+
+// GetAuditLog lists the admin audit log (admin only)
+// @Summary Admin audit log (Admin)
+// @Description List what admins and the admin CLI did, newest first. With format=csv the matching entries are downloaded as a CSV file (up to 10000 rows) instead of a page.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Produce text/csv
+// @Param actor_id query int false "Only entries by this admin"
+// @Param action query string false "Only this action, e.g. user.ban"
+// @Param target_type query string false "Only entries about this kind of record, e.g. user"
+// @Param target_id query int false "Only entries about this record"
+// @Param from query string false "Only entries at or after this time (RFC 3339)"
+// @Param to query string false "Only entries before this time (RFC 3339)"
+// @Param format query string false "json or csv" default(json)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/audit [get]
+func GetAuditLog(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	query := database.DB.Model(&models.AdminAuditLog{})
+	for _, filter := range []string{"actor_id", "target_id"} {
+		value := c.Query(filter)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid "+filter)
+			return
+		}
+		query = query.Where(filter+" = ?", id)
+	}
+	for _, filter := range []string{"action", "target_type"} {
+		if value := c.Query(filter); value != "" {
+			query = query.Where(filter+" = ?", value)
+		}
+	}
+	for filter, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		value := c.Query(filter)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid "+filter+" time, use RFC 3339")
+			return
+		}
+		query = query.Where(condition, at)
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		respondWithError(c, http.StatusBadRequest, "Invalid format")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+	query = query.Order("id DESC")
+	if format == "csv" {
+		query = query.Limit(maxAuditExportRows)
+	} else {
+		limit, offset := getPaginationParams(c)
+		query = query.Limit(limit).Offset(offset)
+	}
+	var entries []models.AdminAuditLog
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve audit log"})
+		return
+	}
+
+	// Reading the audit log is itself audited
+	if !recordAdminView(c, "audit.view", "", 0) {
+		return
+	}
+
+	if format == "csv" {
+		writeAuditCSV(c, entries)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"total":   total,
+	})
+}
+
+// writeAuditCSV sends audit entries as a CSV download
+func writeAuditCSV(c *gin.Context, entries []models.AdminAuditLog) {
+	c.Header("Content-Disposition", `attachment; filename="admin-audit.csv"`)
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	optional := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	encode := func(diff map[string]interface{}) string {
+		if diff == nil {
+			return ""
+		}
+		data, _ := json.Marshal(diff)
+		return string(data)
+	}
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "created_at", "actor_id", "actor", "action", "target_type", "target_id", "before", "after", "details", "ip", "prev_hash", "hash"})
+	for _, entry := range entries {
+		w.Write([]string{
+			strconv.FormatUint(uint64(entry.ID), 10),
+			entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+			optional(entry.ActorID),
+			entry.Actor,
+			entry.Action,
+			entry.TargetType,
+			optional(entry.TargetID),
+			encode(entry.Before),
+			encode(entry.After),
+			entry.Details,
+			entry.IP,
+			entry.PrevHash,
+			entry.Hash,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		logger.Printf("Failed to write audit CSV: %v", err)
+	}
+}
+
+// VerifyAuditLog checks the audit log's hash chain (admin only)
+// @Summary Verify the admin audit log (Admin)
+// @Description Recompute the hash chain over the whole audit log. An entry that was edited or a gap where one was removed is reported by ID; entries removed from the end are caught by comparing with the recorded head of the chain.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/audit/verify [get]
+func VerifyAuditLog(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	// Read the head first: entries appended during the walk then only extend the chain past it
+	head, anchored, err := models.LoadAuditChainHead(database.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+
+	var checked int64
+	var brokenID, lastID uint
+	prev := ""
+	var batch []models.AdminAuditLog
+	err = database.DB.Order("id").FindInBatches(&batch, auditBatchSize, func(tx *gorm.DB, _ int) error {
+		if id, ok := models.VerifyAuditChain(prev, batch); !ok {
+			brokenID = id
+			return errAuditChainBroken
+		}
+		checked += int64(len(batch))
+		for _, entry := range batch {
+			if anchored && entry.ID == head.LastID {
+				lastID, prev = entry.ID, entry.Hash
+				return errAuditHeadReached
+			}
+		}
+		lastID, prev = batch[len(batch)-1].ID, batch[len(batch)-1].Hash
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errAuditChainBroken) && !errors.Is(err, errAuditHeadReached) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+	if !recordAdminView(c, "audit.verify", "", 0) {
+		return
+	}
+	if brokenID != 0 {
+		logger.Printf("Admin audit log hash chain is broken at entry %d", brokenID)
+		c.JSON(http.StatusOK, gin.H{"valid": false, "brokenAt": brokenID, "checked": checked})
+		return
+	}
+	if anchored && !models.VerifyAuditHead(head, lastID, prev) {
+		logger.Printf("Admin audit log ends at entry %d but its recorded head is entry %d", lastID, head.LastID)
+		c.JSON(http.StatusOK, gin.H{"valid": false, "truncatedAfter": lastID, "expectedLast": head.LastID, "checked": checked})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"valid": true, "checked": checked, "anchored": anchored})
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuditLog(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/reports", middleware.AuthMiddleware(), GetAllReports)
+	router.PATCH("/reports/:id", middleware.AuthMiddleware(), UpdateReport)
+	router.POST("/admin/users/:id/ban", middleware.AuthMiddleware(), BanUser)
+	router.GET("/admin/audit", middleware.AuthMiddleware(), GetAuditLog)
+	router.GET("/admin/audit/verify", middleware.AuthMiddleware(), VerifyAuditLog)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+	report := models.Report{ReporterID: alice.ID, TargetID: bob.ID, Category: models.ReportCategorySpam, Reason: "Links",
+		Status: models.ReportOpen, Severity: models.ReportSeverityLow}
+	db.Create(&report)
+
+	assert.Equal(t, http.StatusOK, performRequest(router, "GET", "/reports?status=all", "", admin.ID).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, "PATCH", "/reports/"+strconv.Itoa(int(report.ID)), `{"severity":"high"}`, admin.ID).Code)
+	assert.Equal(t, http.StatusCreated, performRequest(router, "POST", "/admin/users/"+strconv.Itoa(int(bob.ID))+"/ban", fmt.Sprintf(`{"reportId":%d,"reason":"Spam"}`, report.ID), admin.ID).Code)
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/admin/audit", "", alice.ID).Code)
+
+	w := performRequest(router, "GET", "/admin/audit?target_type=user&target_id="+strconv.Itoa(int(bob.ID)), "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/admin/audit", TestResult{
+		TestName: "Admin Audit Log",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var page struct {
+		Entries []models.AdminAuditLog `json:"entries"`
+		Total   int64                  `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	if assert.Len(t, page.Entries, 1) {
+		ban := page.Entries[0]
+		assert.Equal(t, "user.ban", ban.Action)
+		assert.Equal(t, "admin@example.com", ban.Actor)
+		assert.Equal(t, "203.0.113.9", ban.IP)
+		assert.Equal(t, "active", ban.Before["accountStatus"])
+		assert.Equal(t, "banned", ban.After["accountStatus"])
+	}
+
+	var update models.AdminAuditLog
+	db.Where("action = ?", "report.update").First(&update)
+	assert.Equal(t, map[string]interface{}{"severity": "low"}, update.Before)
+	assert.Equal(t, map[string]interface{}{"severity": "high"}, update.After)
+
+	// Viewing the reports and the audit log are recorded too
+	var views int64
+	db.Model(&models.AdminAuditLog{}).Where("action IN ?", []string{"reports.view", "audit.view"}).Count(&views)
+	assert.Equal(t, int64(2), views)
+
+	w = performRequest(router, "GET", "/admin/audit?format=csv", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, rows, 1+4, "header and the four entries before the export")
+
+	// The chain verifies, and entries can't be changed through the ORM
+	w = performRequest(router, "GET", "/admin/audit/verify", "", admin.ID)
+	assert.Contains(t, w.Body.String(), `"valid":true`)
+	assert.ErrorIs(t, db.Model(&update).Update("action", "report.view").Error, models.ErrAuditLogImmutable)
+
+	// Removing the newest entries still verifies as a chain, but no longer reaches the recorded head
+	var last models.AdminAuditLog
+	db.Order("id DESC").First(&last)
+	db.Exec("DELETE FROM admin_audit_logs WHERE id = ?", last.ID)
+	w = performRequest(router, "GET", "/admin/audit/verify", "", admin.ID)
+	assert.Contains(t, w.Body.String(), `"valid":false`)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"expectedLast":%d`, last.ID))
+
+	// Tampering underneath the application is detected
+	db.Exec("UPDATE admin_audit_logs SET actor = ? WHERE id = ?", "someone@example.com", update.ID)
+	w = performRequest(router, "GET", "/admin/audit/verify", "", admin.ID)
+	assert.Contains(t, w.Body.String(), `"valid":false`)
+	assert.Contains(t, w.Body.String(), fmt.Sprintf(`"brokenAt":%d`, update.ID))
+}
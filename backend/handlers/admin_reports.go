@@ -3,18 +3,40 @@ package handlers
 import (
 	"datingapp/database"
 	"datingapp/models"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// GetAllReports returns all submitted user reports (admin only)
-// @Summary View all user reports (Admin)
-// @Description Admin-only endpoint to list all submitted reports
+// reportPriorityOrder sorts the triage queue by severity, then oldest first
+const reportPriorityOrder = "CASE severity WHEN 'critical' THEN 4 WHEN 'high' THEN 3 WHEN 'medium' THEN 2 ELSE 1 END DESC, created_at, id"
+
+// errReportChanged means another moderator updated the report while this change was being made
+var errReportChanged = errors.New("report was changed concurrently")
+
+// GetAllReports lists submitted user reports for triage (admin only)
+// @Summary List user reports (Admin)
+// @Description Admin-only triage queue. By default lists open and in-review reports, most severe and oldest first.
 // @Tags admin
 // @Security ApiKeyAuth
 // @Produce json
-// @Success 200 {array} models.Report
+// @Param status query string false "Filter by status (open, in_review, actioned, dismissed, unresolved, all)" default(unresolved)
+// @Param category query string false "Filter by category (spam, harassment, underage, fake_profile, other)"
+// @Param severity query string false "Filter by severity (low, medium, high, critical)"
+// @Param assignee query string false "Filter by assignee: a moderator's user ID, me or none"
+// @Param target_id query int false "Only reports against this user"
+// @Param reporter_id query int false "Only reports submitted by this user"
+// @Param sort query string false "Order (priority, newest, oldest)" default(priority)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden - Admin only"
 // @Failure 500 {object} map[string]string "Internal server error"
@@ -25,12 +47,247 @@ func GetAllReports(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
 		return
 	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.Report{})
+	switch status := c.DefaultQuery("status", "unresolved"); status {
+	case "unresolved":
+		query = query.Where("status IN ?", unresolvedReportStatuses)
+	case string(models.ReportOpen), string(models.ReportInReview), string(models.ReportActioned), string(models.ReportDismissed):
+		query = query.Where("status = ?", status)
+	case "all":
+	default:
+		respondWithError(c, http.StatusBadRequest, "Invalid status filter")
+		return
+	}
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category = ?", category)
+	}
+	if severity := c.Query("severity"); severity != "" {
+		query = query.Where("severity = ?", severity)
+	}
+	switch assignee := c.Query("assignee"); assignee {
+	case "":
+	case "none":
+		query = query.Where("assignee_id IS NULL")
+	case "me":
+		query = query.Where("assignee_id = ?", adminID)
+	default:
+		assigneeID, err := strconv.ParseUint(assignee, 10, 32)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid assignee filter")
+			return
+		}
+		query = query.Where("assignee_id = ?", assigneeID)
+	}
+	for _, filter := range []string{"target_id", "reporter_id"} {
+		value := c.Query(filter)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid "+filter)
+			return
+		}
+		query = query.Where(filter+" = ?", id)
+	}
+
+	order := reportPriorityOrder
+	switch c.DefaultQuery("sort", "priority") {
+	case "priority":
+	case "newest":
+		order = "created_at DESC, id DESC"
+	case "oldest":
+		order = "created_at, id"
+	default:
+		respondWithError(c, http.StatusBadRequest, "Invalid sort order")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports"})
+		return
+	}
 
 	var reports []models.Report
-	if err := database.DB.Find(&reports).Error; err != nil {
+	if err := query.Order(order).Limit(limit).Offset(offset).Find(&reports).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve reports"})
 		return
 	}
+	if !recordAdminView(c, "reports.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reports": reports,
+		"total":   total,
+	})
+}
+
+// UpdateReport moves a report through triage (admin only)
+// @Summary Triage a user report (Admin)
+// @Description Change a report's status, severity, assignee or resolution notes. Reports go from open to in_review (claimed by the moderator unless someone is assigned) and are resolved as actioned or dismissed, which requires resolution notes and notifies the reporter. Resolved reports can't be changed.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Report ID"
+// @Param report body models.UpdateReportRequest true "Changes to the report"
+// @Success 200 {object} models.Report
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /reports/{id} [patch]
+func UpdateReport(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
 
-	c.JSON(http.StatusOK, reports)
+	reportID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid report ID")
+		return
+	}
+
+	var req models.UpdateReportRequest
+	if !validateInput(c, &req) {
+		return
+	}
+
+	var report models.Report
+	if err := database.DB.First(&report, reportID).Error; err != nil {
+		respondWithError(c, http.StatusNotFound, "Report not found")
+		return
+	}
+	if report.Status.IsResolved() {
+		respondWithError(c, http.StatusBadRequest, "Report has already been resolved")
+		return
+	}
+	previousStatus := report.Status
+	original := report
+
+	if req.AssigneeID != nil {
+		if *req.AssigneeID == 0 {
+			report.AssigneeID = nil
+		} else {
+			var assignee models.User
+			if err := database.DB.Select("id", "is_admin").First(&assignee, *req.AssigneeID).Error; err != nil || !assignee.IsAdmin {
+				respondWithError(c, http.StatusBadRequest, "Reports can only be assigned to moderators")
+				return
+			}
+			report.AssigneeID = &assignee.ID
+		}
+	}
+	if req.Severity != nil {
+		report.Severity = *req.Severity
+	}
+	if req.ResolutionNotes != nil {
+		report.ResolutionNotes = strings.TrimSpace(*req.ResolutionNotes)
+	}
+
+	resolved := false
+	if req.Status != nil && *req.Status != report.Status {
+		if !report.Status.CanTransitionTo(*req.Status) {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Cannot move a report from %s to %s", report.Status, *req.Status))
+			return
+		}
+		report.Status = *req.Status
+		switch {
+		case report.Status == models.ReportInReview && report.AssigneeID == nil:
+			// Starting a review claims the report
+			report.AssigneeID = &adminID
+		case report.Status.IsResolved():
+			if report.ResolutionNotes == "" {
+				respondWithError(c, http.StatusBadRequest, "Resolution notes are required to resolve a report")
+				return
+			}
+			now := time.Now()
+			report.ResolvedBy = &adminID
+			report.ResolvedAt = &now
+			resolved = true
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveReport(tx, report, previousStatus); err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "report.update", "report", report.ID, original, report)
+	})
+	if errors.Is(err, errReportChanged) {
+		respondWithError(c, http.StatusConflict, "Report was updated by another moderator, reload it and try again")
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to update report %d: %v", report.ID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to update report")
+		return
+	}
+	if resolved {
+		notifyOutbox()
+	}
+
+	logger.Printf("Admin %d updated report %d: %s", adminID, report.ID, report.Status)
+	c.JSON(http.StatusOK, report)
+}
+
+// saveReport writes a moderator's changes to a report, provided nobody changed its status since it
+// was loaded. If this change resolved it, the reporter is told and the reported account is put back
+// into matches once nothing else about it is waiting for review.
+func saveReport(tx *gorm.DB, report models.Report, previousStatus models.ReportStatus) error {
+	result := tx.Model(&report).Where("status = ?", previousStatus).
+		Select("status", "severity", "assignee_id", "resolution_notes", "resolved_by", "resolved_at").
+		Updates(&report)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errReportChanged
+	}
+	if previousStatus.IsResolved() || !report.Status.IsResolved() {
+		return nil
+	}
+	if err := releaseDiscoveryHold(tx, report.TargetID); err != nil {
+		return err
+	}
+	notification, err := reportResolvedNotification(tx, report)
+	if err != nil || notification == nil {
+		return err
+	}
+	return enqueueNotification(tx, *notification)
+}
+
+// reportResolvedNotification tells the reporter their report was resolved, in their language.
+// It doesn't say who resolved it or what action was taken. It returns nil if the reporter's
+// account is gone.
+func reportResolvedNotification(tx *gorm.DB, report models.Report) (*pendingNotification, error) {
+	var reporter models.User
+	if err := tx.Select("id", "locale").First(&reporter, report.ReporterID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newCatalogNotification(report.ReporterID, reporter.Locale, models.ReportData{
+		ReportID: report.ID,
+		Status:   report.Status,
+		Action:   "view_report",
+		NotificationText: models.NotificationText{
+			TitleKey:   "report.notification.title",
+			MessageKey: "report.notification." + string(report.Status),
+		},
+	})
 }
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"bytes"
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportTriage(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/reports", middleware.AuthMiddleware(), GetAllReports)
+	router.PATCH("/reports/:id", middleware.AuthMiddleware(), UpdateReport)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", Locale: "es"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+
+	req, _ := http.NewRequest("POST", "/report/"+strconv.Itoa(int(bob.ID)), bytes.NewBufferString(`{"reason":"Keeps sending links","category":"spam"}`))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req, _ = http.NewRequest("POST", "/report/"+strconv.Itoa(int(bob.ID)), bytes.NewBufferString(`{"reason":"Bad","category":"rude"}`))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid report category")
+
+	underage := models.Report{ReporterID: bob.ID, TargetID: alice.ID, Category: models.ReportCategoryUnderage, Reason: "Says she is 15",
+		Status: models.ReportOpen, Severity: models.ReportSeverityCritical}
+	db.Create(&underage)
+	old := models.Report{ReporterID: bob.ID, TargetID: alice.ID, Reason: "Old", Status: models.ReportDismissed, Severity: models.ReportSeverityHigh}
+	db.Create(&old)
+
+	// The queue shows unresolved reports, most severe first
+	req, _ = http.NewRequest("GET", "/reports", nil)
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/reports", TestResult{
+		TestName: "Report Triage Queue",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var queue struct {
+		Reports []models.Report `json:"reports"`
+		Total   int64           `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	assert.Equal(t, int64(2), queue.Total)
+	var spam models.Report
+	if assert.Len(t, queue.Reports, 2) {
+		assert.Equal(t, underage.ID, queue.Reports[0].ID)
+		spam = queue.Reports[1]
+		assert.Equal(t, models.ReportCategorySpam, spam.Category)
+		assert.Equal(t, models.ReportSeverityLow, spam.Severity)
+	}
+
+	req, _ = http.NewRequest("GET", "/reports?category=spam&assignee=none", nil)
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	assert.Equal(t, int64(1), queue.Total)
+
+	patch := func(id uint, body string, userID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/reports/"+strconv.Itoa(int(id)), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		addAuthHeader(req, userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, patch(spam.ID, `{"status":"in_review"}`, alice.ID).Code)
+
+	// Starting a review claims the report
+	w = patch(spam.ID, `{"status":"in_review","severity":"medium"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.First(&spam, spam.ID)
+	assert.Equal(t, models.ReportInReview, spam.Status)
+	assert.Equal(t, models.ReportSeverityMedium, spam.Severity)
+	if assert.NotNil(t, spam.AssigneeID) {
+		assert.Equal(t, admin.ID, *spam.AssigneeID)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, patch(spam.ID, `{"assigneeId":`+strconv.Itoa(int(bob.ID))+`}`, admin.ID).Code)
+	assert.Equal(t, http.StatusBadRequest, patch(spam.ID, `{"status":"actioned"}`, admin.ID).Code, "resolution notes are required")
+
+	w = patch(spam.ID, `{"status":"actioned","resolutionNotes":"Removed spam links and warned the user"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/reports/:id", TestResult{
+		TestName: "Resolve Report",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	db.First(&spam, spam.ID)
+	assert.Equal(t, models.ReportActioned, spam.Status)
+	assert.NotNil(t, spam.ResolvedAt)
+
+	// Resolved reports are final
+	assert.Equal(t, http.StatusBadRequest, patch(spam.ID, `{"status":"open"}`, admin.ID).Code)
+
+	// The reporter is told, in their language
+	drainOutbox(t, db, time.Now())
+	var notifications []models.Notification
+	db.Where("user_id = ? AND type = ?", alice.ID, models.NotificationTypeReport).Find(&notifications)
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, "Novedades sobre tu denuncia", notifications[0].Title)
+		assert.Nil(t, notifications[0].FromUserID)
+	}
+}
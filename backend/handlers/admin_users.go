@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/i18n"
+	"datingapp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// adminUserDetailLimit caps each history list on the admin user page
+const adminUserDetailLimit = 50
+
+// reportCountSQL counts the reports received by the user in the surrounding users query
+const reportCountSQL = "(SELECT COUNT(*) FROM reports WHERE reports.target_id = users.id)"
+
+// GetAdminUsers searches accounts (admin only)
+// @Summary Search users (Admin)
+// @Description Search accounts by email, name or ID and filter by sign-up date, reports received and account state, newest first
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param q query string false "Email or name fragment, or a user ID"
+// @Param created_from query string false "Signed up at or after (RFC 3339)"
+// @Param created_to query string false "Signed up before (RFC 3339)"
+// @Param min_reports query int false "Received at least this many reports"
+// @Param state query string false "Account state (active, suspended, banned, shadow_banned, hidden, admin, deleted)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/users [get]
+func GetAdminUsers(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	now := time.Now()
+	query := database.DB.Model(&models.User{})
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		if id, err := strconv.ParseUint(q, 10, 32); err == nil {
+			query = query.Where("users.id = ? OR LOWER(users.email) LIKE ? OR LOWER(users.first_name) LIKE ?", id, pattern, pattern)
+		} else {
+			query = query.Where("LOWER(users.email) LIKE ? OR LOWER(users.first_name) LIKE ?", pattern, pattern)
+		}
+	}
+	for filter, condition := range map[string]string{"created_from": "users.created_at >= ?", "created_to": "users.created_at < ?"} {
+		value := c.Query(filter)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid "+filter+" time, use RFC 3339")
+			return
+		}
+		query = query.Where(condition, at)
+	}
+	if value := c.Query("min_reports"); value != "" {
+		minReports, err := strconv.Atoi(value)
+		if err != nil || minReports < 0 {
+			respondWithError(c, http.StatusBadRequest, "Invalid min_reports")
+			return
+		}
+		query = query.Where(reportCountSQL+" >= ?", minReports)
+	}
+	switch state := c.Query("state"); state {
+	case "":
+	case "active":
+		query = query.Where("users.account_status = ? OR (users.account_status = ? AND users.suspended_until <= ?)",
+			models.AccountActive, models.AccountSuspended, now)
+	case "suspended":
+		query = query.Where("users.account_status = ? AND users.suspended_until > ?", models.AccountSuspended, now)
+	case "banned":
+		query = query.Where("users.account_status = ?", models.AccountBanned)
+	case "shadow_banned":
+		query = query.Where("users.shadow_banned = ?", true)
+	case "hidden":
+		query = query.Where("users.hidden_pending_review = ?", true)
+	case "admin":
+		query = query.Where("users.is_admin = ?", true)
+	case "deleted":
+		query = query.Unscoped().Where("users.deleted_at IS NOT NULL")
+	default:
+		respondWithError(c, http.StatusBadRequest, "Invalid state filter")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	var users []models.AdminUserSummary
+	if err := query.Select("users.*, " + reportCountSQL + " AS report_count").
+		Order("users.created_at DESC, users.id DESC").Limit(limit).Offset(offset).
+		Scan(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users"})
+		return
+	}
+	if !recordAdminView(c, "users.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users": users,
+		"total": total,
+	})
+}
+
+// GetAdminUser shows everything moderators need to know about an account (admin only)
+// @Summary User details (Admin)
+// @Description Profile, moderation state, interaction counts, reports received and filed, moderation history, recent activity and sign-in sessions. Deleted accounts are included.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id} [get]
+func GetAdminUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	user, ok := findAdminUser(c, database.DB)
+	if !ok {
+		return
+	}
+
+	var likesGiven, likesReceived, matches int64
+	var interactions []models.Interaction
+	var reportsReceived, reportsFiled []models.Report
+	var actions []models.ModerationAction
+	var activity []models.ActivityLog
+	var sessions []models.Session
+	db := database.DB
+	for _, err := range []error{
+		db.Model(&models.Interaction{}).Where("user_id = ? AND liked = ?", user.ID, true).Count(&likesGiven).Error,
+		db.Model(&models.Interaction{}).Where("target_id = ? AND liked = ?", user.ID, true).Count(&likesReceived).Error,
+		db.Model(&models.Interaction{}).Where("user_id = ? AND matched = ?", user.ID, true).Count(&matches).Error,
+		db.Where("user_id = ? OR target_id = ?", user.ID, user.ID).Order("created_at DESC").Limit(adminUserDetailLimit).Find(&interactions).Error,
+		db.Where("target_id = ?", user.ID).Order("created_at DESC").Limit(adminUserDetailLimit).Find(&reportsReceived).Error,
+		db.Where("reporter_id = ?", user.ID).Order("created_at DESC").Limit(adminUserDetailLimit).Find(&reportsFiled).Error,
+		db.Where("user_id = ?", user.ID).Order("created_at DESC, id DESC").Find(&actions).Error,
+		db.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(adminUserDetailLimit).Find(&activity).Error,
+		db.Where("user_id = ?", user.ID).Order("created_at DESC").Limit(adminUserDetailLimit).Find(&sessions).Error,
+	} {
+		if err != nil {
+			logger.Printf("Failed to load admin details for user %d: %v", user.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user"})
+			return
+		}
+	}
+
+	now := time.Now()
+	sessionResponses := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		sessionResponses[i] = gin.H{
+			"id":        session.ID,
+			"ip":        session.IP,
+			"userAgent": session.UserAgent,
+			"createdAt": session.CreatedAt,
+			"expiresAt": session.ExpiresAt,
+			"active":    session.IsActive(user, now),
+		}
+	}
+
+	if !recordAdminView(c, "user.view", "user", user.ID) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+		"moderation": gin.H{
+			"accountStatus":       user.AccountStatus,
+			"suspendedUntil":      user.SuspendedUntil,
+			"accountStatusReason": user.AccountStatusReason,
+			"shadowBanned":        user.ShadowBanned,
+			"hiddenPendingReview": user.HiddenPendingReview,
+			"sessionsRevokedAt":   user.SessionsRevokedAt,
+			"deletedAt":           user.DeletedAt,
+			"actions":             actions,
+		},
+		"interactions": gin.H{
+			"likesGiven":    likesGiven,
+			"likesReceived": likesReceived,
+			"matches":       matches,
+			"recent":        interactions,
+		},
+		"reports": gin.H{
+			"received": reportsReceived,
+			"filed":    reportsFiled,
+		},
+		"activity": activity,
+		"sessions": sessionResponses,
+	})
+}
+
+// UpdateAdminUser edits an account's profile fields (admin only)
+// @Summary Edit a user (Admin)
+// @Description Change an account's name, email, bio, date of birth, location, phone, locale or timezone. Omitted fields are left alone.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "User ID"
+// @Param user body models.AdminUserUpdateRequest true "Changes"
+// @Success 200 {object} models.User
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "Email already in use"
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id} [patch]
+func UpdateAdminUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	var req models.AdminUserUpdateRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	if req.Timezone != nil && *req.Timezone != "" {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid timezone")
+			return
+		}
+	}
+	if req.Locale != nil && strings.TrimSpace(*req.Locale) != "" {
+		locale := i18n.Normalize(*req.Locale)
+		if locale == "" {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported locale; supported locales are %s", strings.Join(i18n.Locales(), ", ")))
+			return
+		}
+		req.Locale = &locale
+	}
+
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		found, ok := findAdminUser(c, tx.Clauses(clause.Locking{Strength: "UPDATE"}))
+		if !ok {
+			return errResponded
+		}
+		user = found
+		original := user
+		for field, value := range map[*string]*string{
+			&user.FirstName: req.FirstName, &user.Email: req.Email, &user.Bio: req.Bio, &user.DateOfBirth: req.DateOfBirth,
+			&user.City: req.City, &user.Country: req.Country, &user.Phone: req.Phone, &user.Locale: req.Locale, &user.Timezone: req.Timezone,
+		} {
+			if value != nil {
+				*field = strings.TrimSpace(*value)
+			}
+		}
+		if user.Email != original.Email {
+			var taken int64
+			if err := tx.Unscoped().Model(&models.User{}).Where("email = ? AND id <> ?", user.Email, user.ID).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return errEmailTaken
+			}
+		}
+		if err := tx.Model(&user).Select("first_name", "email", "bio", "date_of_birth", "city", "country", "phone", "locale", "timezone").
+			Updates(&user).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "user.update", "user", user.ID, original, user)
+	})
+	switch {
+	case errors.Is(err, errResponded):
+		return
+	case errors.Is(err, errEmailTaken):
+		respondWithError(c, http.StatusConflict, "Email already in use")
+		return
+	case err != nil:
+		logger.Printf("Failed to update user: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to update user")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ForceLogoutUser signs a user out of every session (admin only)
+// @Summary Sign a user out everywhere (Admin)
+// @Description End every session of an account. The user has to sign in again.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "User ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/logout [post]
+func ForceLogoutUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		user, ok := findAdminUser(c, tx.Clauses(clause.Locking{Strength: "UPDATE"}))
+		if !ok {
+			return errResponded
+		}
+		before := gin.H{"sessionsRevokedAt": user.SessionsRevokedAt}
+		now := time.Now().Truncate(models.TokenTimePrecision)
+		if err := tx.Model(&user).Update("sessions_revoked_at", now).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "user.logout", "user", user.ID, before, gin.H{"sessionsRevokedAt": now})
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to sign user out: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to sign user out")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User signed out of every session"})
+}
+
+// DeleteAdminUser deletes or anonymizes an account (admin only)
+// @Summary Delete or anonymize a user (Admin)
+// @Description Delete an account, or with mode=anonymize also scrub its personal data. Either way the user is signed out. Admin accounts and your own account can't be removed here.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "User ID"
+// @Param mode query string false "delete or anonymize" default(delete)
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id} [delete]
+func DeleteAdminUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+	mode := models.AdminUserAction(c.DefaultQuery("mode", string(models.AdminUserDelete)))
+	if mode != models.AdminUserDelete && mode != models.AdminUserAnonymize {
+		respondWithError(c, http.StatusBadRequest, "Invalid mode, use delete or anonymize")
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		user, ok := findAdminUser(c, tx.Clauses(clause.Locking{Strength: "UPDATE"}))
+		if !ok {
+			return errResponded
+		}
+		if user.ID == adminID || user.IsAdmin {
+			return moderationError("Admin accounts can't be deleted here")
+		}
+		if user.DeletedAt.Valid && mode == models.AdminUserDelete {
+			return moderationError("Account is already deleted")
+		}
+		// The audit log shows that it happened, not the personal data that was removed
+		before := gin.H{"deleted": user.DeletedAt.Valid}
+		now := time.Now().Truncate(models.TokenTimePrecision)
+		if mode == models.AdminUserAnonymize {
+			user.Anonymize(now)
+		} else {
+			user.SessionsRevokedAt = &now
+		}
+		if err := tx.Unscoped().Model(&user).Select("first_name", "email", "password", "date_of_birth", "bio", "interests", "photos",
+			"profile_picture_url", "approved_photos", "approved_picture_url", "verified_at", "latitude", "longitude", "city", "country", "phone", "blocked_users", "last_active_at", "sessions_revoked_at").
+			Updates(&user).Error; err != nil {
+			return err
+		}
+		// A deleted account gets no more pushes, and anonymizing also drops the IPs and devices
+		// recorded at sign-in
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.PushSubscription{}).Error; err != nil {
+			return err
+		}
+		if mode == models.AdminUserAnonymize {
+			if err := tx.Where("user_id = ?", user.ID).Delete(&models.Session{}).Error; err != nil {
+				return err
+			}
+			if err := scrubUserContent(tx, user.ID); err != nil {
+				return err
+			}
+		}
+		if !user.DeletedAt.Valid {
+			if err := tx.Delete(&user).Error; err != nil {
+				return err
+			}
+		}
+		return recordAdminAction(c, tx, "user."+string(mode), "user", user.ID, before, gin.H{"deleted": true})
+	})
+	var invalid moderationError
+	switch {
+	case errors.Is(err, errResponded):
+		return
+	case errors.As(err, &invalid):
+		respondWithError(c, http.StatusBadRequest, invalid.Error())
+		return
+	case err != nil:
+		logger.Printf("Failed to %s user: %v", mode, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to delete user")
+		return
+	}
+	if mode == models.AdminUserAnonymize {
+		c.JSON(http.StatusOK, gin.H{"message": "User anonymized"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+// scrubUserContent removes what an anonymized user uploaded or left behind outside the users row:
+// photo records with their URLs and hashes, verification selfies, appeal evidence and profile views
+func scrubUserContent(tx *gorm.DB, userID uint) error {
+	photoIDs := tx.Model(&models.Photo{}).Select("id").Where("user_id = ?", userID)
+	if err := tx.Model(&models.Photo{}).Where("duplicate_of_id IN (?)", photoIDs).Update("duplicate_of_id", nil).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.Photo{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.VerificationRequest{}).Where("user_id = ?", userID).Update("selfie_url", "").Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Appeal{}).Where("user_id = ?", userID).Update("evidence_url", "").Error; err != nil {
+		return err
+	}
+	return tx.Where("viewer_id = ? OR viewed_id = ?", userID, userID).Delete(&models.ProfileView{}).Error
+}
+
+// errResponded aborts a transaction after the handler has already sent an error response
+var errResponded = errors.New("response already sent")
+
+// errEmailTaken means another account already uses the email
+var errEmailTaken = errors.New("email already in use")
+
+// findAdminUser loads the account named by the :id parameter, including deleted ones, responding
+// with an error if it can't
+func findAdminUser(c *gin.Context, db *gorm.DB) (models.User, bool) {
+	var user models.User
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return user, false
+	}
+	if err := db.Unscoped().First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(c, http.StatusNotFound, "User not found")
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to retrieve user")
+		}
+		return user, false
+	}
+	return user, true
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminUserManagement(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/admin/users", middleware.AuthMiddleware(), GetAdminUsers)
+	router.GET("/admin/users/:id", middleware.AuthMiddleware(), GetAdminUser)
+	router.PATCH("/admin/users/:id", middleware.AuthMiddleware(), UpdateAdminUser)
+	router.DELETE("/admin/users/:id", middleware.AuthMiddleware(), DeleteAdminUser)
+	router.POST("/admin/users/:id/logout", middleware.AuthMiddleware(), ForceLogoutUser)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", Phone: "555-0100"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	alice.HashPassword(alice.Password)
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+	db.Create(&models.Report{ReporterID: bob.ID, TargetID: alice.ID, Reason: "Rude", Status: models.ReportOpen, Severity: models.ReportSeverityMedium})
+	db.Create(&models.Interaction{UserID: bob.ID, TargetID: alice.ID, Liked: true})
+
+	alicePath := "/admin/users/" + strconv.Itoa(int(alice.ID))
+
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/admin/users", "", bob.ID).Code)
+
+	// Search by name fragment and filter on reports received
+	w := performRequest(router, "GET", "/admin/users?q=ALI&min_reports=1", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/admin/users", TestResult{
+		TestName: "Search Users",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var list struct {
+		Users []models.AdminUserSummary `json:"users"`
+		Total int64                     `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(t, list.Users, 1) {
+		assert.Equal(t, alice.ID, list.Users[0].ID)
+		assert.Equal(t, int64(1), list.Users[0].ReportCount)
+	}
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/admin/users?state=sleeping", "", admin.ID).Code)
+
+	// Alice signs in; her session shows up on her admin page
+	w = performRequest(router, "POST", "/login", `{"email":"alice@example.com","password":"password123"}`, 0)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var login struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &login)
+
+	w = performRequest(router, "GET", alicePath, "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var detail struct {
+		Interactions struct {
+			LikesReceived int64 `json:"likesReceived"`
+		} `json:"interactions"`
+		Reports struct {
+			Received []models.Report `json:"received"`
+		} `json:"reports"`
+		Sessions []map[string]interface{} `json:"sessions"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &detail))
+	assert.Equal(t, int64(1), detail.Interactions.LikesReceived)
+	assert.Len(t, detail.Reports.Received, 1)
+	if assert.Len(t, detail.Sessions, 1) {
+		assert.Equal(t, true, detail.Sessions[0]["active"])
+	}
+
+	// Edits are audited with a diff
+	w = performRequest(router, "PATCH", alicePath, `{"firstName":"Alicia","email":"bob@example.com"}`, admin.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = performRequest(router, "PATCH", alicePath, `{"firstName":"Alicia","phone":"555-0199"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var edit models.AdminAuditLog
+	db.Where("action = ?", "user.update").First(&edit)
+	assert.Equal(t, map[string]interface{}{"firstName": models.AuditFingerprint("Alice"), "phone": models.AuditFingerprint("555-0100")}, edit.Before,
+		"personal data is fingerprinted")
+	assert.Equal(t, map[string]interface{}{"firstName": models.AuditFingerprint("Alicia"), "phone": models.AuditFingerprint("555-0199")}, edit.After)
+	var raw int64
+	db.Model(&models.AdminAuditLog{}).Where("CAST(before AS text) LIKE ? OR CAST(after AS text) LIKE ?", "%555-01%", "%555-01%").Count(&raw)
+	assert.Zero(t, raw)
+
+	// Locales are normalized like the user's own settings, and unsupported ones are refused
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PATCH", alicePath, `{"locale":"klingon"}`, admin.ID).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, "PATCH", alicePath, `{"locale":"es-MX"}`, admin.ID).Code)
+	var localized models.User
+	db.First(&localized, alice.ID)
+	assert.Equal(t, "es", localized.Locale)
+
+	// Signing her out everywhere ends the session, and signing in right after works
+	assert.Equal(t, http.StatusOK, performRequestWithToken(router, "GET", "/conversations", "", login.Token).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, "POST", alicePath+"/logout", "", admin.ID).Code)
+	assert.Equal(t, http.StatusUnauthorized, performRequestWithToken(router, "GET", "/conversations", "", login.Token).Code)
+	w = performRequest(router, "POST", "/login", `{"email":"alice@example.com","password":"password123"}`, 0)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &login)
+	assert.Equal(t, http.StatusOK, performRequestWithToken(router, "GET", "/conversations", "", login.Token).Code)
+	db.Create(&models.PushSubscription{UserID: alice.ID, Endpoint: "https://push.example.com/alice", P256dh: "key", Auth: "auth"})
+	alicePhoto := models.Photo{UserID: &alice.ID, URL: "https://cdn.example.com/alice.jpg", Status: models.PhotoApproved, Hash: "00ff00ff00ff00ff"}
+	db.Create(&alicePhoto)
+	bobPhoto := models.Photo{UserID: &bob.ID, URL: "https://cdn.example.com/bob.jpg", Status: models.PhotoPending, DuplicateOfID: &alicePhoto.ID}
+	db.Create(&bobPhoto)
+	db.Create(&models.VerificationRequest{UserID: alice.ID, Pose: "thumbs_up", Status: models.VerificationRejected, ChallengeExpiresAt: time.Now(), SelfieURL: "https://cdn.example.com/selfie.jpg"})
+	db.Create(&models.Appeal{UserID: alice.ID, PhotoID: &alicePhoto.ID, Statement: "It's me", EvidenceURL: "https://cdn.example.com/evidence.jpg", Status: models.AppealUpheld})
+	db.Create(&models.ProfileView{ViewedID: bob.ID, ViewerID: alice.ID, Day: models.ProfileViewDay(time.Now()), ViewedAt: time.Now()})
+	db.Create(&models.ProfileView{ViewedID: alice.ID, ViewerID: bob.ID, Day: models.ProfileViewDay(time.Now()), ViewedAt: time.Now()})
+
+	// Anonymizing scrubs her personal data but keeps the row
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "DELETE", "/admin/users/"+strconv.Itoa(int(admin.ID)), "", admin.ID).Code)
+	w = performRequest(router, "DELETE", alicePath+"?mode=anonymize", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var anonymized models.User
+	db.Unscoped().First(&anonymized, alice.ID)
+	assert.True(t, anonymized.DeletedAt.Valid)
+	assert.NotContains(t, anonymized.Email, "alice")
+	assert.Empty(t, anonymized.Phone)
+
+	var audit models.AdminAuditLog
+	db.Where("action = ?", "user.anonymize").First(&audit)
+	assert.NotContains(t, audit.Before, "email", "the audit log doesn't keep the data that was scrubbed")
+	var sessions, subscriptions int64
+	db.Model(&models.Session{}).Where("user_id = ?", alice.ID).Count(&sessions)
+	db.Model(&models.PushSubscription{}).Where("user_id = ?", alice.ID).Count(&subscriptions)
+	assert.Zero(t, sessions, "sign-in IPs and devices are removed")
+	assert.Zero(t, subscriptions)
+
+	// So are her photos, selfies, appeal evidence and profile views
+	var photos, views int64
+	db.Model(&models.Photo{}).Where("user_id = ?", alice.ID).Count(&photos)
+	db.Model(&models.ProfileView{}).Where("viewer_id = ? OR viewed_id = ?", alice.ID, alice.ID).Count(&views)
+	assert.Zero(t, photos)
+	assert.Zero(t, views)
+	db.First(&bobPhoto, bobPhoto.ID)
+	assert.Nil(t, bobPhoto.DuplicateOfID)
+	var selfie models.VerificationRequest
+	db.Where("user_id = ?", alice.ID).First(&selfie)
+	assert.Empty(t, selfie.SelfieURL)
+	var appeal models.Appeal
+	db.Where("user_id = ?", alice.ID).First(&appeal)
+	assert.Empty(t, appeal.EvidenceURL)
+
+	w = performRequest(router, "GET", "/admin/users?state=deleted", "", admin.ID)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, int64(1), list.Total)
+}
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"context"
+	"datingapp/database"
+	"datingapp/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// announcementCheckInterval is how often the scheduler looks for announcements that are due
+	announcementCheckInterval = time.Minute
+	// announcementBatchSize is how many users each fan-out transaction covers
+	announcementBatchSize = 500
+)
+
+// announcementWake starts a fan-out right away when an announcement is created for immediate delivery
+var announcementWake = make(chan struct{}, 1)
+
+// StartAnnouncementScheduler delivers announcements once their scheduled time arrives, until ctx is cancelled
+func StartAnnouncementScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(announcementCheckInterval)
+		defer ticker.Stop()
+		for {
+			if err := runDueAnnouncements(ctx, database.DB, time.Now()); err != nil {
+				logger.Printf("Announcement run failed: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-announcementWake:
+			}
+		}
+	}()
+	logger.Printf("Announcement scheduler started")
+}
+
+// runDueAnnouncements starts every announcement whose time has come and finishes any fan-out that
+// was interrupted, e.g. by a restart
+func runDueAnnouncements(ctx context.Context, db *gorm.DB, now time.Time) error {
+	var due []models.Announcement
+	err := db.Where("(status = ? AND scheduled_at <= ?) OR status = ?", models.AnnouncementScheduled, now, models.AnnouncementSending).
+		Order("scheduled_at").Find(&due).Error
+	if err != nil {
+		return err
+	}
+
+	for _, announcement := range due {
+		if announcement.Status == models.AnnouncementScheduled {
+			// Only one instance gets to start it
+			result := db.Model(&models.Announcement{}).
+				Where("id = ? AND status = ?", announcement.ID, models.AnnouncementScheduled).
+				Updates(map[string]interface{}{"status": models.AnnouncementSending, "started_at": now})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+		}
+
+		delivered, err := fanOutAnnouncement(ctx, db, announcement.ID)
+		if err != nil {
+			logger.Printf("Announcement %d fan-out stopped after %d notifications: %v", announcement.ID, delivered, err)
+			continue
+		}
+		logger.Printf("Announcement %d delivered to %d users", announcement.ID, delivered)
+	}
+	return nil
+}
+
+// fanOutAnnouncement creates the announcement's notifications batch by batch and returns how many
+// this call created
+func fanOutAnnouncement(ctx context.Context, db *gorm.DB, announcementID uint) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		created, done, err := sendAnnouncementBatch(db, announcementID, time.Now())
+		total += int64(len(created))
+		if err != nil {
+			return total, err
+		}
+		for _, notificationID := range created {
+			queuePushDelivery(notificationID)
+		}
+		if done {
+			return total, nil
+		}
+	}
+}
+
+// sendAnnouncementBatch notifies the next batch of users after the announcement's cursor. The
+// notifications, the cursor and the delivery count are committed together, so an interrupted
+// fan-out resumes without notifying anyone twice. It returns the new notification IDs and whether
+// the fan-out is finished.
+func sendAnnouncementBatch(db *gorm.DB, announcementID uint, now time.Time) (created []uint, done bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var announcement models.Announcement
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&announcement, announcementID).Error; err != nil {
+			return err
+		}
+		if announcement.Status != models.AnnouncementSending {
+			done = true
+			return nil
+		}
+
+		var users []models.User
+		err := announcementAudience(tx, announcement.Segment).
+			Select("id", "notification_settings").
+			Where("id > ?", announcement.LastUserID).
+			Order("id").
+			Limit(announcementBatchSize).
+			Find(&users).Error
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			done = true
+			return tx.Model(&announcement).Updates(map[string]interface{}{"status": models.AnnouncementSent, "completed_at": now}).Error
+		}
+
+		data, err := announcementData(announcement)
+		if err != nil {
+			return err
+		}
+		var notifications []models.Notification
+		for _, user := range users {
+			if !models.ShouldNotifyAt(user, models.NotificationTypeAppUpdate, models.ChannelInApp, now) {
+				continue
+			}
+			notifications = append(notifications, models.Notification{
+				UserID:         user.ID,
+				Type:           models.NotificationTypeAppUpdate,
+				Title:          announcement.Title,
+				Message:        announcement.Message,
+				Data:           data,
+				Count:          1,
+				AnnouncementID: &announcement.ID,
+			})
+		}
+		if len(notifications) > 0 {
+			if err := tx.CreateInBatches(&notifications, announcementBatchSize).Error; err != nil {
+				return err
+			}
+		}
+
+		err = tx.Model(&announcement).Updates(map[string]interface{}{
+			"last_user_id":    users[len(users)-1].ID,
+			"delivered_count": gorm.Expr("delivered_count + ?", len(notifications)),
+		}).Error
+		if err != nil {
+			return err
+		}
+		for _, notification := range notifications {
+			created = append(created, notification.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return created, done, nil
+}
+
+// announcementAudience selects the users in a segment
+func announcementAudience(db *gorm.DB, segment models.AnnouncementSegment) *gorm.DB {
+	query := db.Model(&models.User{})
+	if len(segment.Countries) > 0 {
+		query = query.Where("LOWER(country) IN ?", lowerAll(segment.Countries))
+	}
+	if len(segment.Cities) > 0 {
+		query = query.Where("LOWER(city) IN ?", lowerAll(segment.Cities))
+	}
+	if segment.RegisteredAfter != nil {
+		query = query.Where("created_at >= ?", *segment.RegisteredAfter)
+	}
+	if segment.RegisteredBefore != nil {
+		query = query.Where("created_at < ?", *segment.RegisteredBefore)
+	}
+	return query
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(strings.TrimSpace(value))
+	}
+	return lowered
+}
+
+// announcementData is the notification payload for an announcement
+func announcementData(announcement models.Announcement) (string, error) {
+	data := models.AppUpdateData{AnnouncementID: announcement.ID, URL: announcement.URL, Action: "view_notifications"}
+	if announcement.URL != "" {
+		data.Action = "open_url"
+	}
+	return models.EncodeNotificationData(data)
+}
+
+// announcementResponses adds read statistics to announcements
+func announcementResponses(announcements []models.Announcement) []models.AnnouncementResponse {
+	responses := make([]models.AnnouncementResponse, 0, len(announcements))
+	for _, announcement := range announcements {
+		response := models.AnnouncementResponse{Announcement: announcement}
+		if announcement.DeliveredCount > 0 {
+			response.ReadRate = float64(announcement.ReadCount) / float64(announcement.DeliveredCount)
+		}
+		responses = append(responses, response)
+	}
+	return responses
+}
+
+// countAnnouncementReads adds notifications that were just marked read to their announcements'
+// read counts
+func countAnnouncementReads(tx *gorm.DB, read []models.Notification) error {
+	counts := map[uint]int64{}
+	for _, notification := range read {
+		if notification.AnnouncementID != nil {
+			counts[*notification.AnnouncementID]++
+		}
+	}
+	for id, count := range counts {
+		if err := tx.Model(&models.Announcement{}).Where("id = ?", id).
+			UpdateColumn("read_count", gorm.Expr("read_count + ?", count)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillAnnouncementReadCounts counts the reads of announcements sent before read counts were
+// kept on the announcement. It runs once, before the retention purge can remove more of them.
+func BackfillAnnouncementReadCounts(db *gorm.DB) error {
+	return database.RunOnce(db, "announcement_read_counts", func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE announcements SET read_count = (SELECT COUNT(*) FROM notifications " +
+			"WHERE notifications.announcement_id = announcements.id AND notifications.read = TRUE)").Error
+	})
+}
+
+// bindAnnouncementRequest reads and validates an announcement from the request body
+func bindAnnouncementRequest(c *gin.Context) (models.CreateAnnouncementRequest, bool) {
+	var req models.CreateAnnouncementRequest
+	if !validateInput(c, &req) {
+		return req, false
+	}
+	if err := req.Segment.Validate(); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return req, false
+	}
+	return req, true
+}
+
+// CreateAnnouncement composes an announcement for all users or a segment (admin only)
+// @Summary Create an announcement (Admin)
+// @Description Send an app update notification to all users, or to users matching a segment by country, city and registration date. Only users with app update notifications enabled receive it. Without scheduledAt it is sent right away.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param announcement body models.CreateAnnouncementRequest true "Announcement"
+// @Success 201 {object} models.Announcement
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/announcements [post]
+func CreateAnnouncement(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+	req, ok := bindAnnouncementRequest(c)
+	if !ok {
+		return
+	}
+
+	announcement := models.Announcement{
+		CreatedBy:   adminID,
+		Title:       req.Title,
+		Message:     req.Message,
+		URL:         req.URL,
+		Segment:     req.Segment,
+		Status:      models.AnnouncementScheduled,
+		ScheduledAt: time.Now(),
+	}
+	if req.ScheduledAt != nil && req.ScheduledAt.After(announcement.ScheduledAt) {
+		announcement.ScheduledAt = *req.ScheduledAt
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&announcement).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "announcement.create", "announcement", announcement.ID, nil, announcement)
+	})
+	if err != nil {
+		logger.Printf("Failed to create announcement: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to create announcement")
+		return
+	}
+	if req.ScheduledAt == nil {
+		select {
+		case announcementWake <- struct{}{}:
+		default:
+		}
+	}
+
+	logger.Printf("Admin %d scheduled announcement %d for %s", adminID, announcement.ID, announcement.ScheduledAt.Format(time.RFC3339))
+	c.JSON(http.StatusCreated, announcement)
+}
+
+// PreviewAnnouncement renders an announcement and counts its audience without sending it (admin only)
+// @Summary Preview an announcement (Admin)
+// @Description Show the notification an announcement would create and how many users it would reach
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param announcement body models.CreateAnnouncementRequest true "Announcement"
+// @Success 200 {object} models.AnnouncementPreview
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/announcements/preview [post]
+func PreviewAnnouncement(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	req, ok := bindAnnouncementRequest(c)
+	if !ok {
+		return
+	}
+
+	draft := models.Announcement{Title: req.Title, Message: req.Message, URL: req.URL, Segment: req.Segment}
+	data, err := announcementData(draft)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	preview := models.AnnouncementPreview{
+		Notification: models.NotificationResponse{
+			Type:      models.NotificationTypeAppUpdate,
+			Title:     draft.Title,
+			Message:   draft.Message,
+			Data:      models.NotificationDataJSON(models.NotificationTypeAppUpdate, data),
+			CreatedAt: time.Now(),
+			Count:     1,
+		},
+	}
+
+	// Preferences live in a JSON column, so recipients are counted in Go
+	now := time.Now()
+	var users []models.User
+	err = announcementAudience(database.DB, req.Segment).
+		Select("id", "notification_settings").
+		FindInBatches(&users, announcementBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, user := range users {
+				preview.SegmentSize++
+				if models.ShouldNotifyAt(user, models.NotificationTypeAppUpdate, models.ChannelInApp, now) {
+					preview.Recipients++
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		logger.Printf("Failed to count announcement audience: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to preview announcement")
+		return
+	}
+	if !recordAdminView(c, "announcement.preview", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// GetAnnouncements lists announcements with delivery and read statistics (admin only)
+// @Summary List announcements (Admin)
+// @Description List announcements, newest first, with delivery counts and read rates
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status (scheduled, sending, sent, cancelled)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/announcements [get]
+func GetAnnouncements(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.Announcement{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve announcements"})
+		return
+	}
+	var announcements []models.Announcement
+	if err := query.Order("scheduled_at DESC").Limit(limit).Offset(offset).Find(&announcements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve announcements"})
+		return
+	}
+	responses := announcementResponses(announcements)
+	if !recordAdminView(c, "announcements.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"announcements": responses,
+		"total":         total,
+	})
+}
+
+// GetAnnouncement returns one announcement with its delivery and read statistics (admin only)
+// @Summary Get an announcement (Admin)
+// @Description Get an announcement with its delivery count and read rate
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "Announcement ID"
+// @Success 200 {object} models.AnnouncementResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/announcements/{id} [get]
+func GetAnnouncement(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	announcement, ok := findAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	responses := announcementResponses([]models.Announcement{announcement})
+	if !recordAdminView(c, "announcement.view", "announcement", announcement.ID) {
+		return
+	}
+	c.JSON(http.StatusOK, responses[0])
+}
+
+// CancelAnnouncement cancels an announcement that hasn't started sending (admin only)
+// @Summary Cancel an announcement (Admin)
+// @Description Cancel a scheduled announcement. Announcements already sending or sent can't be cancelled.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "Announcement ID"
+// @Success 200 {object} models.Announcement
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/announcements/{id} [delete]
+func CancelAnnouncement(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	announcement, ok := findAnnouncement(c)
+	if !ok {
+		return
+	}
+
+	original := announcement
+	announcement.Status = models.AnnouncementCancelled
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Announcement{}).
+			Where("id = ? AND status = ?", announcement.ID, models.AnnouncementScheduled).
+			Update("status", models.AnnouncementCancelled)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAnnouncementNotScheduled
+		}
+		return recordAdminAction(c, tx, "announcement.cancel", "announcement", announcement.ID, original, announcement)
+	})
+	if errors.Is(err, errAnnouncementNotScheduled) {
+		respondWithError(c, http.StatusBadRequest, "Only scheduled announcements can be cancelled")
+		return
+	}
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to cancel announcement")
+		return
+	}
+
+	c.JSON(http.StatusOK, announcement)
+}
+
+// errAnnouncementNotScheduled means the announcement started sending or was cancelled before it could be cancelled
+var errAnnouncementNotScheduled = errors.New("announcement is no longer scheduled")
+
+// findAnnouncement loads the announcement named by the :id parameter, responding with an error if it can't
+func findAnnouncement(c *gin.Context) (models.Announcement, bool) {
+	var announcement models.Announcement
+	announcementID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid announcement ID")
+		return announcement, false
+	}
+	if err := database.DB.First(&announcement, announcementID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(c, http.StatusNotFound, "Announcement not found")
+		} else {
+			respondWithError(c, http.StatusInternalServerError, "Failed to retrieve announcement")
+		}
+		return announcement, false
+	}
+	return announcement, true
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnouncements(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/admin/announcements", middleware.AuthMiddleware(), CreateAnnouncement)
+	router.POST("/admin/announcements/preview", middleware.AuthMiddleware(), PreviewAnnouncement)
+	router.GET("/admin/announcements/:id", middleware.AuthMiddleware(), GetAnnouncement)
+	router.DELETE("/admin/announcements/:id", middleware.AuthMiddleware(), CancelAnnouncement)
+	router.PUT("/notifications/read", middleware.AuthMiddleware(), MarkNotificationsRead)
+
+	optedIn, optedOut := true, false
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", Country: "Germany",
+		NotificationSettings: models.NotificationSettings{AppUpdates: &optedIn}}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123", Country: "germany",
+		NotificationSettings: models.NotificationSettings{AppUpdates: &optedOut}}
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123", Country: "France",
+		NotificationSettings: models.NotificationSettings{AppUpdates: &optedIn}}
+	for _, user := range []*models.User{&admin, &alice, &bob, &carol} {
+		db.Create(user)
+	}
+
+	body := `{"title":"New feature","message":"Try video calls","url":"https://example.com/video","segment":{"countries":["GERMANY"]}}`
+
+	req, _ := http.NewRequest("POST", "/admin/announcements/preview", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, admin.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var preview models.AnnouncementPreview
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &preview))
+	assert.Equal(t, int64(2), preview.SegmentSize)
+	assert.Equal(t, int64(1), preview.Recipients)
+	assert.Equal(t, "New feature", preview.Notification.Title)
+
+	req, _ = http.NewRequest("POST", "/admin/announcements", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	writeTestResult("/admin/announcements", TestResult{
+		TestName: "Create Announcement",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var announcement models.Announcement
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &announcement))
+	assert.Equal(t, models.AnnouncementScheduled, announcement.Status)
+
+	// Running twice must not notify anyone twice
+	assert.NoError(t, runDueAnnouncements(context.Background(), db, time.Now()))
+	assert.NoError(t, runDueAnnouncements(context.Background(), db, time.Now()))
+
+	var notifications []models.Notification
+	db.Where("announcement_id = ?", announcement.ID).Find(&notifications)
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, alice.ID, notifications[0].UserID)
+		assert.Equal(t, models.NotificationTypeAppUpdate, notifications[0].Type)
+		// Marking it read twice counts once
+		for i := 0; i < 2; i++ {
+			body := fmt.Sprintf(`{"notificationIds":[%d]}`, notifications[0].ID)
+			assert.Equal(t, http.StatusOK, performRequest(router, "PUT", "/notifications/read", body, alice.ID).Code)
+		}
+	}
+	// The read still counts after the retention job removes the notification
+	_, _, err := purgeNotifications(context.Background(), db, NotificationRetention{ReadAfter: time.Minute}, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	var remaining int64
+	db.Unscoped().Model(&models.Notification{}).Where("announcement_id = ?", announcement.ID).Count(&remaining)
+	assert.Zero(t, remaining)
+
+	req, _ = http.NewRequest("GET", "/admin/announcements/"+strconv.Itoa(int(announcement.ID)), nil)
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats models.AnnouncementResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, models.AnnouncementSent, stats.Status)
+	assert.Equal(t, int64(1), stats.DeliveredCount)
+	assert.Equal(t, int64(1), stats.ReadCount)
+	assert.Equal(t, 1.0, stats.ReadRate)
+
+	// Sent announcements can't be cancelled, scheduled ones can
+	req, _ = http.NewRequest("DELETE", "/admin/announcements/"+strconv.Itoa(int(announcement.ID)), nil)
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	later := models.Announcement{CreatedBy: admin.ID, Title: "Later", Message: "Soon", Status: models.AnnouncementScheduled, ScheduledAt: time.Now().Add(time.Hour)}
+	db.Create(&later)
+	req, _ = http.NewRequest("DELETE", "/admin/announcements/"+strconv.Itoa(int(later.ID)), nil)
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Non-admins are turned away
+	req, _ = http.NewRequest("POST", "/admin/announcements", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestUnitAnnouncementData(t *testing.T) {
+	data, err := announcementData(models.Announcement{ID: 7})
+	assert.NoError(t, err)
+	payload, err := models.DecodeNotificationData(models.NotificationTypeAppUpdate, data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &models.AppUpdateData{AnnouncementID: 7, Action: "view_notifications"}, payload)
+	}
+
+	data, err = announcementData(models.Announcement{ID: 8, URL: "https://example.com"})
+	assert.NoError(t, err)
+	payload, err = models.DecodeNotificationData(models.NotificationTypeAppUpdate, data)
+	if assert.NoError(t, err) {
+		assert.Equal(t, &models.AppUpdateData{AnnouncementID: 8, URL: "https://example.com", Action: "open_url"}, payload)
+	}
+
+	after := time.Now()
+	before := after.Add(-time.Hour)
+	assert.Error(t, models.AnnouncementSegment{RegisteredAfter: &after, RegisteredBefore: &before}.Validate())
+	assert.NoError(t, models.AnnouncementSegment{RegisteredAfter: &before, RegisteredBefore: &after}.Validate())
+}
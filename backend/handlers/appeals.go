@@ -0,0 +1,687 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"datingapp/storage"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxAppealStatementLength caps the statement a user writes for an appeal, in characters
+const maxAppealStatementLength = 2000
+
+// isUniqueViolation reports whether err is Postgres refusing a row that breaks a unique index
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// actionInEffect reports whether a suspension or ban is what currently restricts the user's
+// account: it hasn't run out, and no later suspension, ban or reinstatement replaced it
+func actionInEffect(db *gorm.DB, action models.ModerationAction, user models.User, now time.Time) (bool, error) {
+	if _, restricted := user.AccountRestriction(now); !restricted {
+		return false, nil
+	}
+	var latest models.ModerationAction
+	err := db.Where("user_id = ? AND action IN ?", user.ID, []models.ModerationActionType{
+		models.ModerationSuspend, models.ModerationBan, models.ModerationReinstate,
+	}).Order("id DESC").First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return latest.ID == action.ID, nil
+}
+
+// parseAppealTarget reads the optional ID form field of what is being appealed
+func parseAppealTarget(c *gin.Context, field string) (*uint, bool) {
+	value := strings.TrimSpace(c.PostForm(field))
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		respondWithError(c, http.StatusBadRequest, "Invalid "+field)
+		return nil, false
+	}
+	target := uint(id)
+	return &target, true
+}
+
+// checkActionAppealable responds with why the user can't appeal a moderation action, if they can't
+func checkActionAppealable(c *gin.Context, userID, actionID uint, now time.Time) bool {
+	var action models.ModerationAction
+	if err := database.DB.Where("id = ? AND user_id = ?", actionID, userID).First(&action).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(c, http.StatusNotFound, "Moderation action not found")
+			return false
+		}
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return false
+	}
+	if !action.Appealable() {
+		respondWithError(c, http.StatusBadRequest, "Only suspensions and bans can be appealed")
+		return false
+	}
+	if now.After(action.CreatedAt.Add(models.AppealWindow)) {
+		respondWithError(c, http.StatusBadRequest, "This decision is too old to appeal")
+		return false
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "account_status", "suspended_until").First(&user, userID).Error; err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return false
+	}
+	inEffect, err := actionInEffect(database.DB, action, user, now)
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return false
+	}
+	if !inEffect {
+		respondWithError(c, http.StatusBadRequest, "This decision is no longer in effect")
+		return false
+	}
+
+	var appeals int64
+	if err := database.DB.Model(&models.Appeal{}).Where("moderation_action_id = ?", action.ID).Count(&appeals).Error; err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return false
+	}
+	if appeals > 0 {
+		respondWithError(c, http.StatusConflict, "You have already appealed this decision")
+		return false
+	}
+	return true
+}
+
+// checkPhotoAppealable responds with why the user can't appeal a photo rejection, if they can't
+func checkPhotoAppealable(c *gin.Context, userID, photoID uint, now time.Time) bool {
+	var photo models.Photo
+	if err := database.DB.Where("id = ? AND user_id = ?", photoID, userID).First(&photo).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondWithError(c, http.StatusNotFound, "Photo not found")
+			return false
+		}
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return false
+	}
+	if photo.Status != models.PhotoRejected {
+		respondWithError(c, http.StatusBadRequest, "Only rejected photos can be appealed")
+		return false
+	}
+	decidedAt := photo.CreatedAt
+	if photo.ReviewedAt != nil {
+		decidedAt = *photo.ReviewedAt
+	}
+	if now.After(decidedAt.Add(models.AppealWindow)) {
+		respondWithError(c, http.StatusBadRequest, "This decision is too old to appeal")
+		return false
+	}
+
+	// A photo can be rejected again after an overturned appeal, and that rejection appealed too
+	var appeals int64
+	err := database.DB.Model(&models.Appeal{}).
+		Where("photo_id = ? AND status IN ?", photo.ID, []models.AppealStatus{models.AppealPending, models.AppealUpheld}).
+		Count(&appeals).Error
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return false
+	}
+	if appeals > 0 {
+		respondWithError(c, http.StatusConflict, "You have already appealed this decision")
+		return false
+	}
+	return true
+}
+
+// CreateAppeal asks moderators to reconsider a suspension, ban or photo rejection
+// @Summary Appeal a moderation decision
+// @Description Appeal a suspension or ban that is still in effect, or a rejected photo, within 30 days of the decision. Suspended and banned users use the appealToken they got when signing in. Send exactly one of moderationActionId and photoId.
+// @Tags appeals
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param moderationActionId formData int false "The suspension or ban being appealed"
+// @Param photoId formData int false "The rejected photo being appealed"
+// @Param statement formData string true "Why the decision should be reversed"
+// @Param evidence formData file false "Optional image supporting the appeal"
+// @Success 201 {object} models.Appeal
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "Already appealed"
+// @Failure 500 {object} map[string]string
+// @Router /appeals [post]
+func CreateAppeal(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	actionID, ok := parseAppealTarget(c, "moderationActionId")
+	if !ok {
+		return
+	}
+	photoID, ok := parseAppealTarget(c, "photoId")
+	if !ok {
+		return
+	}
+	if (actionID == nil) == (photoID == nil) {
+		respondWithError(c, http.StatusBadRequest, "Appeal either a moderationActionId or a photoId")
+		return
+	}
+	statement := strings.TrimSpace(c.PostForm("statement"))
+	if statement == "" {
+		respondWithError(c, http.StatusBadRequest, "A statement is required")
+		return
+	}
+	if utf8.RuneCountInString(statement) > maxAppealStatementLength {
+		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("The statement can be at most %d characters", maxAppealStatementLength))
+		return
+	}
+
+	now := time.Now()
+	if actionID != nil && !checkActionAppealable(c, userID, *actionID, now) {
+		return
+	}
+	if photoID != nil && !checkPhotoAppealable(c, userID, *photoID, now) {
+		return
+	}
+
+	appeal := models.Appeal{
+		UserID:             userID,
+		ModerationActionID: actionID,
+		PhotoID:            photoID,
+		Statement:          statement,
+		Status:             models.AppealPending,
+	}
+
+	// Evidence is optional; a bad file is still an error rather than silently dropped
+	if file, err := c.FormFile("evidence"); err == nil {
+		if file.Size > maxUploadSize {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("File %s is too large (max %d MB)", file.Filename, maxUploadSize/1024/1024))
+			return
+		}
+		if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid file type for %s: only images allowed", file.Filename))
+			return
+		}
+		url, err := storage.UploadImage(file, fmt.Sprintf("dating_app/appeals/user_%d", userID))
+		if err != nil {
+			logger.Printf("Failed to upload appeal evidence to Cloudinary: %v", err)
+			respondWithError(c, http.StatusInternalServerError, "Failed to upload evidence")
+			return
+		}
+		appeal.EvidenceURL = url
+	}
+
+	if err := database.DB.Create(&appeal).Error; err != nil {
+		// Nothing refers to the evidence now
+		if appeal.EvidenceURL != "" {
+			if err := storage.DeleteImage(storage.ExtractPublicIDFromURL(appeal.EvidenceURL)); err != nil {
+				logger.Printf("Failed to delete evidence of unsaved appeal from Cloudinary: %v", err)
+			}
+		}
+		// Another request appealing the same decision got there first
+		if isUniqueViolation(err) {
+			respondWithError(c, http.StatusConflict, "You have already appealed this decision")
+			return
+		}
+		logger.Printf("Failed to create appeal for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to submit appeal")
+		return
+	}
+
+	logger.Printf("User %d submitted appeal %d", userID, appeal.ID)
+	c.JSON(http.StatusCreated, appeal)
+}
+
+// GetMyAppeals lists the signed-in user's appeals
+// @Summary List my appeals
+// @Description List the user's appeals, newest first, with the moderator's note once decided, and the decisions they can still appeal. Suspended and banned users can use their appealToken.
+// @Tags appeals
+// @Security ApiKeyAuth
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /appeals [get]
+func GetMyAppeals(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.Appeal{}).Where("user_id = ?", userID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve appeals")
+		return
+	}
+	appeals := []models.Appeal{}
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&appeals).Error; err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve appeals")
+		return
+	}
+
+	appealable, err := appealableDecisions(userID, time.Now())
+	if err != nil {
+		logger.Printf("Failed to find appealable decisions for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve appeals")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"appeals":    appeals,
+		"total":      total,
+		"appealable": appealable,
+	})
+}
+
+// appealableDecisions lists what the user could appeal right now: the suspension or ban on their
+// account and their rejected photos, leaving out anything already appealed or too old
+func appealableDecisions(userID uint, now time.Time) (gin.H, error) {
+	var moderationAction *models.ModerationAction
+	var user models.User
+	if err := database.DB.Select("id", "account_status", "suspended_until").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	var latest models.ModerationAction
+	err := database.DB.Where("user_id = ? AND action IN ?", userID, []models.ModerationActionType{models.ModerationSuspend, models.ModerationBan}).
+		Order("id DESC").First(&latest).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && !now.After(latest.CreatedAt.Add(models.AppealWindow)) {
+		inEffect, err := actionInEffect(database.DB, latest, user, now)
+		if err != nil {
+			return nil, err
+		}
+		var appeals int64
+		if err := database.DB.Model(&models.Appeal{}).Where("moderation_action_id = ?", latest.ID).Count(&appeals).Error; err != nil {
+			return nil, err
+		}
+		if inEffect && appeals == 0 {
+			moderationAction = &latest
+		}
+	}
+
+	photos := []models.Photo{}
+	err = database.DB.Where("user_id = ? AND status = ? AND COALESCE(reviewed_at, created_at) >= ?", userID, models.PhotoRejected, now.Add(-models.AppealWindow)).
+		Where("id NOT IN (?)", database.DB.Model(&models.Appeal{}).Select("photo_id").
+			Where("photo_id IS NOT NULL AND status IN ?", []models.AppealStatus{models.AppealPending, models.AppealUpheld})).
+		Order("id DESC").Find(&photos).Error
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"moderationAction": moderationAction, "photos": photos}, nil
+}
+
+// appealOutcomeNotification tells the user how their appeal was decided, in their language
+func appealOutcomeNotification(tx *gorm.DB, appeal models.Appeal) (*pendingNotification, error) {
+	var user models.User
+	if err := tx.Select("id", "locale").First(&user, appeal.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newCatalogNotification(user.ID, user.Locale, models.AppealData{
+		AppealID: appeal.ID,
+		Status:   appeal.Status,
+		Action:   "view_appeal",
+		NotificationText: models.NotificationText{
+			TitleKey:   "appeal.notification.title",
+			MessageKey: "appeal.notification." + string(appeal.Status),
+			Vars:       map[string]string{"note": appeal.ResolutionNote},
+		},
+	})
+}
+
+// GetAppealQueue lists appeals for moderators (admin only)
+// @Summary Appeals queue (Admin)
+// @Description List appeals, oldest first, each with the decision being appealed and the user's current account status. Kept apart from the reports queue.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status (pending, upheld, overturned, all)" default(pending)
+// @Param type query string false "Filter by what was appealed (account, photo)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/appeals [get]
+func GetAppealQueue(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.Appeal{})
+	if status := c.DefaultQuery("status", string(models.AppealPending)); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	switch c.Query("type") {
+	case "account":
+		query = query.Where("moderation_action_id IS NOT NULL")
+	case "photo":
+		query = query.Where("photo_id IS NOT NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve appeals"})
+		return
+	}
+	var appeals []models.Appeal
+	if err := query.Order("created_at ASC, id ASC").Limit(limit).Offset(offset).Find(&appeals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve appeals"})
+		return
+	}
+
+	var userIDs, actionIDs, photoIDs []uint
+	for _, appeal := range appeals {
+		userIDs = append(userIDs, appeal.UserID)
+		if appeal.ModerationActionID != nil {
+			actionIDs = append(actionIDs, *appeal.ModerationActionID)
+		}
+		if appeal.PhotoID != nil {
+			photoIDs = append(photoIDs, *appeal.PhotoID)
+		}
+	}
+	var users []models.User
+	var actions []models.ModerationAction
+	var photos []models.Photo
+	if len(userIDs) > 0 {
+		if err := database.DB.Unscoped().Select("id", "first_name", "account_status", "suspended_until", "account_status_reason").
+			Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve appeals"})
+			return
+		}
+	}
+	if len(actionIDs) > 0 {
+		if err := database.DB.Where("id IN ?", actionIDs).Find(&actions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve appeals"})
+			return
+		}
+	}
+	if len(photoIDs) > 0 {
+		if err := database.DB.Where("id IN ?", photoIDs).Find(&photos).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve appeals"})
+			return
+		}
+	}
+	usersByID := map[uint]models.User{}
+	for _, user := range users {
+		usersByID[user.ID] = user
+	}
+	actionsByID := map[uint]models.ModerationAction{}
+	for _, action := range actions {
+		actionsByID[action.ID] = action
+	}
+	photosByID := map[uint]models.Photo{}
+	for _, photo := range photos {
+		photosByID[photo.ID] = photo
+	}
+
+	items := make([]gin.H, 0, len(appeals))
+	for _, appeal := range appeals {
+		user := usersByID[appeal.UserID]
+		item := gin.H{
+			"appeal": appeal,
+			"user": gin.H{
+				"id":                  user.ID,
+				"firstName":           user.FirstName,
+				"accountStatus":       user.AccountStatus,
+				"suspendedUntil":      user.SuspendedUntil,
+				"accountStatusReason": user.AccountStatusReason,
+			},
+		}
+		if appeal.ModerationActionID != nil {
+			item["moderationAction"] = actionsByID[*appeal.ModerationActionID]
+		}
+		if appeal.PhotoID != nil {
+			item["photo"] = photosByID[*appeal.PhotoID]
+		}
+		items = append(items, item)
+	}
+	if !recordAdminView(c, "appeals.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"appeals": items,
+		"total":   total,
+	})
+}
+
+// overturnModerationAction lifts the suspension or ban an appeal was about and dismisses the report
+// that led to it. If it has already run out or been replaced by a later decision there is nothing
+// left to reverse on the account.
+func overturnModerationAction(c *gin.Context, tx *gorm.DB, appeal models.Appeal, adminID uint, now time.Time) error {
+	var action models.ModerationAction
+	if err := tx.First(&action, *appeal.ModerationActionID).Error; err != nil {
+		return err
+	}
+	if err := dismissOverturnedReport(c, tx, action, appeal, adminID, now); err != nil {
+		return err
+	}
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, action.UserID).Error; err != nil {
+		return err
+	}
+	inEffect, err := actionInEffect(tx, action, user, now)
+	if err != nil || !inEffect {
+		return err
+	}
+
+	reinstate := models.ModerationAction{
+		UserID:      user.ID,
+		ModeratorID: adminID,
+		Action:      models.ModerationReinstate,
+		Reason:      fmt.Sprintf("Appeal %d overturned %s %d", appeal.ID, action.Action, action.ID),
+		ReportID:    action.ReportID,
+	}
+	before := moderationSnapshot(user)
+	if err := applyModerationAction(&user, reinstate, now); err != nil {
+		return err
+	}
+	err = tx.Model(&user).Select("account_status", "suspended_until", "account_status_reason", "shadow_banned").Updates(&user).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(&reinstate).Error; err != nil {
+		return err
+	}
+	return recordAdminAction(c, tx, "user."+string(reinstate.Action), "user", user.ID, before, moderationSnapshot(user))
+}
+
+// dismissOverturnedReport marks the report behind an overturned suspension or ban as dismissed, so
+// it no longer stands as a violation by the account
+func dismissOverturnedReport(c *gin.Context, tx *gorm.DB, action models.ModerationAction, appeal models.Appeal, adminID uint, now time.Time) error {
+	if action.ReportID == nil {
+		return nil
+	}
+	var report models.Report
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&report, *action.ReportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if report.Status == models.ReportDismissed {
+		return nil
+	}
+
+	original := report
+	note := fmt.Sprintf("Appeal %d overturned %s %d", appeal.ID, action.Action, action.ID)
+	if report.ResolutionNotes != "" {
+		note = report.ResolutionNotes + "\n" + note
+	}
+	report.Status = models.ReportDismissed
+	report.ResolutionNotes = note
+	report.ResolvedBy = &adminID
+	report.ResolvedAt = &now
+	if err := saveReport(tx, report, original.Status); err != nil {
+		return err
+	}
+	return recordAdminAction(c, tx, "report.update", "report", report.ID, original, report)
+}
+
+// overturnPhotoRejection approves the photo an appeal was about and shows it on the profile again
+func overturnPhotoRejection(c *gin.Context, tx *gorm.DB, appeal models.Appeal, adminID uint, now time.Time) error {
+	var photo models.Photo
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&photo, *appeal.PhotoID).Error; err != nil {
+		return err
+	}
+	if photo.Status != models.PhotoRejected {
+		return nil
+	}
+
+	original := photo
+	photo.Status = models.PhotoApproved
+	photo.RejectionReason = ""
+	photo.ReviewedBy = &adminID
+	photo.ReviewedAt = &now
+	if err := tx.Save(&photo).Error; err != nil {
+		return err
+	}
+	if photo.UserID != nil {
+		var owner models.User
+		if err := tx.Select("id", "photos", "profile_picture_url").First(&owner, *photo.UserID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		} else if err == nil {
+			if err := syncApprovedPhotos(tx, &owner); err != nil {
+				return err
+			}
+		}
+	}
+	return recordAdminAction(c, tx, "photo.review", "photo", photo.ID, original, photo)
+}
+
+// ReviewAppeal records a moderator decision on an appeal (admin only)
+// @Summary Review an appeal (Admin)
+// @Description Uphold the original decision with a note for the user, or overturn it. Overturning reinstates a suspended or banned account and dismisses the report behind it, or approves a rejected photo. The user is notified either way.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Appeal ID"
+// @Param review body models.ReviewAppealRequest true "Moderator decision"
+// @Success 200 {object} models.Appeal
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/appeals/{id} [put]
+func ReviewAppeal(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	appealID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid appeal ID")
+		return
+	}
+
+	var req models.ReviewAppealRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Decision == "uphold" && req.Note == "" {
+		respondWithError(c, http.StatusBadRequest, "A note is required to uphold a decision")
+		return
+	}
+
+	var appeal models.Appeal
+	notify := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&appeal, appealID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				respondWithError(c, http.StatusNotFound, "Appeal not found")
+				return errResponded
+			}
+			return err
+		}
+		if appeal.Status != models.AppealPending {
+			respondWithError(c, http.StatusBadRequest, "Appeal has already been decided")
+			return errResponded
+		}
+
+		original := appeal
+		now := time.Now()
+		appeal.ResolutionNote = req.Note
+		appeal.ReviewedBy = &adminID
+		appeal.ReviewedAt = &now
+		if req.Decision == "overturn" {
+			appeal.Status = models.AppealOverturned
+			var err error
+			if appeal.ModerationActionID != nil {
+				err = overturnModerationAction(c, tx, appeal, adminID, now)
+			} else {
+				err = overturnPhotoRejection(c, tx, appeal, adminID, now)
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			appeal.Status = models.AppealUpheld
+		}
+		if err := tx.Save(&appeal).Error; err != nil {
+			return err
+		}
+
+		notification, err := appealOutcomeNotification(tx, appeal)
+		if err != nil {
+			return err
+		}
+		if notification != nil {
+			if err := enqueueNotification(tx, *notification); err != nil {
+				return err
+			}
+			notify = true
+		}
+		return recordAdminAction(c, tx, "appeal.review", "appeal", appeal.ID, original, appeal)
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to review appeal %d: %v", appealID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to review appeal")
+		return
+	}
+
+	if notify {
+		notifyOutbox()
+	}
+
+	logger.Printf("Admin %d reviewed appeal %d: %s", adminID, appeal.ID, appeal.Status)
+	c.JSON(http.StatusOK, appeal)
+}
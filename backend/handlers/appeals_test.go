@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppeals(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/admin/users/:id/ban", middleware.AuthMiddleware(), BanUser)
+	router.POST("/appeals", middleware.AppealAuthMiddleware(), CreateAppeal)
+	router.GET("/appeals", middleware.AppealAuthMiddleware(), GetMyAppeals)
+	router.GET("/admin/appeals", middleware.AuthMiddleware(), GetAppealQueue)
+	router.PUT("/admin/appeals/:id", middleware.AuthMiddleware(), ReviewAppeal)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123", Photos: []string{"https://cdn.example.com/bob.jpg"}}
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+	report := models.Report{ReporterID: alice.ID, TargetID: bob.ID, Category: models.ReportCategoryHarassment, Reason: "Rude",
+		Status: models.ReportOpen, Severity: models.ReportSeverityMedium}
+	db.Create(&report)
+	now := time.Now()
+	photo := models.Photo{UserID: &bob.ID, URL: "https://cdn.example.com/bob.jpg", Status: models.PhotoRejected,
+		RejectionReason: "Face not visible", ReviewedBy: &admin.ID, ReviewedAt: &now}
+	db.Create(&photo)
+
+	appeal := func(fields map[string]string, token string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for name, value := range fields {
+			form.WriteField(name, value)
+		}
+		form.Close()
+		req, _ := http.NewRequest("POST", "/appeals", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	aliceToken := generateTestToken(alice.ID)
+
+	bobPath := "/admin/users/" + strconv.Itoa(int(bob.ID))
+	assert.Equal(t, http.StatusCreated, performRequest(router, "POST", bobPath+"/ban", fmt.Sprintf(`{"reportId":%d,"reason":"Harassment"}`, report.ID), admin.ID).Code)
+	var ban models.ModerationAction
+	db.Where("user_id = ? AND action = ?", bob.ID, models.ModerationBan).First(&ban)
+
+	// Bob can't sign in, but is handed a token that only opens the appeal routes
+	w := performRequest(router, "POST", "/login", `{"email":"bob@example.com","password":"password123"}`, 0)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var login struct {
+		AppealToken string `json:"appealToken"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &login)
+	assert.NotEmpty(t, login.AppealToken)
+	appealToken := login.AppealToken
+	assert.Equal(t, http.StatusUnauthorized, performRequestWithToken(router, "GET", "/conversations", "", appealToken).Code)
+
+	w = performRequestWithToken(router, "GET", "/appeals", "", appealToken)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var mine struct {
+		Appeals    []models.Appeal `json:"appeals"`
+		Appealable struct {
+			ModerationAction *models.ModerationAction `json:"moderationAction"`
+			Photos           []models.Photo           `json:"photos"`
+		} `json:"appealable"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &mine)
+	assert.Empty(t, mine.Appeals)
+	if assert.NotNil(t, mine.Appealable.ModerationAction) {
+		assert.Equal(t, ban.ID, mine.Appealable.ModerationAction.ID)
+	}
+	assert.Len(t, mine.Appealable.Photos, 1)
+
+	banID := strconv.Itoa(int(ban.ID))
+	assert.Equal(t, http.StatusBadRequest, appeal(map[string]string{"moderationActionId": banID}, appealToken).Code, "statement required")
+	assert.Equal(t, http.StatusBadRequest, appeal(map[string]string{"moderationActionId": banID, "photoId": strconv.Itoa(int(photo.ID)), "statement": "Both"}, appealToken).Code)
+	assert.Equal(t, http.StatusNotFound, appeal(map[string]string{"moderationActionId": banID, "statement": "Not mine"}, aliceToken).Code)
+
+	w = appeal(map[string]string{"moderationActionId": banID, "statement": "It was a misunderstanding between friends"}, appealToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	writeTestResult("/appeals", TestResult{
+		TestName: "Appeal Ban",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var banAppeal models.Appeal
+	json.Unmarshal(w.Body.Bytes(), &banAppeal)
+	assert.Equal(t, models.AppealPending, banAppeal.Status)
+	assert.Equal(t, http.StatusConflict, appeal(map[string]string{"moderationActionId": banID, "statement": "Again"}, appealToken).Code)
+
+	w = appeal(map[string]string{"photoId": strconv.Itoa(int(photo.ID)), "statement": "My face is in the photo"}, appealToken)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var photoAppeal models.Appeal
+	json.Unmarshal(w.Body.Bytes(), &photoAppeal)
+
+	// Moderators get their own queue with the decision being appealed
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/admin/appeals", "", alice.ID).Code)
+	w = performRequest(router, "GET", "/admin/appeals?type=account", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var queue struct {
+		Appeals []struct {
+			Appeal           models.Appeal           `json:"appeal"`
+			ModerationAction models.ModerationAction `json:"moderationAction"`
+			User             struct {
+				AccountStatus models.AccountStatus `json:"accountStatus"`
+			} `json:"user"`
+		} `json:"appeals"`
+		Total int64 `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queue)
+	if assert.Len(t, queue.Appeals, 1) {
+		assert.Equal(t, banAppeal.ID, queue.Appeals[0].Appeal.ID)
+		assert.Equal(t, models.ModerationBan, queue.Appeals[0].ModerationAction.Action)
+		assert.Equal(t, models.AccountBanned, queue.Appeals[0].User.AccountStatus)
+	}
+
+	// Overturning the ban reinstates Bob
+	w = performRequest(router, "PUT", "/admin/appeals/"+strconv.Itoa(int(banAppeal.ID)), `{"decision":"overturn"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/admin/appeals/:id", TestResult{
+		TestName: "Overturn Appeal",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PUT", "/admin/appeals/"+strconv.Itoa(int(banAppeal.ID)), `{"decision":"overturn"}`, admin.ID).Code, "already decided")
+	db.First(&bob, bob.ID)
+	assert.Equal(t, models.AccountActive, bob.AccountStatus)
+	var reinstated int64
+	db.Model(&models.ModerationAction{}).Where("user_id = ? AND action = ? AND moderator_id = ?", bob.ID, models.ModerationReinstate, admin.ID).Count(&reinstated)
+	assert.Equal(t, int64(1), reinstated)
+	db.First(&report, report.ID)
+	assert.Equal(t, models.ReportDismissed, report.Status, "the report behind the ban no longer stands")
+	assert.Contains(t, report.ResolutionNotes, "Appeal "+strconv.Itoa(int(banAppeal.ID))+" overturned")
+	assert.Equal(t, http.StatusOK, performRequest(router, "POST", "/login", `{"email":"bob@example.com","password":"password123"}`, 0).Code)
+
+	// Upholding needs a note for the user; the photo stays rejected
+	photoPath := "/admin/appeals/" + strconv.Itoa(int(photoAppeal.ID))
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PUT", photoPath, `{"decision":"uphold"}`, admin.ID).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, "PUT", photoPath, `{"decision":"uphold","note":"Your face must be clearly visible"}`, admin.ID).Code)
+	db.First(&photo, photo.ID)
+	assert.Equal(t, models.PhotoRejected, photo.Status)
+	assert.Equal(t, http.StatusConflict, appeal(map[string]string{"photoId": strconv.Itoa(int(photo.ID)), "statement": "Please look again"}, generateTestToken(bob.ID)).Code)
+
+	// Two appeals of the same photo at once make one appeal
+	second := models.Photo{UserID: &bob.ID, URL: "https://cdn.example.com/bob-2.jpg", Status: models.PhotoRejected,
+		RejectionReason: "Face not visible", ReviewedBy: &admin.ID, ReviewedAt: &now}
+	db.Create(&second)
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = appeal(map[string]string{"photoId": strconv.Itoa(int(second.ID)), "statement": "It's me"}, generateTestToken(bob.ID)).Code
+		}(i)
+	}
+	wg.Wait()
+	assert.ElementsMatch(t, []int{http.StatusCreated, http.StatusConflict}, codes)
+	var secondAppeals int64
+	db.Model(&models.Appeal{}).Where("photo_id = ?", second.ID).Count(&secondAppeals)
+	assert.Equal(t, int64(1), secondAppeals)
+
+	drainOutbox(t, db, time.Now())
+	var notifications []models.Notification
+	db.Where("user_id = ? AND type = ?", bob.ID, models.NotificationTypeAppeal).Order("id").Find(&notifications)
+	if assert.Len(t, notifications, 2) {
+		assert.Contains(t, notifications[1].Message, "Your face must be clearly visible")
+	}
+}
+
+func TestUnitAppealScopedToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	router := gin.New()
+	router.GET("/conversations", middleware.AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	token, err := generateAppealToken(1, time.Now())
+	assert.NoError(t, err)
+
+	// The normal middleware refuses the token before looking up the user
+	req, _ := http.NewRequest("GET", "/conversations", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid token")
+}
+
+func TestUnitIsUniqueViolation(t *testing.T) {
+	assert.True(t, isUniqueViolation(&pgconn.PgError{Code: "23505"}))
+	assert.True(t, isUniqueViolation(fmt.Errorf("create appeal: %w", &pgconn.PgError{Code: "23505"})))
+	assert.False(t, isUniqueViolation(&pgconn.PgError{Code: "23503"}))
+	assert.False(t, isUniqueViolation(errors.New("duplicate key value violates unique constraint")))
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UpdateConversationState archives, mutes, pins or marks a conversation unread
+// @Summary Update conversation state
+// @Description Set per-user conversation controls: archive, mute, pin and mark unread
+// @Tags messaging
+// @Accept json
+// @Produce json
+// @Param user_id path uint true "Conversation partner's user ID"
+// @Param state body models.UpdateConversationStateRequest true "Conversation state changes"
+// @Security ApiKeyAuth
+// @Success 200 {object} models.ConversationState
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /conversations/{user_id} [put]
+func UpdateConversationState(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	partnerID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if uint(partnerID) == userID {
+		respondWithError(c, http.StatusBadRequest, "You cannot have a conversation with yourself")
+		return
+	}
+
+	var req models.UpdateConversationStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid request format")
+		return
+	}
+
+	if req.MutedUntil != nil && !req.MutedUntil.After(time.Now()) {
+		respondWithError(c, http.StatusBadRequest, "mutedUntil must be in the future")
+		return
+	}
+
+	var partner models.User
+	if err := database.DB.Select("id").First(&partner, partnerID).Error; err != nil {
+		respondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	state, err := getConversationState(database.DB, userID, uint(partnerID))
+	if err != nil {
+		logger.Printf("Failed to load conversation state for user %d and partner %d: %v", userID, partnerID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to update conversation")
+		return
+	}
+
+	if req.Archived != nil {
+		state.Archived = *req.Archived
+	}
+	if req.Pinned != nil {
+		state.Pinned = *req.Pinned
+	}
+	if req.ManuallyUnread != nil {
+		state.ManuallyUnread = *req.ManuallyUnread
+	}
+	switch {
+	case req.MutedUntil != nil:
+		mutedUntil := req.MutedUntil.UTC()
+		state.MutedUntil = &mutedUntil
+	case req.Muted != nil && *req.Muted:
+		mutedUntil := models.MutedIndefinitely
+		state.MutedUntil = &mutedUntil
+	case req.Muted != nil && !*req.Muted:
+		state.MutedUntil = nil
+	}
+
+	if err := database.DB.Save(&state).Error; err != nil {
+		logger.Printf("Failed to save conversation state for user %d and partner %d: %v", userID, partnerID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to update conversation")
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// getConversationState returns the stored state for a conversation, or a fresh unsaved one
+func getConversationState(db *gorm.DB, userID, partnerID uint) (models.ConversationState, error) {
+	state := models.ConversationState{UserID: userID, PartnerID: partnerID}
+	err := db.Where("user_id = ? AND partner_id = ?", userID, partnerID).First(&state).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return state, err
+	}
+	return state, nil
+}
+
+// loadConversationStates returns all of a user's conversation states keyed by partner ID
+func loadConversationStates(db *gorm.DB, userID uint) (map[uint]models.ConversationState, error) {
+	var states []models.ConversationState
+	if err := db.Where("user_id = ?", userID).Find(&states).Error; err != nil {
+		return nil, err
+	}
+	byPartner := make(map[uint]models.ConversationState, len(states))
+	for _, state := range states {
+		byPartner[state.PartnerID] = state
+	}
+	return byPartner, nil
+}
+
+// isConversationMuted reports whether userID has muted their conversation with partnerID
+func isConversationMuted(db *gorm.DB, userID, partnerID uint) bool {
+	state, err := getConversationState(db, userID, partnerID)
+	if err != nil {
+		logger.Printf("Failed to check mute state for user %d and partner %d: %v", userID, partnerID, err)
+		return false
+	}
+	return state.IsMuted(time.Now())
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"datingapp/middleware"
+	"datingapp/models"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateConversationState(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.PUT("/conversations/:user_id", middleware.AuthMiddleware(), UpdateConversationState)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "Hey Alice"})
+
+	// Archive the conversation with Bob
+	req, _ := http.NewRequest("PUT", "/conversations/"+strconv.Itoa(int(bob.ID)), bytes.NewBufferString(`{"archived":true,"muted":true}`))
+	addAuthHeader(req, alice.ID)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"archived":true`)
+	assert.True(t, isConversationMuted(db, alice.ID, bob.ID))
+
+	writeTestResult("/conversations/:user_id", TestResult{
+		TestName: "Archive And Mute Conversation",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	// Archived conversations are hidden from the inbox...
+	req, _ = http.NewRequest("GET", "/conversations", nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"firstName":"Bob"`)
+
+	// ...but listed with archived=true
+	req, _ = http.NewRequest("GET", "/conversations?archived=true", nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"firstName":"Bob"`)
+	assert.Contains(t, w.Body.String(), `"muted":true`)
+}
+
+func TestMarkMessagesRead(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/messages/read", middleware.AuthMiddleware(), MarkMessagesRead)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&models.Interaction{UserID: alice.ID, TargetID: bob.ID, Liked: true, Matched: true})
+	db.Create(&models.Interaction{UserID: bob.ID, TargetID: alice.ID, Liked: true, Matched: true})
+
+	first := models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "First"}
+	second := models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "Second"}
+	db.Create(&first)
+	db.Create(&second)
+
+	// Fetching the conversation delivers but does not read
+	req, _ := http.NewRequest("GET", "/messages/"+strconv.Itoa(int(bob.ID)), nil)
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stored models.Message
+	db.First(&stored, first.ID)
+	assert.NotNil(t, stored.DeliveredAt)
+	assert.False(t, stored.Read)
+
+	// Mark only the first message read
+	payload := `{"partner_id":` + strconv.Itoa(int(bob.ID)) + `,"up_to_message_id":` + strconv.Itoa(int(first.ID)) + `}`
+	req, _ = http.NewRequest("POST", "/messages/read", bytes.NewBufferString(payload))
+	addAuthHeader(req, alice.ID)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"updated":1`)
+
+	db.First(&stored, first.ID)
+	assert.True(t, stored.Read)
+	assert.NotNil(t, stored.ReadAt)
+	db.First(&stored, second.ID)
+	assert.False(t, stored.Read)
+
+	writeTestResult("/messages/read", TestResult{
+		TestName: "Mark Messages Read Up To ID",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+}
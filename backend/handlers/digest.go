@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"datingapp/database"
+	"datingapp/digest"
+	"datingapp/mail"
+	"datingapp/models"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// digestCheckInterval is how often the scheduler looks for users due a digest
+	digestCheckInterval = time.Hour
+	// digestSendHour is the earliest local hour a digest goes out, so it arrives in the morning
+	digestSendHour = 9
+	// digestMaxItems caps the notifications listed in one email
+	digestMaxItems = 10
+	// digestMaxConversations caps the senders listed in one email
+	digestMaxConversations = 5
+	// digestBatchSize is how many users are loaded at a time
+	digestBatchSize = 200
+)
+
+// digestLinks holds the public URLs digests link to
+var digestLinks = struct {
+	AppURL string // Frontend, for the "Open CampusCupid" button
+	APIURL string // This API, for the unsubscribe link
+}{
+	AppURL: "http://localhost:3000",
+	APIURL: "http://localhost:8080",
+}
+
+// StartDigestScheduler sends email digests every hour to users who are due one, until ctx is cancelled.
+// Empty URLs keep the local development defaults.
+func StartDigestScheduler(ctx context.Context, mailer mail.Mailer, appURL, apiURL string) {
+	if appURL != "" {
+		digestLinks.AppURL = strings.TrimRight(appURL, "/")
+	}
+	if apiURL != "" {
+		digestLinks.APIURL = strings.TrimRight(apiURL, "/")
+	}
+
+	go func() {
+		ticker := time.NewTicker(digestCheckInterval)
+		defer ticker.Stop()
+		for {
+			sent, err := runDigests(ctx, database.DB, mailer, time.Now())
+			if err != nil {
+				logger.Printf("Digest run failed: %v", err)
+			} else if sent > 0 {
+				logger.Printf("Sent %d email digests", sent)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Printf("Email digest scheduler started")
+}
+
+// digestSecret signs unsubscribe links
+func digestSecret() []byte {
+	if secret := os.Getenv("DIGEST_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// runDigests sends a digest to every user who is due one and returns how many were sent
+func runDigests(ctx context.Context, db *gorm.DB, mailer mail.Mailer, now time.Time) (int, error) {
+	sent := 0
+	var users []models.User
+	result := db.Where("digest_frequency IN ?", []models.DigestFrequency{models.DigestDaily, models.DigestWeekly}).
+		FindInBatches(&users, digestBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, user := range users {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if !digestDue(user, now) {
+					continue
+				}
+				ok, err := sendUserDigest(ctx, db, mailer, user, now)
+				if err != nil {
+					// Leave LastDigestAt alone so the next run retries
+					logger.Printf("Failed to send digest to user %d: %v", user.ID, err)
+					continue
+				}
+				if ok {
+					sent++
+				}
+			}
+			return nil
+		})
+	return sent, result.Error
+}
+
+// BackfillDigestEmailPreferences turns on the email channel in notification settings saved before
+// digests honoured it. It runs once; until then the per-type email switch did nothing, so a stored
+// "off" wasn't a choice anyone made.
+func BackfillDigestEmailPreferences(db *gorm.DB) error {
+	return database.RunOnce(db, "digest_email_preferences", backfillDigestEmailPreferences)
+}
+
+func backfillDigestEmailPreferences(tx *gorm.DB) error {
+	var users []models.User
+	return tx.Select("id", "notification_settings").
+		FindInBatches(&users, digestBatchSize, func(*gorm.DB, int) error {
+			for i := range users {
+				user := &users[i]
+				if len(user.NotificationSettings.Types) == 0 {
+					continue
+				}
+				for notificationType, prefs := range user.NotificationSettings.Types {
+					prefs.Email = models.DefaultChannelPreferences(notificationType).Email
+					user.NotificationSettings.Types[notificationType] = prefs
+				}
+				if err := tx.Model(user).Select("notification_settings").Updates(user).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// digestDue reports whether a user's digest period has elapsed and it's morning where they are,
+// outside their quiet hours
+func digestDue(user models.User, now time.Time) bool {
+	period := user.DigestFrequency.Period()
+	if period == 0 {
+		return false
+	}
+	if now.In(user.UserLocation()).Hour() < digestSendHour {
+		return false
+	}
+	if user.NotificationSettings.QuietHours.Contains(now, user.UserLocation()) {
+		return false
+	}
+	// Allow one check interval of slack so a digest sent at 9:05 isn't pushed to 10:00 the next day
+	return user.LastDigestAt == nil || now.Sub(*user.LastDigestAt) >= period-digestCheckInterval
+}
+
+// sendUserDigest emails one user a summary of what they missed since their last digest.
+// Users who opened the app since then, or have nothing new, are skipped; either way the period
+// is marked as handled. It reports whether an email was sent.
+func sendUserDigest(ctx context.Context, db *gorm.DB, mailer mail.Mailer, user models.User, now time.Time) (bool, error) {
+	since := now.Add(-user.DigestFrequency.Period())
+	if user.LastDigestAt != nil {
+		since = *user.LastDigestAt
+	}
+	markHandled := func() error {
+		return db.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("last_digest_at", now).Error
+	}
+
+	if user.LastActiveAt != nil && user.LastActiveAt.After(since) {
+		return false, markHandled()
+	}
+
+	data, err := buildDigest(db, user, since, now)
+	if err != nil {
+		return false, err
+	}
+	if data.Empty() {
+		return false, markHandled()
+	}
+
+	subject, text, html, err := digest.Render(data)
+	if err != nil {
+		return false, err
+	}
+	err = mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+		Headers: map[string]string{
+			// RFC 8058 one-click unsubscribe
+			"List-Unsubscribe":      "<" + data.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, markHandled()
+}
+
+// buildDigest collects unread notifications and messages received since the given time, of the
+// types the user gets by email
+func buildDigest(db *gorm.DB, user models.User, since, now time.Time) (digest.Data, error) {
+	data := digest.Data{
+		FirstName:      user.FirstName,
+		Locale:         user.Locale,
+		Frequency:      string(user.DigestFrequency),
+		AppURL:         digestLinks.AppURL,
+		UnsubscribeURL: digestLinks.APIURL + "/email/unsubscribe?token=" + url.QueryEscape(digest.UnsubscribeToken(digestSecret(), user.ID)),
+	}
+
+	var types []models.NotificationType
+	for _, notificationType := range models.NotificationTypes {
+		if models.ShouldNotifyAt(user, notificationType, models.ChannelEmail, now) {
+			types = append(types, notificationType)
+		}
+	}
+	if len(types) == 0 {
+		return data, nil
+	}
+
+	unread := db.Model(&models.Notification{}).Where("user_id = ? AND read = ? AND created_at > ? AND type IN ?", user.ID, false, since, types)
+	if err := unread.Count(&data.NotificationCount).Error; err != nil {
+		return data, err
+	}
+	var notifications []models.Notification
+	if err := unread.Preload("FromUser").Order("created_at DESC").Limit(digestMaxItems).Find(&notifications).Error; err != nil {
+		return data, err
+	}
+	for _, notification := range notifications {
+		localizeNotification(user.Locale, &notification)
+		data.Notifications = append(data.Notifications, digest.Item{
+			Title:     notification.Title,
+			Message:   notification.Message,
+			CreatedAt: notification.CreatedAt,
+		})
+	}
+
+	if !models.ShouldNotifyAt(user, models.NotificationTypeMessage, models.ChannelEmail, now) {
+		return data, nil
+	}
+
+	// Unread messages per sender, leaving out blocked users and muted conversations
+	var senders []struct {
+		SenderID  uint
+		FirstName string
+		Count     int64
+	}
+	err := db.Table("messages").
+		Select("messages.sender_id, users.first_name, COUNT(*) AS count").
+		Joins("JOIN users ON users.id = messages.sender_id AND users.deleted_at IS NULL").
+		Where("messages.receiver_id = ? AND messages.read = ? AND messages.shadowed = ? AND messages.created_at > ? AND messages.deleted_at IS NULL", user.ID, false, false, since).
+		Group("messages.sender_id, users.first_name").
+		Order("count DESC").
+		Scan(&senders).Error
+	if err != nil {
+		return data, err
+	}
+
+	states, err := loadConversationStates(db, user.ID)
+	if err != nil {
+		return data, err
+	}
+	for _, sender := range senders {
+		if containsUint(user.BlockedUsers, sender.SenderID) {
+			continue
+		}
+		if state, ok := states[sender.SenderID]; ok && state.IsMuted(now) {
+			continue
+		}
+		data.MessageCount += sender.Count
+		if len(data.Conversations) < digestMaxConversations {
+			data.Conversations = append(data.Conversations, digest.Conversation{Name: sender.FirstName, Count: sender.Count})
+		}
+	}
+
+	return data, nil
+}
+
+func containsUint(list []uint, value uint) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfirmUnsubscribeDigest shows the page behind the unsubscribe link in a digest email. It
+// changes nothing, since mail scanners and link previews follow links; the page posts back to
+// UnsubscribeDigest.
+// @Summary Confirm unsubscribing from email digests
+// @Description Confirmation page for the unsubscribe link in a digest email. Nothing changes until the form is submitted.
+// @Tags settings
+// @Produce html
+// @Param token query string true "Signed unsubscribe token from the email"
+// @Success 200 {string} string "Confirmation page"
+// @Failure 400 {string} string "Invalid or missing token"
+// @Router /email/unsubscribe [get]
+func ConfirmUnsubscribeDigest(c *gin.Context) {
+	token := c.Query("token")
+	if _, err := digest.ParseUnsubscribeToken(digestSecret(), token); err != nil {
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8",
+			[]byte(unsubscribePage("This unsubscribe link is invalid. You can change email settings in the app instead.")))
+		return
+	}
+
+	var page bytes.Buffer
+	if err := unsubscribeConfirmTemplate.Execute(&page, "?token="+url.QueryEscape(token)); err != nil {
+		logger.Printf("Failed to render unsubscribe confirmation: %v", err)
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8",
+			[]byte(unsubscribePage("Something went wrong. Please try again later.")))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// UnsubscribeDigest turns off email digests for the user a signed unsubscribe token was issued to
+// @Summary Unsubscribe from email digests
+// @Description Turns off email digests. Mail clients call this directly for RFC 8058 one-click unsubscribe, sending List-Unsubscribe=One-Click as the form body; the confirmation page sends the same.
+// @Tags settings
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param token query string true "Signed unsubscribe token from the email"
+// @Param List-Unsubscribe formData string true "Must be One-Click"
+// @Success 200 {string} string "Unsubscribed"
+// @Failure 400 {string} string "Invalid or missing token"
+// @Router /email/unsubscribe [post]
+func UnsubscribeDigest(c *gin.Context) {
+	userID, err := digest.ParseUnsubscribeToken(digestSecret(), c.Query("token"))
+	if err != nil || c.PostForm("List-Unsubscribe") != "One-Click" {
+		c.Data(http.StatusBadRequest, "text/html; charset=utf-8",
+			[]byte(unsubscribePage("This unsubscribe link is invalid. You can change email settings in the app instead.")))
+		return
+	}
+
+	result := database.DB.Model(&models.User{}).Where("id = ?", userID).Update("digest_frequency", models.DigestOff)
+	if result.Error != nil {
+		logger.Printf("Failed to unsubscribe user %d from digests: %v", userID, result.Error)
+		c.Data(http.StatusInternalServerError, "text/html; charset=utf-8",
+			[]byte(unsubscribePage("Something went wrong. Please try again later.")))
+		return
+	}
+
+	logger.Printf("User %d unsubscribed from email digests", userID)
+	c.Data(http.StatusOK, "text/html; charset=utf-8",
+		[]byte(unsubscribePage("You've been unsubscribed from CampusCupid email digests. You can turn them back on in Settings.")))
+}
+
+func unsubscribePage(message string) string {
+	return fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>CampusCupid</title></head>`+
+		`<body style="font-family:Helvetica,Arial,sans-serif;text-align:center;padding:48px;"><p>%s</p></body></html>`, message)
+}
+
+// unsubscribeConfirmTemplate is the confirmation page; its data is the form action
+var unsubscribeConfirmTemplate = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>CampusCupid</title></head>` +
+	`<body style="font-family:Helvetica,Arial,sans-serif;text-align:center;padding:48px;">` +
+	`<p>Stop receiving CampusCupid email digests?</p>` +
+	`<form method="post" action="{{.}}"><input type="hidden" name="List-Unsubscribe" value="One-Click">` +
+	`<button type="submit" style="padding:12px 24px;">Unsubscribe</button></form></body></html>`))
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"context"
+	"datingapp/digest"
+	"datingapp/mail"
+	"datingapp/models"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMailer keeps sent messages in memory
+type recordingMailer struct {
+	sent []mail.Message
+}
+
+func (m *recordingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestRunDigests(t *testing.T) {
+	db := setupTestDB()
+	setupRouter(db)
+
+	now := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+	lastWeek := now.Add(-8 * 24 * time.Hour)
+	yesterday := now.Add(-24 * time.Hour)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", DigestFrequency: models.DigestWeekly, LastDigestAt: &lastWeek}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123", DigestFrequency: models.DigestWeekly, LastDigestAt: &lastWeek, LastActiveAt: &yesterday}
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123", DigestFrequency: models.DigestOff}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&carol)
+
+	for _, user := range []models.User{alice, bob, carol} {
+		db.Create(&models.Notification{UserID: user.ID, Type: models.NotificationTypeLike, Title: "Someone Liked You! ❤️", Message: "Dan liked your profile"})
+	}
+	db.Create(&models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "Hey Alice"})
+	db.Create(&models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "Are you free Friday?"})
+
+	mailer := &recordingMailer{}
+	sent, err := runDigests(context.Background(), db, mailer, now)
+	assert.NoError(t, err)
+
+	// Only Alice: Bob was active since his last digest and Carol turned digests off
+	assert.Equal(t, 1, sent)
+	if assert.Len(t, mailer.sent, 1) {
+		msg := mailer.sent[0]
+		assert.Equal(t, "alice@example.com", msg.To)
+		assert.Equal(t, "You have 1 new notification and 2 unread messages on CampusCupid", msg.Subject)
+		assert.Contains(t, msg.Text, "Bob sent you 2 messages")
+		assert.Contains(t, msg.Headers["List-Unsubscribe"], "/email/unsubscribe?token=")
+	}
+
+	// Both Alice's and Bob's periods are marked handled, so an hour later nothing is sent
+	var updated models.User
+	db.First(&updated, bob.ID)
+	if assert.NotNil(t, updated.LastDigestAt) {
+		assert.WithinDuration(t, now, *updated.LastDigestAt, time.Second)
+	}
+	sent, _ = runDigests(context.Background(), db, mailer, now.Add(time.Hour))
+	assert.Equal(t, 0, sent)
+
+	// Types the user turned email off for are left out
+	settings := models.DefaultNotificationSettings()
+	settings.Types[models.NotificationTypeLike] = models.ChannelPreferences{InApp: true, Push: true, Email: false}
+	settings.Types[models.NotificationTypeMessage] = models.ChannelPreferences{InApp: true, Push: true, Email: false}
+	erin := models.User{FirstName: "Erin", Email: "erin@example.com", Password: "password123", DigestFrequency: models.DigestWeekly, LastDigestAt: &lastWeek, NotificationSettings: settings}
+	db.Create(&erin)
+	db.Create(&models.Notification{UserID: erin.ID, Type: models.NotificationTypeLike, Title: "Someone Liked You! ❤️", Message: "Dan liked your profile"})
+	db.Create(&models.Notification{UserID: erin.ID, Type: models.NotificationTypeMatch, Title: "New Match! 🎉", Message: "You matched with Dan"})
+	db.Create(&models.Message{SenderID: bob.ID, ReceiverID: erin.ID, Content: "Hey Erin"})
+	mailer.sent = nil
+	sent, _ = runDigests(context.Background(), db, mailer, now.Add(2*time.Hour))
+	assert.Equal(t, 1, sent)
+	if assert.Len(t, mailer.sent, 1) {
+		msg := mailer.sent[0]
+		assert.Equal(t, "erin@example.com", msg.To)
+		assert.Contains(t, msg.Text, "You matched with Dan")
+		assert.NotContains(t, msg.Text, "Dan liked your profile")
+		assert.NotContains(t, msg.Text, "Bob sent you")
+	}
+}
+
+func TestBackfillDigestEmailPreferences(t *testing.T) {
+	db := setupTestDB()
+
+	settings := models.DefaultNotificationSettings()
+	for notificationType, prefs := range settings.Types {
+		prefs.Email = false
+		settings.Types[notificationType] = prefs
+	}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", NotificationSettings: settings}
+	db.Create(&alice)
+
+	assert.NoError(t, BackfillDigestEmailPreferences(db))
+	db.First(&alice, alice.ID)
+	assert.True(t, alice.NotificationSettings.ChannelsFor(models.NotificationTypeLike).Email)
+	assert.False(t, alice.NotificationSettings.ChannelsFor(models.NotificationTypeView).Email)
+
+	// It only runs once, so email turned off afterwards stays off
+	db.Model(&alice).Select("notification_settings").Updates(&models.User{NotificationSettings: settings})
+	assert.NoError(t, BackfillDigestEmailPreferences(db))
+	db.First(&alice, alice.ID)
+	assert.False(t, alice.NotificationSettings.ChannelsFor(models.NotificationTypeLike).Email)
+}
+
+func TestDigestDue(t *testing.T) {
+	morning := time.Date(2024, 5, 6, 9, 30, 0, 0, time.UTC)
+	sixDaysAgo := morning.Add(-6 * 24 * time.Hour)
+	yesterday := morning.Add(-24*time.Hour + 20*time.Minute)
+
+	assert.True(t, digestDue(models.User{DigestFrequency: models.DigestWeekly}, morning))
+	assert.False(t, digestDue(models.User{DigestFrequency: models.DigestOff}, morning))
+	assert.False(t, digestDue(models.User{DigestFrequency: models.DigestWeekly, LastDigestAt: &sixDaysAgo}, morning))
+	assert.True(t, digestDue(models.User{DigestFrequency: models.DigestDaily, LastDigestAt: &yesterday}, morning))
+
+	// 9:30 UTC is 5:30 in New York, too early to send
+	assert.False(t, digestDue(models.User{DigestFrequency: models.DigestDaily, Timezone: "America/New_York"}, morning))
+
+	// Nor during quiet hours
+	quiet := models.NotificationSettings{QuietHours: models.QuietHours{Enabled: true, Start: "09:00", End: "10:00"}}
+	assert.False(t, digestDue(models.User{DigestFrequency: models.DigestWeekly, NotificationSettings: quiet}, morning))
+}
+
+func TestUnsubscribeDigest(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/email/unsubscribe", ConfirmUnsubscribeDigest)
+	router.POST("/email/unsubscribe", UnsubscribeDigest)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", DigestFrequency: models.DigestDaily}
+	db.Create(&alice)
+	token := url.QueryEscape(digest.UnsubscribeToken(digestSecret(), alice.ID))
+
+	req, _ := http.NewRequest("GET", "/email/unsubscribe?token=forged."+token, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Following the link only shows a confirmation form
+	req, _ = http.NewRequest("GET", "/email/unsubscribe?token="+token, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `method="post"`)
+	assert.Contains(t, w.Body.String(), `name="List-Unsubscribe" value="One-Click"`)
+	var unchanged models.User
+	db.First(&unchanged, alice.ID)
+	assert.Equal(t, models.DigestDaily, unchanged.DigestFrequency)
+
+	// A POST without the one-click body is refused
+	req, _ = http.NewRequest("POST", "/email/unsubscribe?token="+token, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// RFC 8058 one-click POST from a mail client
+	req, _ = http.NewRequest("POST", "/email/unsubscribe?token="+token, strings.NewReader("List-Unsubscribe=One-Click"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/email/unsubscribe", TestResult{
+		TestName: "One-Click Digest Unsubscribe",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var updated models.User
+	db.First(&updated, alice.ID)
+	assert.Equal(t, models.DigestOff, updated.DigestFrequency)
+}
@@ -56,7 +56,7 @@ func TestAdminReportAccess(t *testing.T) {
 	setupExtraRoutes(router)
 
 	// Create admin user
-	admin := models.User{FirstName: "Admin", Email: "admin@a.com", Password: "admin"}
+	admin := models.User{FirstName: "Admin", Email: "admin@a.com", Password: "admin", IsAdmin: true}
 	admin.HashPassword(admin.Password)
 	db.Create(&admin)
 
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/icebreakers"
+	"datingapp/models"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// icebreakersPerMatch is how many prompts are suggested to each new match
+const icebreakersPerMatch = 3
+
+// errUnknownIcebreaker is returned when a prompt_id isn't one of the pair's suggestions
+var errUnknownIcebreaker = errors.New("unknown icebreaker prompt for this match")
+
+// generateMatchIcebreakers picks and stores icebreakers for a new match. Existing rows are kept.
+func generateMatchIcebreakers(db *gorm.DB, a, b models.User) ([]models.MatchIcebreaker, error) {
+	low, high := models.IcebreakerPair(a.ID, b.ID)
+	suggestions := icebreakers.Select(a.Interests, b.Interests, a.LookingFor, b.LookingFor, icebreakersPerMatch, int64(low)<<32|int64(high))
+
+	rows := make([]models.MatchIcebreaker, len(suggestions))
+	for i, suggestion := range suggestions {
+		rows[i] = models.MatchIcebreaker{
+			UserLowID:  low,
+			UserHighID: high,
+			PromptID:   suggestion.PromptID,
+			Text:       suggestion.Text,
+		}
+	}
+	if len(rows) > 0 {
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+	return loadMatchIcebreakers(db, a.ID, b.ID)
+}
+
+// loadMatchIcebreakers returns the icebreakers suggested to a pair
+func loadMatchIcebreakers(db *gorm.DB, a, b uint) ([]models.MatchIcebreaker, error) {
+	low, high := models.IcebreakerPair(a, b)
+	var rows []models.MatchIcebreaker
+	err := db.Where("user_low_id = ? AND user_high_id = ?", low, high).Order("id").Find(&rows).Error
+	return rows, err
+}
+
+// findMatchIcebreaker looks up one of the pair's icebreakers by prompt ID
+func findMatchIcebreaker(db *gorm.DB, a, b uint, promptID string) (models.MatchIcebreaker, error) {
+	low, high := models.IcebreakerPair(a, b)
+	var row models.MatchIcebreaker
+	err := db.Where("user_low_id = ? AND user_high_id = ? AND prompt_id = ?", low, high, promptID).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return row, errUnknownIcebreaker
+	}
+	return row, err
+}
+
+// markIcebreakerSent records that a prompt was used to open the conversation
+func markIcebreakerSent(db *gorm.DB, icebreaker models.MatchIcebreaker, senderID, messageID uint) error {
+	now := time.Now()
+	return db.Model(&icebreaker).Updates(map[string]interface{}{
+		"sent_by_id":      senderID,
+		"sent_message_id": messageID,
+		"sent_at":         now,
+	}).Error
+}
+
+// markIcebreakerReplied records a reply to any prompt the other user sent to senderID
+func markIcebreakerReplied(db *gorm.DB, senderID, receiverID uint) error {
+	low, high := models.IcebreakerPair(senderID, receiverID)
+	return db.Model(&models.MatchIcebreaker{}).
+		Where("user_low_id = ? AND user_high_id = ? AND sent_by_id = ? AND replied_at IS NULL", low, high, receiverID).
+		Update("replied_at", time.Now()).Error
+}
+
+// GetMatchIcebreakers lists the icebreakers suggested to the current user and a match
+// @Summary Get icebreakers for a match
+// @Description List the icebreaker prompts suggested to the current user and a match, to show while their conversation is empty. Send one with POST /messages and its prompt_id.
+// @Tags messaging
+// @Security ApiKeyAuth
+// @Produce json
+// @Param user_id path uint true "Other user's ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /messages/{user_id}/icebreakers [get]
+func GetMatchIcebreakers(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+	otherUserID, err := strconv.ParseUint(c.Param("user_id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid user ID format")
+		return
+	}
+
+	var matches int64
+	if err := database.DB.Model(&models.Interaction{}).
+		Where("((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?)) AND matched = ?", userID, otherUserID, otherUserID, userID, true).
+		Count(&matches).Error; err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve icebreakers")
+		return
+	}
+	if matches == 0 {
+		respondWithError(c, http.StatusForbidden, "You can only see icebreakers for users you have matched with")
+		return
+	}
+
+	suggestions, err := loadMatchIcebreakers(database.DB, userID, uint(otherUserID))
+	if err != nil {
+		logger.Printf("Failed to load icebreakers for users %d and %d: %v", userID, otherUserID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve icebreakers")
+		return
+	}
+	if suggestions == nil {
+		suggestions = []models.MatchIcebreaker{}
+	}
+	c.JSON(http.StatusOK, gin.H{"icebreakers": suggestions})
+}
+
+// GetIcebreakerStats reports how often each icebreaker prompt is sent and replied to (admin only)
+// @Summary Icebreaker prompt performance (Admin)
+// @Description Admin-only endpoint showing, per prompt, how often it was suggested, sent and replied to
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {array} models.IcebreakerStats
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/icebreakers/stats [get]
+func GetIcebreakerStats(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var stats []models.IcebreakerStats
+	if err := database.DB.Model(&models.MatchIcebreaker{}).
+		Select("prompt_id, COUNT(*) AS suggested, COUNT(sent_at) AS sent, COUNT(replied_at) AS replied").
+		Group("prompt_id").
+		Order("replied DESC, sent DESC").
+		Scan(&stats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve icebreaker stats"})
+		return
+	}
+
+	for i := range stats {
+		if stats[i].Sent > 0 {
+			stats[i].ReplyRate = float64(stats[i].Replied) / float64(stats[i].Sent)
+		}
+	}
+	if !recordAdminView(c, "icebreaker_stats.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchIcebreakers(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/messages/:user_id/icebreakers", middleware.AuthMiddleware(), GetMatchIcebreakers)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123", Interests: []string{"Hiking"}}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123", Interests: []string{"Hiking"}}
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123"}
+	for _, user := range []*models.User{&alice, &bob, &carol} {
+		db.Create(user)
+	}
+
+	assert.Equal(t, http.StatusOK, performRequest(router, "POST", fmt.Sprintf("/like/%d", bob.ID), "", alice.ID).Code)
+	assert.Equal(t, http.StatusOK, performRequest(router, "POST", fmt.Sprintf("/like/%d", alice.ID), "", bob.ID).Code)
+
+	// An empty conversation is still a plain list
+	w := performRequest(router, "GET", fmt.Sprintf("/messages/%d", bob.ID), "", alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var messages []models.Message
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &messages))
+	assert.Empty(t, messages)
+
+	w = performRequest(router, "GET", fmt.Sprintf("/messages/%d/icebreakers", bob.ID), "", alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/messages/:user_id/icebreakers", TestResult{
+		TestName: "Get Match Icebreakers",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var response struct {
+		Icebreakers []models.MatchIcebreaker `json:"icebreakers"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Icebreakers, icebreakersPerMatch)
+
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", fmt.Sprintf("/messages/%d/icebreakers", bob.ID), "", carol.ID).Code)
+}
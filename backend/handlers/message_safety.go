@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"datingapp/safety"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// messageSafety screens every outgoing chat message; classifiers can be added with Register
+var messageSafety = safety.NewPipelineFromEnv()
+
+// screenMessage gathers conversation context and runs the content through the safety pipeline
+func screenMessage(db *gorm.DB, senderID, receiverID uint, content string) safety.Result {
+	input := safety.Input{
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Content:    content,
+	}
+
+	db.Model(&models.Message{}).
+		Where("sender_id = ? AND receiver_id = ?", senderID, receiverID).
+		Count(&input.PriorMessages)
+
+	// Messages sent since the receiver last replied
+	var lastReply models.Message
+	unanswered := db.Model(&models.Message{}).Where("sender_id = ? AND receiver_id = ?", senderID, receiverID)
+	if err := db.Where("sender_id = ? AND receiver_id = ?", receiverID, senderID).
+		Order("created_at DESC").First(&lastReply).Error; err == nil {
+		unanswered = unanswered.Where("created_at > ?", lastReply.CreatedAt)
+	}
+	unanswered.Count(&input.UnansweredCount)
+
+	var previous models.Message
+	if err := db.Where("sender_id = ? AND receiver_id = ?", senderID, receiverID).
+		Order("created_at DESC").First(&previous).Error; err == nil {
+		input.PreviousContent = previous.Content
+	}
+
+	return messageSafety.Evaluate(input)
+}
+
+// recordMessageFlag stores a safety result for moderator review
+func recordMessageFlag(db *gorm.DB, result safety.Result, senderID, receiverID uint, content string, messageID *uint) error {
+	findings := make([]models.FlagFinding, len(result.Findings))
+	for i, finding := range result.Findings {
+		findings[i] = models.FlagFinding{
+			Rule:   finding.Rule,
+			Reason: finding.Reason,
+			Action: string(finding.Action),
+		}
+	}
+
+	flag := models.MessageFlag{
+		MessageID:  messageID,
+		SenderID:   senderID,
+		ReceiverID: receiverID,
+		Content:    content,
+		Action:     string(result.Action),
+		Findings:   findings,
+		Status:     models.MessageFlagPending,
+	}
+	return db.Create(&flag).Error
+}
+
+// GetMessageFlags lists messages flagged by the chat safety filter (admin only)
+// @Summary List flagged messages (Admin)
+// @Description Admin-only endpoint to review messages flagged by the chat safety filter
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status (pending, approved, rejected)" default(pending)
+// @Param action query string false "Filter by action taken (warn, hold, block)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /admin/message-flags [get]
+func GetMessageFlags(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.MessageFlag{})
+	if status := c.DefaultQuery("status", string(models.MessageFlagPending)); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flagged messages"})
+		return
+	}
+
+	var flags []models.MessageFlag
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&flags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve flagged messages"})
+		return
+	}
+	if !recordAdminView(c, "message_flags.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"flags": flags,
+		"total": total,
+	})
+}
+
+// ReviewMessageFlag records a moderator decision on a flagged message (admin only)
+// @Summary Review a flagged message (Admin)
+// @Description Approve or reject a flagged message. Approving a held message delivers it to the receiver.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Flag ID"
+// @Param review body models.ReviewMessageFlagRequest true "Moderator decision"
+// @Success 200 {object} models.MessageFlag
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string "Already reviewed"
+// @Failure 500 {object} map[string]string
+// @Router /admin/message-flags/{id} [put]
+func ReviewMessageFlag(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	flagID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid flag ID")
+		return
+	}
+
+	var req models.ReviewMessageFlagRequest
+	if !validateInput(c, &req) {
+		return
+	}
+
+	var flag models.MessageFlag
+	deliver := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so two moderators approving a held message at once can't both deliver it
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&flag, flagID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				respondWithError(c, http.StatusNotFound, "Flagged message not found")
+				return errResponded
+			}
+			return err
+		}
+		if flag.Status != models.MessageFlagPending {
+			respondWithError(c, http.StatusConflict, "Flagged message has already been reviewed")
+			return errResponded
+		}
+
+		original := flag
+		now := time.Now()
+		flag.ReviewedBy = &adminID
+		flag.ReviewedAt = &now
+		if req.Decision == "approve" {
+			flag.Status = models.MessageFlagApproved
+			// Held messages were never stored; deliver them now. A sender shadow-banned since
+			// is checked as of approval, as sending would be.
+			if flag.Action == string(safety.ActionHold) && flag.MessageID == nil {
+				var sender models.User
+				if err := tx.Select("id", "first_name", "shadow_banned").First(&sender, flag.SenderID).Error; err != nil {
+					return err
+				}
+				message := models.Message{
+					SenderID:   flag.SenderID,
+					ReceiverID: flag.ReceiverID,
+					Content:    flag.Content,
+					Shadowed:   sender.ShadowBanned,
+				}
+				if err := tx.Create(&message).Error; err != nil {
+					return err
+				}
+				flag.MessageID = &message.ID
+				deliver = true
+
+				if !message.Shadowed && !isConversationMuted(tx, flag.ReceiverID, flag.SenderID) {
+					notification, err := messageNotification(flag.ReceiverID, flag.SenderID, sender.FirstName, flag.Content)
+					if err != nil {
+						return err
+					}
+					if err := enqueueNotification(tx, notification); err != nil {
+						return err
+					}
+				}
+			}
+		} else {
+			flag.Status = models.MessageFlagRejected
+		}
+		if err := tx.Save(&flag).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "message_flag.review", "message_flag", flag.ID, original, flag)
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to review message flag %d: %v", flagID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to review flagged message")
+		return
+	}
+
+	if deliver {
+		notifyOutbox()
+	}
+
+	logger.Printf("Admin %d reviewed message flag %d: %s", adminID, flag.ID, flag.Status)
+	c.JSON(http.StatusOK, flag)
+}
+
+// safetyWarning formats findings for the sender when a message is delivered with a warning
+func safetyWarning(result safety.Result) string {
+	if len(result.Findings) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Your message was delivered, but it was flagged: %s", result.Findings[0].Reason)
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageFlagReview(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/admin/message-flags", middleware.AuthMiddleware(), GetMessageFlags)
+	router.PUT("/admin/message-flags/:id", middleware.AuthMiddleware(), ReviewMessageFlag)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+	db.Create(&models.Interaction{UserID: alice.ID, TargetID: bob.ID, Liked: true, Matched: true})
+	db.Create(&models.Interaction{UserID: bob.ID, TargetID: alice.ID, Liked: true, Matched: true})
+
+	send := func(content string) *httptest.ResponseRecorder {
+		return performRequest(router, "POST", "/messages", fmt.Sprintf(`{"receiver_id":%d,"content":%q}`, bob.ID, content), alice.ID)
+	}
+	delivered := func() int64 {
+		var count int64
+		db.Model(&models.Message{}).Where("sender_id = ? AND receiver_id = ?", alice.ID, bob.ID).Count(&count)
+		return count
+	}
+
+	// A link is delivered with a warning and flagged for review
+	w := send("Find me at www.example.com")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "warning")
+	assert.Equal(t, int64(1), delivered())
+
+	// Insults are held back until a moderator decides
+	w = send("you are such a loser")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), "held")
+	w = send("what a loser you are")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, int64(1), delivered())
+
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/admin/message-flags", "", alice.ID).Code)
+	w = performRequest(router, "GET", "/admin/message-flags?action=hold", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/admin/message-flags", TestResult{
+		TestName: "List Held Messages",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var list struct {
+		Flags []models.MessageFlag `json:"flags"`
+		Total int64                `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if !assert.Len(t, list.Flags, 2) {
+		return
+	}
+	sort.Slice(list.Flags, func(i, j int) bool { return list.Flags[i].ID < list.Flags[j].ID })
+	for _, flag := range list.Flags {
+		assert.Nil(t, flag.MessageID, "held messages aren't stored")
+	}
+	approvePath := "/admin/message-flags/" + strconv.Itoa(int(list.Flags[0].ID))
+	rejectPath := "/admin/message-flags/" + strconv.Itoa(int(list.Flags[1].ID))
+
+	// Two moderators approving at once deliver the message once
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = performRequest(router, "PUT", approvePath, `{"decision":"approve"}`, admin.ID).Code
+		}(i)
+	}
+	wg.Wait()
+	assert.ElementsMatch(t, []int{http.StatusOK, http.StatusConflict}, codes)
+	assert.Equal(t, int64(2), delivered())
+
+	var approved models.MessageFlag
+	db.First(&approved, list.Flags[0].ID)
+	assert.Equal(t, models.MessageFlagApproved, approved.Status)
+	assert.NotNil(t, approved.MessageID)
+
+	// A rejected message is never delivered
+	assert.Equal(t, http.StatusOK, performRequest(router, "PUT", rejectPath, `{"decision":"reject"}`, admin.ID).Code)
+	assert.Equal(t, http.StatusConflict, performRequest(router, "PUT", rejectPath, `{"decision":"approve"}`, admin.ID).Code)
+	assert.Equal(t, int64(2), delivered())
+
+	drainOutbox(t, db, time.Now())
+	// One event for the link message and one for the approved message, grouped per sender
+	var events int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", bob.ID, models.NotificationTypeMessage).
+		Select("COALESCE(SUM(count), 0)").Scan(&events)
+	assert.Equal(t, int64(2), events)
+
+	// A held message from a sender shadow-banned before approval is stored shadowed, without a notification
+	assert.Equal(t, http.StatusAccepted, send("you absolute loser").Code)
+	var held models.MessageFlag
+	db.Where("status = ?", models.MessageFlagPending).First(&held)
+	db.Model(&alice).Update("shadow_banned", true)
+	assert.Equal(t, http.StatusOK, performRequest(router, "PUT", "/admin/message-flags/"+strconv.Itoa(int(held.ID)), `{"decision":"approve"}`, admin.ID).Code)
+	var shadowed models.Message
+	db.Where("content = ?", "you absolute loser").First(&shadowed)
+	assert.True(t, shadowed.Shadowed)
+
+	drainOutbox(t, db, time.Now())
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", bob.ID, models.NotificationTypeMessage).
+		Select("COALESCE(SUM(count), 0)").Scan(&events)
+	assert.Equal(t, int64(2), events)
+}
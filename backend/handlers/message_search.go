@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"html"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// snippetRadius is the number of characters kept on each side of the first hit
+	snippetRadius = 40
+	// highlightStart and highlightStop wrap matched terms in search snippets
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+	// headlineStart and headlineStop are what ts_headline marks hits with. They are swapped for
+	// highlightStart and highlightStop once the rest of the snippet has been HTML-escaped.
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// messageSearchRow is a single matching message as returned by the search query
+type messageSearchRow struct {
+	ID         uint
+	SenderID   uint
+	ReceiverID uint
+	PartnerID  uint
+	Content    string
+	Snippet    string
+	Rank       float64
+	CreatedAt  time.Time
+}
+
+// SearchMessages performs a full-text search over the caller's conversations
+// @Summary Search messages
+// @Description Full-text search over the authenticated user's messages, grouped by conversation partner
+// @Tags messaging
+// @Produce json
+// @Param q query string true "Search query"
+// @Param limit query int false "Maximum number of matching messages" default(50)
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /messages/search [get]
+func SearchMessages(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		respondWithError(c, http.StatusBadRequest, "Search query is required")
+		return
+	}
+	if len(q) > 200 {
+		respondWithError(c, http.StatusBadRequest, "Search query is too long")
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		respondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	var rows []messageSearchRow
+	if database.DB.Dialector.Name() == "postgres" {
+		rows, err = searchMessagesPostgres(database.DB, userID, q, limit)
+	} else {
+		rows, err = searchMessagesFallback(database.DB, userID, q, limit)
+	}
+	if err != nil {
+		logger.Printf("Failed to search messages for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to search messages")
+		return
+	}
+
+	// Drop conversations that are blocked in either direction or whose partner no longer exists
+	partnerIDs := make([]uint, 0, len(rows))
+	seen := make(map[uint]bool)
+	for _, row := range rows {
+		if !seen[row.PartnerID] {
+			seen[row.PartnerID] = true
+			partnerIDs = append(partnerIDs, row.PartnerID)
+		}
+	}
+
+	partners := make(map[uint]models.User)
+	if len(partnerIDs) > 0 {
+		var partnerUsers []models.User
+		if err := database.DB.Where("id IN ?", partnerIDs).Find(&partnerUsers).Error; err != nil {
+			logger.Printf("Failed to load conversation partners for user %d: %v", userID, err)
+			respondWithError(c, http.StatusInternalServerError, "Failed to search messages")
+			return
+		}
+		for _, partner := range partnerUsers {
+			if isBlockedEitherWay(user, partner) {
+				continue
+			}
+			partners[partner.ID] = partner
+		}
+	}
+
+	type groupedResult struct {
+		partner  models.User
+		rank     float64
+		messages []gin.H
+	}
+	groups := make(map[uint]*groupedResult)
+	var order []uint
+	for _, row := range rows {
+		partner, ok := partners[row.PartnerID]
+		if !ok {
+			continue
+		}
+		group, exists := groups[row.PartnerID]
+		if !exists {
+			group = &groupedResult{partner: partner, rank: row.Rank}
+			groups[row.PartnerID] = group
+			order = append(order, row.PartnerID)
+		}
+		if row.Rank > group.rank {
+			group.rank = row.Rank
+		}
+		group.messages = append(group.messages, gin.H{
+			"id":          row.ID,
+			"sender_id":   row.SenderID,
+			"receiver_id": row.ReceiverID,
+			"snippet":     row.Snippet,
+			"created_at":  row.CreatedAt,
+		})
+	}
+
+	// Best matching conversation first, ties broken by first appearance
+	sort.SliceStable(order, func(i, j int) bool {
+		return groups[order[i]].rank > groups[order[j]].rank
+	})
+
+	results := make([]gin.H, 0, len(order))
+	for _, partnerID := range order {
+		group := groups[partnerID]
+		results = append(results, gin.H{
+			"user": gin.H{
+				"id":                group.partner.ID,
+				"firstName":         group.partner.FirstName,
+				"profilePictureURL": group.partner.ApprovedPictureURL,
+			},
+			"matchCount": len(group.messages),
+			"messages":   group.messages,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":   q,
+		"results": results,
+	})
+}
+
+// searchMessagesPostgres uses the GIN-indexed tsvector on messages.content
+func searchMessagesPostgres(db *gorm.DB, userID uint, q string, limit int) ([]messageSearchRow, error) {
+	var rows []messageSearchRow
+	err := db.Raw(`
+		SELECT
+			m.id,
+			m.sender_id,
+			m.receiver_id,
+			CASE WHEN m.sender_id = ? THEN m.receiver_id ELSE m.sender_id END AS partner_id,
+			m.content,
+			ts_headline('english', m.content, plainto_tsquery('english', ?),
+				'StartSel=' || chr(2) || ', StopSel=' || chr(3) || ', MaxWords=20, MinWords=5, MaxFragments=2') AS snippet,
+			ts_rank(to_tsvector('english', m.content), plainto_tsquery('english', ?)) AS rank,
+			m.created_at
+		FROM messages m
+		WHERE (m.sender_id = ? OR (m.receiver_id = ? AND m.shadowed = false))
+			AND m.deleted_at IS NULL
+			AND to_tsvector('english', m.content) @@ plainto_tsquery('english', ?)
+		ORDER BY rank DESC, m.created_at DESC
+		LIMIT ?
+	`, userID, q, q, userID, userID, q, limit).Scan(&rows).Error
+	for i := range rows {
+		rows[i].Snippet = markHeadline(rows[i].Snippet)
+	}
+	return rows, err
+}
+
+// markHeadline HTML-escapes a ts_headline snippet and turns its hit markers into highlight tags,
+// so message content can't inject markup into clients that render the snippet as HTML
+func markHeadline(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, headlineStart, highlightStart)
+	return strings.ReplaceAll(escaped, headlineStop, highlightStop)
+}
+
+// searchMessagesFallback is a portable LIKE-based search for databases without tsvector support
+func searchMessagesFallback(db *gorm.DB, userID uint, q string, limit int) ([]messageSearchRow, error) {
+	terms := searchTerms(q)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	query := db.Model(&models.Message{}).Where("sender_id = ? OR (receiver_id = ? AND shadowed = ?)", userID, userID, false)
+	for _, term := range terms {
+		query = query.Where("LOWER(content) LIKE ?", "%"+term+"%")
+	}
+
+	var messages []models.Message
+	if err := query.Order("created_at DESC").Limit(limit).Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	rows := make([]messageSearchRow, 0, len(messages))
+	for _, message := range messages {
+		partnerID := message.SenderID
+		if message.SenderID == userID {
+			partnerID = message.ReceiverID
+		}
+		rows = append(rows, messageSearchRow{
+			ID:         message.ID,
+			SenderID:   message.SenderID,
+			ReceiverID: message.ReceiverID,
+			PartnerID:  partnerID,
+			Content:    message.Content,
+			Snippet:    highlightSnippet(message.Content, terms),
+			Rank:       float64(len(terms)),
+			CreatedAt:  message.CreatedAt,
+		})
+	}
+	return rows, nil
+}
+
+// searchTerms lowercases the query and splits it into words
+func searchTerms(q string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return fields
+}
+
+// highlightSnippet returns an HTML-escaped excerpt of content around the first hit with every term
+// wrapped in <mark>
+func highlightSnippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+
+	// Only simple case folding is used, so rune offsets line up between content and lower
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+
+	first := -1
+	for _, term := range terms {
+		if idx := indexRunes(lower, []rune(term), 0); idx >= 0 && (first == -1 || idx < first) {
+			first = idx
+		}
+	}
+	if first == -1 {
+		first = 0
+	}
+
+	start := first - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := first + snippetRadius
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			termRunes := []rune(term)
+			if len(termRunes) > matched && i+len(termRunes) <= len(lower) && indexRunes(lower[i:i+len(termRunes)], termRunes, 0) == 0 {
+				matched = len(termRunes)
+			}
+		}
+		if matched > 0 {
+			b.WriteString(highlightStart)
+			b.WriteString(html.EscapeString(string(runes[i : i+matched])))
+			b.WriteString(highlightStop)
+			i += matched
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+// indexRunes returns the index of needle in haystack at or after from, or -1
+func indexRunes(haystack, needle []rune, from int) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := from; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// isBlockedEitherWay reports whether either user has blocked the other
+func isBlockedEitherWay(a, b models.User) bool {
+	for _, id := range a.BlockedUsers {
+		if id == b.ID {
+			return true
+		}
+	}
+	for _, id := range b.BlockedUsers {
+		if id == a.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHighlightSnippet(t *testing.T) {
+	snippet := highlightSnippet("Want to grab Coffee tomorrow?", []string{"coffee"})
+	assert.Equal(t, "Want to grab <mark>Coffee</mark> tomorrow?", snippet)
+
+	long := "This is a fairly long message that goes on and on before it finally mentions pizza and then keeps going for a while longer"
+	snippet = highlightSnippet(long, []string{"pizza"})
+	assert.Contains(t, snippet, "<mark>pizza</mark>")
+	assert.True(t, len(snippet) < len(long))
+
+	// Message content is escaped; only the highlight tags are markup
+	snippet = highlightSnippet(`<img src=x onerror="alert(1)"> pizza & <b>chips</b>`, []string{"pizza"})
+	assert.Equal(t, `&lt;img src=x onerror=&#34;alert(1)&#34;&gt; <mark>pizza</mark> &amp; &lt;b&gt;chips&lt;/b&gt;`, snippet)
+}
+
+func TestHighlightHeadline(t *testing.T) {
+	headline := markHeadline("<script>x</script> \x02pizza\x03 & \x02chips\x03")
+	assert.Equal(t, "&lt;script&gt;x&lt;/script&gt; <mark>pizza</mark> &amp; <mark>chips</mark>", headline)
+}
+
+func TestSearchMessages(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/messages/search", middleware.AuthMiddleware(), SearchMessages)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+	db.Create(&carol)
+
+	db.Create(&models.Message{SenderID: alice.ID, ReceiverID: bob.ID, Content: "Want to grab coffee tomorrow?"})
+	db.Create(&models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "Coffee sounds great"})
+	db.Create(&models.Message{SenderID: carol.ID, ReceiverID: alice.ID, Content: "I love coffee too"})
+	db.Create(&models.Message{SenderID: carol.ID, ReceiverID: bob.ID, Content: "coffee with someone else"})
+
+	// Alice blocks Carol, so that conversation must not show up
+	alice.BlockedUsers = []uint{carol.ID}
+	db.Save(&alice)
+
+	req, _ := http.NewRequest("GET", "/messages/search?q="+url.QueryEscape("coffee"), nil)
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Results []struct {
+			User       map[string]interface{} `json:"user"`
+			MatchCount int                    `json:"matchCount"`
+			Messages   []struct {
+				Snippet string `json:"snippet"`
+			} `json:"messages"`
+		} `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	if assert.Len(t, body.Results, 1) {
+		assert.Equal(t, float64(bob.ID), body.Results[0].User["id"])
+		assert.Equal(t, 2, body.Results[0].MatchCount)
+		assert.Contains(t, body.Results[0].Messages[0].Snippet, "<mark>")
+	}
+
+	writeTestResult("/messages/search", TestResult{
+		TestName: "Search Messages Grouped By Partner",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// moderationError is a problem with a moderation request that is reported back as a 400
+type moderationError string
+
+func (e moderationError) Error() string { return string(e) }
+
+// SuspendUser locks a user out for a while because of a report (admin only)
+// @Summary Suspend a user (Admin)
+// @Description Suspend an account for a number of hours. The user is signed out and can't sign in until the suspension ends. The report is resolved as actioned if it is still open.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "User ID"
+// @Param suspension body models.SuspendUserRequest true "Suspension"
+// @Success 201 {object} models.ModerationAction
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/suspend [post]
+func SuspendUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	var req models.SuspendUserRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	until := time.Now().Add(time.Duration(req.DurationHours) * time.Hour)
+	moderateUser(c, models.ModerationAction{
+		Action:         models.ModerationSuspend,
+		Reason:         req.Reason,
+		ReportID:       &req.ReportID,
+		SuspendedUntil: &until,
+	})
+}
+
+// BanUser permanently bans a user because of a report (admin only)
+// @Summary Ban a user (Admin)
+// @Description Permanently ban an account. The user is signed out and hidden from matches, conversations and likes. The report is resolved as actioned if it is still open.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "User ID"
+// @Param ban body models.BanUserRequest true "Ban"
+// @Success 201 {object} models.ModerationAction
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/ban [post]
+func BanUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	var req models.BanUserRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	moderateUser(c, models.ModerationAction{
+		Action:   models.ModerationBan,
+		Reason:   req.Reason,
+		ReportID: &req.ReportID,
+	})
+}
+
+// ReinstateUser lifts a suspension or ban (admin only)
+// @Summary Reinstate a user (Admin)
+// @Description Lift a suspension or ban so the user can sign in again
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "User ID"
+// @Param reinstatement body models.ReinstateUserRequest true "Reinstatement"
+// @Success 201 {object} models.ModerationAction
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/reinstate [post]
+func ReinstateUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	var req models.ReinstateUserRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	moderateUser(c, models.ModerationAction{
+		Action:   models.ModerationReinstate,
+		Reason:   req.Reason,
+		ReportID: req.ReportID,
+	})
+}
+
+// ShadowBanUser turns shadow-ban mode on or off for a suspected spammer (admin only)
+// @Summary Shadow-ban a user (Admin)
+// @Description Turn shadow-ban mode on or off. A shadow-banned user can keep using the app, but their messages are never delivered, their likes don't notify or match, and they are left out of other users' matches. Turning it on resolves the report as actioned if it is still open.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "User ID"
+// @Param shadowBan body models.ShadowBanUserRequest true "Shadow-ban"
+// @Success 201 {object} models.ModerationAction
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/shadow-ban [put]
+func ShadowBanUser(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	var req models.ShadowBanUserRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	action := models.ModerationLiftShadowBan
+	if req.Enabled {
+		action = models.ModerationShadowBan
+	}
+	moderateUser(c, models.ModerationAction{
+		Action:   action,
+		Reason:   req.Reason,
+		ReportID: req.ReportID,
+	})
+}
+
+// GetModerationActions lists the suspensions, bans, shadow-bans and reinstatements of a user (admin only)
+// @Summary A user's moderation history (Admin)
+// @Description List the moderation actions taken on an account and the escalation rules its reports set off, newest first
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "User ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{id}/moderation [get]
+func GetModerationActions(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var actions []models.ModerationAction
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&actions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve moderation actions"})
+		return
+	}
+	var escalations []models.ReportEscalation
+	if err := database.DB.Where("target_id = ?", userID).Order("created_at DESC, id DESC").Find(&escalations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve moderation actions"})
+		return
+	}
+	if !recordAdminView(c, "user.moderation.view", "user", uint(userID)) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"actions": actions, "escalations": escalations})
+}
+
+// moderateUser applies an action to the user named by the :id parameter and records it. An open
+// report behind the action is resolved as actioned.
+func moderateUser(c *gin.Context, action models.ModerationAction) {
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	action.UserID = uint(userID)
+	action.ModeratorID = adminID
+
+	resolvedReport := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, action.UserID).Error; err != nil {
+			return err
+		}
+		before := moderationSnapshot(user)
+		if err := applyModerationAction(&user, action, time.Now()); err != nil {
+			return err
+		}
+
+		if action.ReportID != nil {
+			var report models.Report
+			if err := tx.First(&report, *action.ReportID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return moderationError("Report not found")
+				}
+				return err
+			}
+			if report.TargetID != user.ID {
+				return moderationError("The report is not about this user")
+			}
+			if action.Action.Restricts() && !report.Status.IsResolved() {
+				previousStatus := report.Status
+				now := time.Now()
+				report.Status = models.ReportActioned
+				report.ResolvedBy = &adminID
+				report.ResolvedAt = &now
+				if report.ResolutionNotes == "" {
+					report.ResolutionNotes = fmt.Sprintf("%s: %s", action.Action, action.Reason)
+				}
+				if err := saveReport(tx, report, previousStatus); err != nil {
+					return err
+				}
+				resolvedReport = true
+			}
+		}
+
+		err := tx.Model(&user).Select("account_status", "suspended_until", "account_status_reason", "shadow_banned").Updates(&user).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&action).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "user."+string(action.Action), "user", user.ID, before, moderationSnapshot(user))
+	})
+
+	var invalid moderationError
+	switch {
+	case errors.As(err, &invalid):
+		respondWithError(c, http.StatusBadRequest, invalid.Error())
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondWithError(c, http.StatusNotFound, "User not found")
+		return
+	case errors.Is(err, errReportChanged):
+		respondWithError(c, http.StatusConflict, "Report was updated by another moderator, reload it and try again")
+		return
+	case err != nil:
+		logger.Printf("Failed to %s user %d: %v", action.Action, action.UserID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to update account")
+		return
+	}
+	if resolvedReport {
+		notifyOutbox()
+	}
+
+	logger.Printf("Admin %d applied %s to user %d", adminID, action.Action, action.UserID)
+	c.JSON(http.StatusCreated, action)
+}
+
+// moderationSnapshot is the part of an account that moderation actions change, for the audit log
+func moderationSnapshot(user models.User) gin.H {
+	return gin.H{
+		"accountStatus":       user.AccountStatus,
+		"suspendedUntil":      user.SuspendedUntil,
+		"accountStatusReason": user.AccountStatusReason,
+		"shadowBanned":        user.ShadowBanned,
+	}
+}
+
+// applyModerationAction changes the user's account status for an action, or explains why it can't
+func applyModerationAction(user *models.User, action models.ModerationAction, now time.Time) error {
+	switch action.Action {
+	case models.ModerationSuspend, models.ModerationBan:
+		if user.IsAdmin {
+			return moderationError("Admins can't be suspended or banned")
+		}
+		if user.IsBanned() {
+			return moderationError("Account is already banned")
+		}
+		if action.Action == models.ModerationSuspend {
+			user.AccountStatus = models.AccountSuspended
+			user.SuspendedUntil = action.SuspendedUntil
+		} else {
+			user.AccountStatus = models.AccountBanned
+			user.SuspendedUntil = nil
+		}
+		user.AccountStatusReason = action.Reason
+	case models.ModerationReinstate:
+		if !user.IsBanned() && !user.IsSuspended(now) {
+			return moderationError("Account is not suspended or banned")
+		}
+		user.AccountStatus = models.AccountActive
+		user.SuspendedUntil = nil
+		user.AccountStatusReason = ""
+	case models.ModerationShadowBan:
+		if user.IsAdmin {
+			return moderationError("Admins can't be shadow-banned")
+		}
+		if user.ShadowBanned {
+			return moderationError("Account is already shadow-banned")
+		}
+		user.ShadowBanned = true
+	case models.ModerationLiftShadowBan:
+		if !user.ShadowBanned {
+			return moderationError("Account is not shadow-banned")
+		}
+		user.ShadowBanned = false
+	default:
+		return moderationError(fmt.Sprintf("Unknown moderation action %q", action.Action))
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bytes"
+	"datingapp/middleware"
+	"datingapp/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerationActions(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/admin/users/:id/suspend", middleware.AuthMiddleware(), SuspendUser)
+	router.POST("/admin/users/:id/ban", middleware.AuthMiddleware(), BanUser)
+	router.POST("/admin/users/:id/reinstate", middleware.AuthMiddleware(), ReinstateUser)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+	report := models.Report{ReporterID: alice.ID, TargetID: bob.ID, Category: models.ReportCategoryHarassment, Reason: "Threats",
+		Status: models.ReportOpen, Severity: models.ReportSeverityHigh}
+	db.Create(&report)
+	db.Create(&models.Message{SenderID: bob.ID, ReceiverID: alice.ID, Content: "Hi"})
+
+	post := func(path, body string, userID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		addAuthHeader(req, userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	bobPath := "/admin/users/" + strconv.Itoa(int(bob.ID))
+
+	assert.Equal(t, http.StatusForbidden, post(bobPath+"/suspend", `{"reportId":1,"reason":"x","durationHours":1}`, alice.ID).Code)
+	assert.Equal(t, http.StatusBadRequest, post(bobPath+"/suspend", `{"reason":"No report","durationHours":24}`, admin.ID).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/admin/users/"+strconv.Itoa(int(alice.ID))+"/suspend",
+		fmt.Sprintf(`{"reportId":%d,"reason":"Wrong user","durationHours":24}`, report.ID), admin.ID).Code)
+
+	w := post(bobPath+"/suspend", fmt.Sprintf(`{"reportId":%d,"reason":"Threatening messages","durationHours":24}`, report.ID), admin.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	writeTestResult("/admin/users/:id/suspend", TestResult{
+		TestName: "Suspend User",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	db.First(&bob, bob.ID)
+	assert.Equal(t, models.AccountSuspended, bob.AccountStatus)
+	assert.True(t, bob.IsSuspended(time.Now()))
+	db.First(&report, report.ID)
+	assert.Equal(t, models.ReportActioned, report.Status)
+
+	// Suspended users are turned away with the reason
+	req, _ := http.NewRequest("GET", "/conversations", nil)
+	addAuthHeader(req, bob.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Threatening messages")
+
+	// Escalate to a ban: Bob disappears from Alice's conversations
+	w = post(bobPath+"/ban", fmt.Sprintf(`{"reportId":%d,"reason":"Repeated threats"}`, report.ID), admin.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	req, _ = http.NewRequest("GET", "/conversations", nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"firstName":"Bob"`)
+
+	req, _ = http.NewRequest("POST", "/like/"+strconv.Itoa(int(bob.ID)), nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = post(bobPath+"/reinstate", `{"reason":"Appeal accepted"}`, admin.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	db.First(&bob, bob.ID)
+	assert.Equal(t, models.AccountActive, bob.AccountStatus)
+	assert.Equal(t, http.StatusBadRequest, post(bobPath+"/reinstate", `{"reason":"Again"}`, admin.ID).Code)
+
+	var actions []models.ModerationAction
+	db.Where("user_id = ?", bob.ID).Order("id").Find(&actions)
+	if assert.Len(t, actions, 3) {
+		assert.Equal(t, models.ModerationSuspend, actions[0].Action)
+		assert.Equal(t, models.ModerationBan, actions[1].Action)
+		assert.Equal(t, models.ModerationReinstate, actions[2].Action)
+		assert.Equal(t, admin.ID, actions[2].ModeratorID)
+	}
+}
+
+func TestUnitApplyModerationAction(t *testing.T) {
+	now := time.Now()
+	until := now.Add(24 * time.Hour)
+
+	user := models.User{AccountStatus: models.AccountActive}
+	assert.NoError(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationSuspend, Reason: "Spam", SuspendedUntil: &until}, now))
+	assert.Equal(t, models.AccountSuspended, user.AccountStatus)
+	assert.Equal(t, "Spam", user.AccountStatusReason)
+
+	assert.NoError(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationBan, Reason: "More spam"}, now))
+	assert.True(t, user.IsBanned())
+	assert.Nil(t, user.SuspendedUntil)
+	assert.Error(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationSuspend, SuspendedUntil: &until}, now))
+
+	assert.NoError(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationReinstate}, now))
+	assert.Equal(t, models.AccountActive, user.AccountStatus)
+	assert.Empty(t, user.AccountStatusReason)
+	assert.Error(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationReinstate}, now))
+
+	admin := models.User{AccountStatus: models.AccountActive, IsAdmin: true}
+	assert.Error(t, applyModerationAction(&admin, models.ModerationAction{Action: models.ModerationBan}, now))
+}
+
+func TestShadowBan(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.PUT("/admin/users/:id/shadow-ban", middleware.AuthMiddleware(), ShadowBanUser)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	spammer := models.User{FirstName: "Spammer", Email: "spammer@example.com", Password: "password123"}
+	for _, user := range []*models.User{&admin, &alice, &spammer} {
+		db.Create(user)
+	}
+
+	shadowBan := func(body string, userID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PUT", "/admin/users/"+strconv.Itoa(int(spammer.ID))+"/shadow-ban", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		addAuthHeader(req, userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	send := func(from, to uint, content string) int {
+		body := fmt.Sprintf(`{"receiver_id":%d,"content":%q}`, to, content)
+		req, _ := http.NewRequest("POST", "/messages", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		addAuthHeader(req, from)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	get := func(path string, userID uint) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", path, nil)
+		addAuthHeader(req, userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, shadowBan(`{"enabled":true,"reason":"Spam"}`, alice.ID).Code)
+	w := shadowBan(`{"enabled":true,"reason":"Sends the same link to everyone"}`, admin.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	writeTestResult("/admin/users/:id/shadow-ban", TestResult{
+		TestName: "Shadow-ban User",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+	assert.Equal(t, http.StatusBadRequest, shadowBan(`{"enabled":true,"reason":"Again"}`, admin.ID).Code)
+
+	// The spammer sees nothing unusual: sending works and the message shows in their own thread
+	db.Create(&models.Interaction{UserID: spammer.ID, TargetID: alice.ID, Liked: true, Matched: true})
+	db.Create(&models.Interaction{UserID: alice.ID, TargetID: spammer.ID, Liked: true, Matched: true})
+	assert.Equal(t, http.StatusCreated, send(spammer.ID, alice.ID, "Click my link"))
+	assert.Contains(t, get("/messages/"+strconv.Itoa(int(alice.ID)), spammer.ID).Body.String(), "Click my link")
+
+	// Alice never receives it
+	assert.NotContains(t, get("/messages/"+strconv.Itoa(int(spammer.ID)), alice.ID).Body.String(), "Click my link")
+	assert.NotContains(t, get("/conversations", alice.ID).Body.String(), "Click my link")
+	drainOutbox(t, db, time.Now())
+	var notifications int64
+	db.Model(&models.Notification{}).Where("user_id = ?", alice.ID).Count(&notifications)
+	assert.Zero(t, notifications)
+
+	// Lifting the shadow-ban delivers new messages but not the ones sent while it was on
+	assert.Equal(t, http.StatusCreated, shadowBan(`{"enabled":false,"reason":"False positive"}`, admin.ID).Code)
+	assert.Equal(t, http.StatusCreated, send(spammer.ID, alice.ID, "Sorry about that"))
+	body := get("/messages/"+strconv.Itoa(int(spammer.ID)), alice.ID).Body.String()
+	assert.Contains(t, body, "Sorry about that")
+	assert.NotContains(t, body, "Click my link")
+
+	var actions []models.ModerationAction
+	db.Where("user_id = ?", spammer.ID).Order("id").Find(&actions)
+	if assert.Len(t, actions, 2) {
+		assert.Equal(t, models.ModerationShadowBan, actions[0].Action)
+		assert.Equal(t, models.ModerationLiftShadowBan, actions[1].Action)
+	}
+}
+
+func TestUnitApplyShadowBan(t *testing.T) {
+	now := time.Now()
+	user := models.User{AccountStatus: models.AccountActive}
+	assert.NoError(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationShadowBan, Reason: "Spam"}, now))
+	assert.True(t, user.ShadowBanned)
+	assert.Empty(t, user.AccountStatusReason, "shadow-bans are never shown to the user")
+	assert.Equal(t, models.AccountActive, user.AccountStatus)
+	assert.Error(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationShadowBan}, now))
+	assert.Error(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationReinstate}, now))
+
+	assert.NoError(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationLiftShadowBan}, now))
+	assert.False(t, user.ShadowBanned)
+	assert.Error(t, applyModerationAction(&user, models.ModerationAction{Action: models.ModerationLiftShadowBan}, now))
+
+	admin := models.User{IsAdmin: true}
+	assert.Error(t, applyModerationAction(&admin, models.ModerationAction{Action: models.ModerationShadowBan}, now))
+}
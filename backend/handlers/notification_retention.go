@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"datingapp/database"
+	"datingapp/models"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// retentionInterval is how often old notifications are purged
+	retentionInterval = 6 * time.Hour
+	// retentionBatchSize bounds each DELETE so a large backlog doesn't hold long locks
+	retentionBatchSize = 1000
+)
+
+// NotificationRetention says how long notifications are kept. A zero duration keeps them forever.
+type NotificationRetention struct {
+	ReadAfter    time.Duration `json:"readAfter"`    // Read notifications are purged this long after they were read
+	DeletedAfter time.Duration `json:"deletedAfter"` // Notifications users deleted are purged this long after deletion
+}
+
+// DefaultNotificationRetention purges read notifications after 30 days and deleted ones after 90
+var DefaultNotificationRetention = NotificationRetention{
+	ReadAfter:    30 * 24 * time.Hour,
+	DeletedAfter: 90 * 24 * time.Hour,
+}
+
+// NotificationRetentionFromEnv reads NOTIFICATION_READ_RETENTION_DAYS and
+// NOTIFICATION_DELETED_RETENTION_DAYS, falling back to the defaults. 0 disables a rule.
+func NotificationRetentionFromEnv() (NotificationRetention, error) {
+	policy := DefaultNotificationRetention
+	for _, setting := range []struct {
+		name   string
+		target *time.Duration
+	}{
+		{"NOTIFICATION_READ_RETENTION_DAYS", &policy.ReadAfter},
+		{"NOTIFICATION_DELETED_RETENTION_DAYS", &policy.DeletedAfter},
+	} {
+		value := os.Getenv(setting.name)
+		if value == "" {
+			continue
+		}
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 0 {
+			return policy, fmt.Errorf("%s must be a whole number of days, got %q", setting.name, value)
+		}
+		*setting.target = time.Duration(days) * 24 * time.Hour
+	}
+	return policy, nil
+}
+
+// retentionMetrics counts retention runs and purged rows; it is published through expvar
+var retentionMetrics = expvar.NewMap("notification_retention")
+
+var retentionState struct {
+	sync.Mutex
+	policy  NotificationRetention
+	lastRun time.Time
+}
+
+// StartNotificationRetention purges old notifications on a schedule until ctx is cancelled
+func StartNotificationRetention(ctx context.Context, policy NotificationRetention) {
+	retentionState.Lock()
+	retentionState.policy = policy
+	retentionState.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(retentionInterval)
+		defer ticker.Stop()
+		for {
+			read, deleted, err := purgeNotifications(ctx, database.DB, policy, time.Now())
+			if err != nil {
+				logger.Printf("Notification retention failed: %v", err)
+			} else if read+deleted > 0 {
+				logger.Printf("Notification retention purged %d read and %d deleted notifications", read, deleted)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	logger.Printf("Notification retention started (read: %s, deleted: %s)", policy.ReadAfter, policy.DeletedAfter)
+}
+
+// purgeNotifications hard-deletes notifications past the retention policy and returns how many
+// read and soft-deleted rows were removed
+func purgeNotifications(ctx context.Context, db *gorm.DB, policy NotificationRetention, now time.Time) (read, deleted int64, err error) {
+	defer func() {
+		retentionMetrics.Add("runs", 1)
+		retentionMetrics.Add("read_purged", read)
+		retentionMetrics.Add("deleted_purged", deleted)
+		if err != nil {
+			retentionMetrics.Add("failures", 1)
+		}
+		retentionState.Lock()
+		retentionState.lastRun = now
+		retentionState.Unlock()
+	}()
+
+	if policy.ReadAfter > 0 {
+		// Notifications marked read before read_at existed fall back to their last update
+		read, err = purgeNotificationBatches(ctx, db, "read = ? AND COALESCE(read_at, updated_at) < ?", true, now.Add(-policy.ReadAfter))
+		if err != nil {
+			return read, deleted, err
+		}
+	}
+	if policy.DeletedAfter > 0 {
+		deleted, err = purgeNotificationBatches(ctx, db, "deleted_at IS NOT NULL AND deleted_at < ?", now.Add(-policy.DeletedAfter))
+	}
+	return read, deleted, err
+}
+
+func purgeNotificationBatches(ctx context.Context, db *gorm.DB, query string, args ...interface{}) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		batch := db.Unscoped().Model(&models.Notification{}).Select("id").Where(query, args...).Limit(retentionBatchSize)
+		result := db.Unscoped().Where("id IN (?)", batch).Delete(&models.Notification{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// GetNotificationRetention reports the retention policy and how many notifications it has purged (admin only)
+// @Summary Notification retention status (Admin)
+// @Description Admin-only view of the notification retention policy and purge counters since the server started
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Router /admin/notifications/retention [get]
+func GetNotificationRetention(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	if !recordAdminView(c, "notification_retention.view", "", 0) {
+		return
+	}
+
+	metrics := gin.H{}
+	retentionMetrics.Do(func(kv expvar.KeyValue) {
+		if counter, ok := kv.Value.(*expvar.Int); ok {
+			metrics[kv.Key] = counter.Value()
+		}
+	})
+
+	retentionState.Lock()
+	defer retentionState.Unlock()
+	response := gin.H{
+		"readAfterDays":    int(retentionState.policy.ReadAfter.Hours() / 24),
+		"deletedAfterDays": int(retentionState.policy.DeletedAfter.Hours() / 24),
+		"metrics":          metrics,
+	}
+	if !retentionState.lastRun.IsZero() {
+		response["lastRun"] = retentionState.lastRun
+	}
+	c.JSON(http.StatusOK, response)
+}
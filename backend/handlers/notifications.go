@@ -2,17 +2,22 @@ package handlers
 
 import (
 	"datingapp/database"
+	"datingapp/i18n"
 	"datingapp/models"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetNotifications retrieves notifications for the authenticated user
 // @Summary Get user notifications
-// @Description Get paginated notifications for the authenticated user
+// @Description Get paginated notifications for the authenticated user, in the user's locale. Repeated messages from one sender, likes and profile views are grouped, with a count and the users involved.
 // @Tags notifications
 // @Accept json
 // @Produce json
@@ -78,24 +83,45 @@ func GetNotifications(c *gin.Context) {
 		return
 	}
 
+	actors, err := loadNotificationActors(notifications)
+	if err != nil {
+		logger.Printf("Failed to fetch notification actors: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to fetch notifications")
+		return
+	}
+
+	// Render in the reader's current language
+	var reader models.User
+	database.DB.Select("id", "locale").First(&reader, userID)
+
 	// Convert to response format
 	var notificationResponses []models.NotificationResponse
 	for _, notification := range notifications {
+		localizeNotification(reader.Locale, &notification)
 		response := models.NotificationResponse{
 			ID:        notification.ID,
 			Type:      notification.Type,
 			Title:     notification.Title,
 			Message:   notification.Message,
-			Data:      notification.Data,
+			Data:      models.NotificationDataJSON(notification.Type, notification.Data),
 			Read:      notification.Read,
 			CreatedAt: notification.CreatedAt,
+			GroupKey:  notification.GroupKey,
+			Count:     notification.Count,
 		}
 
 		if notification.FromUser != nil {
 			response.FromUser = &models.UserBasicInfo{
 				ID:                notification.FromUser.ID,
 				FirstName:         notification.FromUser.FirstName,
-				ProfilePictureURL: notification.FromUser.ProfilePictureURL,
+				ProfilePictureURL: notification.FromUser.ApprovedPictureURL,
+			}
+		}
+
+		// Most recent actors first
+		for i := len(notification.ActorIDs) - 1; i >= 0 && len(response.Actors) < notificationMaxActors; i-- {
+			if actor, ok := actors[notification.ActorIDs[i]]; ok {
+				response.Actors = append(response.Actors, actor)
 			}
 		}
 
@@ -111,6 +137,34 @@ func GetNotifications(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// notificationMaxActors caps the actors listed on one grouped notification
+const notificationMaxActors = 10
+
+// loadNotificationActors fetches basic info for every actor across the notifications, keyed by ID
+func loadNotificationActors(notifications []models.Notification) (map[uint]models.UserBasicInfo, error) {
+	var ids []uint
+	for _, notification := range notifications {
+		ids = append(ids, notification.ActorIDs...)
+	}
+	actors := make(map[uint]models.UserBasicInfo)
+	if len(ids) == 0 {
+		return actors, nil
+	}
+
+	var users []models.User
+	if err := database.DB.Select("id", "first_name", "approved_picture_url").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		actors[user.ID] = models.UserBasicInfo{
+			ID:                user.ID,
+			FirstName:         user.FirstName,
+			ProfilePictureURL: user.ApprovedPictureURL,
+		}
+	}
+	return actors, nil
+}
+
 // MarkNotificationsRead marks specified notifications as read
 // @Summary Mark notifications as read
 // @Description Mark one or more notifications as read for the authenticated user
@@ -141,10 +195,8 @@ func MarkNotificationsRead(c *gin.Context) {
 		return
 	}
 
-	// Update notifications to read status
-	err := database.DB.Model(&models.Notification{}).
-		Where("id IN ? AND user_id = ?", req.NotificationIDs, userID).
-		Update("read", true).Error
+	// Update notifications to read status; already read ones keep their read time
+	err := markNotificationsRead(database.DB, "id IN ? AND user_id = ? AND read = ?", req.NotificationIDs, userID, false)
 
 	if err != nil {
 		logger.Printf("Failed to mark notifications as read: %v", err)
@@ -155,6 +207,19 @@ func MarkNotificationsRead(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read"})
 }
 
+// markNotificationsRead marks the unread notifications matching the condition as read, counting
+// those sent for an announcement towards its read rate
+func markNotificationsRead(db *gorm.DB, condition string, args ...interface{}) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var read []models.Notification
+		if err := tx.Model(&read).Where(condition, args...).Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}, {Name: "announcement_id"}}}).
+			Updates(map[string]interface{}{"read": true, "read_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		return countAnnouncementReads(tx, read)
+	})
+}
+
 // MarkAllNotificationsRead marks all notifications as read for the authenticated user
 // @Summary Mark all notifications as read
 // @Description Mark all notifications as read for the authenticated user
@@ -172,9 +237,7 @@ func MarkAllNotificationsRead(c *gin.Context) {
 		return
 	}
 
-	err := database.DB.Model(&models.Notification{}).
-		Where("user_id = ?", userID).
-		Update("read", true).Error
+	err := markNotificationsRead(database.DB, "user_id = ? AND read = ?", userID, false)
 
 	if err != nil {
 		logger.Printf("Failed to mark all notifications as read: %v", err)
@@ -216,56 +279,376 @@ func GetNotificationCount(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"unreadCount": unreadCount})
 }
 
-// CreateNotification creates a new notification (internal function)
-func CreateNotification(userID uint, fromUserID *uint, notificationType models.NotificationType, title, message, data string) error {
-	notification := models.Notification{
+// DeleteNotification deletes one of the authenticated user's notifications
+// @Summary Delete a notification
+// @Description Delete a notification. It disappears immediately and is purged permanently by the retention job.
+// @Tags notifications
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path uint true "Notification ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications/{id} [delete]
+func DeleteNotification(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	notificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid notification ID")
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", notificationID, userID).Delete(&models.Notification{})
+	if result.Error != nil {
+		logger.Printf("Failed to delete notification %d: %v", notificationID, result.Error)
+		respondWithError(c, http.StatusInternalServerError, "Failed to delete notification")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondWithError(c, http.StatusNotFound, "Notification not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification deleted"})
+}
+
+// DeleteNotifications clears the authenticated user's read notifications
+// @Summary Delete read notifications
+// @Description Delete all of the authenticated user's read notifications. read=true is required so unread notifications are never cleared by accident.
+// @Tags notifications
+// @Produce json
+// @Security ApiKeyAuth
+// @Param read query bool true "Must be true"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /notifications [delete]
+func DeleteNotifications(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	if read, err := strconv.ParseBool(c.Query("read")); err != nil || !read {
+		respondWithError(c, http.StatusBadRequest, "Only read notifications can be cleared; pass read=true")
+		return
+	}
+
+	result := database.DB.Where("user_id = ? AND read = ?", userID, true).Delete(&models.Notification{})
+	if result.Error != nil {
+		logger.Printf("Failed to delete read notifications for user %d: %v", userID, result.Error)
+		respondWithError(c, http.StatusInternalServerError, "Failed to delete notifications")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Read notifications deleted",
+		"deleted": result.RowsAffected,
+	})
+}
+
+// CreateNotification creates a new notification (internal function).
+// The recipient's preferences are checked with models.ShouldNotify; suppressed notifications are not an error.
+func CreateNotification(userID uint, fromUserID *uint, title, message string, data models.NotificationPayload) error {
+	encoded, err := models.EncodeNotificationData(data)
+	if err != nil {
+		return err
+	}
+	return pendingNotification{
 		UserID:     userID,
 		FromUserID: fromUserID,
-		Type:       notificationType,
+		Type:       data.NotificationType(),
 		Title:      title,
 		Message:    message,
-		Data:       data,
+		Data:       encoded,
+	}.create()
+}
+
+// createNotification stores a notification using db, which may be a transaction. It returns nil
+// when the recipient's preferences suppress it. Push delivery is left to the caller, since the
+// row must be committed before the push worker can load it.
+func createNotification(db *gorm.DB, pending pendingNotification) (*models.Notification, error) {
+	userID, fromUserID, notificationType := pending.UserID, pending.FromUserID, pending.Type
+	if _, err := models.DecodeNotificationData(notificationType, pending.Data); err != nil {
+		return nil, err
+	}
+
+	var recipient models.User
+	if err := db.First(&recipient, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load notification recipient %d: %v", userID, err)
+	}
+	if !models.ShouldNotify(recipient, notificationType, models.ChannelInApp) {
+		logger.Printf("Notification of type %s suppressed for user %d by preferences", notificationType, userID)
+		return nil, nil
+	}
+
+	notification := models.Notification{
+		UserID:     userID,
+		FromUserID: fromUserID,
+		Type:       notificationType,
+		Title:      pending.Title,
+		Message:    pending.Message,
+		Data:       pending.Data,
 		Read:       false,
+		GroupKey:   notificationGroupKey(notificationType, fromUserID),
+		Count:      1,
+	}
+	if fromUserID != nil {
+		notification.ActorIDs = []uint{*fromUserID}
 	}
 
-	err := database.DB.Create(&notification).Error
+	grouped := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		grouped, err = coalesceNotification(tx, &notification, time.Now())
+		if err != nil || grouped {
+			return err
+		}
+		return tx.Create(&notification).Error
+	})
 	if err != nil {
 		logger.Printf("Failed to create notification: %v", err)
-		return fmt.Errorf("failed to create notification: %v", err)
+		return nil, fmt.Errorf("failed to create notification: %v", err)
 	}
 
 	// TODO: Send real-time notification via WebSocket here
-	logger.Printf("Notification created for user %d: %s", userID, title)
+	if grouped {
+		logger.Printf("Notification %d for user %d grouped (%d events): %s", notification.ID, userID, notification.Count, notification.Message)
+	} else {
+		logger.Printf("Notification created for user %d: %s", userID, pending.Title)
+	}
+	return &notification, nil
+}
+
+// notificationGroupWindows is how long an unread notification keeps absorbing repeats, measured
+// from its latest event. Types without a window are never grouped.
+var notificationGroupWindows = map[models.NotificationType]time.Duration{
+	models.NotificationTypeMessage: time.Hour,
+	models.NotificationTypeLike:    24 * time.Hour,
+	models.NotificationTypeView:    24 * time.Hour,
+}
+
+// notificationGroupKey returns the key repeats are grouped under, or "" if the type isn't grouped.
+// Messages group per sender; likes and profile views group across everyone.
+func notificationGroupKey(notificationType models.NotificationType, fromUserID *uint) string {
+	switch notificationType {
+	case models.NotificationTypeMessage:
+		if fromUserID != nil {
+			return fmt.Sprintf("message:%d", *fromUserID)
+		}
+	case models.NotificationTypeLike:
+		return "like"
+	case models.NotificationTypeView:
+		return "profile_view"
+	}
+	return ""
+}
+
+// coalesceNotification folds a new notification into a recent unread one with the same group key.
+// On success it replaces *notification with the updated aggregate and reports true.
+func coalesceNotification(tx *gorm.DB, notification *models.Notification, now time.Time) (bool, error) {
+	window, ok := notificationGroupWindows[notification.Type]
+	if !ok || notification.GroupKey == "" {
+		return false, nil
+	}
+
+	var existing models.Notification
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND group_key = ? AND read = ? AND created_at > ?", notification.UserID, notification.GroupKey, false, now.Add(-window)).
+		Order("created_at DESC").
+		First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	existing.Count++
+	if notification.FromUserID != nil {
+		existing.FromUserID = notification.FromUserID
+		if !containsUint(existing.ActorIDs, *notification.FromUserID) {
+			existing.ActorIDs = append(existing.ActorIDs, *notification.FromUserID)
+		}
+	}
+	existing.Data = notification.Data
+	existing.CreatedAt = now // Bubble the group back to the top of the list
+	if title, message, ok := groupedNotificationText(tx, existing); ok {
+		existing.Title, existing.Message = title, message
+	}
+
+	if err := tx.Save(&existing).Error; err != nil {
+		return false, err
+	}
+	*notification = existing
+	return true, nil
+}
+
+// groupedNotificationText renders the stored title and message for a notification covering
+// several events. Stored text is in the default locale; readers see it in theirs.
+func groupedNotificationText(db *gorm.DB, notification models.Notification) (title, message string, ok bool) {
+	var actor models.User
+	if notification.FromUserID == nil || db.Select("first_name").First(&actor, *notification.FromUserID).Error != nil {
+		return "", "", false
+	}
+	return notificationText(i18n.DefaultLocale, notification.Type, notificationEventCount(notification), actor.FirstName)
+}
+
+// notificationText renders a notification's title and message from the template catalog.
+// It reports false for types without templates, such as admin announcements.
+func notificationText(locale string, notificationType models.NotificationType, count int, actorName string) (title, message string, ok bool) {
+	key := "notification." + string(notificationType)
+	if !i18n.Has(key + ".title") {
+		return "", "", false
+	}
+	vars := i18n.Vars{"name": actorName, "count": count}
+	return i18n.T(locale, key+".title", vars), i18n.T(locale, key+".message", vars), true
+}
+
+// notificationEventCount picks the plural form of a notification's text: grouped messages count
+// messages, while likes and profile views count people
+func notificationEventCount(notification models.Notification) int {
+	switch notification.Type {
+	case models.NotificationTypeLike, models.NotificationTypeView:
+		if len(notification.ActorIDs) > 0 {
+			return len(notification.ActorIDs)
+		}
+	}
+	if notification.Count > 0 {
+		return notification.Count
+	}
+	return 1
+}
 
+// localizeNotification renders a notification in the reader's locale at read time, so a change of
+// language also applies to older notifications. The stored text is kept when the type has no
+// template or the user who triggered it is gone, unless the data carries its catalog keys.
+func localizeNotification(locale string, notification *models.Notification) {
+	if notification.FromUser == nil {
+		// Notifications no user triggered carry their catalog keys in their data
+		if payload, err := models.DecodeNotificationData(notification.Type, notification.Data); err == nil {
+			if localized, ok := payload.(models.LocalizedPayload); ok && localized.CatalogText().TitleKey != "" {
+				notification.Title, notification.Message = catalogNotificationText(locale, localized.CatalogText())
+			}
+		}
+		return
+	}
+	if title, message, ok := notificationText(locale, notification.Type, notificationEventCount(*notification), notification.FromUser.FirstName); ok {
+		notification.Title, notification.Message = title, message
+	}
+}
+
+// pendingNotification is a notification's content before it is stored. It doubles as the
+// outbox payload when the notification is part of a larger transaction.
+type pendingNotification struct {
+	UserID     uint                    `json:"userId"`
+	FromUserID *uint                   `json:"fromUserId,omitempty"`
+	Type       models.NotificationType `json:"type"`
+	Title      string                  `json:"title"`
+	Message    string                  `json:"message"`
+	Data       string                  `json:"data"` // Encoded with models.EncodeNotificationData
+}
+
+func (p pendingNotification) create() error {
+	notification, err := createNotification(database.DB, p)
+	if err != nil {
+		return err
+	}
+	if notification != nil {
+		queuePushDelivery(notification.ID)
+	}
 	return nil
 }
 
+// newPendingNotification encodes the payload and renders the stored text in the default locale
+func newPendingNotification(userID, fromUserID uint, data models.NotificationPayload, actorName string) (pendingNotification, error) {
+	encoded, err := models.EncodeNotificationData(data)
+	if err != nil {
+		return pendingNotification{}, err
+	}
+	notification := pendingNotification{
+		UserID:     userID,
+		FromUserID: &fromUserID,
+		Type:       data.NotificationType(),
+		Data:       encoded,
+	}
+	notification.Title, notification.Message, _ = notificationText(i18n.DefaultLocale, notification.Type, 1, actorName)
+	return notification, nil
+}
+
+// newCatalogNotification encodes a payload that carries its catalog text and renders the stored
+// text in the recipient's locale
+func newCatalogNotification(userID uint, locale string, data models.LocalizedPayload) (*pendingNotification, error) {
+	encoded, err := models.EncodeNotificationData(data)
+	if err != nil {
+		return nil, err
+	}
+	notification := &pendingNotification{UserID: userID, Type: data.NotificationType(), Data: encoded}
+	notification.Title, notification.Message = catalogNotificationText(locale, data.CatalogText())
+	return notification, nil
+}
+
+// catalogNotificationText renders a notification's stored catalog keys
+func catalogNotificationText(locale string, text models.NotificationText) (title, message string) {
+	vars := make(i18n.Vars, len(text.Vars))
+	for name, value := range text.Vars {
+		vars[name] = value
+	}
+	return i18n.T(locale, text.TitleKey, vars), i18n.T(locale, text.MessageKey, vars)
+}
+
+func matchNotification(userID, matchedUserID uint, matchedUserName string) (pendingNotification, error) {
+	return newPendingNotification(userID, matchedUserID, models.MatchData{MatchedUserID: matchedUserID, Action: "view_match"}, matchedUserName)
+}
+
+// messagePreviewLength caps the message text copied into a notification, in characters
+const messagePreviewLength = 50
+
+func messageNotification(receiverID, senderID uint, senderName, messagePreview string) (pendingNotification, error) {
+	if preview := []rune(messagePreview); len(preview) > messagePreviewLength {
+		messagePreview = string(preview[:messagePreviewLength]) + "..."
+	}
+	return newPendingNotification(receiverID, senderID, models.MessageData{SenderID: senderID, MessagePreview: messagePreview, Action: "view_chat"}, senderName)
+}
+
+func likeNotification(likedUserID, likerUserID uint, likerName string) (pendingNotification, error) {
+	return newPendingNotification(likedUserID, likerUserID, models.LikeData{LikerID: likerUserID, Action: "view_profile"}, likerName)
+}
+
+func profileViewNotification(viewedUserID, viewerID uint, viewerName string) (pendingNotification, error) {
+	return newPendingNotification(viewedUserID, viewerID, models.ProfileViewData{ViewerID: viewerID, Action: "view_profile_views"}, viewerName)
+}
+
 // Helper function to create match notification
 func CreateMatchNotification(userID, matchedUserID uint, matchedUserName string) error {
-	title := "New Match! 💕"
-	message := fmt.Sprintf("You matched with %s! Start chatting now.", matchedUserName)
-	data := fmt.Sprintf(`{"matchedUserId": %d, "action": "view_match"}`, matchedUserID)
-
-	return CreateNotification(userID, &matchedUserID, models.NotificationTypeMatch, title, message, data)
+	notification, err := matchNotification(userID, matchedUserID, matchedUserName)
+	if err != nil {
+		return err
+	}
+	return notification.create()
 }
 
 // Helper function to create message notification
 func CreateMessageNotification(receiverID, senderID uint, senderName, messagePreview string) error {
-	title := "New Message 💬"
-	message := fmt.Sprintf("%s sent you a message", senderName)
-	if len(messagePreview) > 50 {
-		messagePreview = messagePreview[:50] + "..."
+	notification, err := messageNotification(receiverID, senderID, senderName, messagePreview)
+	if err != nil {
+		return err
 	}
-	data := fmt.Sprintf(`{"senderId": %d, "messagePreview": "%s", "action": "view_chat"}`, senderID, messagePreview)
-
-	return CreateNotification(receiverID, &senderID, models.NotificationTypeMessage, title, message, data)
+	return notification.create()
 }
 
 // Helper function to create like notification
 func CreateLikeNotification(likedUserID, likerUserID uint, likerName string) error {
-	title := "Someone Liked You! ❤️"
-	message := fmt.Sprintf("%s liked your profile", likerName)
-	data := fmt.Sprintf(`{"likerId": %d, "action": "view_profile"}`, likerUserID)
-
-	return CreateNotification(likedUserID, &likerUserID, models.NotificationTypeLike, title, message, data)
+	notification, err := likeNotification(likedUserID, likerUserID, likerName)
+	if err != nil {
+		return err
+	}
+	return notification.create()
 }
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestNotificationGrouping(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/notifications", middleware.AuthMiddleware(), GetNotifications)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	alex := models.User{FirstName: "Alex", Email: "alex@example.com", Password: "password123"}
+	sam := models.User{FirstName: "Sam", Email: "sam@example.com", Password: "password123"}
+	jo := models.User{FirstName: "Jo", Email: "jo@example.com", Password: "password123"}
+	for _, user := range []*models.User{&alice, &alex, &sam, &jo} {
+		db.Create(user)
+	}
+
+	// A burst of five messages from Alex becomes one notification
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, CreateMessageNotification(alice.ID, alex.ID, alex.FirstName, "hello"))
+	}
+	// Three different people liking Alice become one notification
+	for _, liker := range []models.User{alex, sam, jo} {
+		assert.NoError(t, CreateLikeNotification(alice.ID, liker.ID, liker.FirstName))
+	}
+	// Matches are never grouped
+	assert.NoError(t, CreateMatchNotification(alice.ID, sam.ID, sam.FirstName))
+	assert.NoError(t, CreateMatchNotification(alice.ID, jo.ID, jo.FirstName))
+
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ?", alice.ID).Count(&count)
+	assert.Equal(t, int64(4), count)
+
+	req, _ := http.NewRequest("GET", "/notifications", nil)
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/notifications", TestResult{
+		TestName: "Grouped Notifications",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var response models.GetNotificationsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	byKey := map[string]models.NotificationResponse{}
+	for _, notification := range response.Notifications {
+		byKey[notification.GroupKey] = notification
+	}
+
+	messages := byKey["message:"+strconv.Itoa(int(alex.ID))]
+	assert.Equal(t, 5, messages.Count)
+	assert.Equal(t, "Alex sent you 5 messages", messages.Message)
+	if assert.Len(t, messages.Actors, 1) {
+		assert.Equal(t, "Alex", messages.Actors[0].FirstName)
+	}
+
+	likes := byKey["like"]
+	assert.Equal(t, 3, likes.Count)
+	assert.Equal(t, "3 people liked you", likes.Message)
+	if assert.Len(t, likes.Actors, 3) {
+		assert.Equal(t, "Jo", likes.Actors[0].FirstName, "most recent actor first")
+	}
+
+	// Once read, the next message starts a new notification
+	db.Model(&models.Notification{}).Where("user_id = ?", alice.ID).Update("read", true)
+	assert.NoError(t, CreateMessageNotification(alice.ID, alex.ID, alex.FirstName, "you there?"))
+	db.Model(&models.Notification{}).Where("user_id = ?", alice.ID).Count(&count)
+	assert.Equal(t, int64(5), count)
+}
+
+func TestUnitNotificationGroupKey(t *testing.T) {
+	alex := uint(7)
+	assert.Equal(t, "message:7", notificationGroupKey(models.NotificationTypeMessage, &alex))
+	assert.Equal(t, "", notificationGroupKey(models.NotificationTypeMessage, nil))
+	assert.Equal(t, "like", notificationGroupKey(models.NotificationTypeLike, &alex))
+	assert.Equal(t, "profile_view", notificationGroupKey(models.NotificationTypeView, &alex))
+	assert.Equal(t, "", notificationGroupKey(models.NotificationTypeMatch, &alex))
+}
+
+func TestDeleteNotifications(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.DELETE("/notifications", middleware.AuthMiddleware(), DeleteNotifications)
+	router.DELETE("/notifications/:id", middleware.AuthMiddleware(), DeleteNotification)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	read := models.Notification{UserID: alice.ID, Type: models.NotificationTypeLike, Title: "Like", Message: "Bob liked your profile", Read: true}
+	unread := models.Notification{UserID: alice.ID, Type: models.NotificationTypeMatch, Title: "Match", Message: "You matched with Bob"}
+	bobs := models.Notification{UserID: bob.ID, Type: models.NotificationTypeMatch, Title: "Match", Message: "You matched with Alice"}
+	for _, notification := range []*models.Notification{&read, &unread, &bobs} {
+		db.Create(notification)
+	}
+
+	// Other users' notifications can't be deleted
+	req, _ := http.NewRequest("DELETE", "/notifications/"+strconv.Itoa(int(bobs.ID)), nil)
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/notifications", nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "read=true is required")
+
+	req, _ = http.NewRequest("DELETE", "/notifications?read=true", nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"deleted":1`)
+
+	writeTestResult("/notifications?read=true", TestResult{
+		TestName: "Delete Read Notifications",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	req, _ = http.NewRequest("DELETE", "/notifications/"+strconv.Itoa(int(unread.ID)), nil)
+	addAuthHeader(req, alice.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var remaining int64
+	db.Model(&models.Notification{}).Where("user_id = ?", alice.ID).Count(&remaining)
+	assert.Equal(t, int64(0), remaining)
+	// Deleted notifications stay in the table until the retention job purges them
+	db.Unscoped().Model(&models.Notification{}).Where("user_id = ?", alice.ID).Count(&remaining)
+	assert.Equal(t, int64(2), remaining)
+}
+
+func TestPurgeNotifications(t *testing.T) {
+	db := setupTestDB()
+	setupRouter(db)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	db.Create(&alice)
+
+	now := time.Now()
+	old := now.Add(-40 * 24 * time.Hour)
+	ancient := now.Add(-100 * 24 * time.Hour)
+	notifications := []models.Notification{
+		{Title: "old read", Read: true, ReadAt: &old},
+		{Title: "old read, updated since", Read: true, ReadAt: &old},
+		{Title: "recent read", Read: true, ReadAt: &now},
+		{Title: "old read before read_at", Read: true},
+		{Title: "old unread"},
+		{Title: "deleted long ago", DeletedAt: gorm.DeletedAt{Time: ancient, Valid: true}},
+		{Title: "deleted recently", DeletedAt: gorm.DeletedAt{Time: old, Valid: true}},
+	}
+	for i := range notifications {
+		notifications[i].UserID = alice.ID
+		notifications[i].Type = models.NotificationTypeLike
+		notifications[i].Message = notifications[i].Title
+		db.Create(&notifications[i])
+	}
+	// Create sets UpdatedAt to now; only rows without a read time fall back to it
+	db.Model(&models.Notification{}).Where("title IN ?", []string{"recent read", "old read before read_at", "old unread"}).UpdateColumn("updated_at", old)
+
+	read, deleted, err := purgeNotifications(context.Background(), db, DefaultNotificationRetention, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), read)
+	assert.Equal(t, int64(1), deleted)
+
+	var titles []string
+	db.Unscoped().Model(&models.Notification{}).Order("id").Pluck("title", &titles)
+	assert.Equal(t, []string{"recent read", "old unread", "deleted recently"}, titles)
+}
+
+func TestUnitNotificationRetentionFromEnv(t *testing.T) {
+	t.Setenv("NOTIFICATION_READ_RETENTION_DAYS", "7")
+	t.Setenv("NOTIFICATION_DELETED_RETENTION_DAYS", "0")
+	policy, err := NotificationRetentionFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, policy.ReadAfter)
+	assert.Equal(t, time.Duration(0), policy.DeletedAfter)
+
+	t.Setenv("NOTIFICATION_READ_RETENTION_DAYS", "a month")
+	_, err = NotificationRetentionFromEnv()
+	assert.Error(t, err)
+}
+
+func TestUnitLocalizeNotification(t *testing.T) {
+	alex := models.User{ID: 7, FirstName: "Alex"}
+	notification := models.Notification{
+		Type:     models.NotificationTypeMessage,
+		Title:    "New Messages 💬",
+		Message:  "Alex sent you 3 messages",
+		Count:    3,
+		FromUser: &alex,
+	}
+	localizeNotification("es", &notification)
+	assert.Equal(t, "Nuevos mensajes 💬", notification.Title)
+	assert.Equal(t, "Alex te envió 3 mensajes", notification.Message)
+
+	// Likes count people rather than events
+	likes := models.Notification{Type: models.NotificationTypeLike, Count: 3, ActorIDs: []uint{7, 8}, FromUser: &alex}
+	localizeNotification("fr", &likes)
+	assert.Equal(t, "2 personnes vous ont aimé", likes.Message)
+
+	// Announcements have no template, and notifications from deleted users keep their stored text
+	announcement := models.Notification{Type: models.NotificationTypeAppUpdate, Title: "New feature", Message: "Try it out", FromUser: &alex}
+	localizeNotification("es", &announcement)
+	assert.Equal(t, "Try it out", announcement.Message)
+	orphan := models.Notification{Type: models.NotificationTypeMatch, Message: "You matched with Sam! Start chatting now."}
+	localizeNotification("es", &orphan)
+	assert.Equal(t, "You matched with Sam! Start chatting now.", orphan.Message)
+
+	// Notifications no user triggered are rendered from the catalog keys in their data
+	rejected, err := newCatalogNotification(1, "en", models.PhotoData{
+		PhotoID: 4,
+		Status:  models.PhotoRejected,
+		Reason:  "blurry",
+		Action:  "edit_photos",
+		NotificationText: models.NotificationText{
+			TitleKey:   "photo.notification.title",
+			MessageKey: "photo.notification.rejected",
+			Vars:       map[string]string{"reason": "blurry"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "A Photo Wasn't Approved", rejected.Title)
+	photo := models.Notification{Type: rejected.Type, Title: rejected.Title, Message: rejected.Message, Data: rejected.Data}
+	localizeNotification("es", &photo)
+	assert.Equal(t, "Una de tus fotos no fue aprobada", photo.Title)
+	assert.Equal(t, "Una de tus fotos no cumple nuestras normas, así que no se muestra a otras personas: blurry", photo.Message)
+
+	// Older rows without catalog keys keep their stored text
+	legacy := models.Notification{Type: models.NotificationTypeReport, Title: "Update on Your Report", Data: `{"reportId":3,"status":"dismissed","action":"view_report"}`}
+	localizeNotification("es", &legacy)
+	assert.Equal(t, "Update on Your Report", legacy.Title)
+
+	// Stored text is rendered in the default locale
+	like, err := likeNotification(1, 7, "Alex")
+	assert.NoError(t, err)
+	assert.Equal(t, "Alex liked your profile", like.Message)
+}
+
+func TestUnitMessageNotificationData(t *testing.T) {
+	// Quotes, backslashes and multi-byte text used to produce invalid JSON
+	preview := `she said "hi" \ 😀` + strings.Repeat("é", 60)
+	notification, err := messageNotification(1, 7, "Alex", preview)
+	assert.NoError(t, err)
+	assert.True(t, json.Valid([]byte(notification.Data)))
+
+	var data struct {
+		Version        int    `json:"version"`
+		SenderID       uint   `json:"senderId"`
+		MessagePreview string `json:"messagePreview"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(notification.Data), &data))
+	assert.Equal(t, models.NotificationDataVersion, data.Version)
+	assert.Equal(t, uint(7), data.SenderID)
+	assert.True(t, strings.HasPrefix(data.MessagePreview, `she said "hi" \ 😀`))
+	assert.Equal(t, messagePreviewLength+3, utf8.RuneCountInString(data.MessagePreview))
+
+	_, err = messageNotification(1, 0, "Nobody", "hi")
+	assert.Error(t, err, "sender is required")
+}
+
+func TestUnitNotificationResponseDataShape(t *testing.T) {
+	notification, err := matchNotification(1, 7, "Alex")
+	assert.NoError(t, err)
+	body, err := json.Marshal(models.NotificationResponse{
+		ID:   1,
+		Type: notification.Type,
+		Data: models.NotificationDataJSON(notification.Type, notification.Data),
+	})
+	assert.NoError(t, err)
+
+	// Clients read data as an object, not a string holding JSON
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(body, &response))
+	data, ok := response["data"].(map[string]interface{})
+	if assert.True(t, ok, "data is %T", response["data"]) {
+		assert.Equal(t, "view_match", data["action"])
+		assert.Equal(t, float64(7), data["matchedUserId"])
+		assert.Equal(t, float64(models.NotificationDataVersion), data["version"])
+	}
+
+	// Unreadable data is left out rather than sent as null or a string
+	body, err = json.Marshal(models.NotificationResponse{ID: 2, Type: models.NotificationTypeMatch,
+		Data: models.NotificationDataJSON(models.NotificationTypeMatch, "not json")})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), `"data"`)
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"context"
+	"datingapp/database"
+	"datingapp/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// outboxPollInterval is how often the dispatcher looks for due events when nothing wakes it
+	outboxPollInterval = 5 * time.Second
+	// outboxMaxAttempts is how many times an event is tried before it is dead-lettered
+	outboxMaxAttempts = 8
+	// outboxBaseBackoff is the delay after the first failure; it doubles with each attempt
+	outboxBaseBackoff = 5 * time.Second
+	// outboxMaxBackoff caps the delay between attempts
+	outboxMaxBackoff = time.Hour
+	// outboxStuckAfter is how long a pending event can wait before the admin view calls it stuck
+	outboxStuckAfter = 10 * time.Minute
+)
+
+// Outbox event kinds
+const (
+	outboxKindNotification = "notification"
+	outboxKindActivity     = "activity"
+)
+
+// outboxHandler delivers one event using tx, which is rolled back if it returns an error.
+// The returned func, if any, runs after the delivery has been committed.
+type outboxHandler func(tx *gorm.DB, payload []byte) (afterCommit func(), err error)
+
+// outboxHandlers maps each event kind to its delivery channel. New channels register here.
+var outboxHandlers = map[string]outboxHandler{
+	outboxKindNotification: deliverOutboxNotification,
+	outboxKindActivity:     deliverOutboxActivity,
+}
+
+// outboxWake nudges the dispatcher when new events are committed, so delivery doesn't wait for the next poll
+var outboxWake = make(chan struct{}, 1)
+
+// outboxActivity is the payload of an activity log event
+type outboxActivity struct {
+	UserID   uint   `json:"userId"`
+	Event    string `json:"event"`
+	Message  string `json:"message"`
+	TargetID *uint  `json:"targetId,omitempty"`
+}
+
+// enqueueOutbox records an event in tx, so it is delivered if and only if tx commits.
+// Call notifyOutbox after the commit.
+func enqueueOutbox(tx *gorm.DB, kind string, payload interface{}) error {
+	if _, ok := outboxHandlers[kind]; !ok {
+		return fmt.Errorf("unknown outbox event kind %q", kind)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s outbox event: %v", kind, err)
+	}
+	event := models.OutboxEvent{
+		Kind:          kind,
+		Payload:       string(body),
+		Status:        models.OutboxPending,
+		NextAttemptAt: time.Now(),
+	}
+	return tx.Create(&event).Error
+}
+
+// enqueueNotification records a notification to be created once tx commits
+func enqueueNotification(tx *gorm.DB, notification pendingNotification) error {
+	// Reject bad data now rather than dead-lettering it later
+	if _, err := models.DecodeNotificationData(notification.Type, notification.Data); err != nil {
+		return err
+	}
+	return enqueueOutbox(tx, outboxKindNotification, notification)
+}
+
+// enqueueActivity records an activity log entry to be written once tx commits
+func enqueueActivity(tx *gorm.DB, userID uint, event, message string, targetID *uint) error {
+	return enqueueOutbox(tx, outboxKindActivity, outboxActivity{UserID: userID, Event: event, Message: message, TargetID: targetID})
+}
+
+// notifyOutbox wakes the dispatcher; it never blocks
+func notifyOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartOutboxDispatcher delivers outbox events until ctx is cancelled
+func StartOutboxDispatcher(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(outboxPollInterval)
+		defer ticker.Stop()
+		for {
+			for {
+				dispatched, err := dispatchOutboxEvent(database.DB, time.Now())
+				if err != nil {
+					logger.Printf("Outbox dispatch failed: %v", err)
+					break
+				}
+				if !dispatched || ctx.Err() != nil {
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-outboxWake:
+			}
+		}
+	}()
+	logger.Printf("Outbox dispatcher started")
+}
+
+// dispatchOutboxEvent claims the oldest due event and delivers it. The claim, the delivery and
+// the status update share one transaction, so an event's side effects are committed exactly once
+// even with several dispatchers running. It reports whether an event was found.
+func dispatchOutboxEvent(db *gorm.DB, now time.Time) (bool, error) {
+	var afterCommit func()
+	found := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND next_attempt_at <= ?", models.OutboxPending, now).Order("next_attempt_at, id")
+		if tx.Dialector.Name() == "postgres" {
+			// Skip events another dispatcher is already working on
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		var event models.OutboxEvent
+		if err := query.First(&event).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		found = true
+
+		// Run the handler in a savepoint so a failure undoes its writes but keeps the claim
+		deliveryErr := tx.Transaction(func(delivery *gorm.DB) error {
+			handler, ok := outboxHandlers[event.Kind]
+			if !ok {
+				return fmt.Errorf("unknown outbox event kind %q", event.Kind)
+			}
+			var err error
+			afterCommit, err = handler(delivery, []byte(event.Payload))
+			return err
+		})
+
+		event.Attempts++
+		if deliveryErr == nil {
+			event.Status = models.OutboxDelivered
+			event.DeliveredAt = &now
+			event.LastError = ""
+		} else {
+			afterCommit = nil
+			event.LastError = deliveryErr.Error()
+			if event.Attempts >= outboxMaxAttempts {
+				event.Status = models.OutboxDead
+				logger.Printf("Outbox event %d (%s) dead-lettered after %d attempts: %v", event.ID, event.Kind, event.Attempts, deliveryErr)
+			} else {
+				event.NextAttemptAt = now.Add(outboxBackoff(event.Attempts))
+				logger.Printf("Outbox event %d (%s) failed, retrying at %s: %v", event.ID, event.Kind, event.NextAttemptAt.Format(time.RFC3339), deliveryErr)
+			}
+		}
+		return tx.Model(&event).Select("attempts", "status", "delivered_at", "last_error", "next_attempt_at").Updates(&event).Error
+	})
+	if err != nil {
+		return found, err
+	}
+	if afterCommit != nil {
+		afterCommit()
+	}
+	return found, nil
+}
+
+// outboxBackoff is the delay before retrying an event that has failed the given number of times
+func outboxBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return outboxBaseBackoff
+	}
+	if attempts > 20 {
+		return outboxMaxBackoff
+	}
+	delay := outboxBaseBackoff << (attempts - 1)
+	if delay > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return delay
+}
+
+func deliverOutboxNotification(tx *gorm.DB, payload []byte) (func(), error) {
+	var pending pendingNotification
+	if err := json.Unmarshal(payload, &pending); err != nil {
+		return nil, fmt.Errorf("invalid notification payload: %v", err)
+	}
+	notification, err := createNotification(tx, pending)
+	if err != nil || notification == nil {
+		return nil, err
+	}
+	return func() { queuePushDelivery(notification.ID) }, nil
+}
+
+func deliverOutboxActivity(tx *gorm.DB, payload []byte) (func(), error) {
+	var activity outboxActivity
+	if err := json.Unmarshal(payload, &activity); err != nil {
+		return nil, fmt.Errorf("invalid activity payload: %v", err)
+	}
+	return nil, models.LogActivity(tx, activity.UserID, activity.Event, activity.Message, activity.TargetID)
+}
+
+// GetOutboxEvents lists outbox events for troubleshooting delivery (admin only)
+// @Summary List outbox events (Admin)
+// @Description Admin-only view of side effects waiting for delivery. "stuck" shows dead-lettered events and pending events older than 10 minutes.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status (stuck, pending, delivered, dead, all)" default(stuck)
+// @Param kind query string false "Filter by event kind (notification, activity)"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/outbox [get]
+func GetOutboxEvents(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.OutboxEvent{})
+	switch status := c.DefaultQuery("status", "stuck"); status {
+	case "stuck":
+		query = query.Where("status = ? OR (status = ? AND created_at < ?)", models.OutboxDead, models.OutboxPending, time.Now().Add(-outboxStuckAfter))
+	case string(models.OutboxPending), string(models.OutboxDelivered), string(models.OutboxDead):
+		query = query.Where("status = ?", status)
+	case "all":
+	default:
+		respondWithError(c, http.StatusBadRequest, "Invalid status filter")
+		return
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve outbox events"})
+		return
+	}
+
+	var events []models.OutboxEvent
+	if err := query.Order("created_at").Limit(limit).Offset(offset).Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve outbox events"})
+		return
+	}
+	if !recordAdminView(c, "outbox.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+	})
+}
+
+// RetryOutboxEvent puts a dead-lettered event back in the queue (admin only)
+// @Summary Retry a dead outbox event (Admin)
+// @Description Reset a dead-lettered event's attempts so the dispatcher delivers it again
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param id path uint true "Outbox event ID"
+// @Success 200 {object} models.OutboxEvent
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/outbox/{id}/retry [post]
+func RetryOutboxEvent(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	eventID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid event ID")
+		return
+	}
+
+	var event models.OutboxEvent
+	if err := database.DB.First(&event, eventID).Error; err != nil {
+		respondWithError(c, http.StatusNotFound, "Outbox event not found")
+		return
+	}
+	if event.Status != models.OutboxDead {
+		respondWithError(c, http.StatusBadRequest, "Only dead events can be retried")
+		return
+	}
+
+	original := event
+	event.Status = models.OutboxPending
+	event.Attempts = 0
+	event.NextAttemptAt = time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&event).Select("status", "attempts", "next_attempt_at").Updates(&event).Error; err != nil {
+			return err
+		}
+		return recordAdminAction(c, tx, "outbox_event.retry", "outbox_event", event.ID, original, event)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry outbox event"})
+		return
+	}
+	notifyOutbox()
+
+	logger.Printf("Outbox event %d requeued by an admin", event.ID)
+	c.JSON(http.StatusOK, event)
+}
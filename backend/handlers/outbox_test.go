@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestOutboxDelivery(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	req, _ := http.NewRequest("POST", "/like/"+strconv.Itoa(int(bob.ID)), nil)
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// The like's notification and activity entry are queued, not yet delivered
+	var pending int64
+	db.Model(&models.OutboxEvent{}).Where("status = ?", models.OutboxPending).Count(&pending)
+	assert.Equal(t, int64(2), pending)
+	var notifications int64
+	db.Model(&models.Notification{}).Count(&notifications)
+	assert.Equal(t, int64(0), notifications)
+
+	now := time.Now()
+	for {
+		dispatched, err := dispatchOutboxEvent(db, now)
+		assert.NoError(t, err)
+		if !dispatched {
+			break
+		}
+	}
+
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", bob.ID, models.NotificationTypeLike).Count(&notifications)
+	assert.Equal(t, int64(1), notifications)
+	var activities int64
+	db.Model(&models.ActivityLog{}).Where("user_id = ? AND event = ?", alice.ID, "like").Count(&activities)
+	assert.Equal(t, int64(1), activities)
+
+	// Delivered events are never delivered again
+	dispatched, err := dispatchOutboxEvent(db, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.False(t, dispatched)
+	db.Model(&models.Notification{}).Count(&notifications)
+	assert.Equal(t, int64(1), notifications)
+}
+
+func TestOutboxDeadLetter(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/admin/outbox", middleware.AuthMiddleware(), GetOutboxEvents)
+	router.POST("/admin/outbox/:id/retry", middleware.AuthMiddleware(), RetryOutboxEvent)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	db.Create(&admin)
+
+	// A channel that always fails, and writes a row that must be rolled back each time
+	outboxHandlers["failing"] = func(tx *gorm.DB, payload []byte) (func(), error) {
+		tx.Create(&models.ActivityLog{UserID: admin.ID, Event: "partial", Message: "should be rolled back"})
+		return nil, errors.New("channel unavailable")
+	}
+	defer delete(outboxHandlers, "failing")
+
+	assert.NoError(t, enqueueOutbox(db, "failing", map[string]string{"hello": "world"}))
+
+	now := time.Now()
+	for i := 0; i < outboxMaxAttempts; i++ {
+		dispatched, err := dispatchOutboxEvent(db, now)
+		assert.NoError(t, err)
+		assert.True(t, dispatched)
+		// Not due again until the backoff has passed
+		dispatched, _ = dispatchOutboxEvent(db, now)
+		assert.False(t, dispatched)
+		now = now.Add(outboxMaxBackoff)
+	}
+
+	var event models.OutboxEvent
+	db.First(&event)
+	assert.Equal(t, models.OutboxDead, event.Status)
+	assert.Equal(t, outboxMaxAttempts, event.Attempts)
+	assert.Equal(t, "channel unavailable", event.LastError)
+
+	var partial int64
+	db.Model(&models.ActivityLog{}).Where("event = ?", "partial").Count(&partial)
+	assert.Equal(t, int64(0), partial)
+
+	req, _ := http.NewRequest("GET", "/admin/outbox", nil)
+	addAuthHeader(req, admin.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/admin/outbox", TestResult{
+		TestName: "Stuck Outbox Events",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var response struct {
+		Events []models.OutboxEvent `json:"events"`
+		Total  int64                `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(1), response.Total)
+
+	req, _ = http.NewRequest("POST", "/admin/outbox/"+strconv.Itoa(int(event.ID))+"/retry", nil)
+	addAuthHeader(req, admin.ID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	db.First(&event, event.ID)
+	assert.Equal(t, models.OutboxPending, event.Status)
+	assert.Equal(t, 0, event.Attempts)
+}
+
+func TestUnitOutboxBackoff(t *testing.T) {
+	assert.Equal(t, outboxBaseBackoff, outboxBackoff(1))
+	assert.Equal(t, 4*outboxBaseBackoff, outboxBackoff(3))
+	assert.Equal(t, outboxMaxBackoff, outboxBackoff(15))
+	assert.Equal(t, outboxMaxBackoff, outboxBackoff(100))
+}
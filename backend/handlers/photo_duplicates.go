@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/imagehash"
+	"datingapp/models"
+	"datingapp/photoscreen"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Rule names reported by the duplicate photo screener
+const (
+	ruleDuplicatePhoto   = "duplicate_photo"
+	ruleBannedPhotoMatch = "banned_photo_match"
+)
+
+// defaultDuplicateDistance is how many of the 64 hash bits may differ for two photos to count
+// as copies of each other
+const defaultDuplicateDistance = 6
+
+// photoHashRescan is how far before the newest indexed photo each catch-up looks again. Photo
+// IDs and creation times are taken before the upload commits, so a slow transaction can commit
+// a photo older than ones already indexed.
+const photoHashRescan = 10 * time.Minute
+
+// photoHashIndex keeps every stored photo hash in a BK-tree. Each instance catches up on photos
+// added since its last lookup, so uploads handled elsewhere are found too.
+type photoHashIndex struct {
+	mu      sync.Mutex
+	tree    *imagehash.BKTree
+	indexed map[uint]bool // Photo IDs already looked at
+	since   time.Time     // Creation time of the newest photo looked at
+}
+
+var photoHashes = newPhotoHashIndex()
+
+func newPhotoHashIndex() *photoHashIndex {
+	return &photoHashIndex{tree: imagehash.NewBKTree(), indexed: map[uint]bool{}}
+}
+
+// search returns the stored photos whose hash is within maxDistance of hash, closest first
+func (i *photoHashIndex) search(db *gorm.DB, hash imagehash.Hash, maxDistance int) ([]imagehash.Match, error) {
+	if err := i.catchUp(db); err != nil {
+		return nil, err
+	}
+	return i.tree.Search(hash, maxDistance), nil
+}
+
+// catchUp adds the hashes of photos stored since the last call, looking photoHashRescan back
+// for ones that committed late
+func (i *photoHashIndex) catchUp(db *gorm.DB) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	query := db.Select("id", "hash", "created_at").Where("hash <> ''")
+	if !i.since.IsZero() {
+		query = query.Where("created_at > ?", i.since.Add(-photoHashRescan))
+	}
+	var photos []models.Photo
+	return query.FindInBatches(&photos, 1000, func(tx *gorm.DB, _ int) error {
+		for _, photo := range photos {
+			if i.indexed[photo.ID] {
+				continue
+			}
+			i.indexed[photo.ID] = true
+			if photo.CreatedAt.After(i.since) {
+				i.since = photo.CreatedAt
+			}
+			hash, err := imagehash.Parse(photo.Hash)
+			if err != nil {
+				logger.Printf("Skipping photo %d with invalid hash: %v", photo.ID, err)
+				continue
+			}
+			// Hashes of flat images stored before they were left out would match each other
+			if !hash.Informative() {
+				continue
+			}
+			i.tree.Add(hash, photo.ID)
+		}
+		return nil
+	}).Error
+}
+
+// duplicatePhotoScreener compares uploads with every photo already stored. A copy of a banned
+// account's photo is rejected outright; a copy of another account's photo waits for a moderator.
+type duplicatePhotoScreener struct {
+	maxDistance int
+}
+
+// newDuplicatePhotoScreener reads PHOTO_DUPLICATE_DISTANCE, the Hamming distance within which
+// two photos count as copies
+func newDuplicatePhotoScreener() *duplicatePhotoScreener {
+	distance := defaultDuplicateDistance
+	if value := os.Getenv("PHOTO_DUPLICATE_DISTANCE"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 && n <= 64 {
+			distance = n
+		}
+	}
+	return &duplicatePhotoScreener{maxDistance: distance}
+}
+
+// Screen implements photoscreen.Screener
+func (s *duplicatePhotoScreener) Screen(input photoscreen.Input) []photoscreen.Finding {
+	if !input.Hashed || !input.Hash.Informative() {
+		return nil
+	}
+	matches, err := photoHashes.search(database.DB, input.Hash, s.maxDistance)
+	if err != nil {
+		logger.Printf("Failed to look up duplicate photos: %v", err)
+		return nil
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(matches))
+	for i, match := range matches {
+		ids[i] = match.ID
+	}
+	var owners []struct {
+		ID            uint
+		UserID        *uint
+		AccountStatus models.AccountStatus
+	}
+	// Deleted accounts count too; a banned user's photos stay banned after the account is gone
+	if err := database.DB.Model(&models.Photo{}).
+		Select("photos.id, photos.user_id, users.account_status").
+		Joins("LEFT JOIN users ON users.id = photos.user_id").
+		Where("photos.id IN ?", ids).
+		Scan(&owners).Error; err != nil {
+		logger.Printf("Failed to look up duplicate photos: %v", err)
+		return nil
+	}
+	byID := map[uint]int{}
+	for i, owner := range owners {
+		byID[owner.ID] = i
+	}
+
+	var duplicate *photoscreen.Finding
+	for _, match := range matches {
+		i, ok := byID[match.ID]
+		if !ok {
+			continue
+		}
+		owner := owners[i]
+		// Re-uploading your own photo isn't suspicious
+		if owner.UserID != nil && input.UserID != 0 && *owner.UserID == input.UserID {
+			continue
+		}
+		if owner.AccountStatus == models.AccountBanned {
+			logger.Printf("Blocked photo upload by user %d: matches photo %d of a banned account", input.UserID, match.ID)
+			return []photoscreen.Finding{{
+				Rule:    ruleBannedPhotoMatch,
+				Reason:  "This photo can't be used",
+				Verdict: photoscreen.VerdictReject,
+				MatchID: match.ID,
+			}}
+		}
+		if duplicate == nil {
+			duplicate = &photoscreen.Finding{
+				Rule:    ruleDuplicatePhoto,
+				Reason:  fmt.Sprintf("Looks like photo %d, uploaded by another account (distance %d)", match.ID, match.Distance),
+				Verdict: photoscreen.VerdictReview,
+				MatchID: match.ID,
+			}
+		}
+	}
+	if duplicate == nil {
+		return nil
+	}
+	return []photoscreen.Finding{*duplicate}
+}
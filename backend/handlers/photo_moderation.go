@@ -0,0 +1,408 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"datingapp/photoscreen"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// photoScreening pre-screens every uploaded photo; screeners can be added with Register
+var photoScreening = newPhotoScreening()
+
+func newPhotoScreening() *photoscreen.Pipeline {
+	pipeline := photoscreen.NewPipelineFromEnv()
+	pipeline.Register(newDuplicatePhotoScreener())
+	return pipeline
+}
+
+// screenedUpload is the pre-screen outcome of an uploaded file
+type screenedUpload struct {
+	photoscreen.Result
+	Hash string // Perceptual hash, empty when the image couldn't be decoded
+}
+
+// errPhotoNotYours is returned when a profile lists a photo another account uploaded
+var errPhotoNotYours = errors.New("you can only use photos you uploaded")
+
+// screenUpload hashes an uploaded file and runs it through the pre-screen before it is stored
+func screenUpload(file *multipart.FileHeader, userID uint) (screenedUpload, error) {
+	src, err := file.Open()
+	if err != nil {
+		return screenedUpload{}, err
+	}
+	defer src.Close()
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return screenedUpload{}, err
+	}
+	input := photoscreen.ImageInput(data, file.Header.Get("Content-Type"))
+	input.UserID = userID
+	screened := screenedUpload{Result: photoScreening.Evaluate(input)}
+	if input.Hashed {
+		screened.Hash = input.Hash.String()
+	}
+	return screened, nil
+}
+
+// newPhoto builds the moderation record of a stored photo from its pre-screen result
+func newPhoto(url string, userID *uint, result photoscreen.Result, now time.Time) models.Photo {
+	photo := models.Photo{URL: url, UserID: userID, Status: models.PhotoPending}
+	for _, finding := range result.Findings {
+		photo.Findings = append(photo.Findings, models.FlagFinding{
+			Rule:    finding.Rule,
+			Reason:  finding.Reason,
+			Action:  string(finding.Verdict),
+			MatchID: finding.MatchID,
+		})
+		if finding.Rule == ruleDuplicatePhoto && photo.DuplicateOfID == nil {
+			matchID := finding.MatchID
+			photo.DuplicateOfID = &matchID
+		}
+	}
+	if result.Verdict == photoscreen.VerdictApprove {
+		photo.Status = models.PhotoApproved
+		photo.ReviewedAt = &now
+	}
+	return photo
+}
+
+// recordUploadedPhoto stores the moderation record and hash of a photo that was just uploaded
+func recordUploadedPhoto(db *gorm.DB, url string, userID *uint, screened screenedUpload) (models.Photo, error) {
+	photo := newPhoto(url, userID, screened.Result, time.Now())
+	photo.Hash = screened.Hash
+	return photo, db.Create(&photo).Error
+}
+
+// rejectedUploadMessage tells the uploader why the pre-screen refused a file
+func rejectedUploadMessage(filename string, screened screenedUpload) string {
+	return fmt.Sprintf("Photo %s was rejected: %s", filename, screened.Reason())
+}
+
+// claimPhotos records the photos a profile lists as the user's. Photos uploaded during
+// registration are claimed by the account that lists them first; URLs that were never uploaded
+// here are screened by URL alone. It then refreshes the photos other users see.
+func claimPhotos(tx *gorm.DB, user *models.User, now time.Time) error {
+	urls := append([]string{}, user.Photos...)
+	if user.ProfilePictureURL != "" {
+		urls = append(urls, user.ProfilePictureURL)
+	}
+	if len(urls) > 0 {
+		var known []models.Photo
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("url IN ?", urls).Find(&known).Error; err != nil {
+			return err
+		}
+		seen := map[string]bool{}
+		for _, photo := range known {
+			seen[photo.URL] = true
+			switch {
+			case photo.UserID == nil:
+				if err := tx.Model(&photo).Update("user_id", user.ID).Error; err != nil {
+					return err
+				}
+			case *photo.UserID != user.ID:
+				return errPhotoNotYours
+			}
+		}
+		for _, url := range urls {
+			if seen[url] {
+				continue
+			}
+			seen[url] = true
+			photo := newPhoto(url, &user.ID, photoScreening.Evaluate(photoscreen.Input{UserID: user.ID, URL: url}), now)
+			if err := tx.Create(&photo).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return syncApprovedPhotos(tx, user)
+}
+
+// syncApprovedPhotos copies the user's approved photos to the columns other users read
+func syncApprovedPhotos(tx *gorm.DB, user *models.User) error {
+	statuses := map[string]models.PhotoStatus{}
+	if len(user.Photos) > 0 || user.ProfilePictureURL != "" {
+		var photos []models.Photo
+		if err := tx.Select("url", "status").Where("url IN ?", append([]string{user.ProfilePictureURL}, user.Photos...)).
+			Where("user_id = ?", user.ID).Find(&photos).Error; err != nil {
+			return err
+		}
+		for _, photo := range photos {
+			statuses[photo.URL] = photo.Status
+		}
+	}
+	user.SyncApprovedPhotos(statuses)
+	return tx.Model(user).Select("approved_photos", "approved_picture_url").Updates(user).Error
+}
+
+// photoReviews returns the moderation state of each of the user's own photos, for their profile
+func photoReviews(db *gorm.DB, user models.User) ([]models.PhotoReview, error) {
+	reviews := []models.PhotoReview{}
+	if len(user.Photos) == 0 {
+		return reviews, nil
+	}
+	var photos []models.Photo
+	if err := db.Where("url IN ? AND user_id = ?", user.Photos, user.ID).Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	byURL := map[string]models.Photo{}
+	for _, photo := range photos {
+		byURL[photo.URL] = photo
+	}
+	for _, url := range user.Photos {
+		review := models.PhotoReview{URL: url, Status: models.PhotoPending}
+		if photo, ok := byURL[url]; ok {
+			review.Status = photo.Status
+			review.RejectionReason = photo.RejectionReason
+		}
+		reviews = append(reviews, review)
+	}
+	return reviews, nil
+}
+
+// BackfillPhotoModeration approves the photos of accounts created before photo moderation, so
+// they stay visible. It runs once; photos added later always wait for a moderator.
+func BackfillPhotoModeration(db *gorm.DB) error {
+	return database.RunOnce(db, "photo_moderation_backfill", backfillPhotoModeration)
+}
+
+func backfillPhotoModeration(tx *gorm.DB) error {
+	// Accounts with any photo record already went through moderation, even if nothing of theirs
+	// has been approved yet
+	var users []models.User
+	return tx.Select("id", "photos", "profile_picture_url").
+		Where("approved_photos IS NULL AND NOT EXISTS (SELECT 1 FROM photos WHERE photos.user_id = users.id)").
+		FindInBatches(&users, 200, func(*gorm.DB, int) error {
+			now := time.Now()
+			for i := range users {
+				user := &users[i]
+				for _, url := range append([]string{user.ProfilePictureURL}, user.Photos...) {
+					if url == "" {
+						continue
+					}
+					photo := models.Photo{URL: url, UserID: &user.ID, Status: models.PhotoApproved, ReviewedAt: &now}
+					if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&photo).Error; err != nil {
+						return err
+					}
+				}
+				if err := syncApprovedPhotos(tx, user); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// photoRejectedNotification tells the owner a moderator rejected their photo and why, in their
+// language. It returns nil if the owner's account is gone.
+func photoRejectedNotification(tx *gorm.DB, photo models.Photo) (*pendingNotification, error) {
+	var owner models.User
+	if err := tx.Select("id", "locale").First(&owner, *photo.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newCatalogNotification(owner.ID, owner.Locale, models.PhotoData{
+		PhotoID: photo.ID,
+		Status:  photo.Status,
+		Reason:  photo.RejectionReason,
+		Action:  "edit_photos",
+		NotificationText: models.NotificationText{
+			TitleKey:   "photo.notification.title",
+			MessageKey: "photo.notification.rejected",
+			Vars:       map[string]string{"reason": photo.RejectionReason},
+		},
+	})
+}
+
+// GetPhotoQueue lists photos for moderator review (admin only)
+// @Summary Photo review queue (Admin)
+// @Description List uploaded photos by moderation status, oldest first, with what the automatic pre-screen found
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status (pending, approved, rejected, all)" default(pending)
+// @Param user_id query int false "Only photos of this user"
+// @Param duplicates query bool false "Only photos that look like another account's photo"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/photos [get]
+func GetPhotoQueue(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.Photo{})
+	if status := c.DefaultQuery("status", string(models.PhotoPending)); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if value := c.Query("user_id"); value != "" {
+		userID, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid user_id")
+			return
+		}
+		query = query.Where("user_id = ?", userID)
+	}
+	if c.Query("duplicates") == "true" {
+		query = query.Where("duplicate_of_id IS NOT NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve photos"})
+		return
+	}
+
+	var photos []models.Photo
+	if err := query.Order("created_at ASC").Limit(limit).Offset(offset).Find(&photos).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve photos"})
+		return
+	}
+	if !recordAdminView(c, "photos.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"photos": photos,
+		"total":  total,
+	})
+}
+
+// ReviewPhoto records a moderator decision on a photo (admin only)
+// @Summary Review a photo (Admin)
+// @Description Approve or reject a photo. Approved photos are shown to other users; rejecting one hides it again and tells the owner the reason.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Photo ID"
+// @Param review body models.ReviewPhotoRequest true "Moderator decision"
+// @Success 200 {object} models.Photo
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/photos/{id} [put]
+func ReviewPhoto(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	photoID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid photo ID")
+		return
+	}
+
+	var req models.ReviewPhotoRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	status := models.PhotoApproved
+	if req.Decision == "reject" {
+		status = models.PhotoRejected
+		if req.Reason == "" {
+			respondWithError(c, http.StatusBadRequest, "A reason is required to reject a photo")
+			return
+		}
+	}
+
+	var photo models.Photo
+	notify := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&photo, photoID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				respondWithError(c, http.StatusNotFound, "Photo not found")
+				return errResponded
+			}
+			return err
+		}
+		if photo.Status == status {
+			respondWithError(c, http.StatusBadRequest, "Photo is already "+string(status))
+			return errResponded
+		}
+
+		original := photo
+		now := time.Now()
+		photo.Status = status
+		photo.RejectionReason = ""
+		if status == models.PhotoRejected {
+			photo.RejectionReason = req.Reason
+		}
+		photo.ReviewedBy = &adminID
+		photo.ReviewedAt = &now
+		if err := tx.Save(&photo).Error; err != nil {
+			return err
+		}
+
+		if photo.UserID != nil {
+			var owner models.User
+			if err := tx.Select("id", "photos", "profile_picture_url").First(&owner, *photo.UserID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			} else if err == nil {
+				if err := syncApprovedPhotos(tx, &owner); err != nil {
+					return err
+				}
+			}
+			// Taking down an approved photo changes the set a verified badge was checked against
+			if original.Status == models.PhotoApproved {
+				if err := clearVerification(tx, *photo.UserID); err != nil {
+					return err
+				}
+			}
+			if status == models.PhotoRejected {
+				notification, err := photoRejectedNotification(tx, photo)
+				if err != nil {
+					return err
+				}
+				if notification != nil {
+					if err := enqueueNotification(tx, *notification); err != nil {
+						return err
+					}
+					notify = true
+				}
+			}
+		}
+		return recordAdminAction(c, tx, "photo.review", "photo", photo.ID, original, photo)
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to review photo %d: %v", photoID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to review photo")
+		return
+	}
+
+	if notify {
+		notifyOutbox()
+	}
+
+	logger.Printf("Admin %d reviewed photo %d: %s", adminID, photo.ID, photo.Status)
+	c.JSON(http.StatusOK, photo)
+}
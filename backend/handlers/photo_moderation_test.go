@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"datingapp/imagehash"
+	"datingapp/middleware"
+	"datingapp/models"
+	"datingapp/photoscreen"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhotoModeration(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.GET("/admin/photos", middleware.AuthMiddleware(), GetPhotoQueue)
+	router.PUT("/admin/photos/:id", middleware.AuthMiddleware(), ReviewPhoto)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123", DateOfBirth: "1990-01-01",
+		Gender: "Male", InterestedIn: "Female", LookingFor: "Relationship"}
+	for _, user := range []*models.User{&admin, &bob} {
+		db.Create(user)
+	}
+	// A photo uploaded during registration, waiting to be claimed
+	db.Create(&models.Photo{URL: "https://cdn.example.com/alice-1.jpg", Status: models.PhotoPending})
+
+	w := performRequest(router, "POST", "/register", `{"firstName":"Alice","email":"alice@example.com","password":"password123","dateOfBirth":"1995-01-01",
+		"gender":"Female","interestedIn":"Male","lookingFor":"Relationship","interests":["Hiking"],
+		"photos":["https://cdn.example.com/alice-1.jpg","https://cdn.example.com/alice-2.jpg"]}`, 0)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var alice models.User
+	db.Where("email = ?", "alice@example.com").First(&alice)
+	assert.Empty(t, alice.ApprovedPhotos)
+	assert.Empty(t, alice.ApprovedPictureURL)
+
+	// Both photos are Alice's now and wait in the queue
+	w = performRequest(router, "GET", "/admin/photos", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/admin/photos", TestResult{
+		TestName: "Photo Review Queue",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var queue struct {
+		Photos []models.Photo `json:"photos"`
+		Total  int64          `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &queue))
+	if assert.Len(t, queue.Photos, 2) {
+		for _, photo := range queue.Photos {
+			if assert.NotNil(t, photo.UserID) {
+				assert.Equal(t, alice.ID, *photo.UserID)
+			}
+		}
+	}
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/admin/photos", "", bob.ID).Code)
+
+	// Bob can't put Alice's photo on his profile
+	w = performRequest(router, "PUT", "/profile/"+strconv.Itoa(int(bob.ID)), `{"photos":["https://cdn.example.com/alice-1.jpg"]}`, bob.ID)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Until a moderator approves a photo, Bob doesn't see it in discovery
+	match := func() map[string]interface{} {
+		w := performRequest(router, "GET", "/matches/"+strconv.Itoa(int(bob.ID)), "", bob.ID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var matches []map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &matches)
+		for _, m := range matches {
+			if uint(m["id"].(float64)) == alice.ID {
+				return m
+			}
+		}
+		return nil
+	}
+	if m := match(); assert.NotNil(t, m) {
+		assert.Equal(t, "", m["profilePictureURL"])
+		assert.Empty(t, m["photos"])
+	}
+
+	first, second := queue.Photos[0], queue.Photos[1]
+	path := func(photo models.Photo) string { return "/admin/photos/" + strconv.Itoa(int(photo.ID)) }
+	assert.Equal(t, http.StatusOK, performRequest(router, "PUT", path(second), `{"decision":"approve"}`, admin.ID).Code)
+	if m := match(); assert.NotNil(t, m) {
+		assert.Equal(t, second.URL, m["profilePictureURL"])
+		assert.Equal(t, []interface{}{second.URL}, m["photos"])
+	}
+
+	// Rejecting needs a reason, which the owner is told
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PUT", path(first), `{"decision":"reject"}`, admin.ID).Code)
+	w = performRequest(router, "PUT", path(first), `{"decision":"reject","reason":"Group photo; we can't tell which one is you"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PUT", path(first), `{"decision":"reject","reason":"again"}`, admin.ID).Code)
+
+	drainOutbox(t, db, time.Now())
+	var notifications []models.Notification
+	db.Where("user_id = ? AND type = ?", alice.ID, models.NotificationTypePhoto).Find(&notifications)
+	if assert.Len(t, notifications, 1) {
+		assert.Contains(t, notifications[0].Message, "Group photo; we can't tell which one is you")
+	}
+
+	// Alice still sees every photo on her own profile, with its status
+	w = performRequest(router, "GET", "/profile/"+strconv.Itoa(int(alice.ID)), "", alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var profile struct {
+		Photos       []string             `json:"photos"`
+		PhotoReviews []models.PhotoReview `json:"photoReviews"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &profile)
+	assert.Len(t, profile.Photos, 2)
+	if assert.Len(t, profile.PhotoReviews, 2) {
+		assert.Equal(t, models.PhotoRejected, profile.PhotoReviews[0].Status)
+		assert.Equal(t, "Group photo; we can't tell which one is you", profile.PhotoReviews[0].RejectionReason)
+		assert.Equal(t, models.PhotoApproved, profile.PhotoReviews[1].Status)
+	}
+
+	var audit int64
+	db.Model(&models.AdminAuditLog{}).Where("action = ?", "photo.review").Count(&audit)
+	assert.Equal(t, int64(2), audit)
+}
+
+func TestBackfillPhotoModeration(t *testing.T) {
+	db := setupTestDB()
+	setupRouter(db)
+
+	legacy := models.User{FirstName: "Legacy", Email: "legacy@example.com", Password: "password123", Photos: []string{"https://cdn.example.com/legacy.jpg"}}
+	waiting := models.User{FirstName: "Waiting", Email: "waiting@example.com", Password: "password123", Photos: []string{"https://cdn.example.com/waiting.jpg"}}
+	for _, user := range []*models.User{&legacy, &waiting} {
+		db.Create(user)
+	}
+	db.Create(&models.Photo{UserID: &waiting.ID, URL: "https://cdn.example.com/waiting.jpg", Status: models.PhotoPending})
+
+	assert.NoError(t, BackfillPhotoModeration(db))
+	db.First(&legacy, legacy.ID)
+	assert.Equal(t, []string{"https://cdn.example.com/legacy.jpg"}, legacy.ApprovedPhotos)
+	db.First(&waiting, waiting.ID)
+	assert.Empty(t, waiting.ApprovedPhotos, "photos already in moderation stay pending")
+
+	// Later starts don't approve anything
+	late := models.User{FirstName: "Late", Email: "late@example.com", Password: "password123", Photos: []string{"https://cdn.example.com/late.jpg"}}
+	db.Create(&late)
+	assert.NoError(t, BackfillPhotoModeration(db))
+	db.First(&late, late.ID)
+	assert.Empty(t, late.ApprovedPhotos)
+	var photos int64
+	db.Model(&models.Photo{}).Where("user_id = ?", late.ID).Count(&photos)
+	assert.Zero(t, photos)
+}
+
+func TestUnitNewPhotoFromPreScreen(t *testing.T) {
+	now := time.Now()
+	userID := uint(4)
+
+	photo := newPhoto("https://cdn.example.com/a.jpg", &userID, photoscreen.Result{Verdict: photoscreen.VerdictApprove}, now)
+	assert.Equal(t, models.PhotoApproved, photo.Status)
+	assert.Equal(t, &now, photo.ReviewedAt)
+	assert.Nil(t, photo.ReviewedBy)
+
+	photo = newPhoto("https://cdn.example.com/b.jpg", nil, photoscreen.Result{
+		Verdict:  photoscreen.VerdictReview,
+		Findings: []photoscreen.Finding{{Rule: photoscreen.RuleFormat, Reason: "Image format couldn't be read", Verdict: photoscreen.VerdictReview}},
+	}, now)
+	assert.Equal(t, models.PhotoPending, photo.Status)
+	assert.Nil(t, photo.ReviewedAt)
+	assert.Equal(t, []models.FlagFinding{{Rule: photoscreen.RuleFormat, Reason: "Image format couldn't be read", Action: "review"}}, photo.Findings)
+}
+
+func TestPhotoDuplicateScreening(t *testing.T) {
+	db := setupTestDB()
+	setupRouter(db)
+	photoHashes = newPhotoHashIndex()
+
+	banned := models.User{FirstName: "Mallory", Email: "mallory@example.com", Password: "password123", AccountStatus: models.AccountBanned}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123"}
+	for _, user := range []*models.User{&banned, &bob, &carol} {
+		db.Create(user)
+	}
+	bannedHash, bobHash := imagehash.Hash(0xf0f0f0f0f0f0f0f0), imagehash.Hash(0x123456789abcdef0)
+	bannedPhoto := models.Photo{URL: "https://cdn.example.com/m.jpg", UserID: &banned.ID, Status: models.PhotoApproved, Hash: bannedHash.String()}
+	bobPhoto := models.Photo{URL: "https://cdn.example.com/b.jpg", UserID: &bob.ID, Status: models.PhotoApproved, Hash: bobHash.String()}
+	db.Create(&bannedPhoto)
+	db.Create(&bobPhoto)
+
+	screener := &duplicatePhotoScreener{maxDistance: 6}
+	input := func(userID uint, hash imagehash.Hash) photoscreen.Input {
+		return photoscreen.Input{UserID: userID, Hash: hash, Hashed: true}
+	}
+
+	// A slightly altered copy of the banned account's photo is blocked, even at registration
+	findings := screener.Screen(input(0, bannedHash^0b101))
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, photoscreen.VerdictReject, findings[0].Verdict)
+		assert.Equal(t, bannedPhoto.ID, findings[0].MatchID)
+		assert.NotContains(t, findings[0].Reason, "banned", "the uploader isn't told whose photo it was")
+	}
+
+	// A copy of another account's photo goes to the review queue with the match
+	findings = screener.Screen(input(carol.ID, bobHash^0b1))
+	if assert.Len(t, findings, 1) {
+		assert.Equal(t, photoscreen.VerdictReview, findings[0].Verdict)
+		assert.Equal(t, ruleDuplicatePhoto, findings[0].Rule)
+	}
+	photo := newPhoto("https://cdn.example.com/c.jpg", &carol.ID, photoScreening.Evaluate(input(carol.ID, bobHash^0b1)), time.Now())
+	assert.Equal(t, models.PhotoPending, photo.Status)
+	if assert.NotNil(t, photo.DuplicateOfID) {
+		assert.Equal(t, bobPhoto.ID, *photo.DuplicateOfID)
+	}
+
+	// Re-uploading your own photo and unrelated photos pass
+	assert.Empty(t, screener.Screen(input(bob.ID, bobHash)))
+	assert.Empty(t, screener.Screen(input(carol.ID, ^bobHash)))
+	assert.Empty(t, screener.Screen(photoscreen.Input{UserID: carol.ID}))
+
+	// Photos stored after the index was built are found too
+	carolHash := imagehash.Hash(0x0fedcba987654321)
+	carolPhoto := models.Photo{URL: "https://cdn.example.com/c2.jpg", UserID: &carol.ID, Status: models.PhotoPending, Hash: carolHash.String()}
+	carolPhoto.ID = 1000
+	db.Create(&carolPhoto)
+	assert.Len(t, screener.Screen(input(bob.ID, carolHash)), 1)
+
+	// So are photos with a lower ID that commit after a later one was indexed
+	lateHash := imagehash.Hash(0x5a5a5a5a0f0f0f0f)
+	late := models.Photo{URL: "https://cdn.example.com/c3.jpg", UserID: &carol.ID, Status: models.PhotoPending, Hash: lateHash.String(), CreatedAt: time.Now().Add(-time.Minute)}
+	db.Create(&late)
+	assert.Less(t, late.ID, carolPhoto.ID)
+	assert.Len(t, screener.Screen(input(bob.ID, lateHash)), 1)
+
+	// Flat images hash to nearly all zeros and don't match each other
+	db.Create(&models.Photo{URL: "https://cdn.example.com/flat.jpg", UserID: &carol.ID, Status: models.PhotoApproved, Hash: imagehash.Hash(0).String()})
+	assert.Empty(t, screener.Screen(input(bob.ID, 0b1)))
+}
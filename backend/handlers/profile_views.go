@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// profileViewNotifyInterval is the minimum time between profile-view notifications to one user.
+	// Views in between still show up in GET /profile/views.
+	profileViewNotifyInterval = time.Hour
+	// profileViewsPeriod is how far back the "who viewed me" list goes
+	profileViewsPeriod = 30 * 24 * time.Hour
+)
+
+// recordProfileView stores a view, de-duplicated per viewer per day. It returns the day's row
+// when this was the viewer's first view of the profile that day, or nil for a repeat.
+func recordProfileView(tx *gorm.DB, viewerID, viewedID uint, now time.Time) (*models.ProfileView, error) {
+	view := models.ProfileView{
+		ViewedID: viewedID,
+		ViewerID: viewerID,
+		Day:      models.ProfileViewDay(now),
+		Count:    1,
+		ViewedAt: now,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&view)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &view, nil
+	}
+
+	err := tx.Model(&models.ProfileView{}).
+		Where("viewed_id = ? AND viewer_id = ? AND day = ?", viewedID, viewerID, view.Day).
+		Updates(map[string]interface{}{"count": gorm.Expr("count + 1"), "viewed_at": now}).Error
+	return nil, err
+}
+
+// profileViewNotificationDue reports whether a user can be sent another profile-view notification.
+// It looks at views rather than notifications, so events still waiting in the outbox count too.
+func profileViewNotificationDue(tx *gorm.DB, viewedID uint, now time.Time) (bool, error) {
+	var recent int64
+	err := tx.Model(&models.ProfileView{}).
+		Where("viewed_id = ? AND notified = ? AND created_at > ?", viewedID, true, now.Add(-profileViewNotifyInterval)).
+		Count(&recent).Error
+	return recent == 0, err
+}
+
+// GetProfileViews lists the users who recently viewed the authenticated user's profile
+// @Summary Who viewed my profile
+// @Description List users who viewed your profile in the last 30 days, most recent first. Viewers browsing in incognito mode and users you've blocked are not shown.
+// @Tags settings
+// @Produce json
+// @Security ApiKeyAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} models.GetProfileViewsResponse
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /profile/views [get]
+func GetProfileViews(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "blocked_users").First(&user, userID).Error; err != nil {
+		respondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Table("profile_views").
+		Joins("JOIN users ON users.id = profile_views.viewer_id AND users.deleted_at IS NULL").
+		Where("profile_views.viewed_id = ? AND profile_views.viewed_at > ?", userID, time.Now().Add(-profileViewsPeriod))
+	if len(user.BlockedUsers) > 0 {
+		query = query.Where("profile_views.viewer_id NOT IN ?", user.BlockedUsers)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Distinct("profile_views.viewer_id").Count(&total).Error; err != nil {
+		logger.Printf("Failed to count profile views for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve profile views")
+		return
+	}
+
+	var rows []struct {
+		ViewerID          uint
+		FirstName         string
+		ProfilePictureURL string
+		ViewedAt          time.Time
+		Days              int
+	}
+	err := query.
+		Select("profile_views.viewer_id, users.first_name, users.approved_picture_url AS profile_picture_url, MAX(profile_views.viewed_at) AS viewed_at, COUNT(*) AS days").
+		Group("profile_views.viewer_id, users.first_name, users.approved_picture_url").
+		Order("viewed_at DESC").
+		Limit(limit).Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		logger.Printf("Failed to retrieve profile views for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve profile views")
+		return
+	}
+
+	response := models.GetProfileViewsResponse{
+		Viewers: make([]models.ProfileViewerResponse, 0, len(rows)),
+		Total:   total,
+	}
+	for _, row := range rows {
+		response.Viewers = append(response.Viewers, models.ProfileViewerResponse{
+			Viewer: models.UserBasicInfo{
+				ID:                row.ViewerID,
+				FirstName:         row.FirstName,
+				ProfilePictureURL: row.ProfilePictureURL,
+			},
+			ViewedAt: row.ViewedAt,
+			Days:     row.Days,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
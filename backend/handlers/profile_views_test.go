@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileViews(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/profile/:user_id/view", middleware.AuthMiddleware(), IncrementProfileViews)
+	router.GET("/profile/views", middleware.AuthMiddleware(), GetProfileViews)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123"}
+	ghost := models.User{FirstName: "Ghost", Email: "ghost@example.com", Password: "password123",
+		PrivacySettings: models.PrivacySettings{Incognito: true}}
+	shadow := models.User{FirstName: "Shadow", Email: "shadow@example.com", Password: "password123", ShadowBanned: true}
+	for _, user := range []*models.User{&alice, &bob, &carol, &ghost, &shadow} {
+		db.Create(user)
+	}
+
+	viewProfile := func(viewer, viewed models.User) {
+		req, _ := http.NewRequest("POST", "/profile/"+strconv.Itoa(int(viewed.ID))+"/view", nil)
+		addAuthHeader(req, viewer.ID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	view := func(viewer models.User) { viewProfile(viewer, alice) }
+	view(bob)
+	view(bob) // Same day: de-duplicated
+	view(carol)
+	view(ghost)
+	view(shadow)
+
+	var views []models.ProfileView
+	db.Where("viewed_id = ?", alice.ID).Order("viewer_id").Find(&views)
+	if assert.Len(t, views, 2, "incognito and shadow-banned viewers are not recorded") {
+		assert.Equal(t, bob.ID, views[0].ViewerID)
+		assert.Equal(t, 2, views[0].Count)
+	}
+	var updated models.User
+	db.First(&updated, alice.ID)
+	assert.Equal(t, 2, updated.ProfileViews)
+
+	// Only the first view in the rate-limit interval notifies
+	now := time.Now()
+	drainOutbox(t, db, now)
+	var notifications []models.Notification
+	db.Where("user_id = ? AND type = ?", alice.ID, models.NotificationTypeView).Find(&notifications)
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, "Bob viewed your profile", notifications[0].Message)
+	}
+
+	// A shadow-banned viewer is neither counted nor notified, even as the only viewer
+	viewProfile(shadow, carol)
+	drainOutbox(t, db, now)
+	db.First(&updated, carol.ID)
+	assert.Equal(t, 0, updated.ProfileViews)
+	var shadowNotifications int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND type = ?", carol.ID, models.NotificationTypeView).Count(&shadowNotifications)
+	assert.Equal(t, int64(0), shadowNotifications)
+
+	req, _ := http.NewRequest("GET", "/profile/views", nil)
+	addAuthHeader(req, alice.ID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	writeTestResult("/profile/views", TestResult{
+		TestName: "Who Viewed My Profile",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var response models.GetProfileViewsResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, int64(2), response.Total)
+	if assert.Len(t, response.Viewers, 2) {
+		assert.Equal(t, "Carol", response.Viewers[0].Viewer.FirstName, "most recent viewer first")
+		assert.Equal(t, 1, response.Viewers[1].Days)
+	}
+}
+
+func TestUnitProfileViewDay(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	// 08:00 in Tokyo is still the previous day in UTC
+	assert.Equal(t, "2024-05-05", models.ProfileViewDay(time.Date(2024, 5, 6, 8, 0, 0, 0, tokyo)))
+	assert.Equal(t, "2024-05-06", models.ProfileViewDay(time.Date(2024, 5, 6, 23, 59, 0, 0, time.UTC)))
+}
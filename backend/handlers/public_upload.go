@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"datingapp/database"
+	"datingapp/photoscreen"
 	"datingapp/storage"
 	"fmt"
 	"net/http"
@@ -35,10 +37,9 @@ func PublicUploadPhotos(c *gin.Context) {
 		return
 	}
 
-	var uploadedUrls []string
-	folder := "dating_app/registration" // Photos will be organized in a temporary folder until user is registered
-
-	for _, file := range files {
+	// Validate and pre-screen every file before storing any of them
+	results := make([]screenedUpload, len(files))
+	for i, file := range files {
 		// Validate file size
 		if file.Size > maxUploadSize {
 			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("File %s is too large (max %d MB)", file.Filename, maxUploadSize/1024/1024))
@@ -52,6 +53,22 @@ func PublicUploadPhotos(c *gin.Context) {
 			return
 		}
 
+		result, err := screenUpload(file, 0)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Could not read %s", file.Filename))
+			return
+		}
+		if result.Verdict == photoscreen.VerdictReject {
+			respondWithError(c, http.StatusBadRequest, rejectedUploadMessage(file.Filename, result))
+			return
+		}
+		results[i] = result
+	}
+
+	var uploadedUrls []string
+	folder := "dating_app/registration" // Photos will be organized in a temporary folder until user is registered
+
+	for i, file := range files {
 		// Upload to Cloudinary
 		url, err := storage.UploadImage(file, folder)
 		if err != nil {
@@ -60,6 +77,13 @@ func PublicUploadPhotos(c *gin.Context) {
 			return
 		}
 
+		// Unowned until the account that registers with it claims it
+		if _, err := recordUploadedPhoto(database.DB, url, nil, results[i]); err != nil {
+			logger.Printf("Failed to record uploaded photo %s: %v", url, err)
+			respondWithError(c, http.StatusInternalServerError, "Failed to upload image")
+			return
+		}
+
 		uploadedUrls = append(uploadedUrls, url)
 		logger.Printf("Uploaded file: %s, URL: %s", file.Filename, url)
 	}
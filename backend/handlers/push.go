@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"datingapp/database"
+	"datingapp/models"
+	"datingapp/push"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// pushQueueSize bounds notifications waiting for push delivery; overflow is dropped, not blocked on
+	pushQueueSize = 1000
+	// pushTTL is how long push services hold a message for an offline device
+	pushTTL = 24 * time.Hour
+)
+
+var (
+	pushClient *push.Client
+	pushQueue  chan uint
+	pushRetry  = push.DefaultRetry
+)
+
+// pushMetrics counts notifications queued for push and those dropped because the queue was
+// full; it is published through expvar
+var pushMetrics = expvar.NewMap("push_delivery")
+
+// StartPushWorker enables Web Push delivery and starts workers that send queued notifications
+// until ctx is cancelled
+func StartPushWorker(ctx context.Context, client *push.Client, workers int) {
+	pushClient = client
+	pushQueue = make(chan uint, pushQueueSize)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case notificationID := <-pushQueue:
+					deliverPushNotification(ctx, database.DB, pushClient, notificationID)
+				}
+			}
+		}()
+	}
+	logger.Printf("Web Push delivery started with %d workers", workers)
+}
+
+// queuePushDelivery hands a stored notification to the push workers, if push is enabled
+func queuePushDelivery(notificationID uint) {
+	if pushQueue == nil {
+		return
+	}
+	select {
+	case pushQueue <- notificationID:
+		pushMetrics.Add("queued", 1)
+	default:
+		pushMetrics.Add("dropped", 1)
+		logger.Printf("Push queue full; notification %d will not be pushed (%s dropped so far)", notificationID, pushMetrics.Get("dropped"))
+	}
+}
+
+// pushPayload is what the service worker receives in its push event
+type pushPayload struct {
+	ID        uint                    `json:"id"`
+	Type      models.NotificationType `json:"type"`
+	Title     string                  `json:"title"`
+	Body      string                  `json:"body"`
+	Data      json.RawMessage         `json:"data,omitempty"`
+	CreatedAt time.Time               `json:"createdAt"`
+	GroupKey  string                  `json:"groupKey,omitempty"`
+	Count     int                     `json:"count"`
+}
+
+// deliverPushNotification sends a notification to every subscription of its recipient.
+// Subscriptions the push service reports as gone are deleted.
+func deliverPushNotification(ctx context.Context, db *gorm.DB, client *push.Client, notificationID uint) {
+	var notification models.Notification
+	if err := db.Preload("FromUser").First(&notification, notificationID).Error; err != nil {
+		logger.Printf("Push: failed to load notification %d: %v", notificationID, err)
+		return
+	}
+	var user models.User
+	if err := db.First(&user, notification.UserID).Error; err != nil {
+		logger.Printf("Push: failed to load user %d: %v", notification.UserID, err)
+		return
+	}
+	if !models.ShouldNotify(user, notification.Type, models.ChannelPush) {
+		return
+	}
+
+	var subscriptions []models.PushSubscription
+	if err := db.Where("user_id = ?", user.ID).Find(&subscriptions).Error; err != nil {
+		logger.Printf("Push: failed to load subscriptions for user %d: %v", user.ID, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+	localizeNotification(user.Locale, &notification)
+
+	payload := pushPayload{
+		ID:        notification.ID,
+		Type:      notification.Type,
+		Title:     notification.Title,
+		Body:      notification.Message,
+		CreatedAt: notification.CreatedAt,
+		GroupKey:  notification.GroupKey,
+		Count:     notification.Count,
+	}
+	payload.Data = models.NotificationDataJSON(notification.Type, notification.Data)
+	body, err := json.Marshal(payload)
+	if err != nil {
+		logger.Printf("Push: failed to encode notification %d: %v", notification.ID, err)
+		return
+	}
+
+	opts := push.Options{TTL: pushTTL, Urgency: push.UrgencyNormal}
+	if notification.GroupKey != "" {
+		// A grouped notification is pushed again on each update; replace any copy still pending
+		opts.Topic = fmt.Sprintf("notification-%d", notification.ID)
+	}
+	if notification.Type == models.NotificationTypeMessage || notification.Type == models.NotificationTypeMatch {
+		opts.Urgency = push.UrgencyHigh
+	}
+
+	for _, subscription := range subscriptions {
+		keys, err := push.ParseKeys(subscription.P256dh, subscription.Auth)
+		if err != nil {
+			logger.Printf("Push: deleting subscription %d with invalid keys: %v", subscription.ID, err)
+			db.Delete(&subscription)
+			continue
+		}
+
+		err = client.SendWithRetry(ctx, push.Subscription{Endpoint: subscription.Endpoint, Keys: keys}, body, opts, pushRetry)
+		switch {
+		case err == nil:
+			now := time.Now()
+			db.Model(&subscription).Updates(map[string]interface{}{"last_success_at": now, "failure_count": 0})
+		case push.IsGone(err):
+			logger.Printf("Push: subscription %d for user %d is gone; deleting", subscription.ID, user.ID)
+			db.Delete(&subscription)
+		default:
+			logger.Printf("Push: failed to deliver notification %d to subscription %d: %v", notification.ID, subscription.ID, err)
+			db.Model(&subscription).Update("failure_count", gorm.Expr("failure_count + 1"))
+		}
+	}
+}
+
+// GetVAPIDPublicKey returns the application server key browsers need to subscribe
+// @Summary Get the Web Push public key
+// @Description Returns the VAPID public key to pass as applicationServerKey to PushManager.subscribe
+// @Tags notifications
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 503 {object} map[string]string "Web Push is not configured"
+// @Router /push/vapid-public-key [get]
+func GetVAPIDPublicKey(c *gin.Context) {
+	if pushClient == nil {
+		respondWithError(c, http.StatusServiceUnavailable, "Push notifications are not enabled")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"publicKey": pushClient.VAPID.PublicKey})
+}
+
+// CreatePushSubscription registers a browser for Web Push
+// @Summary Register a push subscription
+// @Description Store a browser PushSubscription (endpoint and keys) for the authenticated user. Registering an existing endpoint updates it.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param subscription body models.CreatePushSubscriptionRequest true "PushSubscription.toJSON() output"
+// @Success 201 {object} models.PushSubscription
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /push/subscriptions [post]
+func CreatePushSubscription(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreatePushSubscriptionRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	if _, err := push.ParseKeys(req.Keys.P256dh, req.Keys.Auth); err != nil {
+		respondWithError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	subscription := models.PushSubscription{
+		UserID:    userID,
+		Endpoint:  req.Endpoint,
+		P256dh:    req.Keys.P256dh,
+		Auth:      req.Keys.Auth,
+		UserAgent: c.Request.UserAgent(),
+	}
+	if req.ExpirationTime != nil {
+		expiresAt := time.UnixMilli(*req.ExpirationTime)
+		subscription.ExpiresAt = &expiresAt
+	}
+
+	// A device that re-subscribes, or is now signed in as someone else, keeps its endpoint
+	err := database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "user_agent", "expires_at", "updated_at"}),
+	}).Create(&subscription).Error
+	if err != nil {
+		logger.Printf("Failed to save push subscription for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to save push subscription")
+		return
+	}
+
+	database.DB.Where("endpoint = ?", req.Endpoint).First(&subscription)
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// DeletePushSubscription unregisters a browser, e.g. on logout
+// @Summary Remove a push subscription
+// @Description Delete the authenticated user's push subscription for an endpoint
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param subscription body models.DeletePushSubscriptionRequest true "Endpoint to remove"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /push/subscriptions [delete]
+func DeletePushSubscription(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.DeletePushSubscriptionRequest
+	if !validateInput(c, &req) {
+		return
+	}
+
+	result := database.DB.Where("user_id = ? AND endpoint = ?", userID, req.Endpoint).Delete(&models.PushSubscription{})
+	if result.Error != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to remove push subscription")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondWithError(c, http.StatusNotFound, "Push subscription not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push subscription removed"})
+}
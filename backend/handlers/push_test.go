@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"datingapp/middleware"
+	"datingapp/models"
+	"datingapp/push"
+	"encoding/base64"
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBrowser is a push subscription backed by a local fake push service
+type fakeBrowser struct {
+	key      *ecdh.PrivateKey
+	auth     []byte
+	status   int
+	mu       sync.Mutex
+	received [][]byte
+}
+
+func newFakeBrowser(t *testing.T, server *httptest.Server, path string, status int) (*fakeBrowser, models.PushSubscription) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+
+	browser := &fakeBrowser{key: key, auth: auth, status: status}
+	return browser, models.PushSubscription{
+		Endpoint: server.URL + path,
+		P256dh:   base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(auth),
+	}
+}
+
+func TestPushDelivery(t *testing.T) {
+	db := setupTestDB()
+	setupRouter(db)
+
+	browsers := map[string]*fakeBrowser{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		browser := browsers[r.URL.Path]
+		body, _ := io.ReadAll(r.Body)
+		plaintext, err := push.Decrypt(browser.key, browser.auth, body)
+		assert.NoError(t, err)
+		browser.mu.Lock()
+		browser.received = append(browser.received, plaintext)
+		browser.mu.Unlock()
+		w.WriteHeader(browser.status)
+	}))
+	defer server.Close()
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	laptop, laptopSub := newFakeBrowser(t, server, "/laptop", http.StatusCreated)
+	phone, phoneSub := newFakeBrowser(t, server, "/phone", http.StatusGone)
+	browsers["/laptop"], browsers["/phone"] = laptop, phone
+	laptopSub.UserID, phoneSub.UserID = alice.ID, alice.ID
+	db.Create(&laptopSub)
+	db.Create(&phoneSub)
+
+	notification := models.Notification{
+		UserID:     alice.ID,
+		FromUserID: &bob.ID,
+		Type:       models.NotificationTypeMatch,
+		Title:      "New Match! 💕",
+		Message:    "You matched with Bob! Start chatting now.",
+		Data:       `{"matchedUserId": 2, "action": "view_match"}`,
+	}
+	db.Create(&notification)
+
+	_, privateKey, _ := push.GenerateVAPIDKeys()
+	vapid, err := push.NewVAPID("mailto:push@campuscupid.test", privateKey)
+	assert.NoError(t, err)
+	defer func(retry push.Retry) { pushRetry = retry }(pushRetry)
+	pushRetry = push.Retry{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	deliverPushNotification(context.Background(), db, push.NewClient(vapid), notification.ID)
+
+	// The laptop got the decrypted notification
+	if assert.Len(t, laptop.received, 1) {
+		var payload pushPayload
+		assert.NoError(t, json.Unmarshal(laptop.received[0], &payload))
+		assert.Equal(t, notification.ID, payload.ID)
+		assert.Equal(t, "New Match! 💕", payload.Title)
+		assert.JSONEq(t, notification.Data, string(payload.Data))
+	}
+
+	// The phone's subscription was gone, so it was pruned without retrying
+	assert.Len(t, phone.received, 1)
+	var remaining []models.PushSubscription
+	db.Where("user_id = ?", alice.ID).Find(&remaining)
+	if assert.Len(t, remaining, 1) {
+		assert.Equal(t, laptopSub.Endpoint, remaining[0].Endpoint)
+		assert.NotNil(t, remaining[0].LastSuccessAt)
+	}
+
+	// Users who turned push off for the type get nothing
+	alice.NotificationSettings = models.DefaultNotificationSettings()
+	alice.NotificationSettings.Types[models.NotificationTypeMatch] = models.ChannelPreferences{InApp: true}
+	db.Save(&alice)
+	deliverPushNotification(context.Background(), db, push.NewClient(vapid), notification.ID)
+	assert.Len(t, laptop.received, 1)
+}
+
+func TestCreatePushSubscription(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/push/subscriptions", middleware.AuthMiddleware(), CreatePushSubscription)
+
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	db.Create(&alice)
+	db.Create(&bob)
+
+	key, _ := ecdh.P256().GenerateKey(rand.Reader)
+	body, _ := json.Marshal(map[string]interface{}{
+		"endpoint":       "https://push.example.com/send/abc",
+		"expirationTime": nil,
+		"keys": map[string]string{
+			"p256dh": base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+			"auth":   base64.RawURLEncoding.EncodeToString(make([]byte, 16)),
+		},
+	})
+
+	subscribe := func(userID uint, body []byte) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/push/subscriptions", bytes.NewBuffer(body))
+		addAuthHeader(req, userID)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := subscribe(alice.ID, body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "p256dh", "keys are never echoed back")
+
+	writeTestResult("/push/subscriptions", TestResult{
+		TestName: "Register Push Subscription",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	// The same device signing in as Bob moves the subscription instead of duplicating it
+	w = subscribe(bob.ID, body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var subscriptions []models.PushSubscription
+	db.Find(&subscriptions)
+	if assert.Len(t, subscriptions, 1) {
+		assert.Equal(t, bob.ID, subscriptions[0].UserID)
+	}
+
+	// Malformed keys are rejected
+	w = subscribe(alice.ID, []byte(`{"endpoint":"https://push.example.com/x","keys":{"p256dh":"bad","auth":"bad"}}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUnitPushQueueCountsDrops(t *testing.T) {
+	previous := pushQueue
+	pushQueue = make(chan uint, 1)
+	defer func() { pushQueue = previous }()
+
+	dropped := func() int64 {
+		if counter, ok := pushMetrics.Get("dropped").(*expvar.Int); ok {
+			return counter.Value()
+		}
+		return 0
+	}
+	before := dropped()
+
+	queuePushDelivery(1)
+	queuePushDelivery(2)
+	assert.Equal(t, before+1, dropped())
+	assert.Equal(t, uint(1), <-pushQueue)
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MarkMessagesRead marks messages from a conversation partner as read
+// @Summary Mark messages as read
+// @Description Mark every message received from a partner up to and including up_to_message_id as read
+// @Tags messaging
+// @Accept json
+// @Produce json
+// @Param request body models.MarkMessagesReadRequest true "Partner and last read message ID"
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /messages/read [post]
+func MarkMessagesRead(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var req models.MarkMessagesReadRequest
+	if !validateInput(c, &req) {
+		return
+	}
+
+	// The up-to message must belong to this conversation
+	var upTo models.Message
+	if err := database.DB.Where(
+		"id = ? AND ((sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?))",
+		req.UpToMessageID, req.PartnerID, userID, userID, req.PartnerID,
+	).First(&upTo).Error; err != nil {
+		respondWithError(c, http.StatusForbidden, "Message does not belong to this conversation")
+		return
+	}
+
+	now := time.Now()
+	result := database.DB.Model(&models.Message{}).
+		Where("sender_id = ? AND receiver_id = ? AND id <= ? AND read = ? AND shadowed = ?", req.PartnerID, userID, req.UpToMessageID, false, false).
+		Updates(map[string]interface{}{
+			"read":         true,
+			"read_at":      now,
+			"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now),
+		})
+	if result.Error != nil {
+		logger.Printf("Failed to mark messages read for user %d from %d: %v", userID, req.PartnerID, result.Error)
+		respondWithError(c, http.StatusInternalServerError, "Failed to mark messages as read")
+		return
+	}
+
+	// Reading the conversation clears a manual unread marker
+	database.DB.Model(&models.ConversationState{}).
+		Where("user_id = ? AND partner_id = ? AND manually_unread = ?", userID, req.PartnerID, true).
+		Update("manually_unread", false)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Messages marked as read",
+		"updated": result.RowsAffected,
+	})
+}
+
+// markMessagesDelivered stamps delivered_at on undelivered messages sent to receiverID.
+// If senderID is non-zero only that conversation is affected.
+func markMessagesDelivered(db *gorm.DB, receiverID, senderID uint, now time.Time) error {
+	query := db.Model(&models.Message{}).Where("receiver_id = ? AND delivered_at IS NULL AND shadowed = ?", receiverID, false)
+	if senderID != 0 {
+		query = query.Where("sender_id = ?", senderID)
+	}
+	return query.Update("delivered_at", now).Error
+}
+
+// readReceiptsVisible reports whether viewer may see read receipts for messages they sent to reader.
+// Receipts are reciprocal: hiding your own also hides everyone else's from you.
+func readReceiptsVisible(viewer, reader models.User) bool {
+	return !viewer.PrivacySettings.HideReadReceipts && !reader.PrivacySettings.HideReadReceipts
+}
+
+// maskReadReceipt clears read state on a message the viewer sent when receipts are hidden
+func maskReadReceipt(message *models.Message, viewerID uint, visible bool) {
+	if visible || message.SenderID != viewerID {
+		return
+	}
+	message.Read = false
+	message.ReadAt = nil
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"datingapp/escalation"
+	"datingapp/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// reportEscalation decides what happens automatically when an account collects reports
+var reportEscalation = newReportEscalation()
+
+func newReportEscalation() *escalation.Engine {
+	engine, err := escalation.NewEngineFromEnv()
+	if err != nil {
+		logger.Printf("Invalid escalation rules, using the built-in ones: %v", err)
+	}
+	return engine
+}
+
+// unresolvedReportStatuses are the statuses of reports still waiting for a moderator
+var unresolvedReportStatuses = []models.ReportStatus{models.ReportOpen, models.ReportInReview}
+
+// lockReportTarget locks a reported account's row until the transaction ends. Filing a report and
+// escalating it both hold this lock, so checks for earlier reports and escalations can't race.
+func lockReportTarget(tx *gorm.DB, targetID uint) error {
+	var target models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&target, targetID).Error
+}
+
+// escalateReport runs the escalation rules over the unresolved reports against the account a new
+// report is about and takes the actions of the rules that fire. Each rule fires at most once per
+// account within its window.
+func escalateReport(tx *gorm.DB, report models.Report, now time.Time) ([]escalation.Decision, error) {
+	window := reportEscalation.MaxWindow()
+	if window == 0 {
+		return nil, nil
+	}
+	// Concurrent reports would otherwise both see no recent escalation and fire a rule twice
+	if err := lockReportTarget(tx, report.TargetID); err != nil {
+		return nil, err
+	}
+
+	var reports []models.Report
+	if err := tx.Where("target_id = ? AND status IN ? AND created_at > ?", report.TargetID, unresolvedReportStatuses, now.Add(-window)).
+		Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	reporterIDs := make([]uint, 0, len(reports))
+	for _, r := range reports {
+		reporterIDs = append(reporterIDs, r.ReporterID)
+	}
+	trust, err := reporterTrust(tx, reporterIDs, now)
+	if err != nil {
+		return nil, err
+	}
+
+	input := escalation.Input{Now: now}
+	for _, r := range reports {
+		input.Reports = append(input.Reports, escalation.Report{
+			ReporterID:    r.ReporterID,
+			Category:      string(r.Category),
+			CreatedAt:     r.CreatedAt,
+			ReporterTrust: trust[r.ReporterID],
+		})
+	}
+
+	var taken []escalation.Decision
+	for _, decision := range reportEscalation.Evaluate(input) {
+		var fired int64
+		since := now.Add(-time.Duration(decision.WindowHours) * time.Hour)
+		if err := tx.Model(&models.ReportEscalation{}).
+			Where("target_id = ? AND rule = ? AND created_at > ?", report.TargetID, decision.Rule, since).
+			Count(&fired).Error; err != nil {
+			return nil, err
+		}
+		if fired > 0 {
+			continue
+		}
+		if err := applyEscalation(tx, report, reports, decision, now); err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.ReportEscalation{
+			TargetID: report.TargetID,
+			ReportID: report.ID,
+			Rule:     decision.Rule,
+			Action:   string(decision.Action),
+			Reason:   decision.Reason,
+		}).Error; err != nil {
+			return nil, err
+		}
+		taken = append(taken, decision)
+	}
+	return taken, nil
+}
+
+// applyEscalation takes the action of a rule that fired for the reported account
+func applyEscalation(tx *gorm.DB, report models.Report, unresolved []models.Report, decision escalation.Decision, now time.Time) error {
+	switch decision.Action {
+	case escalation.ActionPriorityBump:
+		for _, r := range unresolved {
+			if r.Severity == models.ReportSeverityCritical {
+				continue
+			}
+			if err := tx.Model(&models.Report{}).Where("id = ?", r.ID).Update("severity", r.Severity.Bump()).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	case escalation.ActionHideFromDiscovery:
+		return tx.Model(&models.User{}).Where("id = ?", report.TargetID).Update("hidden_pending_review", true).Error
+	case escalation.ActionSuspend:
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, report.TargetID).Error; err != nil {
+			return err
+		}
+		until := now.Add(time.Duration(decision.SuspendHours) * time.Hour)
+		action := models.ModerationAction{
+			UserID:         user.ID,
+			Action:         models.ModerationSuspend,
+			Rule:           decision.Rule,
+			Reason:         "Suspended automatically while reports about your account are reviewed",
+			ReportID:       &report.ID,
+			SuspendedUntil: &until,
+		}
+		// A longer suspension stays as it is, and admins and banned accounts are left alone
+		if user.IsSuspended(now) && user.SuspendedUntil.After(until) {
+			return nil
+		}
+		var invalid moderationError
+		if err := applyModerationAction(&user, action, now); errors.As(err, &invalid) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Select("account_status", "suspended_until", "account_status_reason").Updates(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&action).Error
+	}
+	return nil
+}
+
+// reporterTrust scores each reporter from how their earlier reports were resolved. Reporters who
+// are banned or shadow-banned themselves aren't trusted at all.
+func reporterTrust(tx *gorm.DB, reporterIDs []uint, now time.Time) (map[uint]float64, error) {
+	trust := map[uint]float64{}
+	if len(reporterIDs) == 0 {
+		return trust, nil
+	}
+
+	var history []struct {
+		ReporterID uint
+		Actioned   int64
+		Dismissed  int64
+	}
+	if err := tx.Model(&models.Report{}).
+		Select("reporter_id, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS actioned, SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS dismissed",
+			models.ReportActioned, models.ReportDismissed).
+		Where("reporter_id IN ?", reporterIDs).
+		Group("reporter_id").
+		Scan(&history).Error; err != nil {
+		return nil, err
+	}
+	byReporter := map[uint]int{}
+	for i, h := range history {
+		byReporter[h.ReporterID] = i
+	}
+
+	var reporters []models.User
+	if err := tx.Select("id", "created_at", "account_status", "shadow_banned").Where("id IN ?", reporterIDs).Find(&reporters).Error; err != nil {
+		return nil, err
+	}
+	for _, reporter := range reporters {
+		if reporter.IsBanned() || reporter.ShadowBanned {
+			trust[reporter.ID] = 0
+			continue
+		}
+		var actioned, dismissed int64
+		if i, ok := byReporter[reporter.ID]; ok {
+			actioned, dismissed = history[i].Actioned, history[i].Dismissed
+		}
+		trust[reporter.ID] = escalation.TrustScore(actioned, dismissed, now.Sub(reporter.CreatedAt))
+	}
+	return trust, nil
+}
+
+// releaseDiscoveryHold puts an account held back by an escalation rule back into matches once
+// moderators have resolved every report against it
+func releaseDiscoveryHold(tx *gorm.DB, targetID uint) error {
+	var unresolved int64
+	if err := tx.Model(&models.Report{}).Where("target_id = ? AND status IN ?", targetID, unresolvedReportStatuses).Count(&unresolved).Error; err != nil {
+		return err
+	}
+	if unresolved > 0 {
+		return nil
+	}
+	return tx.Model(&models.User{}).Where("id = ? AND hidden_pending_review = ?", targetID, true).Update("hidden_pending_review", false).Error
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"bytes"
+	"datingapp/escalation"
+	"datingapp/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportEscalation(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+
+	defaultEngine := reportEscalation
+	defer func() { reportEscalation = defaultEngine }()
+	reportEscalation = escalation.NewEngine(
+		escalation.Rule{Name: "burst", WindowHours: 24, MinReporters: 2, MinTrust: 0.8, Action: escalation.ActionPriorityBump},
+		escalation.Rule{Name: "hold", WindowHours: 24, MinReporters: 3, MinTrust: 1.2, Action: escalation.ActionHideFromDiscovery},
+		escalation.Rule{Name: "wave", Categories: []string{"harassment"}, WindowHours: 24, MinReporters: 4, Action: escalation.ActionSuspend, SuspendHours: 12},
+	)
+
+	monthAgo := time.Now().AddDate(0, -1, 0)
+	target := models.User{FirstName: "Target", Email: "target@example.com", Password: "password123"}
+	db.Create(&target)
+	var reporters []models.User
+	for i := 0; i < 4; i++ {
+		reporter := models.User{FirstName: "Reporter", Email: fmt.Sprintf("reporter%d@example.com", i), Password: "password123", CreatedAt: monthAgo}
+		db.Create(&reporter)
+		reporters = append(reporters, reporter)
+	}
+
+	report := func(reporterID uint) int {
+		req, _ := http.NewRequest("POST", "/report/"+strconv.Itoa(int(target.ID)), bytes.NewBufferString(`{"reason":"Abusive messages","category":"harassment"}`))
+		req.Header.Set("Content-Type", "application/json")
+		addAuthHeader(req, reporterID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	escalated := func() []string {
+		var rules []string
+		db.Model(&models.ReportEscalation{}).Where("target_id = ?", target.ID).Order("id").Pluck("rule", &rules)
+		return rules
+	}
+
+	// Repeat reports from the same person are deduplicated and don't escalate
+	assert.Equal(t, http.StatusCreated, report(reporters[0].ID))
+	assert.Equal(t, http.StatusOK, report(reporters[0].ID))
+	var count int64
+	db.Model(&models.Report{}).Where("target_id = ?", target.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+	assert.Empty(t, escalated())
+
+	// A second reporter bumps the queue priority once
+	assert.Equal(t, http.StatusCreated, report(reporters[1].ID))
+	assert.Equal(t, []string{"burst"}, escalated())
+	var reports []models.Report
+	db.Where("target_id = ?", target.ID).Find(&reports)
+	for _, r := range reports {
+		assert.Equal(t, models.ReportSeverityCritical, r.Severity, "harassment starts high and is bumped to critical")
+	}
+
+	// A third hides the account from discovery
+	assert.Equal(t, http.StatusCreated, report(reporters[2].ID))
+	assert.Equal(t, []string{"burst", "hold"}, escalated())
+	db.First(&target, target.ID)
+	assert.True(t, target.HiddenPendingReview)
+
+	// A fourth suspends it pending review
+	assert.Equal(t, http.StatusCreated, report(reporters[3].ID))
+	db.First(&target, target.ID)
+	assert.True(t, target.IsSuspended(time.Now()))
+	var action models.ModerationAction
+	if assert.NoError(t, db.Where("user_id = ?", target.ID).First(&action).Error) {
+		assert.Equal(t, "wave", action.Rule)
+		assert.Zero(t, action.ModeratorID)
+	}
+
+	// Resolving every report puts the account back into discovery
+	db.Model(&models.Report{}).Where("target_id = ?", target.ID).Update("status", models.ReportInReview)
+	db.Where("target_id = ?", target.ID).Find(&reports)
+	for _, r := range reports {
+		previous := r.Status
+		r.Status = models.ReportDismissed
+		r.ResolutionNotes = "Coordinated false reports"
+		assert.NoError(t, saveReport(db, r, previous))
+	}
+	db.First(&target, target.ID)
+	assert.False(t, target.HiddenPendingReview)
+}
+
+func TestConcurrentReports(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+
+	defaultEngine := reportEscalation
+	defer func() { reportEscalation = defaultEngine }()
+	reportEscalation = escalation.NewEngine(
+		escalation.Rule{Name: "hold", WindowHours: 24, MinReporters: 2, Action: escalation.ActionHideFromDiscovery},
+	)
+
+	target := models.User{FirstName: "Target", Email: "target@example.com", Password: "password123"}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123"}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123"}
+	for _, user := range []*models.User{&target, &alice, &bob} {
+		db.Create(user)
+	}
+	db.Create(&models.Interaction{UserID: alice.ID, TargetID: target.ID, Liked: true, Matched: true})
+	db.Create(&models.Interaction{UserID: target.ID, TargetID: alice.ID, Liked: true, Matched: true})
+
+	// Each reporter sends the same report several times at once
+	var wg sync.WaitGroup
+	for _, reporter := range []models.User{alice, bob, alice, bob, alice, bob} {
+		wg.Add(1)
+		go func(reporterID uint) {
+			defer wg.Done()
+			performRequest(router, "POST", "/report/"+strconv.Itoa(int(target.ID)), `{"reason":"Spam","category":"spam"}`, reporterID)
+		}(reporter.ID)
+	}
+	wg.Wait()
+
+	var reports, escalations int64
+	db.Model(&models.Report{}).Where("target_id = ?", target.ID).Count(&reports)
+	assert.Equal(t, int64(2), reports, "one report per reporter")
+	db.Model(&models.ReportEscalation{}).Where("target_id = ?", target.ID).Count(&escalations)
+	assert.Equal(t, int64(1), escalations, "the rule fires once")
+
+	// The hold keeps the account out of discovery but not out of existing matches
+	db.First(&target, target.ID)
+	assert.True(t, target.HiddenPendingReview)
+	w := performRequest(router, "GET", "/matches/"+strconv.Itoa(int(alice.ID))+"?matched=true", "", alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"firstName":"Target"`)
+	w = performRequest(router, "GET", "/matches/"+strconv.Itoa(int(bob.ID)), "", bob.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), `"firstName":"Target"`)
+}
@@ -2,13 +2,16 @@ package handlers
 
 import (
 	"datingapp/database"
+	"datingapp/i18n"
 	"datingapp/models"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // GetUserSettings retrieves user settings
@@ -36,8 +39,11 @@ func GetUserSettings(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"notificationSettings": user.NotificationSettings,
+		"notificationSettings": user.NotificationSettings.WithLegacyToggles(),
 		"privacySettings":      user.PrivacySettings,
+		"timezone":             user.Timezone,
+		"locale":               user.Locale,
+		"digestFrequency":      user.DigestFrequency,
 		"city":                 user.City,
 		"country":              user.Country,
 		"phone":                user.Phone,
@@ -48,7 +54,7 @@ func GetUserSettings(c *gin.Context) {
 
 // UpdateUserSettings updates user settings
 // @Summary Update user settings
-// @Description Update user notification and privacy settings
+// @Description Update user notification and privacy settings. Notification preferences are per type and channel (inApp, push, email), with optional quiet hours in the user's timezone. locale sets the language of notifications and emails. digestFrequency (off, daily, weekly) controls the unread-activity email digest.
 // @Tags settings
 // @Accept json
 // @Produce json
@@ -82,7 +88,33 @@ func UpdateUserSettings(c *gin.Context) {
 	updateMap := make(map[string]interface{})
 
 	if request.NotificationSettings != nil {
-		updateMap["notification_settings"] = request.NotificationSettings
+		settings, err := mergeNotificationSettings(user.NotificationSettings, *request.NotificationSettings)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		updateMap["notification_settings"] = settings
+	}
+
+	if request.Timezone != "" {
+		if _, err := time.LoadLocation(request.Timezone); err != nil {
+			respondWithError(c, http.StatusBadRequest, "Invalid timezone")
+			return
+		}
+		updateMap["timezone"] = request.Timezone
+	}
+
+	if request.Locale != "" {
+		locale := i18n.Normalize(request.Locale)
+		if locale == "" {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Unsupported locale; supported locales are %s", strings.Join(i18n.Locales(), ", ")))
+			return
+		}
+		updateMap["locale"] = locale
+	}
+
+	if request.DigestFrequency != "" {
+		updateMap["digest_frequency"] = request.DigestFrequency
 	}
 
 	if request.PrivacySettings != nil {
@@ -112,6 +144,50 @@ func UpdateUserSettings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Settings updated successfully"})
 }
 
+// mergeNotificationSettings applies a partial update on top of the stored settings.
+// Types present in the update replace the stored ones; quiet hours are replaced when sent.
+func mergeNotificationSettings(current models.NotificationSettings, update models.NotificationSettingsUpdate) (models.NotificationSettings, error) {
+	if err := update.Validate(); err != nil {
+		return current, err
+	}
+
+	merged := current
+	merged.Normalize()
+
+	// Older clients send only the legacy toggles, which set the in-app preference of types
+	// the update doesn't configure explicitly
+	applyLegacy := func(toggle *bool, notificationTypes ...models.NotificationType) {
+		if toggle == nil {
+			return
+		}
+		for _, notificationType := range notificationTypes {
+			if _, sent := update.Types[notificationType]; sent {
+				continue
+			}
+			prefs := merged.Types[notificationType]
+			prefs.InApp = *toggle
+			merged.Types[notificationType] = prefs
+		}
+	}
+	applyLegacy(update.NewMatches, models.NotificationTypeMatch, models.NotificationTypeLike)
+	applyLegacy(update.Messages, models.NotificationTypeMessage)
+	applyLegacy(update.AppUpdates, models.NotificationTypeAppUpdate)
+
+	for notificationType, prefs := range update.Types {
+		merged.Types[notificationType] = prefs
+	}
+	if update.QuietHours != nil {
+		quietHours := *update.QuietHours
+		// Turning quiet hours off keeps the stored window for when they're turned back on
+		if quietHours.Start == "" && quietHours.End == "" {
+			quietHours.Start, quietHours.End = merged.QuietHours.Start, merged.QuietHours.End
+		}
+		merged.QuietHours = quietHours
+	}
+	merged.Normalize()
+	return merged, nil
+}
+
 // UpdateUserOnlineStatus updates user's online status and last active time
 // @Summary Update online status
 // @Description Update user's online status and last active timestamp
@@ -158,7 +234,7 @@ func UpdateUserOnlineStatus(c *gin.Context) {
 
 // IncrementProfileViews increments the profile view count for a user
 // @Summary Increment profile views
-// @Description Increment the profile view count when someone views a user's profile
+// @Description Record that the authenticated user viewed a profile. Views are counted and notified once per viewer per day; incognito viewers are not recorded.
 // @Tags settings
 // @Accept json
 // @Produce json
@@ -194,27 +270,63 @@ func IncrementProfileViews(c *gin.Context) {
 		return
 	}
 
+	var viewer models.User
+	if err := database.DB.Select("id", "first_name", "privacy_settings", "shadow_banned").First(&viewer, viewerID).Error; err != nil {
+		respondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
 	// Get the target user's name for activity logging
 	var targetUser models.User
-	if err := database.DB.Select("first_name").First(&targetUser, targetUserID).Error; err != nil {
+	if err := database.DB.Select("id", "first_name", "blocked_users").First(&targetUser, targetUserID).Error; err != nil {
 		respondWithError(c, http.StatusNotFound, "Target user not found")
 		return
 	}
 
-	// Increment profile views
-	if err := database.DB.Model(&models.User{}).Where("id = ?", targetUserID).
-		Update("profile_views", database.DB.Raw("profile_views + 1")).Error; err != nil {
-		respondWithError(c, http.StatusInternalServerError, "Failed to update profile views")
-		return
-	}
-
-	// Log the profile view activity for the viewer
 	targetIDPtr := uint(targetUserID)
 	activityMessage := fmt.Sprintf("Viewed %s's profile", targetUser.FirstName)
-	err = models.LogActivity(database.DB, viewerID, "profile_view", activityMessage, &targetIDPtr)
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Log the profile view activity for the viewer
+		if err := enqueueActivity(tx, viewerID, "profile_view", activityMessage, &targetIDPtr); err != nil {
+			return err
+		}
+		// Incognito and shadow-banned viewers leave no trace on the other user's side
+		if viewer.PrivacySettings.Incognito || viewer.ShadowBanned {
+			return nil
+		}
+
+		view, err := recordProfileView(tx, viewerID, targetUser.ID, now)
+		if err != nil || view == nil {
+			return err
+		}
+		// Increment profile views, once per viewer per day
+		if err := tx.Model(&models.User{}).Where("id = ?", targetUserID).
+			Update("profile_views", gorm.Expr("profile_views + 1")).Error; err != nil {
+			return err
+		}
+
+		if containsUint(targetUser.BlockedUsers, viewerID) {
+			return nil
+		}
+		due, err := profileViewNotificationDue(tx, targetUser.ID, now)
+		if err != nil || !due {
+			return err
+		}
+		if err := tx.Model(view).Update("notified", true).Error; err != nil {
+			return err
+		}
+		notification, err := profileViewNotification(targetUser.ID, viewerID, viewer.FirstName)
+		if err != nil {
+			return err
+		}
+		return enqueueNotification(tx, notification)
+	})
 	if err != nil {
-		logger.Printf("Failed to log profile view activity for user ID %d: %v", viewerID, err)
+		logger.Printf("Failed to record profile view of user %d by user %d: %v", targetUserID, viewerID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to update profile views")
+		return
 	}
+	notifyOutbox()
 
 	logger.Printf("Profile view recorded: User %d viewed User %d", viewerID, targetUserID)
 	c.JSON(http.StatusOK, gin.H{"message": "Profile view recorded"})
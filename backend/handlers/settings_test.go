@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"datingapp/models"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitMergeNotificationSettings(t *testing.T) {
+	current := models.DefaultNotificationSettings()
+	current.QuietHours = models.QuietHours{Enabled: true, Start: "23:00", End: "07:00"}
+
+	decode := func(body string) models.NotificationSettingsUpdate {
+		var update models.NotificationSettingsUpdate
+		assert.NoError(t, json.Unmarshal([]byte(body), &update))
+		return update
+	}
+
+	// Disabling quiet hours is applied even though it carries no window
+	merged, err := mergeNotificationSettings(current, decode(`{"quietHours":{"enabled":false}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, models.QuietHours{Enabled: false, Start: "23:00", End: "07:00"}, merged.QuietHours)
+
+	// Leaving quiet hours out keeps them
+	merged, err = mergeNotificationSettings(current, decode(`{"types":{"like":{"inApp":false,"push":false,"email":false}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, current.QuietHours, merged.QuietHours)
+	assert.False(t, merged.Types[models.NotificationTypeLike].InApp)
+
+	// An explicit type wins over the legacy toggle in the same request
+	merged, err = mergeNotificationSettings(current, decode(`{"newMatches":false,"types":{"match":{"inApp":true,"push":true,"email":false}}}`))
+	assert.NoError(t, err)
+	assert.True(t, merged.Types[models.NotificationTypeMatch].InApp)
+	assert.False(t, merged.Types[models.NotificationTypeLike].InApp)
+
+	_, err = mergeNotificationSettings(current, decode(`{"quietHours":{"enabled":true,"start":"25:00","end":"07:00"}}`))
+	assert.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"datingapp/database"
+	"datingapp/models"
+	"datingapp/photoscreen"
 	"datingapp/storage"
 	"fmt"
 	"net/http"
@@ -13,7 +16,7 @@ const maxUploadSize = 5 * 1024 * 1024 // 5 MB
 
 // UploadPhoto handles single photo upload (for EditProfile compatibility)
 // @Summary Upload single photo
-// @Description Upload a single photo (used by EditProfile page)
+// @Description Upload a single photo (used by EditProfile page). The photo is pre-screened first; other users only see it once it's approved.
 // @Tags uploads
 // @Accept multipart/form-data
 // @Produce json
@@ -50,6 +53,17 @@ func UploadPhoto(c *gin.Context) {
 		return
 	}
 
+	// Pre-screen before storing anything
+	result, err := screenUpload(file, userID)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Could not read %s", file.Filename))
+		return
+	}
+	if result.Verdict == photoscreen.VerdictReject {
+		respondWithError(c, http.StatusBadRequest, rejectedUploadMessage(file.Filename, result))
+		return
+	}
+
 	folder := fmt.Sprintf("dating_app/user_%d", userID) // Organize by user ID
 
 	// Upload to Cloudinary
@@ -62,15 +76,23 @@ func UploadPhoto(c *gin.Context) {
 
 	logger.Printf("Uploaded file: %s, URL: %s", file.Filename, url)
 
+	photo, err := recordUploadedPhoto(database.DB, url, &userID, result)
+	if err != nil {
+		logger.Printf("Failed to record uploaded photo %s: %v", url, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to upload image")
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Photo uploaded successfully",
 		"url":     url,
+		"status":  photo.Status, // Other users only see the photo once it's approved
 	})
 }
 
 // UploadPhotos handles multipart form file uploads to Cloudinary
 // @Summary Upload photos
-// @Description Uploads one or more photos for a user profile to Cloudinary
+// @Description Uploads one or more photos for a user profile to Cloudinary. Each photo is pre-screened first; other users only see it once it's approved.
 // @Tags uploads
 // @Accept multipart/form-data
 // @Produce json
@@ -99,10 +121,9 @@ func UploadPhotos(c *gin.Context) {
 		return
 	}
 
-	var uploadedUrls []string
-	folder := fmt.Sprintf("dating_app/user_%d", userID) // Organize by user ID
-
-	for _, file := range files {
+	// Validate and pre-screen every file before storing any of them
+	results := make([]screenedUpload, len(files))
+	for i, file := range files {
 		// Validate file size
 		if file.Size > maxUploadSize {
 			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("File %s is too large (max %d MB)", file.Filename, maxUploadSize/1024/1024))
@@ -116,6 +137,23 @@ func UploadPhotos(c *gin.Context) {
 			return
 		}
 
+		result, err := screenUpload(file, userID)
+		if err != nil {
+			respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Could not read %s", file.Filename))
+			return
+		}
+		if result.Verdict == photoscreen.VerdictReject {
+			respondWithError(c, http.StatusBadRequest, rejectedUploadMessage(file.Filename, result))
+			return
+		}
+		results[i] = result
+	}
+
+	var uploadedUrls []string
+	photos := []models.PhotoReview{}
+	folder := fmt.Sprintf("dating_app/user_%d", userID) // Organize by user ID
+
+	for i, file := range files {
 		// Upload to Cloudinary
 		url, err := storage.UploadImage(file, folder)
 		if err != nil {
@@ -124,11 +162,20 @@ func UploadPhotos(c *gin.Context) {
 			return
 		}
 
+		photo, err := recordUploadedPhoto(database.DB, url, &userID, results[i])
+		if err != nil {
+			logger.Printf("Failed to record uploaded photo %s: %v", url, err)
+			respondWithError(c, http.StatusInternalServerError, "Failed to upload image")
+			return
+		}
+
 		uploadedUrls = append(uploadedUrls, url)
+		photos = append(photos, models.PhotoReview{URL: url, Status: photo.Status})
 		logger.Printf("Uploaded file: %s, URL: %s", file.Filename, url)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"urls": uploadedUrls})
+	// photos carries each photo's moderation status; other users only see approved ones
+	c.JSON(http.StatusOK, gin.H{"urls": uploadedUrls, "photos": photos})
 }
 
 // DeletePhoto deletes a photo from Cloudinary
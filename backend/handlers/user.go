@@ -3,12 +3,15 @@ package handlers
 import (
 	"context"
 	"datingapp/database"
+	"datingapp/middleware"
 	"datingapp/models"
+	"datingapp/safety"
 	"errors"
 	"fmt"
 	"log" // Import the log package
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -85,8 +88,34 @@ func getPaginationParams(c *gin.Context) (limit int, offset int) {
 	return limit, offset
 }
 
+// tokenLifetime is how long a sign-in lasts
+const tokenLifetime = 24 * time.Hour
+
 // Helper for JWT token generation
-func generateJWTToken(userID uint) (string, error) {
+func generateJWTToken(session models.Session) (string, error) {
+	jwtSecret := os.Getenv("JWT_SECRET")
+	if jwtSecret == "" {
+		return "", errors.New("JWT_SECRET environment variable is not set")
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": fmt.Sprintf("%d", session.UserID),
+		"sid":     fmt.Sprintf("%d", session.ID),
+		"iat":     session.CreatedAt.Unix(),
+		"exp":     session.ExpiresAt.Unix(),
+
+		middleware.ClaimIssuedAtMicros: session.CreatedAt.UnixMicro(),
+	})
+
+	return token.SignedString([]byte(jwtSecret))
+}
+
+// appealTokenLifetime is how long a suspended or banned user has to appeal after signing in
+const appealTokenLifetime = time.Hour
+
+// generateAppealToken signs a token that only opens the appeal routes, for a user whose account
+// is suspended or banned. No session is recorded since the user isn't signed in to the app.
+func generateAppealToken(userID uint, now time.Time) (string, error) {
 	jwtSecret := os.Getenv("JWT_SECRET")
 	if jwtSecret == "" {
 		return "", errors.New("JWT_SECRET environment variable is not set")
@@ -94,7 +123,11 @@ func generateJWTToken(userID uint) (string, error) {
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": fmt.Sprintf("%d", userID),
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"scope":   middleware.TokenScopeAppeal,
+		"iat":     now.Unix(),
+		"exp":     now.Add(appealTokenLifetime).Unix(),
+
+		middleware.ClaimIssuedAtMicros: now.UnixMicro(),
 	})
 
 	return token.SignedString([]byte(jwtSecret))
@@ -176,9 +209,19 @@ func Register(c *gin.Context) {
 	// Save the user to the database
 	ctxCreate, cancelCreate := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelCreate()
-	result := database.DB.WithContext(ctxCreate).Create(&user)
-	if result.Error != nil {
-		respondWithError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to create user: %v", result.Error))
+	err := database.DB.WithContext(ctxCreate).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		// Photos uploaded during registration become this account's and wait for moderation
+		return claimPhotos(tx, &user, time.Now())
+	})
+	if errors.Is(err, errPhotoNotYours) {
+		respondWithError(c, http.StatusBadRequest, "You can only use photos you uploaded")
+		return
+	}
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to create user: %v", err))
 		return
 	}
 
@@ -196,6 +239,7 @@ func Register(c *gin.Context) {
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]interface{} "Account suspended or banned, with an appealToken for the appeal routes"
 // @Failure 500 {object} map[string]string
 // @Router /login [post]
 func Login(c *gin.Context) {
@@ -219,7 +263,39 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	tokenString, err := generateJWTToken(user.ID)
+	if message, restricted := user.AccountRestriction(time.Now()); restricted {
+		// The user can't sign in, but gets a token to appeal the decision with
+		appealToken, err := generateAppealToken(user.ID, time.Now())
+		if err != nil {
+			logger.Printf("ERROR: Could not generate appeal token: %v", err)
+		}
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":          message,
+			"accountStatus":  user.AccountStatus,
+			"reason":         user.AccountStatusReason,
+			"suspendedUntil": user.SuspendedUntil,
+			"appealToken":    appealToken,
+		})
+		return
+	}
+
+	// Record the sign-in so admins can see it. The time is stored as the database will, since
+	// it is compared with sign-out times.
+	now := time.Now().Truncate(models.TokenTimePrecision)
+	session := models.Session{
+		UserID:    user.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(tokenLifetime),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		logger.Printf("ERROR: Could not record session: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Could not generate token")
+		return
+	}
+
+	tokenString, err := generateJWTToken(session)
 	if err != nil {
 		logger.Printf("ERROR: Could not generate token: %v", err)
 		respondWithError(c, http.StatusInternalServerError, "Could not generate token")
@@ -244,6 +320,7 @@ func Login(c *gin.Context) {
 			"photos":            user.Photos,
 			"profilePictureURL": user.ProfilePictureURL,
 			"isAdmin":           user.IsAdmin, // Include admin status
+			"verified":          user.VerifiedAt != nil,
 			"city":              user.City,
 			"country":           user.Country,
 			"phone":             user.Phone,
@@ -300,6 +377,12 @@ func GetUserProfile(c *gin.Context) {
 	// Get user statistics
 	stats := user.GetUserStats(database.DB)
 
+	reviews, err := photoReviews(database.DB, user)
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve photos")
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"id":                   user.ID,
 		"firstName":            user.FirstName,
@@ -311,6 +394,7 @@ func GetUserProfile(c *gin.Context) {
 		"interests":            user.Interests,
 		"sexualOrientation":    user.SexualOrientation,
 		"photos":               user.Photos,
+		"photoReviews":         reviews, // Moderation status of each photo; others only see approved ones
 		"ageRange":             user.AgeRange,
 		"distance":             user.Distance,
 		"genderPreference":     user.GenderPreference,
@@ -324,8 +408,12 @@ func GetUserProfile(c *gin.Context) {
 		"lastActiveAt":         user.LastActiveAt,
 		"isOnline":             user.IsOnline,
 		"blockedUsers":         user.BlockedUsers,
-		"notificationSettings": user.NotificationSettings,
+		"notificationSettings": user.NotificationSettings.WithLegacyToggles(),
 		"privacySettings":      user.PrivacySettings,
+		"timezone":             user.Timezone,
+		"locale":               user.Locale,
+		"verified":             user.VerifiedAt != nil,
+		"verifiedAt":           user.VerifiedAt,
 		// Statistics
 		"totalMatches": stats.TotalMatches,
 		"activeChats":  stats.ActiveChats,
@@ -433,6 +521,10 @@ func UpdateUserProfile(c *gin.Context) {
 		user.SexualOrientation = updateData.SexualOrientation
 	}
 	if updateData.Photos != nil {
+		// A verified badge only vouches for the photos it was checked against
+		if !slices.Equal(user.Photos, updateData.Photos) {
+			user.VerifiedAt = nil
+		}
 		user.Photos = updateData.Photos
 	}
 	if updateData.Latitude != 0 {
@@ -444,7 +536,18 @@ func UpdateUserProfile(c *gin.Context) {
 
 	ctxUpdate, cancelUpdate := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancelUpdate()
-	if err := database.DB.WithContext(ctxUpdate).Save(&user).Error; err != nil {
+	err = database.DB.WithContext(ctxUpdate).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		// New photos wait for moderation before other users see them
+		return claimPhotos(tx, &user, time.Now())
+	})
+	if errors.Is(err, errPhotoNotYours) {
+		respondWithError(c, http.StatusBadRequest, "You can only use photos you uploaded")
+		return
+	}
+	if err != nil {
 		respondWithError(c, http.StatusInternalServerError, fmt.Sprintf("Failed to update profile: %v", err))
 		return
 	}
@@ -587,6 +690,7 @@ func UpdateUserPreferences(c *gin.Context) {
 // @Param user_id path uint true "User ID"
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page" default(10)
+// @Param verified query bool false "Only show verified profiles"
 // @Success 200 {array} models.User
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -634,6 +738,7 @@ func GetMatches(c *gin.Context) {
 			Where("user_id = ? AND matched = true", paramUserID)
 
 		if err := database.DB.WithContext(ctx).Model(&models.User{}).
+			Scopes(models.NotBanned, models.NotShadowBanned).
 			Where("id IN (?)", subQuery).
 			Limit(limit).Offset(offset).
 			Find(&matches).Error; err != nil {
@@ -661,7 +766,11 @@ func GetMatches(c *gin.Context) {
 			excludedIDs = append(excludedIDs, 0) // Add impossible ID 0
 		}
 
-		query := database.DB.WithContext(ctx).Model(&models.User{}).Where("id NOT IN ?", excludedIDs)
+		query := database.DB.WithContext(ctx).Model(&models.User{}).Scopes(models.NotBanned, models.NotShadowBanned, models.NotHiddenPendingReview).Where("id NOT IN ?", excludedIDs)
+
+		if c.Query("verified") == "true" {
+			query = query.Where("verified_at IS NOT NULL")
+		}
 
 		// Apply gender preference filter if specified
 		if user.GenderPreference != "" && user.GenderPreference != "All" {
@@ -700,7 +809,9 @@ func GetMatches(c *gin.Context) {
 			"gender":            match.Gender,
 			"interests":         match.Interests,
 			"lookingFor":        match.LookingFor,
-			"profilePictureURL": match.ProfilePictureURL,
+			"profilePictureURL": match.ApprovedPictureURL,
+			"photos":            match.ApprovedPhotos, // Only moderator-approved photos are shown to others
+			"verified":          match.VerifiedAt != nil,
 			"bio":               match.Bio,
 		})
 	}
@@ -710,17 +821,19 @@ func GetMatches(c *gin.Context) {
 
 // SendMessage sends a message from one user to another
 // @Summary Send a message
-// @Description Send a message to another user
+// @Description Send a message to another user. Pass prompt_id instead of content to send one of the match's icebreakers.
 // @Tags messaging
 // @Accept json
 // @Produce json
 // @Param message body models.SendMessageRequest true "Message details"
 // @Security ApiKeyAuth
 // @Success 201 {object} map[string]interface{}
+// @Success 202 {object} map[string]interface{} "Message held for moderator review"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]interface{} "Message blocked by the safety filter"
 // @Failure 500 {object} map[string]string
 // @Router /messages [post]
 func SendMessage(c *gin.Context) {
@@ -762,46 +875,123 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Create and save the message
+	// Sending an icebreaker by prompt ID fills in its text
+	var icebreaker *models.MatchIcebreaker
+	if req.PromptID != "" {
+		found, err := findMatchIcebreaker(database.DB, senderID.(uint), req.ReceiverID, req.PromptID)
+		if err != nil {
+			if errors.Is(err, errUnknownIcebreaker) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown icebreaker prompt"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			}
+			return
+		}
+		icebreaker = &found
+		if req.Content == "" {
+			req.Content = found.Text
+		}
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Message content is required"})
+		return
+	}
+
+	// Run the message through the chat safety filter
+	screening := screenMessage(database.DB, senderID.(uint), req.ReceiverID, req.Content)
+	switch screening.Action {
+	case safety.ActionBlock:
+		if err := recordMessageFlag(database.DB, screening, senderID.(uint), req.ReceiverID, req.Content, nil); err != nil {
+			logger.Printf("Failed to record blocked message from user %d: %v", senderID, err)
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":    "Message blocked by the safety filter",
+			"findings": screening.Findings,
+		})
+		return
+	case safety.ActionHold:
+		if err := recordMessageFlag(database.DB, screening, senderID.(uint), req.ReceiverID, req.Content, nil); err != nil {
+			logger.Printf("Failed to hold message from user %d: %v", senderID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":   "held",
+			"message":  "Your message is being reviewed and will be delivered if approved",
+			"findings": screening.Findings,
+		})
+		return
+	}
+
+	// Create and save the message. A shadow-banned sender's messages are stored but never delivered.
 	message := models.Message{
 		SenderID:   senderID.(uint),
 		ReceiverID: req.ReceiverID,
 		Content:    req.Content,
 		Read:       false,
+		Shadowed:   sender.ShadowBanned,
 	}
 
-	if err := database.DB.Create(&message).Error; err != nil {
+	// The message, its activity log entry and the receiver's notification are committed together
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		receiverIDPtr := req.ReceiverID
+		activityMessage := fmt.Sprintf("Sent a message to %s", receiver.FirstName)
+		if err := enqueueActivity(tx, message.SenderID, "message_sent", activityMessage, &receiverIDPtr); err != nil {
+			return err
+		}
+		// Notify the receiver unless they've muted this conversation
+		if message.Shadowed || isConversationMuted(tx, req.ReceiverID, message.SenderID) {
+			return nil
+		}
+		notification, err := messageNotification(req.ReceiverID, message.SenderID, sender.FirstName, req.Content)
+		if err != nil {
+			return err
+		}
+		return enqueueNotification(tx, notification)
+	})
+	if err != nil {
+		logger.Printf("Failed to send message from user %d: %v", message.SenderID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
 		return
 	}
+	notifyOutbox()
 
-	// Log the message sent activity
-	receiverIDPtr := req.ReceiverID
-	activityMessage := fmt.Sprintf("Sent a message to %s", receiver.FirstName)
-	err := models.LogActivity(database.DB, senderID.(uint), "message_sent", activityMessage, &receiverIDPtr)
-	if err != nil {
-		logger.Printf("Failed to log message activity for user ID %d: %v", senderID, err)
+	// Track icebreaker usage and whether it got a reply
+	if icebreaker != nil {
+		if err := markIcebreakerSent(database.DB, *icebreaker, message.SenderID, message.ID); err != nil {
+			logger.Printf("Failed to record icebreaker %s for message %d: %v", icebreaker.PromptID, message.ID, err)
+		}
 	}
-
-	// Create message notification for the receiver (if they have message notifications enabled)
-	if receiver.NotificationSettings.Messages {
-		if err := CreateMessageNotification(req.ReceiverID, senderID.(uint), sender.FirstName, req.Content); err != nil {
-			logger.Printf("Failed to create message notification for user %d: %v", req.ReceiverID, err)
+	if !message.Shadowed {
+		if err := markIcebreakerReplied(database.DB, message.SenderID, message.ReceiverID); err != nil {
+			logger.Printf("Failed to record icebreaker reply for message %d: %v", message.ID, err)
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
+	response := gin.H{
 		"id":          message.ID,
 		"sender_id":   message.SenderID,
 		"receiver_id": message.ReceiverID,
 		"content":     message.Content,
 		"created_at":  message.CreatedAt,
-	})
+	}
+	if screening.Action == safety.ActionWarn {
+		if err := recordMessageFlag(database.DB, screening, message.SenderID, message.ReceiverID, message.Content, &message.ID); err != nil {
+			logger.Printf("Failed to record flagged message %d: %v", message.ID, err)
+		}
+		response["warning"] = safetyWarning(screening)
+		response["findings"] = screening.Findings
+	}
+
+	c.JSON(http.StatusCreated, response)
 }
 
 // GetMessages retrieves the conversation between the current user and another user
 // @Summary Get conversation
-// @Description Get all messages between the current user and another user
+// @Description Get all messages between the current user and another user. Received messages are marked delivered; use POST /messages/read to mark them read.
 // @Tags messaging
 // @Accept json
 // @Produce json
@@ -876,28 +1066,49 @@ func GetMessages(c *gin.Context) {
 	// Get messages between the two users
 	var messages []models.Message
 	if err := database.DB.Where(
-		"(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)",
-		currentUserID, otherUserIDUint, otherUserIDUint, currentUserID,
+		"(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ? AND shadowed = ?)",
+		currentUserID, otherUserIDUint, otherUserIDUint, currentUserID, false,
 	).Order("created_at DESC").Limit(limit).Offset(offset).Find(&messages).Error; err != nil {
 		log.Printf("ERROR: Failed to retrieve messages for users %d and %d: %v", currentUserID, otherUserIDUint, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve messages"})
 		return
 	}
 
-	// Mark messages as read
-	database.DB.Model(&models.Message{}).
-		Where("sender_id = ? AND receiver_id = ? AND read = ?", otherUserIDUint, currentUserID, false).
-		Updates(map[string]interface{}{"read": true})
+	// Fetching messages delivers them; reading is acknowledged separately via POST /messages/read
+	now := time.Now()
+	if err := markMessagesDelivered(database.DB, currentUserID, otherUserIDUint, now); err != nil {
+		log.Printf("ERROR: Failed to mark messages delivered for users %d and %d: %v", currentUserID, otherUserIDUint, err)
+	}
+
+	var participants []models.User
+	database.DB.Select("id", "privacy_settings").Where("id IN ?", []uint{currentUserID, otherUserIDUint}).Find(&participants)
+	var currentUser, otherUser models.User
+	for _, participant := range participants {
+		if participant.ID == currentUserID {
+			currentUser = participant
+		} else {
+			otherUser = participant
+		}
+	}
+	receiptsVisible := readReceiptsVisible(currentUser, otherUser)
+
+	for i := range messages {
+		if messages[i].ReceiverID == currentUserID && messages[i].DeliveredAt == nil {
+			messages[i].DeliveredAt = &now
+		}
+		maskReadReceipt(&messages[i], currentUserID, receiptsVisible)
+	}
 
 	c.JSON(http.StatusOK, messages)
 }
 
 // GetConversations retrieves a list of all conversations for the current user
 // @Summary Get all conversations
-// @Description Get a list of all users the current user has exchanged messages with
+// @Description Get a list of all users the current user has exchanged messages with. Pinned conversations come first; archived ones are only returned with archived=true.
 // @Tags messaging
 // @Accept json
 // @Produce json
+// @Param archived query bool false "Return archived conversations instead of the inbox" default(false)
 // @Security ApiKeyAuth
 // @Success 200 {array} map[string]interface{}
 // @Failure 401 {object} map[string]string
@@ -913,14 +1124,17 @@ func GetConversations(c *gin.Context) {
 
 	// Use a more efficient query to get all unique conversation partners
 	type ConversationData struct {
-		UserID              uint      `json:"user_id"`
-		FirstName           string    `json:"first_name"`
-		ProfilePictureURL   string    `json:"profile_picture_url"`
-		LastMessageID       uint      `json:"last_message_id"`
-		LastMessageContent  string    `json:"last_message_content"`
-		LastMessageTime     time.Time `json:"last_message_time"`
-		LastMessageSenderID uint      `json:"last_message_sender_id"`
-		UnreadCount         int64     `json:"unread_count"`
+		UserID                 uint       `json:"user_id"`
+		FirstName              string     `json:"first_name"`
+		ProfilePictureURL      string     `json:"profile_picture_url"`
+		LastMessageID          uint       `json:"last_message_id"`
+		LastMessageContent     string     `json:"last_message_content"`
+		LastMessageTime        time.Time  `json:"last_message_time"`
+		LastMessageSenderID    uint       `json:"last_message_sender_id"`
+		LastMessageRead        bool       `json:"last_message_read"`
+		LastMessageReadAt      *time.Time `json:"last_message_read_at"`
+		LastMessageDeliveredAt *time.Time `json:"last_message_delivered_at"`
+		UnreadCount            int64      `json:"unread_count"`
 	}
 
 	var conversations []ConversationData
@@ -934,7 +1148,7 @@ func GetConversations(c *gin.Context) {
 					ELSE sender_id 
 				END as partner_id
 			FROM messages 
-			WHERE sender_id = ? OR receiver_id = ?
+			WHERE sender_id = ? OR (receiver_id = ? AND shadowed = false)
 		),
 		last_messages AS (
 			SELECT 
@@ -942,7 +1156,10 @@ func GetConversations(c *gin.Context) {
 				last_message_id,
 				last_message_content,
 				last_message_time,
-				last_message_sender_id
+				last_message_sender_id,
+				last_message_read,
+				last_message_read_at,
+				last_message_delivered_at
 			FROM (
 				SELECT 
 					CASE 
@@ -953,6 +1170,9 @@ func GetConversations(c *gin.Context) {
 					content as last_message_content,
 					created_at as last_message_time,
 					sender_id as last_message_sender_id,
+					read as last_message_read,
+					read_at as last_message_read_at,
+					delivered_at as last_message_delivered_at,
 					ROW_NUMBER() OVER (
 						PARTITION BY 
 							CASE 
@@ -962,7 +1182,7 @@ func GetConversations(c *gin.Context) {
 						ORDER BY created_at DESC
 					) as rn
 				FROM messages 
-				WHERE sender_id = ? OR receiver_id = ?
+				WHERE sender_id = ? OR (receiver_id = ? AND shadowed = false)
 			) ranked_messages
 			WHERE rn = 1
 		),
@@ -971,38 +1191,96 @@ func GetConversations(c *gin.Context) {
 				sender_id as partner_id,
 				COUNT(*) as unread_count
 			FROM messages 
-			WHERE receiver_id = ? AND read = false
+			WHERE receiver_id = ? AND read = false AND shadowed = false
 			GROUP BY sender_id
 		)
 		SELECT 
 			u.id as user_id,
 			u.first_name,
-			u.profile_picture_url,
+			u.approved_picture_url AS profile_picture_url,
 			lm.last_message_id,
 			lm.last_message_content,
 			lm.last_message_time,
 			lm.last_message_sender_id,
+			lm.last_message_read,
+			lm.last_message_read_at,
+			lm.last_message_delivered_at,
 			COALESCE(uc.unread_count, 0) as unread_count
 		FROM conversation_partners cp
 		JOIN users u ON u.id = cp.partner_id
 		JOIN last_messages lm ON lm.partner_id = cp.partner_id
 		LEFT JOIN unread_counts uc ON uc.partner_id = cp.partner_id
+		WHERE u.account_status <> ?
 		ORDER BY lm.last_message_time DESC
 	`
 
 	if err := database.DB.Raw(query,
 		currentUserID, currentUserID, currentUserID, // conversation_partners CTE
 		currentUserID, currentUserID, currentUserID, currentUserID, // last_messages CTE
-		currentUserID, // unread_counts CTE
+		currentUserID,        // unread_counts CTE
+		models.AccountBanned, // banned partners are hidden
 	).Scan(&conversations).Error; err != nil {
 		logger.Printf("Failed to retrieve conversations for user %v: %v", currentUserID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversations"})
 		return
 	}
 
+	// Apply per-user conversation state: archived filter and pinned-first ordering
+	states, err := loadConversationStates(database.DB, currentUserID.(uint))
+	if err != nil {
+		logger.Printf("Failed to retrieve conversation states for user %v: %v", currentUserID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve conversations"})
+		return
+	}
+
+	// Listing conversations delivers any pending messages to this user
+	if err := markMessagesDelivered(database.DB, currentUserID.(uint), 0, time.Now()); err != nil {
+		logger.Printf("Failed to mark messages delivered for user %v: %v", currentUserID, err)
+	}
+
+	var currentUser models.User
+	database.DB.Select("id", "privacy_settings").First(&currentUser, currentUserID)
+	partnerPrivacy := make(map[uint]models.User)
+	if len(conversations) > 0 {
+		partnerIDs := make([]uint, len(conversations))
+		for i, conv := range conversations {
+			partnerIDs[i] = conv.UserID
+		}
+		var partners []models.User
+		database.DB.Select("id", "privacy_settings").Where("id IN ?", partnerIDs).Find(&partners)
+		for _, partner := range partners {
+			partnerPrivacy[partner.ID] = partner
+		}
+	}
+
+	showArchived := c.Query("archived") == "true"
+	var pinned, unpinned []ConversationData
+	for _, conv := range conversations {
+		state := states[conv.UserID]
+		if state.Archived != showArchived {
+			continue
+		}
+		if state.Pinned {
+			pinned = append(pinned, conv)
+		} else {
+			unpinned = append(unpinned, conv)
+		}
+	}
+	conversations = append(pinned, unpinned...)
+
 	// Format response
+	now := time.Now()
 	response := make([]map[string]interface{}, len(conversations))
 	for i, conv := range conversations {
+		state := states[conv.UserID]
+		lastMessage := models.Message{
+			ID:          conv.LastMessageID,
+			SenderID:    conv.LastMessageSenderID,
+			Read:        conv.LastMessageRead,
+			ReadAt:      conv.LastMessageReadAt,
+			DeliveredAt: conv.LastMessageDeliveredAt,
+		}
+		maskReadReceipt(&lastMessage, currentUser.ID, readReceiptsVisible(currentUser, partnerPrivacy[conv.UserID]))
 		response[i] = map[string]interface{}{
 			"user": map[string]interface{}{
 				"id":                conv.UserID,
@@ -1010,12 +1288,20 @@ func GetConversations(c *gin.Context) {
 				"profilePictureURL": conv.ProfilePictureURL,
 			},
 			"lastMessage": map[string]interface{}{
-				"id":         conv.LastMessageID,
-				"content":    conv.LastMessageContent,
-				"created_at": conv.LastMessageTime,
-				"sender_id":  conv.LastMessageSenderID,
+				"id":           conv.LastMessageID,
+				"content":      conv.LastMessageContent,
+				"created_at":   conv.LastMessageTime,
+				"sender_id":    conv.LastMessageSenderID,
+				"read":         lastMessage.Read,
+				"read_at":      lastMessage.ReadAt,
+				"delivered_at": lastMessage.DeliveredAt,
 			},
-			"unreadCount": conv.UnreadCount,
+			"unreadCount":    conv.UnreadCount,
+			"archived":       state.Archived,
+			"pinned":         state.Pinned,
+			"muted":          state.IsMuted(now),
+			"mutedUntil":     state.MutedUntil,
+			"manuallyUnread": state.ManuallyUnread,
 		}
 	}
 
@@ -1050,9 +1336,9 @@ func LikeUser(c *gin.Context) {
 		return
 	}
 
-	// Check if the target user exists
+	// Check if the target user exists; banned users can't be liked
 	var targetUser models.User
-	if err := database.DB.Where("id = ?", targetIDUint).First(&targetUser).Error; err != nil {
+	if err := database.DB.Where("id = ?", targetIDUint).First(&targetUser).Error; err != nil || targetUser.IsBanned() {
 		respondWithError(c, http.StatusNotFound, "Target user not found")
 		return
 	}
@@ -1065,6 +1351,13 @@ func LikeUser(c *gin.Context) {
 		}
 	}()
 
+	var currentUser models.User
+	if err := tx.Where("id = ?", userID).First(&currentUser).Error; err != nil {
+		tx.Rollback()
+		respondWithError(c, http.StatusNotFound, "User not found")
+		return
+	}
+
 	// Check if an interaction already exists
 	var existingInteraction models.Interaction
 	interactionExists := tx.Where("user_id = ? AND target_id = ?", userID, targetIDUint).First(&existingInteraction).Error == nil
@@ -1084,9 +1377,9 @@ func LikeUser(c *gin.Context) {
 		CreatedAt: time.Now(),
 	}
 
-	// Check if the target user has already liked the current user
+	// Check if the target user has already liked the current user. Shadow-banned users never match.
 	var targetInteraction models.Interaction
-	isMatch := tx.Where("user_id = ? AND target_id = ? AND liked = ?", targetIDUint, userID, true).First(&targetInteraction).Error == nil
+	isMatch := !currentUser.ShadowBanned && !targetUser.ShadowBanned && tx.Where("user_id = ? AND target_id = ? AND liked = ?", targetIDUint, userID, true).First(&targetInteraction).Error == nil
 
 	if isMatch {
 		// It's a match! Update both interactions
@@ -1108,65 +1401,80 @@ func LikeUser(c *gin.Context) {
 		return
 	}
 
+	// Record notifications and activity in the same transaction so they can't be lost
+	if err := enqueueLikeEffects(tx, currentUser, targetUser, isMatch); err != nil {
+		tx.Rollback()
+		logger.Printf("Failed to record like side effects: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to record like")
+		return
+	}
+
 	// Commit the transaction
 	if err := tx.Commit().Error; err != nil {
 		logger.Printf("Failed to commit transaction: %v", err)
 		respondWithError(c, http.StatusInternalServerError, "Failed to complete operation")
 		return
 	}
+	notifyOutbox()
 
-	// Log the like activity
-	targetIDPtr := uint(targetIDUint)
-	var activityMessage string
+	// Suggest icebreakers so the conversation doesn't start empty
+	var matchIcebreakers []models.MatchIcebreaker
 	if isMatch {
-		activityMessage = fmt.Sprintf("Matched with %s", targetUser.FirstName)
-		// Also log a match activity
-		err = models.LogActivity(database.DB, userID, "match", activityMessage, &targetIDPtr)
+		matchIcebreakers, err = generateMatchIcebreakers(database.DB, currentUser, targetUser)
 		if err != nil {
-			logger.Printf("Failed to log match activity for user ID %d: %v", userID, err)
-		}
-
-		// Create notifications for both users when a match occurs
-		var currentUser models.User
-		if err := database.DB.Where("id = ?", userID).First(&currentUser).Error; err == nil {
-			// Notify the target user about the match
-			if err := CreateMatchNotification(uint(targetIDUint), userID, currentUser.FirstName); err != nil {
-				logger.Printf("Failed to create match notification for user %d: %v", targetIDUint, err)
-			}
-			// Notify the current user about the match
-			if err := CreateMatchNotification(userID, uint(targetIDUint), targetUser.FirstName); err != nil {
-				logger.Printf("Failed to create match notification for user %d: %v", userID, err)
-			}
-		}
-	} else {
-		activityMessage = fmt.Sprintf("Liked %s", targetUser.FirstName)
-
-		// Create a like notification for the target user (only if they have notifications enabled)
-		var currentUser models.User
-		if err := database.DB.Where("id = ?", userID).First(&currentUser).Error; err == nil {
-			// Check if target user has like notifications enabled
-			if targetUser.NotificationSettings.NewMatches { // Using NewMatches for likes too
-				if err := CreateLikeNotification(uint(targetIDUint), userID, currentUser.FirstName); err != nil {
-					logger.Printf("Failed to create like notification for user %d: %v", targetIDUint, err)
-				}
-			}
+			logger.Printf("Failed to generate icebreakers for users %d and %d: %v", userID, targetIDUint, err)
 		}
 	}
 
-	err = models.LogActivity(database.DB, userID, "like", activityMessage, &targetIDPtr)
-	if err != nil {
-		logger.Printf("Failed to log like activity for user ID %d: %v", userID, err)
-	}
-
 	response := gin.H{
 		"success": true,
 		"liked":   true,
 		"matched": isMatch,
 	}
+	if isMatch {
+		response["icebreakers"] = matchIcebreakers
+	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// enqueueLikeEffects records the activity log entries and notifications for a like in the outbox
+func enqueueLikeEffects(tx *gorm.DB, liker, target models.User, isMatch bool) error {
+	targetID := target.ID
+	if !isMatch {
+		// Likes from shadow-banned users are recorded but nobody is told
+		if !liker.ShadowBanned {
+			notification, err := likeNotification(target.ID, liker.ID, liker.FirstName)
+			if err != nil {
+				return err
+			}
+			if err := enqueueNotification(tx, notification); err != nil {
+				return err
+			}
+		}
+		return enqueueActivity(tx, liker.ID, "like", fmt.Sprintf("Liked %s", target.FirstName), &targetID)
+	}
+
+	activityMessage := fmt.Sprintf("Matched with %s", target.FirstName)
+	if err := enqueueActivity(tx, liker.ID, "match", activityMessage, &targetID); err != nil {
+		return err
+	}
+	// Notify both users about the match
+	for _, pair := range []struct {
+		user    uint
+		matched models.User
+	}{{target.ID, liker}, {liker.ID, target}} {
+		notification, err := matchNotification(pair.user, pair.matched.ID, pair.matched.FirstName)
+		if err != nil {
+			return err
+		}
+		if err := enqueueNotification(tx, notification); err != nil {
+			return err
+		}
+	}
+	return enqueueActivity(tx, liker.ID, "like", activityMessage, &targetID)
+}
+
 // DislikeUser handles when a user dislikes another user
 // @Summary Dislike a user
 // @Description Record when a user dislikes another user
@@ -1291,7 +1599,7 @@ func DeleteUserProfile(c *gin.Context) {
 
 // ReportUser allows a user to report another user for inappropriate behavior
 // @Summary Report a user
-// @Description Submits a report against a target user with a reason
+// @Description Submits a report against a target user with a reason and an optional category (spam, harassment, underage, fake_profile, other). Repeating a report that is still waiting for review changes nothing. Escalation rules may act on the target automatically.
 // @Tags matchmaking
 // @Accept json
 // @Produce json
@@ -1299,6 +1607,7 @@ func DeleteUserProfile(c *gin.Context) {
 // @Param report body models.ReportRequest true "Report details"
 // @Security ApiKeyAuth
 // @Success 201 {object} map[string]string
+// @Success 200 {object} map[string]string "Already reported"
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
 // @Failure 404 {object} map[string]string
@@ -1332,28 +1641,73 @@ func ReportUser(c *gin.Context) {
 	if err := c.ShouldBindJSON(&reportReq); err != nil {
 		var validationErrs validator.ValidationErrors
 		if errors.As(err, &validationErrs) {
+			if validationErrs[0].Field() == "Category" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report category"})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Reason is required"})
 			return
 		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 		return
 	}
-
-	// Check if the target user exists
-	var targetUser models.User
-	if err := database.DB.Where("id = ?", targetUserID).First(&targetUser).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Target user not found"})
-		return
+	if reportReq.Category == "" {
+		reportReq.Category = models.ReportCategoryOther
 	}
 
-	// Create the report
 	report := models.Report{
 		ReporterID: authenticatedUserID.(uint),
 		TargetID:   uint(targetUserID),
+		Category:   reportReq.Category,
 		Reason:     reportReq.Reason,
+		Status:     models.ReportOpen,
+		Severity:   reportReq.Category.DefaultSeverity(),
 	}
 
-	if err := database.DB.Create(&report).Error; err != nil {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Reports against an account are serialized on its row, so concurrent repeats can't both
+		// pass the duplicate check below
+		if err := lockReportTarget(tx, report.TargetID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Target user not found"})
+				return errResponded
+			}
+			return err
+		}
+
+		// A reporter's unresolved report already covers any repeat of it
+		var existing int64
+		if err := tx.Model(&models.Report{}).
+			Where("reporter_id = ? AND target_id = ? AND status IN ?", report.ReporterID, report.TargetID, unresolvedReportStatuses).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			c.JSON(http.StatusOK, gin.H{"message": "You have already reported this user"})
+			return errResponded
+		}
+
+		if err := tx.Create(&report).Error; err != nil {
+			return err
+		}
+
+		// The report stands even if the escalation rules can't be applied; moderators still see it
+		if err := tx.Transaction(func(tx *gorm.DB) error {
+			decisions, err := escalateReport(tx, report, time.Now())
+			for _, decision := range decisions {
+				logger.Printf("Escalation rule %s applied %s to user %d: %s", decision.Rule, decision.Action, report.TargetID, decision.Reason)
+			}
+			return err
+		}); err != nil {
+			logger.Printf("Failed to escalate report %d: %v", report.ID, err)
+		}
+		return nil
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to submit report against user %d: %v", report.TargetID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
 		return
 	}
@@ -45,9 +45,27 @@ func setupTestDB() *gorm.DB {
 	db.Exec("DROP TABLE IF EXISTS interactions")
 	db.Exec("DROP TABLE IF EXISTS reports")
 	db.Exec("DROP TABLE IF EXISTS users")
+	db.Exec("DROP TABLE IF EXISTS conversation_states")
+	db.Exec("DROP TABLE IF EXISTS message_flags")
+	db.Exec("DROP TABLE IF EXISTS match_icebreakers")
+	db.Exec("DROP TABLE IF EXISTS notifications")
+	db.Exec("DROP TABLE IF EXISTS push_subscriptions")
+	db.Exec("DROP TABLE IF EXISTS activity_logs")
+	db.Exec("DROP TABLE IF EXISTS outbox_events")
+	db.Exec("DROP TABLE IF EXISTS profile_views")
+	db.Exec("DROP TABLE IF EXISTS announcements")
+	db.Exec("DROP TABLE IF EXISTS moderation_actions")
+	db.Exec("DROP TABLE IF EXISTS report_escalations")
+	db.Exec("DROP TABLE IF EXISTS admin_audit_logs")
+	db.Exec("DROP TABLE IF EXISTS audit_chain_heads")
+	db.Exec("DROP TABLE IF EXISTS sessions")
+	db.Exec("DROP TABLE IF EXISTS photos")
+	db.Exec("DROP TABLE IF EXISTS verification_requests")
+	db.Exec("DROP TABLE IF EXISTS appeals")
+	db.Exec("DROP TABLE IF EXISTS data_migrations")
 
 	// Migrate models
-	db.AutoMigrate(&models.User{}, &models.Interaction{}, &models.Report{}, &models.Message{})
+	db.AutoMigrate(&models.User{}, &models.Interaction{}, &models.Report{}, &models.Message{}, &models.ConversationState{}, &models.MessageFlag{}, &models.MatchIcebreaker{}, &models.Notification{}, &models.PushSubscription{}, &models.ActivityLog{}, &models.OutboxEvent{}, &models.ProfileView{}, &models.Announcement{}, &models.ModerationAction{}, &models.ReportEscalation{}, &models.AdminAuditLog{}, &models.AuditChainHead{}, &models.Session{}, &models.Photo{}, &models.VerificationRequest{}, &models.Appeal{})
 	return db
 }
 
@@ -103,6 +121,42 @@ func addAuthHeader(req *http.Request, userID uint) {
 	req.Header.Set("Authorization", "Bearer "+token)
 }
 
+// testClientAddr is the address requests from performRequest come from
+const testClientAddr = "203.0.113.9:4000"
+
+// Helper function to send a JSON request as userID, or signed out when userID is 0
+func performRequest(router *gin.Engine, method, path, body string, userID uint) *httptest.ResponseRecorder {
+	token := ""
+	if userID != 0 {
+		token = generateTestToken(userID)
+	}
+	return performRequestWithToken(router, method, path, body, token)
+}
+
+// Helper function to send a JSON request with a token the API issued, such as from /login
+func performRequestWithToken(router *gin.Engine, method, path, body, token string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = testClientAddr
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Helper function to dispatch every outbox event that is due at now
+func drainOutbox(t *testing.T, db *gorm.DB, now time.Time) {
+	for {
+		dispatched, err := dispatchOutboxEvent(db, now)
+		assert.NoError(t, err)
+		if !dispatched {
+			return
+		}
+	}
+}
+
 // TestResult defines the structure of the test result
 type TestResult struct {
 	TestName string `json:"test_name"`
@@ -0,0 +1,434 @@
+package handlers
+
+import (
+	"datingapp/database"
+	"datingapp/models"
+	"datingapp/storage"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// verificationResponse describes a verification request to the user who made it
+func verificationResponse(request models.VerificationRequest, now time.Time) gin.H {
+	response := gin.H{
+		"id":     request.ID,
+		"status": request.Status,
+	}
+	if request.Status == models.VerificationAwaitingSelfie {
+		pose, _ := models.PoseByCode(request.Pose)
+		response["pose"] = pose
+		response["challengeExpiresAt"] = request.ChallengeExpiresAt
+		response["challengeExpired"] = request.ChallengeExpired(now)
+	}
+	if request.SubmittedAt != nil {
+		response["submittedAt"] = request.SubmittedAt
+	}
+	if request.Status == models.VerificationRejected {
+		response["rejectionReason"] = request.RejectionReason
+	}
+	return response
+}
+
+// RequestVerification starts selfie verification with a random pose challenge
+// @Summary Request profile verification
+// @Description Get a random pose to strike in a selfie. Upload the selfie to /verification/selfie before the challenge expires (15 minutes); asking again issues a new pose.
+// @Tags verification
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Already verified or no approved profile photo"
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string "A selfie is already waiting for review"
+// @Failure 500 {object} map[string]string
+// @Router /verification [post]
+func RequestVerification(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var request models.VerificationRequest
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "approved_photos", "verified_at").First(&user, userID).Error; err != nil {
+			return err
+		}
+		if user.VerifiedAt != nil {
+			respondWithError(c, http.StatusBadRequest, "Your profile is already verified")
+			return errResponded
+		}
+		// The selfie is compared with the photos other users see, so one must have been approved
+		if len(user.ApprovedPhotos) == 0 {
+			respondWithError(c, http.StatusBadRequest, "Wait for a profile photo to be approved before requesting verification")
+			return errResponded
+		}
+
+		err := tx.Where("user_id = ? AND status IN ?", userID, []models.VerificationStatus{models.VerificationAwaitingSelfie, models.VerificationPending}).
+			Order("id DESC").First(&request).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && request.Status == models.VerificationPending {
+			respondWithError(c, http.StatusConflict, "Your selfie is already waiting for review")
+			return errResponded
+		}
+
+		// A new pose each time, so a selfie can't be prepared in advance
+		request.UserID = userID
+		request.Status = models.VerificationAwaitingSelfie
+		request.Pose = models.VerificationPoses[rand.Intn(len(models.VerificationPoses))].Code
+		request.ChallengeExpiresAt = now.Add(models.VerificationChallengeTTL)
+		return tx.Save(&request).Error
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to start verification for user %d: %v", userID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to start verification")
+		return
+	}
+
+	c.JSON(http.StatusCreated, verificationResponse(request, now))
+}
+
+// clearVerification removes a user's verified badge. The badge vouches for the photos the selfie
+// was checked against, so it goes when those change.
+func clearVerification(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.User{}).Where("id = ? AND verified_at IS NOT NULL", userID).Update("verified_at", nil).Error
+}
+
+// GetVerification returns the authenticated user's verification state
+// @Summary Get verification status
+// @Description Whether the user is verified, and the state of their latest verification request
+// @Tags verification
+// @Security ApiKeyAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /verification [get]
+func GetVerification(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "verified_at").First(&user, userID).Error; err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve verification")
+		return
+	}
+	response := gin.H{
+		"verified":   user.VerifiedAt != nil,
+		"verifiedAt": user.VerifiedAt,
+	}
+
+	var request models.VerificationRequest
+	err := database.DB.Where("user_id = ?", userID).Order("id DESC").First(&request).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(c, http.StatusInternalServerError, "Failed to retrieve verification")
+		return
+	}
+	if err == nil {
+		response["request"] = verificationResponse(request, time.Now())
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SubmitVerificationSelfie uploads the selfie for the user's pose challenge
+// @Summary Upload a verification selfie
+// @Description Upload a selfie striking the pose from /verification. Only moderators ever see it.
+// @Tags verification
+// @Security ApiKeyAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param selfie formData file true "Selfie striking the requested pose"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "No challenge, challenge expired, or invalid file"
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /verification/selfie [post]
+func SubmitVerificationSelfie(c *gin.Context) {
+	userID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	var request models.VerificationRequest
+	err := database.DB.Where("user_id = ? AND status = ?", userID, models.VerificationAwaitingSelfie).Order("id DESC").First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		respondWithError(c, http.StatusBadRequest, "Request verification to get a pose first")
+		return
+	}
+	if err != nil {
+		respondWithError(c, http.StatusInternalServerError, "Failed to upload selfie")
+		return
+	}
+	if request.ChallengeExpired(time.Now()) {
+		respondWithError(c, http.StatusBadRequest, "Your pose challenge expired, please request a new one")
+		return
+	}
+
+	file, err := c.FormFile("selfie")
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "No selfie file provided")
+		return
+	}
+	if file.Size > maxUploadSize {
+		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("File %s is too large (max %d MB)", file.Filename, maxUploadSize/1024/1024))
+		return
+	}
+	if !strings.HasPrefix(file.Header.Get("Content-Type"), "image/") {
+		respondWithError(c, http.StatusBadRequest, fmt.Sprintf("Invalid file type for %s: only images allowed", file.Filename))
+		return
+	}
+
+	url, err := storage.UploadImage(file, fmt.Sprintf("dating_app/verification/user_%d", userID))
+	if err != nil {
+		logger.Printf("Failed to upload verification selfie to Cloudinary: %v", err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to upload selfie")
+		return
+	}
+
+	now := time.Now()
+	// Only the challenge that was checked above moves on, even if a new pose was issued meanwhile
+	result := database.DB.Model(&models.VerificationRequest{}).
+		Where("id = ? AND status = ? AND pose = ?", request.ID, models.VerificationAwaitingSelfie, request.Pose).
+		Updates(map[string]interface{}{"status": models.VerificationPending, "selfie_url": url, "submitted_at": now})
+	if result.Error != nil {
+		logger.Printf("Failed to record verification selfie for user %d: %v", userID, result.Error)
+		respondWithError(c, http.StatusInternalServerError, "Failed to upload selfie")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondWithError(c, http.StatusConflict, "Your pose challenge changed, please take a new selfie")
+		return
+	}
+
+	request.Status = models.VerificationPending
+	request.SelfieURL = url
+	request.SubmittedAt = &now
+	logger.Printf("User %d submitted verification selfie for request %d", userID, request.ID)
+	c.JSON(http.StatusOK, verificationResponse(request, now))
+}
+
+// verificationOutcomeNotification tells the user how their verification was decided, in their
+// language
+func verificationOutcomeNotification(tx *gorm.DB, request models.VerificationRequest) (*pendingNotification, error) {
+	var user models.User
+	if err := tx.Select("id", "locale").First(&user, request.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return newCatalogNotification(user.ID, user.Locale, models.VerificationData{
+		RequestID: request.ID,
+		Status:    request.Status,
+		Reason:    request.RejectionReason,
+		Action:    "view_verification",
+		NotificationText: models.NotificationText{
+			TitleKey:   "verification.notification.title",
+			MessageKey: "verification.notification." + string(request.Status),
+			Vars:       map[string]string{"reason": request.RejectionReason},
+		},
+	})
+}
+
+// GetVerificationQueue lists verification selfies for moderators (admin only)
+// @Summary Verification review queue (Admin)
+// @Description List verification requests, oldest first, each with the user's approved profile photos to compare the selfie against
+// @Tags admin
+// @Security ApiKeyAuth
+// @Produce json
+// @Param status query string false "Filter by status (awaiting_selfie, pending, approved, rejected, all)" default(pending)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(10)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid status filter"
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 500 {object} map[string]string
+// @Router /admin/verifications [get]
+func GetVerificationQueue(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	limit, offset := getPaginationParams(c)
+	query := database.DB.Model(&models.VerificationRequest{})
+	switch status := c.DefaultQuery("status", string(models.VerificationPending)); status {
+	case string(models.VerificationAwaitingSelfie), string(models.VerificationPending), string(models.VerificationApproved), string(models.VerificationRejected):
+		query = query.Where("status = ?", status)
+	case "all":
+	default:
+		respondWithError(c, http.StatusBadRequest, "Invalid status filter")
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve verifications"})
+		return
+	}
+	var requests []models.VerificationRequest
+	if err := query.Order("submitted_at ASC, id ASC").Limit(limit).Offset(offset).Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve verifications"})
+		return
+	}
+
+	userIDs := make([]uint, len(requests))
+	for i, request := range requests {
+		userIDs[i] = request.UserID
+	}
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := database.DB.Unscoped().Select("id", "first_name", "approved_photos", "approved_picture_url", "verified_at").
+			Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve verifications"})
+			return
+		}
+	}
+	byID := map[uint]models.User{}
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	verifications := make([]gin.H, 0, len(requests))
+	for _, request := range requests {
+		pose, _ := models.PoseByCode(request.Pose)
+		user := byID[request.UserID]
+		verifications = append(verifications, gin.H{
+			"request": request,
+			"pose":    pose,
+			"user": gin.H{
+				"id":                user.ID,
+				"firstName":         user.FirstName,
+				"photos":            user.ApprovedPhotos, // The selfie is compared with the photos others see
+				"profilePictureURL": user.ApprovedPictureURL,
+				"verifiedAt":        user.VerifiedAt,
+			},
+		})
+	}
+	if !recordAdminView(c, "verifications.view", "", 0) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"verifications": verifications,
+		"total":         total,
+	})
+}
+
+// ReviewVerification records a moderator decision on a verification selfie (admin only)
+// @Summary Review a verification selfie (Admin)
+// @Description Approve to give the user the verified badge, or reject with a reason. The user is notified either way.
+// @Tags admin
+// @Security ApiKeyAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Verification request ID"
+// @Param review body models.ReviewVerificationRequest true "Moderator decision"
+// @Success 200 {object} models.VerificationRequest
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Forbidden - Admin only"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/verifications/{id} [put]
+func ReviewVerification(c *gin.Context) {
+	if !c.GetBool("isAdmin") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	adminID, ok := getAuthenticatedUserID(c)
+	if !ok {
+		return
+	}
+
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		respondWithError(c, http.StatusBadRequest, "Invalid verification ID")
+		return
+	}
+
+	var req models.ReviewVerificationRequest
+	if !validateInput(c, &req) {
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Decision == "reject" && req.Reason == "" {
+		respondWithError(c, http.StatusBadRequest, "A reason is required to reject a verification")
+		return
+	}
+
+	var request models.VerificationRequest
+	notify := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				respondWithError(c, http.StatusNotFound, "Verification not found")
+				return errResponded
+			}
+			return err
+		}
+		if request.Status != models.VerificationPending {
+			respondWithError(c, http.StatusBadRequest, "Verification isn't waiting for review")
+			return errResponded
+		}
+
+		original := request
+		now := time.Now()
+		request.ReviewedBy = &adminID
+		request.ReviewedAt = &now
+		if req.Decision == "approve" {
+			request.Status = models.VerificationApproved
+			if err := tx.Model(&models.User{}).Where("id = ?", request.UserID).Update("verified_at", now).Error; err != nil {
+				return err
+			}
+		} else {
+			request.Status = models.VerificationRejected
+			request.RejectionReason = req.Reason
+		}
+		if err := tx.Save(&request).Error; err != nil {
+			return err
+		}
+
+		notification, err := verificationOutcomeNotification(tx, request)
+		if err != nil {
+			return err
+		}
+		if notification != nil {
+			if err := enqueueNotification(tx, *notification); err != nil {
+				return err
+			}
+			notify = true
+		}
+		return recordAdminAction(c, tx, "verification.review", "verification", request.ID, original, request)
+	})
+	if errors.Is(err, errResponded) {
+		return
+	}
+	if err != nil {
+		logger.Printf("Failed to review verification %d: %v", requestID, err)
+		respondWithError(c, http.StatusInternalServerError, "Failed to review verification")
+		return
+	}
+
+	if notify {
+		notifyOutbox()
+	}
+
+	logger.Printf("Admin %d reviewed verification %d: %s", adminID, request.ID, request.Status)
+	c.JSON(http.StatusOK, request)
+}
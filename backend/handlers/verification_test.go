@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"datingapp/middleware"
+	"datingapp/models"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfieVerification(t *testing.T) {
+	db := setupTestDB()
+	router := setupRouter(db)
+	router.POST("/verification", middleware.AuthMiddleware(), RequestVerification)
+	router.GET("/verification", middleware.AuthMiddleware(), GetVerification)
+	router.POST("/verification/selfie", middleware.AuthMiddleware(), SubmitVerificationSelfie)
+	router.GET("/admin/verifications", middleware.AuthMiddleware(), GetVerificationQueue)
+	router.PUT("/admin/verifications/:id", middleware.AuthMiddleware(), ReviewVerification)
+	router.PUT("/admin/photos/:id", middleware.AuthMiddleware(), ReviewPhoto)
+
+	admin := models.User{FirstName: "Admin", Email: "admin@example.com", Password: "password123", IsAdmin: true}
+	alice := models.User{FirstName: "Alice", Email: "alice@example.com", Password: "password123",
+		Photos: []string{"https://cdn.example.com/alice.jpg", "https://cdn.example.com/alice-pending.jpg"}, ApprovedPhotos: []string{"https://cdn.example.com/alice.jpg"}}
+	bob := models.User{FirstName: "Bob", Email: "bob@example.com", Password: "password123", Photos: []string{"https://cdn.example.com/bob.jpg"}}
+	for _, user := range []*models.User{&admin, &alice, &bob} {
+		db.Create(user)
+	}
+	alicePhoto := models.Photo{URL: "https://cdn.example.com/alice.jpg", UserID: &alice.ID, Status: models.PhotoApproved}
+	db.Create(&alicePhoto)
+
+	aliceToken := generateTestToken(alice.ID)
+
+	// None of Bob's photos has been approved yet, so there's nothing to compare a selfie with
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "POST", "/verification", "", bob.ID).Code)
+
+	w := performRequest(router, "POST", "/verification", "", alice.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	writeTestResult("/verification", TestResult{
+		TestName: "Request Verification",
+		Status:   http.StatusText(w.Code),
+		Response: w.Body.String(),
+	})
+
+	var challenge struct {
+		ID     uint                      `json:"id"`
+		Status models.VerificationStatus `json:"status"`
+		Pose   models.VerificationPose   `json:"pose"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &challenge)
+	assert.Equal(t, models.VerificationAwaitingSelfie, challenge.Status)
+	_, known := models.PoseByCode(challenge.Pose.Code)
+	assert.True(t, known)
+
+	// Asking again replaces the pose on the same request
+	w = performRequest(router, "POST", "/verification", "", alice.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var again struct {
+		ID uint `json:"id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &again)
+	assert.Equal(t, challenge.ID, again.ID)
+
+	// A selfie for an expired challenge isn't accepted
+	db.Model(&models.VerificationRequest{}).Where("id = ?", challenge.ID).Update("challenge_expires_at", time.Now().Add(-time.Minute))
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("selfie", "selfie.jpg")
+	part.Write([]byte("jpeg"))
+	form.Close()
+	req, _ := http.NewRequest("POST", "/verification/selfie", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+aliceToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "expired")
+
+	// The selfie arrives (uploading needs Cloudinary, so it's recorded directly)
+	now := time.Now()
+	db.Model(&models.VerificationRequest{}).Where("id = ?", challenge.ID).
+		Updates(map[string]interface{}{"status": models.VerificationPending, "selfie_url": "https://cdn.example.com/selfie.jpg", "submitted_at": now})
+	assert.Equal(t, http.StatusConflict, performRequest(router, "POST", "/verification", "", alice.ID).Code)
+
+	// Moderators see the selfie next to the profile photos
+	assert.Equal(t, http.StatusForbidden, performRequest(router, "GET", "/admin/verifications", "", alice.ID).Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "GET", "/admin/verifications?status=bogus", "", admin.ID).Code)
+	w = performRequest(router, "GET", "/admin/verifications", "", admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var queue struct {
+		Verifications []struct {
+			Request models.VerificationRequest `json:"request"`
+			User    struct {
+				ID     uint     `json:"id"`
+				Photos []string `json:"photos"`
+			} `json:"user"`
+		} `json:"verifications"`
+		Total int64 `json:"total"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &queue)
+	if assert.Len(t, queue.Verifications, 1) {
+		assert.Equal(t, "https://cdn.example.com/selfie.jpg", queue.Verifications[0].Request.SelfieURL)
+		assert.Equal(t, alice.ApprovedPhotos, queue.Verifications[0].User.Photos, "photos awaiting review aren't shown")
+	}
+
+	path := "/admin/verifications/" + strconv.Itoa(int(challenge.ID))
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PUT", path, `{"decision":"reject"}`, admin.ID).Code)
+	w = performRequest(router, "PUT", path, `{"decision":"approve"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "PUT", path, `{"decision":"approve"}`, admin.ID).Code, "already reviewed")
+
+	db.First(&alice, alice.ID)
+	assert.NotNil(t, alice.VerifiedAt)
+	assert.Equal(t, http.StatusBadRequest, performRequest(router, "POST", "/verification", "", alice.ID).Code, "already verified")
+
+	drainOutbox(t, db, time.Now())
+	var notifications []models.Notification
+	db.Where("user_id = ? AND type = ?", alice.ID, models.NotificationTypeVerification).Find(&notifications)
+	assert.Len(t, notifications, 1)
+
+	// The badge shows in discovery, and the verified-only filter leaves out everyone else
+	carol := models.User{FirstName: "Carol", Email: "carol@example.com", Password: "password123"}
+	db.Create(&carol)
+	matches := func(query string) map[uint]bool {
+		w := performRequest(router, "GET", "/matches/"+strconv.Itoa(int(bob.ID))+query, "", bob.ID)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var list []struct {
+			ID       uint `json:"id"`
+			Verified bool `json:"verified"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &list)
+		verified := map[uint]bool{}
+		for _, match := range list {
+			verified[match.ID] = match.Verified
+		}
+		return verified
+	}
+	all := matches("")
+	assert.True(t, all[alice.ID])
+	assert.Contains(t, all, carol.ID)
+	assert.False(t, all[carol.ID])
+	onlyVerified := matches("?verified=true")
+	assert.Contains(t, onlyVerified, alice.ID)
+	assert.NotContains(t, onlyVerified, carol.ID)
+
+	w = performRequest(router, "GET", "/verification", "", alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"verified":true`)
+
+	// Changing the photos takes the badge away
+	w = performRequest(router, "PUT", "/profile/"+strconv.Itoa(int(alice.ID)), `{"photos":["https://cdn.example.com/alice.jpg","https://cdn.example.com/alice-2.jpg"]}`, alice.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.First(&alice, alice.ID)
+	assert.Nil(t, alice.VerifiedAt)
+
+	// So does a moderator taking down an approved photo
+	db.Model(&alice).Update("verified_at", time.Now())
+	w = performRequest(router, "PUT", "/admin/photos/"+strconv.Itoa(int(alicePhoto.ID)), `{"decision":"reject","reason":"Not a photo of you"}`, admin.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.First(&alice, alice.ID)
+	assert.Nil(t, alice.VerifiedAt)
+}
@@ -0,0 +1,191 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is used when a user has no locale or asks for one we don't support
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// Vars are the values interpolated into a message as {name}. "count" also picks the plural form.
+type Vars map[string]interface{}
+
+// entry is one message: either a single string or plural forms keyed by category ("one", "other", ...)
+type entry struct {
+	text   string
+	plural map[string]string
+}
+
+func (e *entry) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &e.plural); err != nil {
+		return fmt.Errorf("message must be a string or an object of plural forms: %v", err)
+	}
+	if _, ok := e.plural["other"]; !ok {
+		return fmt.Errorf("plural message is missing the \"other\" form")
+	}
+	return nil
+}
+
+// catalog maps locale to message key to message
+var catalog = mustLoad()
+
+func mustLoad() map[string]map[string]entry {
+	files, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	loaded := make(map[string]map[string]entry)
+	for _, file := range files {
+		data, err := localeFiles.ReadFile(path.Join("locales", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+		messages := make(map[string]entry)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: invalid %s: %v", file.Name(), err))
+		}
+		loaded[strings.TrimSuffix(file.Name(), ".json")] = messages
+	}
+	if _, ok := loaded[DefaultLocale]; !ok {
+		panic("i18n: missing default locale " + DefaultLocale)
+	}
+	return loaded
+}
+
+// Supported reports whether the catalog has a translation for the locale
+func Supported(locale string) bool {
+	_, ok := catalog[locale]
+	return ok
+}
+
+// Locales lists the supported locales in alphabetical order
+func Locales() []string {
+	var locales []string
+	for locale := range catalog {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Normalize maps a language tag such as "es-MX" or "fr_CA" to a supported locale,
+// or returns "" if there is none
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if Supported(tag) {
+		return tag
+	}
+	base, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	if Supported(base) {
+		return base
+	}
+	return ""
+}
+
+// Has reports whether key exists in the default locale
+func Has(key string) bool {
+	_, ok := catalog[DefaultLocale][key]
+	return ok
+}
+
+// T renders key in locale, falling back to the default locale and then to the key itself
+func T(locale string, key string, vars Vars) string {
+	message, ok := catalog[locale][key]
+	if !ok {
+		locale = DefaultLocale
+		if message, ok = catalog[DefaultLocale][key]; !ok {
+			return key
+		}
+	}
+
+	text := message.text
+	if message.plural != nil {
+		text = message.plural["other"]
+		if form, ok := message.plural[PluralCategory(locale, count(vars))]; ok {
+			text = form
+		}
+	}
+	return interpolate(text, vars)
+}
+
+// PluralCategory returns the CLDR plural category of n for the locale's language.
+// Only "one" and "other" are needed for the languages we ship.
+func PluralCategory(locale string, n int64) string {
+	switch locale {
+	case "fr":
+		// French treats 0 and 1 as singular
+		if n == 0 || n == 1 {
+			return "one"
+		}
+	default:
+		if n == 1 {
+			return "one"
+		}
+	}
+	return "other"
+}
+
+func count(vars Vars) int64 {
+	switch n := vars["count"].(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case uint:
+		return int64(n)
+	}
+	return 0
+}
+
+// interpolate replaces {name} placeholders with vars; unknown placeholders are left as they are
+func interpolate(text string, vars Vars) string {
+	if len(vars) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	var out strings.Builder
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		out.WriteString(text[:start])
+		if value, ok := vars[text[start+1:end]]; ok {
+			out.WriteString(format(value))
+		} else {
+			out.WriteString(text[start : end+1])
+		}
+		text = text[end+1:]
+	}
+	out.WriteString(text)
+	return out.String()
+}
+
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,56 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "You matched with Bob! Start chatting now.", T("en", "notification.match.message", Vars{"name": "Bob"}))
+	assert.Equal(t, "Bob te envió 3 mensajes", T("es", "notification.message.message", Vars{"name": "Bob", "count": 3}))
+	assert.Equal(t, "Bob te envió un mensaje", T("es", "notification.message.message", Vars{"name": "Bob", "count": 1}))
+
+	// Unsupported locales and missing keys fall back
+	assert.Equal(t, "Bob liked your profile", T("xx", "notification.like.message", Vars{"name": "Bob", "count": 1}))
+	assert.Equal(t, "no.such.key", T("en", "no.such.key", nil))
+
+	// Unknown placeholders are left alone rather than blanked
+	assert.Equal(t, "{name} sent you a message", T("en", "notification.message.message", Vars{"count": 1}))
+}
+
+func TestPluralCategory(t *testing.T) {
+	assert.Equal(t, "one", PluralCategory("en", 1))
+	assert.Equal(t, "other", PluralCategory("en", 0))
+	assert.Equal(t, "other", PluralCategory("en", 2))
+	assert.Equal(t, "one", PluralCategory("fr", 0))
+	assert.Equal(t, "one", PluralCategory("fr", 1))
+	assert.Equal(t, "other", PluralCategory("fr", 2))
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "es", Normalize("es-MX"))
+	assert.Equal(t, "fr", Normalize("fr_CA"))
+	assert.Equal(t, "en", Normalize(" EN "))
+	assert.Equal(t, "", Normalize("xx"))
+	assert.Equal(t, "", Normalize(""))
+}
+
+// Every locale must translate every key the default locale has, with the same placeholders
+func TestCatalogComplete(t *testing.T) {
+	for _, locale := range Locales() {
+		for key, message := range catalog[DefaultLocale] {
+			translated, ok := catalog[locale][key]
+			if !assert.True(t, ok, "%s is missing %s", locale, key) {
+				continue
+			}
+			assert.Equal(t, message.plural != nil, translated.plural != nil, "%s %s plural forms", locale, key)
+			for _, placeholder := range []string{"{name}", "{count}", "{summary}"} {
+				if strings.Contains(message.text+message.plural["other"], placeholder) {
+					assert.Contains(t, translated.text+translated.plural["other"], placeholder, "%s %s", locale, key)
+				}
+			}
+		}
+	}
+}
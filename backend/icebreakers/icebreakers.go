@@ -0,0 +1,144 @@
+package icebreakers
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Prompt is a conversation starter from the library.
+// Text may contain {interest}, which is replaced with a shared interest when one is available.
+type Prompt struct {
+	ID         string
+	Text       string
+	Interests  []string // Interests this prompt is written for; empty means generic
+	LookingFor []string // LookingFor values this prompt suits; empty means any
+}
+
+// Suggestion is a prompt rendered for a specific pair of users
+type Suggestion struct {
+	PromptID string `json:"promptId"`
+	Text     string `json:"text"`
+}
+
+// Library is the built-in prompt catalog. IDs are stable and used for reply tracking, so never reuse one.
+var Library = []Prompt{
+	// Shared-interest prompts
+	{ID: "shared-interest-origin", Text: "We both like {interest}! How did you first get into it?"},
+	{ID: "shared-interest-best", Text: "Fellow {interest} fan here. What's the best {interest} experience you've had?"},
+	{ID: "shared-interest-together", Text: "If we did something {interest}-related together this weekend, what would you pick?"},
+
+	// Interest-specific prompts
+	{ID: "travel-next-trip", Text: "Where's the next place you'd travel if you could leave tomorrow?", Interests: []string{"Travel"}},
+	{ID: "hiking-favorite-trail", Text: "What's your favorite trail near campus?", Interests: []string{"Hiking", "Nature"}},
+	{ID: "cooking-signature-dish", Text: "What's your signature dish, and would you cook it on a second date?", Interests: []string{"Cooking", "Foodie"}},
+	{ID: "coffee-order", Text: "Important question: what's your go-to coffee order?", Interests: []string{"Coffee"}},
+	{ID: "music-on-repeat", Text: "What song have you had on repeat this week?", Interests: []string{"Music", "Singing"}},
+	{ID: "movies-rewatch", Text: "What movie could you rewatch a hundred times?", Interests: []string{"Movies"}},
+	{ID: "reading-current-book", Text: "What are you reading right now, and would you recommend it?", Interests: []string{"Reading", "Writing"}},
+	{ID: "gaming-all-time", Text: "What's your all-time favorite game?", Interests: []string{"Gaming", "Board Games"}},
+	{ID: "fitness-routine", Text: "Morning workout or evening workout? Defend your answer.", Interests: []string{"Fitness", "Yoga", "Sports", "Swimming", "Cycling"}},
+	{ID: "pets-photo", Text: "Do you have a pet? I expect photos.", Interests: []string{"Pets"}},
+	{ID: "art-favorite-piece", Text: "Is there a piece of art that's stuck with you?", Interests: []string{"Art", "Painting", "Photography"}},
+
+	// Relationship-goal prompts
+	{ID: "serious-ideal-sunday", Text: "What does your ideal lazy Sunday look like?", LookingFor: []string{"Serious relationship"}},
+	{ID: "serious-green-flag", Text: "What's a green flag you always look for in someone?", LookingFor: []string{"Serious relationship"}},
+	{ID: "casual-spontaneous", Text: "What's the most spontaneous thing you've done this semester?", LookingFor: []string{"Casual dating"}},
+	{ID: "friends-campus-spot", Text: "What's your favorite hidden spot on campus?", LookingFor: []string{"Friends", "Not sure"}},
+
+	// Generic fallbacks
+	{ID: "generic-two-truths", Text: "Two truths and a lie: go!"},
+	{ID: "generic-best-week", Text: "What's been the best part of your week so far?"},
+	{ID: "generic-dream-job", Text: "If you could have any job for a day, what would it be?"},
+}
+
+// Select picks up to n prompts for a pair of users, preferring their shared interests and
+// shared relationship goals. The choice is deterministic for a given seed so retries are stable.
+func Select(interestsA, interestsB []string, lookingForA, lookingForB string, n int, seed int64) []Suggestion {
+	shared := sharedInterests(interestsA, interestsB)
+	rng := rand.New(rand.NewSource(seed))
+
+	type candidate struct {
+		prompt Prompt
+		text   string
+		score  int
+		jitter int
+	}
+	var candidates []candidate
+
+	for _, prompt := range Library {
+		score := 0
+		text := prompt.Text
+
+		if strings.Contains(prompt.Text, "{interest}") {
+			if len(shared) == 0 {
+				continue
+			}
+			interest := shared[rng.Intn(len(shared))]
+			text = strings.ReplaceAll(text, "{interest}", strings.ToLower(interest))
+			score += 3
+		}
+
+		if len(prompt.Interests) > 0 {
+			if !overlaps(prompt.Interests, shared) {
+				continue
+			}
+			score += 2
+		}
+
+		if len(prompt.LookingFor) > 0 {
+			if !containsFold(prompt.LookingFor, lookingForA) || !containsFold(prompt.LookingFor, lookingForB) {
+				continue
+			}
+			score += 2
+		}
+
+		candidates = append(candidates, candidate{prompt: prompt, text: text, score: score, jitter: rng.Int()})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].jitter < candidates[j].jitter
+	})
+
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	suggestions := make([]Suggestion, n)
+	for i := 0; i < n; i++ {
+		suggestions[i] = Suggestion{PromptID: candidates[i].prompt.ID, Text: candidates[i].text}
+	}
+	return suggestions
+}
+
+// sharedInterests returns the interests present in both lists, using A's spelling
+func sharedInterests(a, b []string) []string {
+	var shared []string
+	for _, interest := range a {
+		if containsFold(b, interest) && !containsFold(shared, interest) {
+			shared = append(shared, interest)
+		}
+	}
+	return shared
+}
+
+func overlaps(a, b []string) bool {
+	for _, item := range a {
+		if containsFold(b, item) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(strings.TrimSpace(item), strings.TrimSpace(value)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package icebreakers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectPrefersSharedInterests(t *testing.T) {
+	suggestions := Select(
+		[]string{"Hiking", "Coffee"}, []string{"coffee", "Gaming"},
+		"Serious relationship", "Casual dating",
+		3, 42,
+	)
+
+	assert.Len(t, suggestions, 3)
+	for _, s := range suggestions {
+		assert.NotContains(t, s.Text, "{interest}")
+	}
+	// The shared-interest templates outrank everything else
+	assert.True(t, strings.HasPrefix(suggestions[0].PromptID, "shared-interest-"))
+	assert.Contains(t, strings.ToLower(suggestions[0].Text), "coffee")
+}
+
+func TestSelectFallsBackToGenericPrompts(t *testing.T) {
+	suggestions := Select(nil, nil, "", "", 3, 1)
+
+	assert.Len(t, suggestions, 3)
+	for _, s := range suggestions {
+		assert.True(t, strings.HasPrefix(s.PromptID, "generic-"))
+	}
+}
+
+func TestSelectIsDeterministic(t *testing.T) {
+	a := Select([]string{"Music", "Travel"}, []string{"Music", "Travel"}, "Friends", "Friends", 3, 7)
+	b := Select([]string{"Music", "Travel"}, []string{"Music", "Travel"}, "Friends", "Friends", 3, 7)
+	assert.Equal(t, a, b)
+}
+
+func TestLibraryIDsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, prompt := range Library {
+		assert.False(t, seen[prompt.ID], "duplicate prompt ID %s", prompt.ID)
+		seen[prompt.ID] = true
+	}
+}
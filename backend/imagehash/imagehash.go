@@ -0,0 +1,189 @@
+package imagehash
+
+import (
+	"fmt"
+	"image"
+	"math/bits"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Hash is a 64-bit perceptual hash. Visually similar images have hashes a small Hamming
+// distance apart, even after resizing, recompression or small colour changes.
+type Hash uint64
+
+// String formats the hash as 16 hex digits, the form it is stored in
+func (h Hash) String() string {
+	return fmt.Sprintf("%016x", uint64(h))
+}
+
+// Parse reads a hash formatted by String
+func Parse(s string) (Hash, error) {
+	value, err := strconv.ParseUint(s, 16, 64)
+	if err != nil || len(s) != 16 {
+		return 0, fmt.Errorf("invalid image hash %q", s)
+	}
+	return Hash(value), nil
+}
+
+// Distance returns the number of bits that differ between two hashes
+func Distance(a, b Hash) int {
+	return bits.OnesCount64(uint64(a) ^ uint64(b))
+}
+
+// minInformativeBits is how many bits must be set, and how many clear, for a hash to say
+// anything about an image. Flat or nearly flat images hash to all zeros or close to it, and
+// would otherwise all match each other.
+const minInformativeBits = 4
+
+// Informative reports whether the hash carries enough detail to compare images by
+func (h Hash) Informative() bool {
+	n := bits.OnesCount64(uint64(h))
+	return n >= minInformativeBits && n <= 64-minInformativeBits
+}
+
+// DHash computes the difference hash of an image: it is shrunk to 9x8 grey cells and each bit
+// records whether a cell is brighter than its right-hand neighbour
+func DHash(img image.Image) Hash {
+	const width, height = 9, 8
+	var cells [height][width]float64
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return 0
+	}
+	for cy := 0; cy < height; cy++ {
+		y0, y1 := span(cy, height, h)
+		for cx := 0; cx < width; cx++ {
+			x0, x1 := span(cx, width, w)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			cells[cy][cx] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var hash Hash
+	for cy := 0; cy < height; cy++ {
+		for cx := 0; cx < width-1; cx++ {
+			hash <<= 1
+			if cells[cy][cx] < cells[cy][cx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// span returns the source pixels covered by cell i of n along a side of the given size. Every
+// cell covers at least one pixel, so images smaller than the grid still hash.
+func span(i, n, size int) (int, int) {
+	start, end := i*size/n, (i+1)*size/n
+	if end <= start {
+		end = start + 1
+	}
+	if end > size {
+		start, end = size-1, size
+	}
+	return start, end
+}
+
+// Match is a stored hash found near the one searched for
+type Match struct {
+	ID       uint
+	Hash     Hash
+	Distance int
+}
+
+// BKTree indexes hashes by Hamming distance so the ones near a hash can be found without
+// comparing against all of them. It is safe for concurrent use.
+type BKTree struct {
+	mu   sync.RWMutex
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     Hash
+	ids      []uint // Every ID added with exactly this hash
+	children map[int]*bkNode
+}
+
+// NewBKTree returns an empty tree
+func NewBKTree() *BKTree {
+	return &BKTree{}
+}
+
+// Add stores a hash under an ID
+func (t *BKTree) Add(hash Hash, id uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.size++
+	if t.root == nil {
+		t.root = &bkNode{hash: hash, ids: []uint{id}}
+		return
+	}
+	node := t.root
+	for {
+		d := Distance(hash, node.hash)
+		if d == 0 {
+			node.ids = append(node.ids, id)
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = map[int]*bkNode{}
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []uint{id}}
+			return
+		}
+		node = child
+	}
+}
+
+// Search returns every stored hash within maxDistance of hash, closest first
+func (t *BKTree) Search(hash Hash, maxDistance int) []Match {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var matches []Match
+	if t.root == nil {
+		return matches
+	}
+	pending := []*bkNode{t.root}
+	for len(pending) > 0 {
+		node := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		d := Distance(hash, node.hash)
+		if d <= maxDistance {
+			for _, id := range node.ids {
+				matches = append(matches, Match{ID: id, Hash: node.hash, Distance: d})
+			}
+		}
+		// By the triangle inequality only children this close to the node can hold matches
+		for childDistance, child := range node.children {
+			if childDistance >= d-maxDistance && childDistance <= d+maxDistance {
+				pending = append(pending, child)
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches
+}
+
+// Len returns how many hashes have been added
+func (t *BKTree) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
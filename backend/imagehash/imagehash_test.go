@@ -0,0 +1,103 @@
+package imagehash
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gradient draws a diagonal gradient with a bright square, scaled to the given size
+func gradient(width, height int, shift uint8) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8((x*200/width + y*55/height)) + shift
+			if x > width/3 && x < width/2 && y > height/4 && y < height/2 {
+				v = 250
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestDHashMatchesResizedCopies(t *testing.T) {
+	original := DHash(gradient(320, 240, 0))
+	resized := DHash(gradient(160, 120, 0))
+	brighter := DHash(gradient(320, 240, 4))
+	assert.LessOrEqual(t, Distance(original, resized), 4)
+	assert.LessOrEqual(t, Distance(original, brighter), 4)
+
+	noise := image.NewGray(image.Rect(0, 0, 320, 240))
+	random := rand.New(rand.NewSource(1))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(random.Intn(256))
+	}
+	assert.Greater(t, Distance(original, DHash(noise)), 10)
+
+	// Images smaller than the hash grid still hash
+	assert.NotPanics(t, func() { DHash(gradient(3, 2, 0)) })
+}
+
+func TestInformativeRejectsFlatImages(t *testing.T) {
+	assert.True(t, DHash(gradient(320, 240, 0)).Informative())
+	assert.False(t, DHash(image.NewGray(image.Rect(0, 0, 320, 240))).Informative())
+	assert.False(t, Hash(0).Informative())
+	assert.False(t, Hash(0x8000000000000001).Informative())
+	assert.False(t, Hash(^uint64(0)).Informative())
+}
+
+func TestParseRoundTrips(t *testing.T) {
+	hash := Hash(0x00ff00ff12345678)
+	parsed, err := Parse(hash.String())
+	assert.NoError(t, err)
+	assert.Equal(t, hash, parsed)
+	assert.Equal(t, "00ff00ff12345678", hash.String())
+
+	_, err = Parse("xyz")
+	assert.Error(t, err)
+	_, err = Parse("ff")
+	assert.Error(t, err)
+}
+
+func TestBKTreeSearchMatchesLinearScan(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	tree := NewBKTree()
+	hashes := map[uint]Hash{}
+	base := Hash(random.Uint64())
+	for id := uint(1); id <= 2000; id++ {
+		hash := Hash(random.Uint64())
+		if id%10 == 0 {
+			// Some near copies of base, some exact
+			hash = base ^ Hash(1)<<uint(random.Intn(64)) ^ Hash(1)<<uint(random.Intn(64))
+		}
+		if id%100 == 0 {
+			hash = base
+		}
+		hashes[id] = hash
+		tree.Add(hash, id)
+	}
+	assert.Equal(t, 2000, tree.Len())
+
+	for _, maxDistance := range []int{0, 2, 6} {
+		var expected []uint
+		for id, hash := range hashes {
+			if Distance(base, hash) <= maxDistance {
+				expected = append(expected, id)
+			}
+		}
+		var found []uint
+		for _, match := range tree.Search(base, maxDistance) {
+			found = append(found, match.ID)
+			assert.LessOrEqual(t, match.Distance, maxDistance)
+		}
+		assert.ElementsMatch(t, expected, found, "distance %d", maxDistance)
+	}
+
+	matches := tree.Search(base, 2)
+	assert.Equal(t, 0, matches[0].Distance, "closest first")
+	assert.Empty(t, NewBKTree().Search(base, 64))
+}
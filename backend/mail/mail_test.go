@@ -0,0 +1,109 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageBytes(t *testing.T) {
+	msg := Message{
+		To:      "alice@example.com",
+		Subject: "Your CampusCupid digest 💌",
+		Text:    "You have 3 unread notifications.",
+		HTML:    "<p>You have <b>3</b> unread notifications.</p>",
+		Headers: map[string]string{"List-Unsubscribe": "<https://example.com/unsub>"},
+	}
+	raw, err := msg.Bytes("CampusCupid <no-reply@campuscupid.com>", time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	parsed, err := netmail.ReadMessage(strings.NewReader(string(raw)))
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, msg.Subject, subject)
+	assert.Equal(t, "<https://example.com/unsub>", parsed.Header.Get("List-Unsubscribe"))
+	assert.Contains(t, parsed.Header.Get("Message-ID"), "@campuscupid.com>")
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	reader := multipart.NewReader(parsed.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, _ := io.ReadAll(part) // multipart decodes quoted-printable
+		bodies = append(bodies, string(content))
+	}
+	assert.Equal(t, []string{msg.Text, msg.HTML}, bodies)
+}
+
+func TestMessageRejectsHeaderInjection(t *testing.T) {
+	_, err := Message{To: "alice@example.com\r\nBcc: everyone@example.com", Text: "hi"}.Bytes("a@b.com", time.Now())
+	assert.Error(t, err)
+	_, err = Message{To: "alice@example.com", Text: "hi", Headers: map[string]string{"X-Test": "a\r\nBcc: x"}}.Bytes("a@b.com", time.Now())
+	assert.Error(t, err)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer := &FileMailer{Dir: filepath.Join(dir, "drop"), From: "no-reply@campuscupid.com"}
+
+	err := mailer.Send(context.Background(), Message{To: "Bob <bob@example.com>", Subject: "Hi", Text: "Hello Bob"})
+	require.NoError(t, err)
+
+	files, err := os.ReadDir(mailer.Dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasSuffix(files[0].Name(), "-bob@example.com.eml"))
+
+	content, _ := os.ReadFile(filepath.Join(mailer.Dir, files[0].Name()))
+	assert.Contains(t, string(content), "Hello Bob")
+}
+
+func TestNewMailerFromEnv(t *testing.T) {
+	t.Setenv("MAIL_DRIVER", "")
+	_, err := NewMailerFromEnv()
+	assert.ErrorIs(t, err, ErrMailNotConfigured)
+
+	t.Setenv("MAIL_DRIVER", "smtp")
+	t.Setenv("SMTP_HOST", "")
+	_, err = NewMailerFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("SMTP_HOST", "smtp.example.com")
+	t.Setenv("SMTP_PORT", "2525")
+	mailer, err := NewMailerFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, 2525, mailer.(*SMTPMailer).Port)
+
+	t.Setenv("MAIL_DRIVER", "file")
+	t.Setenv("MAIL_DROP_DIR", "/tmp/drop")
+	mailer, err = NewMailerFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/drop", mailer.(*FileMailer).Dir)
+
+	t.Setenv("MAIL_DRIVER", "carrier-pigeon")
+	_, err = NewMailerFromEnv()
+	assert.Error(t, err)
+}
+
+func TestEnvelopeAddress(t *testing.T) {
+	assert.Equal(t, "hi@campuscupid.com", envelopeAddress("CampusCupid <hi@campuscupid.com>"))
+	assert.Equal(t, "hi@campuscupid.com", envelopeAddress(" hi@campuscupid.com "))
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"datingapp/database"
 	"datingapp/handlers"
+	"datingapp/mail"
 	"datingapp/models"
+	"datingapp/push"
 	"datingapp/storage"
+	"errors"
 	"log"
 	"os"
 	"time"
@@ -42,6 +46,32 @@ func main() {
 	database.DB.AutoMigrate(&models.Report{})
 	database.DB.AutoMigrate(&models.ActivityLog{})
 	database.DB.AutoMigrate(&models.Notification{})
+	database.DB.AutoMigrate(&models.ConversationState{})
+	database.DB.AutoMigrate(&models.MessageFlag{})
+	database.DB.AutoMigrate(&models.MatchIcebreaker{})
+	database.DB.AutoMigrate(&models.PushSubscription{})
+	database.DB.AutoMigrate(&models.OutboxEvent{})
+	database.DB.AutoMigrate(&models.ProfileView{})
+	database.DB.AutoMigrate(&models.Announcement{})
+	database.DB.AutoMigrate(&models.ModerationAction{})
+	database.DB.AutoMigrate(&models.ReportEscalation{})
+	database.DB.AutoMigrate(&models.AdminAuditLog{}, &models.AuditChainHead{})
+	database.DB.AutoMigrate(&models.Session{})
+	database.DB.AutoMigrate(&models.Photo{})
+	database.DB.AutoMigrate(&models.VerificationRequest{})
+	database.DB.AutoMigrate(&models.Appeal{})
+	if err := database.EnsureSearchIndexes(database.DB); err != nil {
+		log.Printf("Failed to create search indexes: %v", err)
+	}
+	if err := handlers.BackfillPhotoModeration(database.DB); err != nil {
+		log.Printf("Failed to backfill photo moderation: %v", err)
+	}
+	if err := handlers.BackfillAnnouncementReadCounts(database.DB); err != nil {
+		log.Printf("Failed to backfill announcement read counts: %v", err)
+	}
+	if err := handlers.BackfillDigestEmailPreferences(database.DB); err != nil {
+		log.Printf("Failed to backfill digest email preferences: %v", err)
+	}
 
 	// Create a new Gin router with default middleware (logging, recovery)
 	r := gin.Default()
@@ -49,7 +79,7 @@ func main() {
 	// Configure CORS middleware
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"}, // Allow all origins (tighten in production)
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
 		AllowCredentials: true,
@@ -63,6 +93,37 @@ func main() {
 	if err := storage.InitCloudinary(); err != nil {
 		log.Fatalf("Failed to initialize Cloudinary: %v", err)
 	}
+	// Deliver notifications and activity logs recorded in the outbox
+	handlers.StartOutboxDispatcher(context.Background())
+
+	// Send admin announcements when they're due
+	handlers.StartAnnouncementScheduler(context.Background())
+
+	// Purge old read and deleted notifications
+	retention, err := handlers.NotificationRetentionFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid notification retention configuration: %v", err)
+	}
+	handlers.StartNotificationRetention(context.Background(), retention)
+
+	// Start Web Push delivery when VAPID keys are configured
+	if vapid, err := push.LoadVAPIDFromEnv(); err == nil {
+		handlers.StartPushWorker(context.Background(), push.NewClient(vapid), 4)
+	} else if errors.Is(err, push.ErrVAPIDNotConfigured) {
+		log.Printf("Web Push disabled: %v", err)
+	} else {
+		log.Fatalf("Invalid Web Push configuration: %v", err)
+	}
+
+	// Start email digests when a mail driver is configured
+	if mailer, err := mail.NewMailerFromEnv(); err == nil {
+		handlers.StartDigestScheduler(context.Background(), mailer, os.Getenv("APP_URL"), os.Getenv("API_URL"))
+	} else if errors.Is(err, mail.ErrMailNotConfigured) {
+		log.Printf("Email digests disabled: %v", err)
+	} else {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+
 	// LOGIN APIS
 	// Public authentication routes
 	r.POST("/register", handlers.Register)
@@ -76,6 +137,8 @@ func main() {
 	r.DELETE("/upload/photos", middleware.AuthMiddleware(), handlers.DeletePhoto)
 
 	// USER PROFILE APIS - Protected with authentication middleware
+	// who viewed my profile
+	r.GET("/profile/views", middleware.AuthMiddleware(), handlers.GetProfileViews)
 	// get profile info
 	r.GET("/profile/:user_id", middleware.AuthMiddleware(), handlers.GetUserProfile)
 	// update profile info
@@ -102,17 +165,72 @@ func main() {
 	// MESSAGING APIS
 	// Send a message to another user
 	r.POST("/messages", middleware.AuthMiddleware(), handlers.SendMessage)
+	// Mark messages from a partner as read up to a message ID
+	r.POST("/messages/read", middleware.AuthMiddleware(), handlers.MarkMessagesRead)
+	// Search across all of the user's conversations
+	r.GET("/messages/search", middleware.AuthMiddleware(), handlers.SearchMessages)
 	// Get conversation with a specific user
 	r.GET("/messages/:user_id", middleware.AuthMiddleware(), handlers.GetMessages)
+	// Icebreaker prompts suggested for a match
+	r.GET("/messages/:user_id/icebreakers", middleware.AuthMiddleware(), handlers.GetMatchIcebreakers)
 	// Get all conversations
 	r.GET("/conversations", middleware.AuthMiddleware(), handlers.GetConversations)
+	// Archive, mute, pin or mark a conversation unread
+	r.PUT("/conversations/:user_id", middleware.AuthMiddleware(), handlers.UpdateConversationState)
 
 	// new routes
 	// USER ACTIVITY LOG
 	r.GET("/activity-log", middleware.AuthMiddleware(), handlers.GetActivityLog)
 
-	// ADMIN REPORT VIEWER
+	// ADMIN REPORT TRIAGE
 	r.GET("/reports", middleware.AuthMiddleware(), handlers.GetAllReports)
+	r.PATCH("/reports/:id", middleware.AuthMiddleware(), handlers.UpdateReport)
+
+	// ADMIN AUDIT LOG
+	r.GET("/admin/audit", middleware.AuthMiddleware(), handlers.GetAuditLog)
+	r.GET("/admin/audit/verify", middleware.AuthMiddleware(), handlers.VerifyAuditLog)
+
+	// ADMIN USER MANAGEMENT
+	r.GET("/admin/users", middleware.AuthMiddleware(), handlers.GetAdminUsers)
+	r.GET("/admin/users/:id", middleware.AuthMiddleware(), handlers.GetAdminUser)
+	r.PATCH("/admin/users/:id", middleware.AuthMiddleware(), handlers.UpdateAdminUser)
+	r.DELETE("/admin/users/:id", middleware.AuthMiddleware(), handlers.DeleteAdminUser)
+	r.POST("/admin/users/:id/logout", middleware.AuthMiddleware(), handlers.ForceLogoutUser)
+
+	// ADMIN ACCOUNT MODERATION
+	r.POST("/admin/users/:id/suspend", middleware.AuthMiddleware(), handlers.SuspendUser)
+	r.POST("/admin/users/:id/ban", middleware.AuthMiddleware(), handlers.BanUser)
+	r.POST("/admin/users/:id/reinstate", middleware.AuthMiddleware(), handlers.ReinstateUser)
+	r.PUT("/admin/users/:id/shadow-ban", middleware.AuthMiddleware(), handlers.ShadowBanUser)
+	r.GET("/admin/users/:id/moderation", middleware.AuthMiddleware(), handlers.GetModerationActions)
+
+	// ADMIN CHAT SAFETY REVIEW
+	r.GET("/admin/message-flags", middleware.AuthMiddleware(), handlers.GetMessageFlags)
+	r.PUT("/admin/message-flags/:id", middleware.AuthMiddleware(), handlers.ReviewMessageFlag)
+	r.GET("/admin/icebreakers/stats", middleware.AuthMiddleware(), handlers.GetIcebreakerStats)
+
+	// ADMIN PHOTO MODERATION
+	r.GET("/admin/photos", middleware.AuthMiddleware(), handlers.GetPhotoQueue)
+	r.PUT("/admin/photos/:id", middleware.AuthMiddleware(), handlers.ReviewPhoto)
+
+	// ADMIN VERIFICATION REVIEW
+	r.GET("/admin/verifications", middleware.AuthMiddleware(), handlers.GetVerificationQueue)
+	r.PUT("/admin/verifications/:id", middleware.AuthMiddleware(), handlers.ReviewVerification)
+
+	// ADMIN APPEALS
+	r.GET("/admin/appeals", middleware.AuthMiddleware(), handlers.GetAppealQueue)
+	r.PUT("/admin/appeals/:id", middleware.AuthMiddleware(), handlers.ReviewAppeal)
+
+	// ADMIN ANNOUNCEMENTS
+	r.POST("/admin/announcements", middleware.AuthMiddleware(), handlers.CreateAnnouncement)
+	r.POST("/admin/announcements/preview", middleware.AuthMiddleware(), handlers.PreviewAnnouncement)
+	r.GET("/admin/announcements", middleware.AuthMiddleware(), handlers.GetAnnouncements)
+	r.GET("/admin/announcements/:id", middleware.AuthMiddleware(), handlers.GetAnnouncement)
+	r.DELETE("/admin/announcements/:id", middleware.AuthMiddleware(), handlers.CancelAnnouncement)
+
+	// ADMIN OUTBOX (stuck and dead-lettered side effects)
+	r.GET("/admin/outbox", middleware.AuthMiddleware(), handlers.GetOutboxEvents)
+	r.POST("/admin/outbox/:id/retry", middleware.AuthMiddleware(), handlers.RetryOutboxEvent)
 
 	// UNMATCH A USER
 	r.POST("/unmatch/:user_id", middleware.AuthMiddleware(), handlers.UnmatchUser)
@@ -121,13 +239,33 @@ func main() {
 	r.GET("/settings", middleware.AuthMiddleware(), handlers.GetUserSettings)
 	r.PUT("/settings", middleware.AuthMiddleware(), handlers.UpdateUserSettings)
 	r.PUT("/status", middleware.AuthMiddleware(), handlers.UpdateUserOnlineStatus)
+	// One-click unsubscribe from digest emails (signed token, no login)
+	r.GET("/email/unsubscribe", handlers.ConfirmUnsubscribeDigest)
+	r.POST("/email/unsubscribe", handlers.UnsubscribeDigest)
 	r.POST("/profile/:user_id/view", middleware.AuthMiddleware(), handlers.IncrementProfileViews)
 
+	// PROFILE VERIFICATION
+	r.POST("/verification", middleware.AuthMiddleware(), handlers.RequestVerification)
+	r.GET("/verification", middleware.AuthMiddleware(), handlers.GetVerification)
+	r.POST("/verification/selfie", middleware.AuthMiddleware(), handlers.SubmitVerificationSelfie)
+
+	// APPEALS (open to suspended and banned users)
+	r.POST("/appeals", middleware.AppealAuthMiddleware(), handlers.CreateAppeal)
+	r.GET("/appeals", middleware.AppealAuthMiddleware(), handlers.GetMyAppeals)
+
 	// NOTIFICATION APIS
 	r.GET("/notifications", middleware.AuthMiddleware(), handlers.GetNotifications)
 	r.PUT("/notifications/read", middleware.AuthMiddleware(), handlers.MarkNotificationsRead)
 	r.PUT("/notifications/read-all", middleware.AuthMiddleware(), handlers.MarkAllNotificationsRead)
 	r.GET("/notifications/count", middleware.AuthMiddleware(), handlers.GetNotificationCount)
+	r.DELETE("/notifications", middleware.AuthMiddleware(), handlers.DeleteNotifications)
+	r.DELETE("/notifications/:id", middleware.AuthMiddleware(), handlers.DeleteNotification)
+	r.GET("/admin/notifications/retention", middleware.AuthMiddleware(), handlers.GetNotificationRetention)
+
+	// WEB PUSH APIS
+	r.GET("/push/vapid-public-key", handlers.GetVAPIDPublicKey)
+	r.POST("/push/subscriptions", middleware.AuthMiddleware(), handlers.CreatePushSubscription)
+	r.DELETE("/push/subscriptions", middleware.AuthMiddleware(), handlers.DeletePushSubscription)
 
 	// Determine the port to run on (default to 8080 if not set)
 	port := os.Getenv("PORT")
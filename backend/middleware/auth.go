@@ -7,12 +7,34 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// activityUpdateInterval is how stale LastActiveAt may get before a request refreshes it
+const activityUpdateInterval = 5 * time.Minute
+
+// TokenScopeAppeal marks the token a suspended or banned user gets at sign-in. Only
+// AppealAuthMiddleware accepts it, so it can be used to appeal and nothing else.
+const TokenScopeAppeal = "appeal"
+
+// ClaimIssuedAtMicros is the token claim holding its issue time in microseconds, since "iat"
+// only has whole seconds and a sign-in within a second of being signed out must still work
+const ClaimIssuedAtMicros = "iat_us"
+
 func AuthMiddleware() gin.HandlerFunc {
+	return authenticate(false)
+}
+
+// AppealAuthMiddleware guards the appeal routes. Unlike AuthMiddleware it lets suspended and
+// banned users through, with a normal token or the appeal token they got at sign-in.
+func AppealAuthMiddleware() gin.HandlerFunc {
+	return authenticate(true)
+}
+
+func authenticate(allowRestricted bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if JWT_SECRET is set
 		jwtSecret := os.Getenv("JWT_SECRET")
@@ -53,6 +75,13 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Scoped tokens only open the routes they were issued for
+		if scope, _ := claims["scope"].(string); scope != "" && !(allowRestricted && scope == TokenScopeAppeal) {
+			c.JSON(401, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+
 		// Extract user_id as string and convert to uint
 		userIDStr, ok := claims["user_id"].(string)
 		if !ok {
@@ -68,16 +97,55 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Fetch user from database to get current admin status
+		// Fetch user from database to get current admin and account status
 		var user models.User
-		if err := database.DB.Select("is_admin").First(&user, uint(userID)).Error; err != nil {
+		if err := database.DB.Select("id", "is_admin", "last_active_at", "account_status", "suspended_until", "account_status_reason", "sessions_revoked_at").First(&user, uint(userID)).Error; err != nil {
 			c.JSON(401, gin.H{"error": "User not found"})
 			c.Abort()
 			return
 		}
 
+		// Tokens from before an admin signed the user out everywhere are refused
+		issuedAt, ok := tokenIssuedAt(claims)
+		if user.SessionsRevokedAt != nil && (!ok || user.TokenRevoked(issuedAt)) {
+			c.JSON(401, gin.H{"error": "Your session has ended, please sign in again"})
+			c.Abort()
+			return
+		}
+
+		// Suspended and banned users are turned away even with a valid token, except from appeals
+		now := time.Now()
+		if message, restricted := user.AccountRestriction(now); restricted && !allowRestricted {
+			c.JSON(403, gin.H{
+				"error":          message,
+				"accountStatus":  user.AccountStatus,
+				"reason":         user.AccountStatusReason,
+				"suspendedUntil": user.SuspendedUntil,
+			})
+			c.Abort()
+			return
+		}
+
+		// Record activity, at most once per interval to avoid a write on every request
+		if user.LastActiveAt == nil || now.Sub(*user.LastActiveAt) > activityUpdateInterval {
+			database.DB.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("last_active_at", now)
+		}
+
 		c.Set("userID", uint(userID))
 		c.Set("isAdmin", user.IsAdmin)
 		c.Next()
 	}
 }
+
+// tokenIssuedAt returns when a token was issued, to the microsecond when it says so
+func tokenIssuedAt(claims jwt.MapClaims) (time.Time, bool) {
+	if micros, ok := claims[ClaimIssuedAtMicros].(float64); ok {
+		return time.UnixMicro(int64(micros)), true
+	}
+	// Tokens issued before ClaimIssuedAtMicros only have whole seconds
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil {
+		return time.Time{}, false
+	}
+	return issuedAt.Time, true
+}
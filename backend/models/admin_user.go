@@ -0,0 +1,54 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AdminUserSummary is a row of the admin user list
+type AdminUserSummary struct {
+	User
+	ReportCount int64 `json:"reportCount"` // Reports received, resolved or not
+}
+
+// AdminUserUpdateRequest is an admin's edit to an account. Omitted fields are left alone.
+type AdminUserUpdateRequest struct {
+	FirstName   *string `json:"firstName,omitempty" binding:"omitempty,min=1,max=100"`
+	Email       *string `json:"email,omitempty" binding:"omitempty,email"`
+	Bio         *string `json:"bio,omitempty" binding:"omitempty,max=2000"`
+	DateOfBirth *string `json:"dateOfBirth,omitempty"`
+	City        *string `json:"city,omitempty" binding:"omitempty,max=100"`
+	Country     *string `json:"country,omitempty" binding:"omitempty,max=100"`
+	Phone       *string `json:"phone,omitempty" binding:"omitempty,max=20"`
+	Locale      *string `json:"locale,omitempty" binding:"omitempty,max=10"`
+	Timezone    *string `json:"timezone,omitempty" binding:"omitempty,max=64"`
+}
+
+// AdminUserAction is what an admin can do to an account from the user management API
+type AdminUserAction string
+
+const (
+	AdminUserDelete    AdminUserAction = "delete"    // Soft-delete; the account's data is kept
+	AdminUserAnonymize AdminUserAction = "anonymize" // Scrub personal data, then soft-delete
+)
+
+// Anonymize removes everything that identifies the user, keeping the row so reports, messages
+// and moderation history still point somewhere
+func (u *User) Anonymize(now time.Time) {
+	u.FirstName = "Deleted user"
+	u.Email = fmt.Sprintf("deleted-%d@invalid", u.ID)
+	u.Password = ""
+	u.DateOfBirth = ""
+	u.Bio = ""
+	u.Interests = nil
+	u.Photos = nil
+	u.ProfilePictureURL = ""
+	u.ApprovedPhotos = nil
+	u.ApprovedPictureURL = ""
+	u.VerifiedAt = nil
+	u.Latitude, u.Longitude = 0, 0
+	u.City, u.Country, u.Phone = "", "", ""
+	u.BlockedUsers = nil
+	u.LastActiveAt = nil
+	u.SessionsRevokedAt = &now
+}
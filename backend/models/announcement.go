@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// AnnouncementStatus tracks an announcement from composition to delivery
+type AnnouncementStatus string
+
+const (
+	AnnouncementScheduled AnnouncementStatus = "scheduled" // Waiting for its send time
+	AnnouncementSending   AnnouncementStatus = "sending"   // Fan-out in progress
+	AnnouncementSent      AnnouncementStatus = "sent"
+	AnnouncementCancelled AnnouncementStatus = "cancelled"
+)
+
+// AnnouncementSegment narrows an announcement's audience. Empty fields match everyone.
+type AnnouncementSegment struct {
+	Countries        []string   `json:"countries,omitempty"`        // Matched case-insensitively
+	Cities           []string   `json:"cities,omitempty"`           // Matched case-insensitively
+	RegisteredAfter  *time.Time `json:"registeredAfter,omitempty"`  // Inclusive
+	RegisteredBefore *time.Time `json:"registeredBefore,omitempty"` // Exclusive
+}
+
+// Validate checks the segment's date range
+func (s AnnouncementSegment) Validate() error {
+	if s.RegisteredAfter != nil && s.RegisteredBefore != nil && !s.RegisteredAfter.Before(*s.RegisteredBefore) {
+		return errors.New("registeredAfter must be before registeredBefore")
+	}
+	return nil
+}
+
+// Announcement is an admin-composed app update sent as a notification to a segment of users
+type Announcement struct {
+	ID          uint                `gorm:"primaryKey" json:"id"`
+	CreatedBy   uint                `gorm:"not null" json:"createdBy"` // Admin who composed it
+	Title       string              `gorm:"not null" json:"title"`
+	Message     string              `gorm:"type:text;not null" json:"message"`
+	URL         string              `gorm:"type:text" json:"url,omitempty"`
+	Segment     AnnouncementSegment `gorm:"type:json;serializer:json" json:"segment"`
+	Status      AnnouncementStatus  `gorm:"type:varchar(20);not null;default:scheduled;index:idx_announcement_due" json:"status"`
+	ScheduledAt time.Time           `gorm:"not null;index:idx_announcement_due" json:"scheduledAt"`
+	StartedAt   *time.Time          `json:"startedAt,omitempty"`
+	CompletedAt *time.Time          `json:"completedAt,omitempty"`
+	// Fan-out progress; each batch advances the cursor in the same transaction as its notifications
+	LastUserID     uint      `gorm:"default:0" json:"-"`
+	DeliveredCount int64     `gorm:"default:0" json:"deliveredCount"`
+	ReadCount      int64     `gorm:"default:0" json:"readCount"` // Counted as notifications are marked read; they are purged later
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// CreateAnnouncementRequest composes an announcement. Without ScheduledAt it is sent right away.
+type CreateAnnouncementRequest struct {
+	Title       string              `json:"title" binding:"required,max=100"`
+	Message     string              `json:"message" binding:"required,max=500"`
+	URL         string              `json:"url,omitempty" binding:"omitempty,url"`
+	Segment     AnnouncementSegment `json:"segment"`
+	ScheduledAt *time.Time          `json:"scheduledAt,omitempty"`
+}
+
+// AnnouncementResponse is an announcement with its delivery statistics
+type AnnouncementResponse struct {
+	Announcement
+	ReadRate float64 `json:"readRate"` // ReadCount / DeliveredCount, 0 before delivery
+}
+
+// AnnouncementPreview shows how an announcement will look and roughly how many users it will reach
+type AnnouncementPreview struct {
+	Notification NotificationResponse `json:"notification"`
+	SegmentSize  int64                `json:"segmentSize"` // Users matching the segment
+	Recipients   int64                `json:"recipients"`  // Of those, users with app update notifications on
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// AppealStatus tracks a moderator's decision on an appeal
+type AppealStatus string
+
+const (
+	AppealPending    AppealStatus = "pending"    // Waiting for a moderator
+	AppealUpheld     AppealStatus = "upheld"     // The original decision stands
+	AppealOverturned AppealStatus = "overturned" // The original decision was reversed
+)
+
+// AppealWindow is how long after a decision the user may appeal it
+const AppealWindow = 30 * 24 * time.Hour
+
+// Appeal is a user asking moderators to reconsider a suspension, a ban or a rejected photo.
+// Exactly one of ModerationActionID and PhotoID is set.
+type Appeal struct {
+	ID                 uint         `gorm:"primaryKey" json:"id"`
+	UserID             uint         `gorm:"not null;index" json:"userId"`
+	ModerationActionID *uint        `gorm:"uniqueIndex" json:"moderationActionId,omitempty"`                                               // A suspend or ban
+	PhotoID            *uint        `gorm:"index;uniqueIndex:idx_appeal_open_photo,where:status <> 'overturned'" json:"photoId,omitempty"` // A rejected photo, appealable again once overturned
+	Statement          string       `gorm:"type:text;not null" json:"statement"`
+	EvidenceURL        string       `gorm:"type:text" json:"evidenceUrl,omitempty"`
+	Status             AppealStatus `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	ResolutionNote     string       `gorm:"type:text" json:"resolutionNote,omitempty"` // Shown to the user
+	ReviewedBy         *uint        `json:"reviewedBy,omitempty"`
+	ReviewedAt         *time.Time   `json:"reviewedAt,omitempty"`
+	CreatedAt          time.Time    `json:"createdAt"`
+	UpdatedAt          time.Time    `json:"updatedAt"`
+}
+
+// Appealable reports whether the user may appeal a moderation action: only suspensions and bans
+// are, since shadow bans are never revealed and the other actions lift restrictions
+func (a ModerationAction) Appealable() bool {
+	return a.Action == ModerationSuspend || a.Action == ModerationBan
+}
+
+// ReviewAppealRequest is a moderator's decision on an appeal. A note is required to uphold.
+type ReviewAppealRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=uphold overturn"`
+	Note     string `json:"note" binding:"max=1000"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerationActionAppealable(t *testing.T) {
+	assert.True(t, ModerationAction{Action: ModerationSuspend}.Appealable())
+	assert.True(t, ModerationAction{Action: ModerationBan}.Appealable())
+	assert.False(t, ModerationAction{Action: ModerationShadowBan}.Appealable(), "shadow bans are never revealed")
+	assert.False(t, ModerationAction{Action: ModerationReinstate}.Appealable())
+}
+
+func TestAppealDataValidate(t *testing.T) {
+	_, err := EncodeNotificationData(AppealData{AppealID: 1, Status: AppealOverturned, Action: "view_appeal"})
+	assert.NoError(t, err)
+	_, err = EncodeNotificationData(AppealData{AppealID: 1, Status: AppealPending, Action: "view_appeal"})
+	assert.Error(t, err)
+	_, err = EncodeNotificationData(AppealData{Status: AppealUpheld, Action: "view_appeal"})
+	assert.Error(t, err)
+}
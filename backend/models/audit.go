@@ -0,0 +1,222 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogImmutable is returned when something tries to change or delete an audit entry
+var ErrAuditLogImmutable = errors.New("admin audit log entries can't be changed or deleted")
+
+// auditChainLock is the Postgres advisory lock key that serializes appends to the audit chain
+const auditChainLock = 4_515_201
+
+// auditChainHeadID is the ID of the single AuditChainHead row
+const auditChainHeadID = 1
+
+// auditPIIFields are the JSON fields of audited records that identify a person. Their values are
+// replaced with a fingerprint, which still shows that a value changed and can be matched against
+// a known value, but doesn't reveal it.
+var auditPIIFields = map[string]bool{
+	"email":       true,
+	"phone":       true,
+	"firstName":   true,
+	"dateOfBirth": true,
+	"latitude":    true,
+	"longitude":   true,
+}
+
+// AdminAuditLog is one entry in the append-only record of what admins did. Each entry's Hash
+// covers its contents and the previous entry's hash, so editing or removing an entry breaks
+// the chain from that point on.
+type AdminAuditLog struct {
+	ID         uint                   `gorm:"primaryKey" json:"id"`
+	ActorID    *uint                  `gorm:"index" json:"actorId,omitempty"`                 // The admin; nil for the admin CLI
+	Actor      string                 `gorm:"type:varchar(255);not null" json:"actor"`        // Who it was, e.g. an email or "cli:<os user>"
+	Action     string                 `gorm:"type:varchar(100);not null;index" json:"action"` // e.g. "report.update"
+	TargetType string                 `gorm:"type:varchar(50);index" json:"targetType,omitempty"`
+	TargetID   *uint                  `gorm:"index" json:"targetId,omitempty"`
+	Before     map[string]interface{} `gorm:"type:json;serializer:json" json:"before,omitempty"` // Changed fields before the action
+	After      map[string]interface{} `gorm:"type:json;serializer:json" json:"after,omitempty"`  // Changed fields after the action
+	Details    string                 `gorm:"type:text" json:"details,omitempty"`                // e.g. the filters of a list that was viewed
+	IP         string                 `gorm:"type:varchar(45)" json:"ip,omitempty"`
+	CreatedAt  time.Time              `gorm:"not null;index" json:"createdAt"`
+	PrevHash   string                 `gorm:"type:varchar(64);not null" json:"prevHash"`
+	Hash       string                 `gorm:"type:varchar(64);not null;uniqueIndex" json:"hash"`
+}
+
+// AuditChainHead records the newest entry of the audit chain outside the log itself. Without it,
+// removing entries from the end of the log would leave a chain that still verifies.
+type AuditChainHead struct {
+	ID        uint      `gorm:"primaryKey"`
+	LastID    uint      `gorm:"not null"`
+	LastHash  string    `gorm:"type:varchar(64);not null"`
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// BeforeUpdate keeps audit entries append-only
+func (AdminAuditLog) BeforeUpdate(*gorm.DB) error { return ErrAuditLogImmutable }
+
+// BeforeDelete keeps audit entries append-only
+func (AdminAuditLog) BeforeDelete(*gorm.DB) error { return ErrAuditLogImmutable }
+
+// ComputeHash hashes the entry's contents together with PrevHash
+func (l AdminAuditLog) ComputeHash() string {
+	optional := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+	// encoding/json sorts map keys, so the same diff always encodes the same way
+	before, _ := json.Marshal(l.Before)
+	after, _ := json.Marshal(l.After)
+	fields := []string{
+		l.PrevHash,
+		optional(l.ActorID), l.Actor, l.Action,
+		l.TargetType, optional(l.TargetID),
+		string(before), string(after), l.Details, l.IP,
+		l.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+	hash := sha256.New()
+	for _, field := range fields {
+		// Length-prefix every field so moving text between fields changes the hash
+		fmt.Fprintf(hash, "%d:%s|", len(field), field)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// AppendAuditLog adds an entry to the end of the chain. Appends are serialized so every entry
+// links to the one before it.
+func AppendAuditLog(db *gorm.DB, entry *AdminAuditLog) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", auditChainLock).Error; err != nil {
+			return err
+		}
+		// New entries link to the recorded head, so entries removed from the end of the log
+		// leave a gap in front of the next one
+		var head AuditChainHead
+		err := tx.Take(&head, auditChainHeadID).Error
+		switch {
+		case err == nil:
+			entry.PrevHash = head.LastHash
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// Logs written before the head was recorded continue from their newest entry
+			var last AdminAuditLog
+			err := tx.Select("hash").Order("id DESC").Take(&last).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			entry.PrevHash = last.Hash
+		default:
+			return err
+		}
+
+		entry.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		entry.Hash = entry.ComputeHash()
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		return tx.Save(&AuditChainHead{ID: auditChainHeadID, LastID: entry.ID, LastHash: entry.Hash}).Error
+	})
+}
+
+// LoadAuditChainHead returns the recorded head of the audit chain, or false if none is recorded
+func LoadAuditChainHead(db *gorm.DB) (AuditChainHead, bool, error) {
+	var head AuditChainHead
+	err := db.Take(&head, auditChainHeadID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return head, false, nil
+	}
+	return head, err == nil, err
+}
+
+// VerifyAuditHead checks that the chain ends where the recorded head says it does. lastID and
+// lastHash describe the newest entry in the log, zero and empty for an empty log.
+func VerifyAuditHead(head AuditChainHead, lastID uint, lastHash string) bool {
+	return head.LastID == lastID && head.LastHash == lastHash
+}
+
+// VerifyAuditChain checks consecutive entries, oldest first, and returns the ID of the first one
+// that doesn't match its hash or link. prev is the hash of the entry before the first one, empty
+// at the start of the log.
+func VerifyAuditChain(prev string, entries []AdminAuditLog) (uint, bool) {
+	for _, entry := range entries {
+		if entry.PrevHash != prev || entry.ComputeHash() != entry.Hash {
+			return entry.ID, false
+		}
+		prev = entry.Hash
+	}
+	return 0, true
+}
+
+// AuditDiff reduces two snapshots of a record to the fields that differ between them, leaving out
+// updatedAt and fingerprinting personal data. Either may be nil, for records that were created or
+// deleted.
+func AuditDiff(before, after interface{}) (map[string]interface{}, map[string]interface{}) {
+	b, a := redactAuditSnapshot(auditSnapshot(before)), redactAuditSnapshot(auditSnapshot(after))
+	delete(b, "updatedAt")
+	delete(a, "updatedAt")
+	if b == nil || a == nil {
+		return b, a
+	}
+	changedBefore, changedAfter := map[string]interface{}{}, map[string]interface{}{}
+	for key, value := range b {
+		if !reflect.DeepEqual(value, a[key]) {
+			changedBefore[key] = value
+		}
+	}
+	for key, value := range a {
+		if !reflect.DeepEqual(value, b[key]) {
+			changedAfter[key] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+// auditSnapshot turns a record into its JSON fields, so the audit log shows what an API client
+// would see and never fields hidden from JSON such as passwords
+func auditSnapshot(record interface{}) map[string]interface{} {
+	if record == nil {
+		return nil
+	}
+	if value := reflect.ValueOf(record); value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+	return snapshot
+}
+
+// AuditFingerprint is what the audit log stores in place of a personal data value
+func AuditFingerprint(value interface{}) string {
+	data, _ := json.Marshal(value)
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// redactAuditSnapshot replaces personal data in a snapshot with fingerprints. Empty values are
+// kept, so clearing a field still shows as cleared.
+func redactAuditSnapshot(snapshot map[string]interface{}) map[string]interface{} {
+	for key, value := range snapshot {
+		if !auditPIIFields[key] || value == nil || value == "" {
+			continue
+		}
+		snapshot[key] = AuditFingerprint(value)
+	}
+	return snapshot
+}
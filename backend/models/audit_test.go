@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditChain(t *testing.T) {
+	adminID := uint(1)
+	targetID := uint(7)
+	var entries []AdminAuditLog
+	prev := ""
+	for i, action := range []string{"user.ban", "report.update", "reports.view"} {
+		entry := AdminAuditLog{
+			ID:        uint(i + 1),
+			ActorID:   &adminID,
+			Actor:     "admin@example.com",
+			Action:    action,
+			TargetID:  &targetID,
+			After:     map[string]interface{}{"status": "banned"},
+			IP:        "10.0.0.1",
+			CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, i*1000, time.UTC),
+			PrevHash:  prev,
+		}
+		entry.Hash = entry.ComputeHash()
+		prev = entry.Hash
+		entries = append(entries, entry)
+	}
+
+	_, ok := VerifyAuditChain("", entries)
+	assert.True(t, ok)
+	_, ok = VerifyAuditChain(entries[0].Hash, entries[1:])
+	assert.True(t, ok, "a later stretch verifies from the hash before it")
+
+	// Editing an entry breaks it
+	tampered := append([]AdminAuditLog(nil), entries...)
+	tampered[1].After = map[string]interface{}{"status": "active"}
+	brokenID, ok := VerifyAuditChain("", tampered)
+	assert.False(t, ok)
+	assert.Equal(t, uint(2), brokenID)
+
+	// So does removing one
+	brokenID, ok = VerifyAuditChain("", []AdminAuditLog{entries[0], entries[2]})
+	assert.False(t, ok)
+	assert.Equal(t, uint(3), brokenID)
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := Report{ID: 1, Status: ReportOpen, Severity: ReportSeverityLow, UpdatedAt: time.Now()}
+	after := before
+	after.Status = ReportActioned
+	after.ResolutionNotes = "Warned"
+	after.UpdatedAt = time.Now().Add(time.Minute)
+
+	b, a := AuditDiff(before, after)
+	assert.Equal(t, map[string]interface{}{"status": "open"}, b)
+	assert.Equal(t, map[string]interface{}{"status": "actioned", "resolutionNotes": "Warned"}, a)
+
+	b, a = AuditDiff(nil, User{FirstName: "Ann", Password: "secret"})
+	assert.Nil(t, b)
+	assert.Equal(t, AuditFingerprint("Ann"), a["firstName"])
+	assert.NotContains(t, a, "password")
+
+	// Personal data is fingerprinted, so a change shows without the values
+	b, a = AuditDiff(User{Email: "ann@example.com", Phone: "+15550100", Bio: "Hi"}, User{Email: "deleted-1@invalid", Bio: "Hello"})
+	assert.Equal(t, map[string]interface{}{"email": AuditFingerprint("ann@example.com"), "phone": AuditFingerprint("+15550100"), "bio": "Hi"}, b)
+	assert.Equal(t, map[string]interface{}{"email": AuditFingerprint("deleted-1@invalid"), "phone": "", "bio": "Hello"}, a)
+	assert.NotEqual(t, b["email"], a["email"])
+}
+
+func TestVerifyAuditHead(t *testing.T) {
+	head := AuditChainHead{ID: 1, LastID: 3, LastHash: "c"}
+	assert.True(t, VerifyAuditHead(head, 3, "c"))
+	assert.False(t, VerifyAuditHead(head, 2, "b"), "entries removed from the end")
+	assert.False(t, VerifyAuditHead(AuditChainHead{}, 3, "c"), "entries after a head that was reset")
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// MutedIndefinitely is stored in MutedUntil when a conversation is muted without an end time
+var MutedIndefinitely = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// ConversationState holds one user's per-conversation preferences.
+// Conversations themselves are derived from messages, so this row is keyed by the user and their partner.
+type ConversationState struct {
+	ID             uint       `gorm:"primaryKey" json:"-"`
+	UserID         uint       `gorm:"not null;uniqueIndex:idx_conversation_state_pair" json:"userId"`
+	PartnerID      uint       `gorm:"not null;uniqueIndex:idx_conversation_state_pair" json:"partnerId"`
+	Archived       bool       `gorm:"default:false" json:"archived"`
+	MutedUntil     *time.Time `json:"mutedUntil,omitempty"`
+	Pinned         bool       `gorm:"default:false" json:"pinned"`
+	ManuallyUnread bool       `gorm:"default:false" json:"manuallyUnread"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// IsMuted reports whether the conversation is muted at the given time
+func (s ConversationState) IsMuted(now time.Time) bool {
+	return s.MutedUntil != nil && s.MutedUntil.After(now)
+}
+
+// UpdateConversationStateRequest defines the fields that can be changed on a conversation.
+// Omitted fields are left untouched.
+type UpdateConversationStateRequest struct {
+	Archived       *bool      `json:"archived,omitempty"`
+	Muted          *bool      `json:"muted,omitempty"`      // false unmutes; true without mutedUntil mutes indefinitely
+	MutedUntil     *time.Time `json:"mutedUntil,omitempty"` // RFC 3339 timestamp the mute expires at
+	Pinned         *bool      `json:"pinned,omitempty"`
+	ManuallyUnread *bool      `json:"manuallyUnread,omitempty"`
+}
@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// DataMigration records a one-time data migration that has been applied, so it never runs again
+type DataMigration struct {
+	Name      string    `gorm:"primaryKey;type:varchar(100)"`
+	AppliedAt time.Time `gorm:"not null"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// MatchIcebreaker is an icebreaker prompt suggested to a newly matched pair.
+// One row exists per pair and prompt; the pair is stored with the lower user ID first.
+type MatchIcebreaker struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserLowID     uint       `gorm:"not null;uniqueIndex:idx_match_icebreaker" json:"-"`
+	UserHighID    uint       `gorm:"not null;uniqueIndex:idx_match_icebreaker" json:"-"`
+	PromptID      string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_match_icebreaker;index" json:"promptId"`
+	Text          string     `gorm:"type:text;not null" json:"text"`
+	SentByID      *uint      `json:"sentById,omitempty"`
+	SentMessageID *uint      `json:"sentMessageId,omitempty"`
+	SentAt        *time.Time `json:"sentAt,omitempty"`
+	RepliedAt     *time.Time `json:"repliedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// IcebreakerPair returns a pair of user IDs in storage order
+func IcebreakerPair(a, b uint) (low, high uint) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+// IcebreakerStats summarizes how often a prompt is sent and replied to
+type IcebreakerStats struct {
+	PromptID  string  `json:"promptId"`
+	Suggested int64   `json:"suggested"`
+	Sent      int64   `json:"sent"`
+	Replied   int64   `json:"replied"`
+	ReplyRate float64 `json:"replyRate"`
+}
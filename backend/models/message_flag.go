@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// MessageFlagStatus tracks moderator review of a flagged message
+type MessageFlagStatus string
+
+const (
+	MessageFlagPending  MessageFlagStatus = "pending"  // Awaiting moderator review
+	MessageFlagApproved MessageFlagStatus = "approved" // Reviewed and allowed (held messages are delivered)
+	MessageFlagRejected MessageFlagStatus = "rejected" // Reviewed and confirmed as a violation
+)
+
+// MessageFlag records a message that tripped the chat safety filter.
+// Held and blocked messages are never written to messages, so the content is kept here.
+type MessageFlag struct {
+	ID         uint              `gorm:"primaryKey" json:"id"`
+	MessageID  *uint             `gorm:"index" json:"messageId,omitempty"` // Set when the message was delivered
+	SenderID   uint              `gorm:"not null;index" json:"senderId"`
+	ReceiverID uint              `gorm:"not null" json:"receiverId"`
+	Content    string            `gorm:"type:text;not null" json:"content"`
+	Action     string            `gorm:"type:varchar(20);not null" json:"action"` // Strictest action taken: warn, hold or block
+	Findings   []FlagFinding     `gorm:"type:json;serializer:json" json:"findings"`
+	Status     MessageFlagStatus `gorm:"type:varchar(20);default:'pending';index" json:"status"`
+	ReviewedBy *uint             `json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time        `json:"reviewedAt,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// FlagFinding is a single rule hit stored on a MessageFlag or a Photo
+type FlagFinding struct {
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason"`
+	Action  string `json:"action"`
+	MatchID uint   `json:"matchId,omitempty"` // The photo a flagged photo is a copy of
+}
+
+// ReviewMessageFlagRequest is a moderator's decision on a flagged message
+type ReviewMessageFlagRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AccountStatus says whether a user may use the app
+type AccountStatus string
+
+const (
+	AccountActive    AccountStatus = "active"
+	AccountSuspended AccountStatus = "suspended" // Locked out until SuspendedUntil
+	AccountBanned    AccountStatus = "banned"    // Locked out and hidden from other users for good
+)
+
+// IsBanned reports whether the account is permanently banned
+func (u User) IsBanned() bool {
+	return u.AccountStatus == AccountBanned
+}
+
+// IsSuspended reports whether the account is suspended at now. Suspensions lapse on their own.
+func (u User) IsSuspended(now time.Time) bool {
+	return u.AccountStatus == AccountSuspended && u.SuspendedUntil != nil && now.Before(*u.SuspendedUntil)
+}
+
+// AccountRestriction explains why the user can't sign in or use the API at now, if they can't
+func (u User) AccountRestriction(now time.Time) (string, bool) {
+	switch {
+	case u.IsBanned():
+		return "Your account has been banned", true
+	case u.IsSuspended(now):
+		return fmt.Sprintf("Your account is suspended until %s", u.SuspendedUntil.UTC().Format(time.RFC1123)), true
+	}
+	return "", false
+}
+
+// NotBanned is a query scope that leaves banned users out of a users query
+func NotBanned(db *gorm.DB) *gorm.DB {
+	return db.Where("users.account_status <> ?", AccountBanned)
+}
+
+// NotShadowBanned is a query scope that leaves shadow-banned users out of a users query
+func NotShadowBanned(db *gorm.DB) *gorm.DB {
+	return db.Where("users.shadow_banned = ?", false)
+}
+
+// NotHiddenPendingReview is a query scope that leaves users held back by escalation rules out of a users query
+func NotHiddenPendingReview(db *gorm.DB) *gorm.DB {
+	return db.Where("users.hidden_pending_review = ?", false)
+}
+
+// ModerationActionType is what a moderator did to an account
+type ModerationActionType string
+
+const (
+	ModerationSuspend   ModerationActionType = "suspend"
+	ModerationBan       ModerationActionType = "ban"
+	ModerationReinstate ModerationActionType = "reinstate"
+	// A shadow-banned user can keep using the app, but their likes never match or notify, their
+	// messages are never delivered and nobody else sees them in matches
+	ModerationShadowBan     ModerationActionType = "shadow_ban"
+	ModerationLiftShadowBan ModerationActionType = "lift_shadow_ban"
+)
+
+// Restricts reports whether the action penalizes the account, as opposed to lifting a penalty
+func (a ModerationActionType) Restricts() bool {
+	return a == ModerationSuspend || a == ModerationBan || a == ModerationShadowBan
+}
+
+// ModerationAction records a change to a user's account status and the report behind it
+type ModerationAction struct {
+	ID             uint                 `gorm:"primaryKey" json:"id"`
+	UserID         uint                 `gorm:"not null;index" json:"userId"`           // The account acted on
+	ModeratorID    uint                 `gorm:"not null" json:"moderatorId"`            // 0 when an escalation rule took the action
+	Rule           string               `gorm:"type:varchar(50)" json:"rule,omitempty"` // The escalation rule that took the action automatically
+	Action         ModerationActionType `gorm:"type:varchar(20);not null" json:"action"`
+	Reason         string               `gorm:"type:text;not null" json:"reason"`
+	ReportID       *uint                `gorm:"index" json:"reportId,omitempty"` // Required for suspensions and bans
+	SuspendedUntil *time.Time           `json:"suspendedUntil,omitempty"`        // Set on suspensions
+	CreatedAt      time.Time            `json:"createdAt"`
+}
+
+// SuspendUserRequest suspends an account for a number of hours because of a report
+type SuspendUserRequest struct {
+	ReportID      uint   `json:"reportId" binding:"required"`
+	Reason        string `json:"reason" binding:"required,max=500"`               // Shown to the user
+	DurationHours int    `json:"durationHours" binding:"required,min=1,max=8760"` // Up to a year
+}
+
+// BanUserRequest permanently bans an account because of a report
+type BanUserRequest struct {
+	ReportID uint   `json:"reportId" binding:"required"`
+	Reason   string `json:"reason" binding:"required,max=500"` // Shown to the user
+}
+
+// ReinstateUserRequest lifts a suspension or ban
+type ReinstateUserRequest struct {
+	ReportID *uint  `json:"reportId,omitempty"` // The report the lifted action was for, if any
+	Reason   string `json:"reason" binding:"required,max=500"`
+}
+
+// ShadowBanUserRequest turns shadow-banning on or off for an account
+type ShadowBanUserRequest struct {
+	Enabled  bool   `json:"enabled"`
+	ReportID *uint  `json:"reportId,omitempty"`                // The report that prompted it, if any
+	Reason   string `json:"reason" binding:"required,max=500"` // Only visible to moderators
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountRestriction(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	_, restricted := User{AccountStatus: AccountActive}.AccountRestriction(now)
+	assert.False(t, restricted)
+
+	message, restricted := User{AccountStatus: AccountBanned}.AccountRestriction(now)
+	assert.True(t, restricted)
+	assert.Equal(t, "Your account has been banned", message)
+
+	message, restricted = User{AccountStatus: AccountSuspended, SuspendedUntil: &later}.AccountRestriction(now)
+	assert.True(t, restricted)
+	assert.Contains(t, message, "suspended until")
+
+	// Suspensions lapse on their own
+	_, restricted = User{AccountStatus: AccountSuspended, SuspendedUntil: &earlier}.AccountRestriction(now)
+	assert.False(t, restricted)
+}
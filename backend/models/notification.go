@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -10,12 +11,40 @@ import (
 type NotificationType string
 
 const (
-	NotificationTypeMatch   NotificationType = "match"
-	NotificationTypeMessage NotificationType = "message"
-	NotificationTypeLike    NotificationType = "like"
-	NotificationTypeView    NotificationType = "profile_view"
+	NotificationTypeMatch        NotificationType = "match"
+	NotificationTypeMessage      NotificationType = "message"
+	NotificationTypeLike         NotificationType = "like"
+	NotificationTypeView         NotificationType = "profile_view"
+	NotificationTypeAppUpdate    NotificationType = "app_update"
+	NotificationTypeReport       NotificationType = "report"       // Outcome of a report the user submitted
+	NotificationTypePhoto        NotificationType = "photo"        // A moderator rejected one of the user's photos
+	NotificationTypeVerification NotificationType = "verification" // Outcome of the user's selfie verification
+	NotificationTypeAppeal       NotificationType = "appeal"       // Outcome of an appeal the user made
 )
 
+// NotificationTypes lists every notification type users can set preferences for
+var NotificationTypes = []NotificationType{
+	NotificationTypeMatch,
+	NotificationTypeMessage,
+	NotificationTypeLike,
+	NotificationTypeView,
+	NotificationTypeAppUpdate,
+	NotificationTypeReport,
+	NotificationTypePhoto,
+	NotificationTypeVerification,
+	NotificationTypeAppeal,
+}
+
+// IsValid reports whether t is a known notification type
+func (t NotificationType) IsValid() bool {
+	for _, known := range NotificationTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
 // Notification represents a user notification
 type Notification struct {
 	ID         uint             `gorm:"primaryKey" json:"id"`
@@ -24,12 +53,20 @@ type Notification struct {
 	Type       NotificationType `gorm:"not null" json:"type"`
 	Title      string           `gorm:"not null" json:"title"`
 	Message    string           `gorm:"not null" json:"message"`
-	Data       string           `gorm:"type:json" json:"data,omitempty"` // Additional data as JSON
+	Data       string           `gorm:"type:json" json:"data,omitempty"` // Type-specific payload encoded with EncodeNotificationData
 	Read       bool             `gorm:"default:false" json:"read"`
-	CreatedAt  time.Time        `json:"createdAt"`
+	ReadAt     *time.Time       `gorm:"index" json:"readAt,omitempty"` // When the notification was first marked read
+	CreatedAt  time.Time        `json:"createdAt"`                     // Time of the latest event when notifications are grouped
 	UpdatedAt  time.Time        `json:"updatedAt"`
 	DeletedAt  gorm.DeletedAt   `gorm:"index" json:"-"`
 
+	// Grouping: repeats with the same key inside the type's window update one unread notification
+	GroupKey string `gorm:"type:varchar(100);index" json:"groupKey,omitempty"`
+	Count    int    `gorm:"default:1" json:"count"`                              // Events folded into this notification
+	ActorIDs []uint `gorm:"type:json;serializer:json" json:"actorIds,omitempty"` // Distinct users who triggered it, oldest first
+
+	AnnouncementID *uint `gorm:"index" json:"announcementId,omitempty"` // Set on app updates sent as an announcement
+
 	// Relationships
 	User     User  `gorm:"foreignKey:UserID" json:"-"`
 	FromUser *User `gorm:"foreignKey:FromUserID" json:"fromUser,omitempty"`
@@ -56,10 +93,13 @@ type NotificationResponse struct {
 	Type      NotificationType `json:"type"`
 	Title     string           `json:"title"`
 	Message   string           `json:"message"`
-	Data      string           `json:"data,omitempty"`
+	Data      json.RawMessage  `json:"data,omitempty" swaggertype:"object"` // Type-specific payload with a "version" field; see models.NotificationPayload
 	Read      bool             `json:"read"`
 	CreatedAt time.Time        `json:"createdAt"`
 	FromUser  *UserBasicInfo   `json:"fromUser,omitempty"`
+	GroupKey  string           `json:"groupKey,omitempty"`
+	Count     int              `json:"count"`
+	Actors    []UserBasicInfo  `json:"actors,omitempty"` // Most recent first
 }
 
 // UserBasicInfo represents basic user info for notifications
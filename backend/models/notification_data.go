@@ -0,0 +1,278 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// NotificationDataVersion is the schema version written into every notification's data.
+// Bump it when a payload changes incompatibly, so clients can tell old and new rows apart.
+const NotificationDataVersion = 1
+
+// NotificationPayload is the typed data attached to one kind of notification
+type NotificationPayload interface {
+	NotificationType() NotificationType
+	Validate() error
+}
+
+// LocalizedPayload is a payload that carries the catalog text of a notification no user
+// triggered, so it can be rendered in the reader's language when read
+type LocalizedPayload interface {
+	NotificationPayload
+	CatalogText() NotificationText
+}
+
+// NotificationText is a notification's catalog message keys and the values interpolated into them.
+// Rows written before it was stored leave it empty and keep their stored text.
+type NotificationText struct {
+	TitleKey   string            `json:"titleKey,omitempty"`
+	MessageKey string            `json:"messageKey,omitempty"`
+	Vars       map[string]string `json:"vars,omitempty"`
+}
+
+// CatalogText returns the text's message keys and values
+func (t NotificationText) CatalogText() NotificationText { return t }
+
+// MatchData is the data of a match notification
+type MatchData struct {
+	MatchedUserID uint   `json:"matchedUserId"`
+	Action        string `json:"action"` // view_match
+}
+
+// MessageData is the data of a message notification
+type MessageData struct {
+	SenderID       uint   `json:"senderId"`
+	MessagePreview string `json:"messagePreview"` // Start of the latest message
+	Action         string `json:"action"`         // view_chat
+}
+
+// LikeData is the data of a like notification
+type LikeData struct {
+	LikerID uint   `json:"likerId"`
+	Action  string `json:"action"` // view_profile
+}
+
+// ProfileViewData is the data of a profile-view notification
+type ProfileViewData struct {
+	ViewerID uint   `json:"viewerId"`
+	Action   string `json:"action"` // view_profile_views
+}
+
+// AppUpdateData is the data of an app update or announcement notification
+type AppUpdateData struct {
+	AnnouncementID uint   `json:"announcementId,omitempty"`
+	URL            string `json:"url,omitempty"` // Optional link for more details
+	Action         string `json:"action"`        // open_url or view_notifications
+}
+
+// ReportData is the data of a report outcome notification
+type ReportData struct {
+	ReportID uint         `json:"reportId"`
+	Status   ReportStatus `json:"status"` // actioned or dismissed
+	Action   string       `json:"action"` // view_report
+	NotificationText
+}
+
+// PhotoData is the data of a photo moderation notification
+type PhotoData struct {
+	PhotoID uint        `json:"photoId"`
+	Status  PhotoStatus `json:"status"` // rejected
+	Reason  string      `json:"reason"`
+	Action  string      `json:"action"` // edit_photos
+	NotificationText
+}
+
+// VerificationData is the data of a selfie verification outcome notification
+type VerificationData struct {
+	RequestID uint               `json:"requestId"`
+	Status    VerificationStatus `json:"status"`           // approved or rejected
+	Reason    string             `json:"reason,omitempty"` // Why it was rejected
+	Action    string             `json:"action"`           // view_verification
+	NotificationText
+}
+
+// AppealData is the data of an appeal outcome notification
+type AppealData struct {
+	AppealID uint         `json:"appealId"`
+	Status   AppealStatus `json:"status"` // upheld or overturned
+	Action   string       `json:"action"` // view_appeal
+	NotificationText
+}
+
+func (MatchData) NotificationType() NotificationType        { return NotificationTypeMatch }
+func (MessageData) NotificationType() NotificationType      { return NotificationTypeMessage }
+func (LikeData) NotificationType() NotificationType         { return NotificationTypeLike }
+func (ProfileViewData) NotificationType() NotificationType  { return NotificationTypeView }
+func (AppUpdateData) NotificationType() NotificationType    { return NotificationTypeAppUpdate }
+func (ReportData) NotificationType() NotificationType       { return NotificationTypeReport }
+func (PhotoData) NotificationType() NotificationType        { return NotificationTypePhoto }
+func (VerificationData) NotificationType() NotificationType { return NotificationTypeVerification }
+func (AppealData) NotificationType() NotificationType       { return NotificationTypeAppeal }
+
+// Validate checks the payload before it is stored
+func (d MatchData) Validate() error {
+	return requireFields(d.MatchedUserID != 0, "matchedUserId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d MessageData) Validate() error {
+	return requireFields(d.SenderID != 0, "senderId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d LikeData) Validate() error {
+	return requireFields(d.LikerID != 0, "likerId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d ProfileViewData) Validate() error {
+	return requireFields(d.ViewerID != 0, "viewerId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d AppUpdateData) Validate() error {
+	if d.Action == "open_url" && d.URL == "" {
+		return errors.New("url is required for the open_url action")
+	}
+	return requireFields(true, "", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d ReportData) Validate() error {
+	if !d.Status.IsResolved() {
+		return fmt.Errorf("status must be %s or %s", ReportActioned, ReportDismissed)
+	}
+	return requireFields(d.ReportID != 0, "reportId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d PhotoData) Validate() error {
+	if d.Status != PhotoRejected {
+		return fmt.Errorf("status must be %s", PhotoRejected)
+	}
+	if d.Reason == "" {
+		return errors.New("reason is required")
+	}
+	return requireFields(d.PhotoID != 0, "photoId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d VerificationData) Validate() error {
+	switch d.Status {
+	case VerificationApproved:
+	case VerificationRejected:
+		if d.Reason == "" {
+			return errors.New("reason is required when rejected")
+		}
+	default:
+		return fmt.Errorf("status must be %s or %s", VerificationApproved, VerificationRejected)
+	}
+	return requireFields(d.RequestID != 0, "requestId", d.Action)
+}
+
+// Validate checks the payload before it is stored
+func (d AppealData) Validate() error {
+	if d.Status != AppealUpheld && d.Status != AppealOverturned {
+		return fmt.Errorf("status must be %s or %s", AppealUpheld, AppealOverturned)
+	}
+	return requireFields(d.AppealID != 0, "appealId", d.Action)
+}
+
+func requireFields(hasID bool, idField, action string) error {
+	if !hasID {
+		return fmt.Errorf("%s is required", idField)
+	}
+	if action == "" {
+		return errors.New("action is required")
+	}
+	return nil
+}
+
+// newNotificationPayload returns an empty payload for a notification type
+func newNotificationPayload(notificationType NotificationType) (NotificationPayload, error) {
+	switch notificationType {
+	case NotificationTypeMatch:
+		return &MatchData{}, nil
+	case NotificationTypeMessage:
+		return &MessageData{}, nil
+	case NotificationTypeLike:
+		return &LikeData{}, nil
+	case NotificationTypeView:
+		return &ProfileViewData{}, nil
+	case NotificationTypeAppUpdate:
+		return &AppUpdateData{}, nil
+	case NotificationTypeReport:
+		return &ReportData{}, nil
+	case NotificationTypePhoto:
+		return &PhotoData{}, nil
+	case NotificationTypeVerification:
+		return &VerificationData{}, nil
+	case NotificationTypeAppeal:
+		return &AppealData{}, nil
+	}
+	return nil, fmt.Errorf("unknown notification type %q", notificationType)
+}
+
+// EncodeNotificationData validates a payload and returns the JSON stored in Notification.Data,
+// tagged with the schema version
+func EncodeNotificationData(payload NotificationPayload) (string, error) {
+	if err := payload.Validate(); err != nil {
+		return "", fmt.Errorf("invalid %s notification data: %v", payload.NotificationType(), err)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	// Add the version alongside the payload's own fields
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", err
+	}
+	fields["version"] = json.RawMessage(fmt.Sprint(NotificationDataVersion))
+	body, err = json.Marshal(fields)
+	return string(body), err
+}
+
+// DecodeNotificationData parses and validates stored data for a notification type.
+// Rows written before data was versioned have the same fields and decode as version 1.
+func DecodeNotificationData(notificationType NotificationType, data string) (NotificationPayload, error) {
+	payload, err := newNotificationPayload(notificationType)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, fmt.Errorf("%s notification data is required", notificationType)
+	}
+
+	var header struct {
+		Version *int `json:"version"`
+	}
+	if err := json.Unmarshal([]byte(data), &header); err != nil {
+		return nil, fmt.Errorf("invalid %s notification data: %v", notificationType, err)
+	}
+	if header.Version != nil && *header.Version > NotificationDataVersion {
+		return nil, fmt.Errorf("unsupported %s notification data version %d", notificationType, *header.Version)
+	}
+	if err := json.Unmarshal([]byte(data), payload); err != nil {
+		return nil, fmt.Errorf("invalid %s notification data: %v", notificationType, err)
+	}
+	if err := payload.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s notification data: %v", notificationType, err)
+	}
+	return payload, nil
+}
+
+// NotificationDataJSON returns a notification's data as a versioned JSON object for API responses,
+// or nil if the stored data can't be read
+func NotificationDataJSON(notificationType NotificationType, data string) json.RawMessage {
+	payload, err := DecodeNotificationData(notificationType, data)
+	if err != nil {
+		return nil
+	}
+	encoded, err := EncodeNotificationData(payload)
+	if err != nil {
+		return nil
+	}
+	return json.RawMessage(encoded)
+}
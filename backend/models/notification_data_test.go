@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeNotificationData(t *testing.T) {
+	data, err := EncodeNotificationData(LikeData{LikerID: 4, Action: "view_profile"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"version":1,"likerId":4,"action":"view_profile"}`, data)
+
+	_, err = EncodeNotificationData(LikeData{Action: "view_profile"})
+	assert.Error(t, err)
+	_, err = EncodeNotificationData(AppUpdateData{Action: "open_url"})
+	assert.Error(t, err, "open_url needs a url")
+}
+
+func TestDecodeNotificationData(t *testing.T) {
+	payload, err := DecodeNotificationData(NotificationTypeMatch, `{"version":1,"matchedUserId":9,"action":"view_match"}`)
+	require.NoError(t, err)
+	assert.Equal(t, &MatchData{MatchedUserID: 9, Action: "view_match"}, payload)
+
+	// Rows written before versioning decode as version 1
+	_, err = DecodeNotificationData(NotificationTypeLike, `{"likerId": 3, "action": "view_profile"}`)
+	assert.NoError(t, err)
+
+	for name, bad := range map[string]struct {
+		notificationType NotificationType
+		data             string
+	}{
+		"empty":         {NotificationTypeLike, ""},
+		"invalid json":  {NotificationTypeMessage, `{"senderId": 3, "messagePreview": "say "hi"", "action": "view_chat"}`},
+		"missing id":    {NotificationTypeMessage, `{"version":1,"action":"view_chat"}`},
+		"future schema": {NotificationTypeLike, `{"version":99,"likerId":3,"action":"view_profile"}`},
+		"unknown type":  {NotificationType("poke"), `{}`},
+	} {
+		_, err := DecodeNotificationData(bad.notificationType, bad.data)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestNotificationDataJSON(t *testing.T) {
+	raw := NotificationDataJSON(NotificationTypeView, `{"viewerId": 2, "action": "view_profile_views"}`)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	assert.Equal(t, float64(NotificationDataVersion), decoded["version"])
+	assert.Equal(t, float64(2), decoded["viewerId"])
+
+	assert.Nil(t, NotificationDataJSON(NotificationTypeView, `not json`))
+}
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// NotificationChannel is a way of reaching a user
+type NotificationChannel string
+
+const (
+	ChannelInApp NotificationChannel = "in_app"
+	ChannelPush  NotificationChannel = "push"
+	ChannelEmail NotificationChannel = "email"
+)
+
+// ChannelPreferences says which channels a notification type may use
+type ChannelPreferences struct {
+	InApp bool `json:"inApp"`
+	Push  bool `json:"push"`
+	Email bool `json:"email"` // Included in email digests
+}
+
+// Allows reports whether the channel is enabled
+func (p ChannelPreferences) Allows(channel NotificationChannel) bool {
+	switch channel {
+	case ChannelInApp:
+		return p.InApp
+	case ChannelPush:
+		return p.Push
+	case ChannelEmail:
+		return p.Email
+	}
+	return false
+}
+
+// QuietHours silences push and email delivery during a daily window in the user's timezone.
+// Start and End are "HH:MM"; a window where End is before Start wraps past midnight.
+type QuietHours struct {
+	Enabled bool   `json:"enabled"`
+	Start   string `json:"start"`
+	End     string `json:"end"`
+}
+
+// Validate checks the window's time format
+func (q QuietHours) Validate() error {
+	if !q.Enabled {
+		return nil
+	}
+	if _, err := parseClock(q.Start); err != nil {
+		return fmt.Errorf("invalid quiet hours start: %v", err)
+	}
+	if _, err := parseClock(q.End); err != nil {
+		return fmt.Errorf("invalid quiet hours end: %v", err)
+	}
+	return nil
+}
+
+// Contains reports whether t falls inside the quiet window when viewed in loc
+func (q QuietHours) Contains(t time.Time, loc *time.Location) bool {
+	if !q.Enabled {
+		return false
+	}
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(q.End)
+	if err != nil || start == end {
+		return false
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseClock converts "HH:MM" to minutes after midnight
+func parseClock(s string) (int, error) {
+	clock, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", s)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+// DigestFrequency is how often the unread-activity email digest is sent
+type DigestFrequency string
+
+const (
+	DigestOff    DigestFrequency = "off"
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+// Period returns the time between digests, or zero when digests are off
+func (f DigestFrequency) Period() time.Duration {
+	switch f {
+	case DigestDaily:
+		return 24 * time.Hour
+	case DigestWeekly:
+		return 7 * 24 * time.Hour
+	}
+	return 0
+}
+
+// NotificationSettings represents user notification preferences
+type NotificationSettings struct {
+	Types      map[NotificationType]ChannelPreferences `json:"types,omitempty"`
+	QuietHours QuietHours                              `json:"quietHours"`
+
+	// Legacy toggles from before per-type preferences. They are still accepted on update and
+	// echoed on read for older clients, but Types is the source of truth once set.
+	NewMatches *bool `json:"newMatches,omitempty"`
+	Messages   *bool `json:"messages,omitempty"`
+	AppUpdates *bool `json:"appUpdates,omitempty"`
+}
+
+// NotificationSettingsUpdate is a partial change to NotificationSettings. Types that are sent
+// replace the stored ones, and quiet hours are replaced whenever they are sent, even when disabled.
+type NotificationSettingsUpdate struct {
+	Types      map[NotificationType]ChannelPreferences `json:"types,omitempty"`
+	QuietHours *QuietHours                             `json:"quietHours,omitempty"`
+
+	// Legacy toggles from older clients. A type sent explicitly in Types takes precedence.
+	NewMatches *bool `json:"newMatches,omitempty"`
+	Messages   *bool `json:"messages,omitempty"`
+	AppUpdates *bool `json:"appUpdates,omitempty"`
+}
+
+// Validate checks notification types and quiet hours
+func (u NotificationSettingsUpdate) Validate() error {
+	for notificationType := range u.Types {
+		if !notificationType.IsValid() {
+			return fmt.Errorf("unknown notification type %q", notificationType)
+		}
+	}
+	if u.QuietHours == nil {
+		return nil
+	}
+	return u.QuietHours.Validate()
+}
+
+// DefaultChannelPreferences returns the preferences used for types a user hasn't configured
+func DefaultChannelPreferences(notificationType NotificationType) ChannelPreferences {
+	switch notificationType {
+	case NotificationTypeAppUpdate:
+		return ChannelPreferences{InApp: false, Push: false, Email: false}
+	case NotificationTypeView:
+		return ChannelPreferences{InApp: true, Push: false, Email: false}
+	default:
+		return ChannelPreferences{InApp: true, Push: true, Email: true}
+	}
+}
+
+// DefaultNotificationSettings returns the settings given to new users
+func DefaultNotificationSettings() NotificationSettings {
+	types := make(map[NotificationType]ChannelPreferences, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		types[notificationType] = DefaultChannelPreferences(notificationType)
+	}
+	return NotificationSettings{
+		Types:      types,
+		QuietHours: QuietHours{Enabled: false, Start: "22:00", End: "08:00"},
+	}
+}
+
+// IsZero reports whether no settings have been stored at all
+func (s NotificationSettings) IsZero() bool {
+	return len(s.Types) == 0 && s.NewMatches == nil && s.Messages == nil && s.AppUpdates == nil &&
+		s.QuietHours == (QuietHours{})
+}
+
+// ChannelsFor returns the channel preferences for a notification type
+func (s NotificationSettings) ChannelsFor(notificationType NotificationType) ChannelPreferences {
+	if prefs, ok := s.Types[notificationType]; ok {
+		return prefs
+	}
+
+	// Fall back to the legacy toggles for settings saved before per-type preferences
+	prefs := DefaultChannelPreferences(notificationType)
+	var legacy *bool
+	switch notificationType {
+	case NotificationTypeMatch, NotificationTypeLike:
+		legacy = s.NewMatches
+	case NotificationTypeMessage:
+		legacy = s.Messages
+	case NotificationTypeAppUpdate:
+		legacy = s.AppUpdates
+	}
+	if legacy != nil {
+		prefs.InApp = *legacy
+		prefs.Push = *legacy && prefs.Push
+	}
+	return prefs
+}
+
+// Normalize folds legacy toggles into Types and fills in any unconfigured types
+func (s *NotificationSettings) Normalize() {
+	types := make(map[NotificationType]ChannelPreferences, len(NotificationTypes))
+	for _, notificationType := range NotificationTypes {
+		types[notificationType] = s.ChannelsFor(notificationType)
+	}
+
+	// Explicit legacy toggles sent by older clients override the in-app preference
+	applyLegacy := func(toggle *bool, notificationTypes ...NotificationType) {
+		if toggle == nil {
+			return
+		}
+		for _, notificationType := range notificationTypes {
+			prefs := types[notificationType]
+			prefs.InApp = *toggle
+			types[notificationType] = prefs
+		}
+	}
+	if len(s.Types) > 0 {
+		applyLegacy(s.NewMatches, NotificationTypeMatch, NotificationTypeLike)
+		applyLegacy(s.Messages, NotificationTypeMessage)
+		applyLegacy(s.AppUpdates, NotificationTypeAppUpdate)
+	}
+
+	s.Types = types
+	s.NewMatches, s.Messages, s.AppUpdates = nil, nil, nil
+	if s.QuietHours.Start == "" && s.QuietHours.End == "" {
+		s.QuietHours = DefaultNotificationSettings().QuietHours
+	}
+}
+
+// WithLegacyToggles returns a copy with the legacy toggles filled in from Types for older clients
+func (s NotificationSettings) WithLegacyToggles() NotificationSettings {
+	newMatches := s.ChannelsFor(NotificationTypeMatch).InApp
+	messages := s.ChannelsFor(NotificationTypeMessage).InApp
+	appUpdates := s.ChannelsFor(NotificationTypeAppUpdate).InApp
+	s.NewMatches, s.Messages, s.AppUpdates = &newMatches, &messages, &appUpdates
+	return s
+}
+
+// Validate checks notification types and quiet hours
+func (s NotificationSettings) Validate() error {
+	for notificationType := range s.Types {
+		if !notificationType.IsValid() {
+			return fmt.Errorf("unknown notification type %q", notificationType)
+		}
+	}
+	return s.QuietHours.Validate()
+}
+
+// UserLocation returns the user's timezone, defaulting to UTC
+func (u User) UserLocation() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ShouldNotify is the single notification policy: it reports whether a notification of the given
+// type may reach the user over channel right now. Quiet hours hold back push and email only;
+// in-app notifications are still recorded so nothing is lost.
+func ShouldNotify(user User, notificationType NotificationType, channel NotificationChannel) bool {
+	return ShouldNotifyAt(user, notificationType, channel, time.Now())
+}
+
+// ShouldNotifyAt is ShouldNotify evaluated at a given time
+func ShouldNotifyAt(user User, notificationType NotificationType, channel NotificationChannel, now time.Time) bool {
+	if !user.NotificationSettings.ChannelsFor(notificationType).Allows(channel) {
+		return false
+	}
+	if channel != ChannelInApp && user.NotificationSettings.QuietHours.Contains(now, user.UserLocation()) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietHoursContains(t *testing.T) {
+	overnight := QuietHours{Enabled: true, Start: "22:00", End: "08:00"}
+	at := func(hour, minute int) time.Time { return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC) }
+
+	assert.True(t, overnight.Contains(at(23, 30), time.UTC))
+	assert.True(t, overnight.Contains(at(7, 59), time.UTC))
+	assert.False(t, overnight.Contains(at(8, 0), time.UTC))
+	assert.False(t, overnight.Contains(at(12, 0), time.UTC))
+
+	daytime := QuietHours{Enabled: true, Start: "09:00", End: "17:00"}
+	assert.True(t, daytime.Contains(at(9, 0), time.UTC))
+	assert.False(t, daytime.Contains(at(17, 0), time.UTC))
+
+	// 12:00 UTC is 21:00 in Tokyo, outside 22:00-08:00; 14:00 UTC is 23:00
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if assert.NoError(t, err) {
+		assert.False(t, overnight.Contains(at(12, 0), tokyo))
+		assert.True(t, overnight.Contains(at(14, 0), tokyo))
+	}
+
+	disabled := QuietHours{Start: "00:00", End: "23:59"}
+	assert.False(t, disabled.Contains(at(12, 0), time.UTC))
+}
+
+func TestNotificationSettingsLegacyFallback(t *testing.T) {
+	off := false
+	legacy := NotificationSettings{Messages: &off}
+
+	assert.False(t, legacy.ChannelsFor(NotificationTypeMessage).InApp)
+	assert.False(t, legacy.ChannelsFor(NotificationTypeMessage).Push)
+	assert.True(t, legacy.ChannelsFor(NotificationTypeMatch).InApp)
+
+	legacy.Normalize()
+	assert.Nil(t, legacy.Messages)
+	assert.False(t, legacy.Types[NotificationTypeMessage].InApp)
+	assert.Len(t, legacy.Types, len(NotificationTypes))
+
+	echoed := legacy.WithLegacyToggles()
+	if assert.NotNil(t, echoed.Messages) {
+		assert.False(t, *echoed.Messages)
+	}
+}
+
+func TestShouldNotifyAt(t *testing.T) {
+	user := User{Timezone: "UTC", NotificationSettings: DefaultNotificationSettings()}
+	user.NotificationSettings.QuietHours = QuietHours{Enabled: true, Start: "22:00", End: "08:00"}
+	night := time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, ShouldNotifyAt(user, NotificationTypeMessage, ChannelPush, noon))
+	assert.False(t, ShouldNotifyAt(user, NotificationTypeMessage, ChannelPush, night))
+	assert.True(t, ShouldNotifyAt(user, NotificationTypeMessage, ChannelInApp, night), "quiet hours never drop in-app notifications")
+	assert.True(t, ShouldNotifyAt(user, NotificationTypeMessage, ChannelEmail, noon), "messages go in the email digest by default")
+	assert.False(t, ShouldNotifyAt(user, NotificationTypeMessage, ChannelEmail, night))
+	assert.False(t, ShouldNotifyAt(user, NotificationTypeView, ChannelEmail, noon), "profile views stay out of the digest")
+	assert.False(t, ShouldNotifyAt(user, NotificationTypeAppUpdate, ChannelInApp, noon), "app updates are opt-in")
+}
+
+func TestNotificationSettingsValidate(t *testing.T) {
+	settings := DefaultNotificationSettings()
+	assert.NoError(t, settings.Validate())
+
+	settings.Types["poke"] = ChannelPreferences{InApp: true}
+	assert.Error(t, settings.Validate())
+
+	bad := DefaultNotificationSettings()
+	bad.QuietHours = QuietHours{Enabled: true, Start: "25:00", End: "08:00"}
+	assert.Error(t, bad.Validate())
+}
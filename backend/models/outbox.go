@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+)
+
+// OutboxStatus tracks an outbox event through delivery
+type OutboxStatus string
+
+const (
+	OutboxPending   OutboxStatus = "pending"
+	OutboxDelivered OutboxStatus = "delivered"
+	OutboxDead      OutboxStatus = "dead" // Gave up after repeated failures; needs an admin to retry
+)
+
+// OutboxEvent is a side effect (notification, activity log, ...) recorded in the same transaction
+// as the domain change that caused it, then delivered by the outbox dispatcher
+type OutboxEvent struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	Kind          string       `gorm:"type:varchar(50);not null;index" json:"kind"`
+	Payload       string       `gorm:"type:json;not null" json:"payload"`
+	Status        OutboxStatus `gorm:"type:varchar(20);not null;default:pending;index:idx_outbox_due" json:"status"`
+	NextAttemptAt time.Time    `gorm:"not null;index:idx_outbox_due" json:"nextAttemptAt"`
+	Attempts      int          `gorm:"default:0" json:"attempts"`
+	LastError     string       `gorm:"type:text" json:"lastError,omitempty"`
+	DeliveredAt   *time.Time   `json:"deliveredAt,omitempty"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// PhotoStatus tracks moderator review of an uploaded photo
+type PhotoStatus string
+
+const (
+	PhotoPending  PhotoStatus = "pending"  // Waiting for a moderator; only the owner sees it
+	PhotoApproved PhotoStatus = "approved" // Shown to other users
+	PhotoRejected PhotoStatus = "rejected" // Never shown to other users; the owner is told why
+)
+
+// Photo is the moderation record of one uploaded photo. Users keep listing their photos by URL
+// in User.Photos; the URLs other users may see are copied to User.ApprovedPhotos.
+type Photo struct {
+	ID              uint          `gorm:"primaryKey" json:"id"`
+	UserID          *uint         `gorm:"index" json:"userId,omitempty"` // Nil until a photo uploaded during registration is claimed
+	URL             string        `gorm:"type:varchar(500);not null;uniqueIndex" json:"url"`
+	Status          PhotoStatus   `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	RejectionReason string        `gorm:"type:text" json:"rejectionReason,omitempty"`
+	Findings        []FlagFinding `gorm:"type:json;serializer:json" json:"findings,omitempty"` // What the automatic pre-screen found
+	Hash            string        `gorm:"type:varchar(16);index" json:"hash,omitempty"`        // Perceptual hash; see imagehash.DHash. Empty for photos never uploaded here
+	DuplicateOfID   *uint         `gorm:"index" json:"duplicateOfId,omitempty"`                // Closest near-duplicate on another account, found on upload
+	ReviewedBy      *uint         `json:"reviewedBy,omitempty"`                                // Nil when decided by the pre-screen
+	ReviewedAt      *time.Time    `json:"reviewedAt,omitempty"`
+	CreatedAt       time.Time     `json:"createdAt"`
+	UpdatedAt       time.Time     `json:"updatedAt"`
+}
+
+// ReviewPhotoRequest is a moderator's decision on a photo. A reason is required to reject.
+type ReviewPhotoRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Reason   string `json:"reason" binding:"max=500"`
+}
+
+// PhotoReview is the moderation state of one of the user's own photos
+type PhotoReview struct {
+	URL             string      `json:"url"`
+	Status          PhotoStatus `json:"status"`
+	RejectionReason string      `json:"rejectionReason,omitempty"`
+}
+
+// SyncApprovedPhotos copies the photos other users may see, given the status of each URL. The
+// profile picture falls back to the first approved photo when the chosen one isn't approved.
+func (u *User) SyncApprovedPhotos(statuses map[string]PhotoStatus) {
+	u.ApprovedPhotos = []string{}
+	for _, url := range u.Photos {
+		if statuses[url] == PhotoApproved {
+			u.ApprovedPhotos = append(u.ApprovedPhotos, url)
+		}
+	}
+	switch {
+	case u.ProfilePictureURL != "" && statuses[u.ProfilePictureURL] == PhotoApproved:
+		u.ApprovedPictureURL = u.ProfilePictureURL
+	case len(u.ApprovedPhotos) > 0:
+		u.ApprovedPictureURL = u.ApprovedPhotos[0]
+	default:
+		u.ApprovedPictureURL = ""
+	}
+}
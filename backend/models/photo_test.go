@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncApprovedPhotos(t *testing.T) {
+	user := User{Photos: []string{"a.jpg", "b.jpg", "c.jpg"}, ProfilePictureURL: "a.jpg"}
+
+	user.SyncApprovedPhotos(map[string]PhotoStatus{"a.jpg": PhotoPending, "b.jpg": PhotoApproved, "c.jpg": PhotoApproved})
+	assert.Equal(t, []string{"b.jpg", "c.jpg"}, user.ApprovedPhotos)
+	assert.Equal(t, "b.jpg", user.ApprovedPictureURL, "falls back to the first approved photo")
+
+	user.SyncApprovedPhotos(map[string]PhotoStatus{"a.jpg": PhotoApproved, "b.jpg": PhotoRejected})
+	assert.Equal(t, []string{"a.jpg"}, user.ApprovedPhotos)
+	assert.Equal(t, "a.jpg", user.ApprovedPictureURL)
+
+	user.SyncApprovedPhotos(nil)
+	assert.NotNil(t, user.ApprovedPhotos, "an empty list marks the account as synced")
+	assert.Empty(t, user.ApprovedPhotos)
+	assert.Empty(t, user.ApprovedPictureURL)
+}
+
+func TestPhotoDataValidate(t *testing.T) {
+	data, err := EncodeNotificationData(PhotoData{PhotoID: 3, Status: PhotoRejected, Reason: "Blurry", Action: "edit_photos"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"version":1,"photoId":3,"status":"rejected","reason":"Blurry","action":"edit_photos"}`, data)
+
+	_, err = EncodeNotificationData(PhotoData{PhotoID: 3, Status: PhotoApproved, Reason: "Fine", Action: "edit_photos"})
+	assert.Error(t, err)
+	_, err = EncodeNotificationData(PhotoData{PhotoID: 3, Status: PhotoRejected, Action: "edit_photos"})
+	assert.Error(t, err, "the owner is always told why")
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+// ProfileView records that one user looked at another's profile on a given day (UTC).
+// Repeat views on the same day update the existing row instead of adding new ones.
+type ProfileView struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ViewedID  uint      `gorm:"not null;uniqueIndex:idx_profile_view_daily,priority:1" json:"viewedId"`             // Whose profile was viewed
+	ViewerID  uint      `gorm:"not null;uniqueIndex:idx_profile_view_daily,priority:2" json:"viewerId"`             // Who viewed it
+	Day       string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_profile_view_daily,priority:3" json:"day"` // YYYY-MM-DD in UTC
+	Count     int       `gorm:"default:1" json:"count"`                                                             // Views by this viewer that day
+	Notified  bool      `gorm:"default:false" json:"-"`                                                             // Whether this view sent the viewed user a notification
+	ViewedAt  time.Time `gorm:"not null;index" json:"viewedAt"`                                                     // Latest view that day
+	CreatedAt time.Time `json:"createdAt"`                                                                          // First view that day
+}
+
+// ProfileViewDay is the day key a view at t is de-duplicated under
+func ProfileViewDay(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// ProfileViewerResponse is one entry in the "who viewed me" list
+type ProfileViewerResponse struct {
+	Viewer   UserBasicInfo `json:"viewer"`
+	ViewedAt time.Time     `json:"viewedAt"` // Most recent view
+	Days     int           `json:"days"`     // Distinct days they viewed the profile in the listed period
+}
+
+// GetProfileViewsResponse represents the response for the "who viewed me" list
+type GetProfileViewsResponse struct {
+	Viewers []ProfileViewerResponse `json:"viewers"`
+	Total   int64                   `json:"total"` // Distinct viewers in the listed period
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// PushSubscription is one browser or device registered for Web Push.
+// The endpoint is unique per device, so re-registering moves it to the current user.
+type PushSubscription struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	UserID        uint       `gorm:"not null;index" json:"userId"`
+	Endpoint      string     `gorm:"type:text;not null;uniqueIndex" json:"endpoint"`
+	P256dh        string     `gorm:"not null" json:"-"`
+	Auth          string     `gorm:"not null" json:"-"`
+	UserAgent     string     `json:"userAgent,omitempty"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+	FailureCount  int        `gorm:"default:0" json:"failureCount"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// PushSubscriptionKeys are the client keys from PushSubscription.toJSON()
+type PushSubscriptionKeys struct {
+	P256dh string `json:"p256dh" binding:"required"`
+	Auth   string `json:"auth" binding:"required"`
+}
+
+// CreatePushSubscriptionRequest mirrors the browser's PushSubscription.toJSON() output
+type CreatePushSubscriptionRequest struct {
+	Endpoint       string               `json:"endpoint" binding:"required,url,startswith=https://"`
+	ExpirationTime *int64               `json:"expirationTime,omitempty"` // Milliseconds since the epoch
+	Keys           PushSubscriptionKeys `json:"keys" binding:"required"`
+}
+
+// DeletePushSubscriptionRequest identifies the device to unsubscribe
+type DeletePushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"time"
+)
+
+// ReportStatus tracks a report through moderator triage
+type ReportStatus string
+
+const (
+	ReportOpen      ReportStatus = "open"      // Waiting for a moderator
+	ReportInReview  ReportStatus = "in_review" // A moderator is looking into it
+	ReportActioned  ReportStatus = "actioned"  // Resolved: the report was upheld and action taken
+	ReportDismissed ReportStatus = "dismissed" // Resolved: no violation found
+)
+
+// IsResolved reports whether the report has reached a final state
+func (s ReportStatus) IsResolved() bool {
+	return s == ReportActioned || s == ReportDismissed
+}
+
+// CanTransitionTo reports whether a moderator may move a report from s to next.
+// Resolved reports are final.
+func (s ReportStatus) CanTransitionTo(next ReportStatus) bool {
+	switch s {
+	case ReportOpen:
+		return next == ReportInReview || next.IsResolved()
+	case ReportInReview:
+		return next == ReportOpen || next.IsResolved()
+	}
+	return false
+}
+
+// ReportCategory is the kind of violation a report is about
+type ReportCategory string
+
+const (
+	ReportCategorySpam        ReportCategory = "spam"
+	ReportCategoryHarassment  ReportCategory = "harassment"
+	ReportCategoryUnderage    ReportCategory = "underage"
+	ReportCategoryFakeProfile ReportCategory = "fake_profile"
+	ReportCategoryOther       ReportCategory = "other" // Used for reports submitted without a category
+)
+
+// ReportSeverity orders the triage queue; moderators can change it
+type ReportSeverity string
+
+const (
+	ReportSeverityLow      ReportSeverity = "low"
+	ReportSeverityMedium   ReportSeverity = "medium"
+	ReportSeverityHigh     ReportSeverity = "high"
+	ReportSeverityCritical ReportSeverity = "critical"
+)
+
+// Bump returns the next severity up; critical stays critical
+func (s ReportSeverity) Bump() ReportSeverity {
+	switch s {
+	case ReportSeverityLow:
+		return ReportSeverityMedium
+	case ReportSeverityMedium:
+		return ReportSeverityHigh
+	}
+	return ReportSeverityCritical
+}
+
+// DefaultSeverity is the severity a new report in the category starts with
+func (c ReportCategory) DefaultSeverity() ReportSeverity {
+	switch c {
+	case ReportCategoryUnderage:
+		return ReportSeverityCritical
+	case ReportCategoryHarassment:
+		return ReportSeverityHigh
+	case ReportCategorySpam:
+		return ReportSeverityLow
+	}
+	return ReportSeverityMedium
+}
+
+// Report represents a user report for inappropriate behavior
+type Report struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	ReporterID uint           `gorm:"not null;index" json:"reporterId"`                               // The user submitting the report
+	TargetID   uint           `gorm:"not null;index" json:"targetId"`                                 // The user being reported
+	Category   ReportCategory `gorm:"type:varchar(20);not null;default:other;index" json:"category"`  // What kind of violation
+	Reason     string         `gorm:"type:text;not null" json:"reason"`                               // Why the report was made
+	Status     ReportStatus   `gorm:"type:varchar(20);not null;default:open;index" json:"status"`     // Triage state
+	Severity   ReportSeverity `gorm:"type:varchar(20);not null;default:medium;index" json:"severity"` // Queue priority
+	AssigneeID *uint          `gorm:"index" json:"assigneeId,omitempty"`                              // Moderator working on it
+	// Set when the report is actioned or dismissed
+	ResolutionNotes string     `gorm:"type:text" json:"resolutionNotes,omitempty"`
+	ResolvedBy      *uint      `json:"resolvedBy,omitempty"`
+	ResolvedAt      *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// ReportRequest defines the structure for a report submission
+type ReportRequest struct {
+	Reason   string         `json:"reason" binding:"required"`                                                                // Required field for the report reason
+	Category ReportCategory `json:"category,omitempty" binding:"omitempty,oneof=spam harassment underage fake_profile other"` // Defaults to other
+}
+
+// UpdateReportRequest is a moderator's change to a report. Omitted fields are left alone.
+type UpdateReportRequest struct {
+	Status          *ReportStatus   `json:"status,omitempty" binding:"omitempty,oneof=open in_review actioned dismissed"`
+	Severity        *ReportSeverity `json:"severity,omitempty" binding:"omitempty,oneof=low medium high critical"`
+	AssigneeID      *uint           `json:"assigneeId,omitempty"` // 0 unassigns
+	ResolutionNotes *string         `json:"resolutionNotes,omitempty" binding:"omitempty,max=2000"`
+}
+
+// ReportEscalation records an escalation rule firing for a reported account. A rule fires at most
+// once per account within its window.
+type ReportEscalation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TargetID  uint      `gorm:"not null;index" json:"targetId"` // The reported account
+	ReportID  uint      `gorm:"not null" json:"reportId"`       // The report that tipped it over
+	Rule      string    `gorm:"type:varchar(50);not null" json:"rule"`
+	Action    string    `gorm:"type:varchar(30);not null" json:"action"`
+	Reason    string    `gorm:"type:text" json:"reason"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportStatusTransitions(t *testing.T) {
+	assert.True(t, ReportOpen.CanTransitionTo(ReportInReview))
+	assert.True(t, ReportOpen.CanTransitionTo(ReportDismissed))
+	assert.True(t, ReportInReview.CanTransitionTo(ReportOpen))
+	assert.True(t, ReportInReview.CanTransitionTo(ReportActioned))
+
+	// Resolved reports are final
+	for _, resolved := range []ReportStatus{ReportActioned, ReportDismissed} {
+		for _, next := range []ReportStatus{ReportOpen, ReportInReview, ReportActioned, ReportDismissed} {
+			assert.False(t, resolved.CanTransitionTo(next), "%s -> %s", resolved, next)
+		}
+	}
+}
+
+func TestReportCategoryDefaultSeverity(t *testing.T) {
+	assert.Equal(t, ReportSeverityCritical, ReportCategoryUnderage.DefaultSeverity())
+	assert.Equal(t, ReportSeverityHigh, ReportCategoryHarassment.DefaultSeverity())
+	assert.Equal(t, ReportSeverityMedium, ReportCategoryFakeProfile.DefaultSeverity())
+	assert.Equal(t, ReportSeverityLow, ReportCategorySpam.DefaultSeverity())
+	assert.Equal(t, ReportSeverityMedium, ReportCategoryOther.DefaultSeverity())
+}
+
+func TestReportSeverityBump(t *testing.T) {
+	assert.Equal(t, ReportSeverityMedium, ReportSeverityLow.Bump())
+	assert.Equal(t, ReportSeverityHigh, ReportSeverityMedium.Bump())
+	assert.Equal(t, ReportSeverityCritical, ReportSeverityHigh.Bump())
+	assert.Equal(t, ReportSeverityCritical, ReportSeverityCritical.Bump())
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Session records a sign-in. Sessions end when their token expires or when an admin signs the
+// user out everywhere, which sets User.SessionsRevokedAt.
+type Session struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"userId"`
+	IP        string    `gorm:"type:varchar(45)" json:"ip"`
+	UserAgent string    `gorm:"type:text" json:"userAgent"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	CreatedAt time.Time `gorm:"index" json:"createdAt"`
+}
+
+// IsActive reports whether the session's token still works
+func (s Session) IsActive(user User, now time.Time) bool {
+	if !now.Before(s.ExpiresAt) {
+		return false
+	}
+	return !user.TokenRevoked(s.CreatedAt)
+}
+
+// TokenTimePrecision is the resolution of token issue times and sign-out times. It matches the
+// database's timestamps, so times stored there compare exactly with those in tokens.
+const TokenTimePrecision = time.Microsecond
+
+// TokenRevoked reports whether a token issued at issuedAt was ended by signing the user out
+// everywhere. Only tokens issued strictly after the sign-out still work.
+func (u User) TokenRevoked(issuedAt time.Time) bool {
+	return u.SessionsRevokedAt != nil && !issuedAt.Truncate(TokenTimePrecision).After(u.SessionsRevokedAt.Truncate(TokenTimePrecision))
+}
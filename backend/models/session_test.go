@@ -0,0 +1,38 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionRevocation(t *testing.T) {
+	now := time.Now()
+	user := User{}
+	session := Session{CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, session.IsActive(user, now))
+	assert.False(t, session.IsActive(user, now.Add(2*time.Hour)), "expired")
+
+	revokedAt := now.Add(-time.Minute)
+	user.SessionsRevokedAt = &revokedAt
+	assert.False(t, session.IsActive(user, now))
+	assert.True(t, user.TokenRevoked(revokedAt), "a token issued at the sign-out is revoked")
+	assert.False(t, user.TokenRevoked(revokedAt.Add(time.Millisecond)), "a sign-in right after the sign-out works")
+
+	later := Session{CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+	assert.True(t, later.IsActive(user, now))
+}
+
+func TestAnonymize(t *testing.T) {
+	now := time.Now()
+	user := User{ID: 9, FirstName: "Ann", Email: "ann@example.com", Phone: "555-0100", Photos: []string{"a.jpg"}, Latitude: 29.6}
+	user.Anonymize(now)
+	assert.Equal(t, "deleted-9@invalid", user.Email)
+	assert.NotEqual(t, "Ann", user.FirstName)
+	assert.Empty(t, user.Phone)
+	assert.Empty(t, user.Photos)
+	assert.Zero(t, user.Latitude)
+	assert.Error(t, user.CheckPassword(""), "nobody can sign in")
+	assert.Equal(t, &now, user.SessionsRevokedAt)
+}
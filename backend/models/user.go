@@ -9,18 +9,13 @@ import (
 	"gorm.io/gorm"
 )
 
-// NotificationSettings represents user notification preferences
-type NotificationSettings struct {
-	NewMatches bool `json:"newMatches"`
-	Messages   bool `json:"messages"`
-	AppUpdates bool `json:"appUpdates"`
-}
-
 // PrivacySettings represents user privacy preferences
 type PrivacySettings struct {
 	ShowOnlineStatus bool `json:"showOnlineStatus"`
 	ShowLastActive   bool `json:"showLastActive"`
 	ShowDistance     bool `json:"showDistance"`
+	HideReadReceipts bool `json:"hideReadReceipts"` // Don't send read receipts (and don't see other people's)
+	Incognito        bool `json:"incognito"`        // Browse profiles without showing up in their "who viewed me" list
 }
 
 // UserStats represents computed user statistics
@@ -66,21 +61,38 @@ type User struct {
 	LastActiveAt *time.Time `gorm:"type:timestamp" json:"lastActiveAt"`
 	IsOnline     bool       `gorm:"default:false" json:"isOnline"`
 	ProfileViews int        `gorm:"default:0" json:"profileViews"`
+	Timezone     string     `gorm:"type:varchar(64)" json:"timezone"`          // IANA name, e.g. "America/New_York"; empty means UTC
+	Locale       string     `gorm:"type:varchar(10);default:en" json:"locale"` // Language for notifications and emails, e.g. "es"
 
 	// Settings
 	NotificationSettings NotificationSettings `gorm:"type:json;serializer:json" json:"notificationSettings"`
 	PrivacySettings      PrivacySettings      `gorm:"type:json;serializer:json" json:"privacySettings"`
+	DigestFrequency      DigestFrequency      `gorm:"type:varchar(10);default:weekly" json:"digestFrequency"`
+	LastDigestAt         *time.Time           `json:"-"` // When the last digest was sent or deliberately skipped
+
+	// Moderation; see ModerationAction for the history
+	AccountStatus       AccountStatus `gorm:"type:varchar(20);not null;default:active;index" json:"accountStatus"`
+	SuspendedUntil      *time.Time    `json:"suspendedUntil,omitempty"`
+	AccountStatusReason string        `gorm:"type:text" json:"-"`           // Shown to the user when they're turned away
+	ShadowBanned        bool          `gorm:"default:false;index" json:"-"` // Never revealed to the user; see ModerationShadowBan
+	HiddenPendingReview bool          `gorm:"default:false;index" json:"-"` // Left out of matches by an escalation rule until its reports are resolved
+
+	// Photo moderation; see Photo. Other users only ever see these, never Photos and ProfilePictureURL.
+	ApprovedPhotos     []string `gorm:"type:json;serializer:json" json:"-"`
+	ApprovedPictureURL string   `gorm:"type:text" json:"-"`
+
+	// Set when a moderator matched the user's selfie to their photos; see VerificationRequest
+	VerifiedAt *time.Time `gorm:"index" json:"verifiedAt,omitempty"`
+
+	// Tokens issued up to this time no longer work; set when an admin signs the user out everywhere
+	SessionsRevokedAt *time.Time `json:"-"`
 }
 
 // BeforeCreate hook to set default values
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	// Set default notification settings
-	if u.NotificationSettings == (NotificationSettings{}) {
-		u.NotificationSettings = NotificationSettings{
-			NewMatches: true,
-			Messages:   true,
-			AppUpdates: false,
-		}
+	if u.NotificationSettings.IsZero() {
+		u.NotificationSettings = DefaultNotificationSettings()
 	}
 
 	// Set default privacy settings
@@ -106,20 +118,31 @@ type Interaction struct {
 
 // Message represents a chat message between users
 type Message struct {
-	ID         uint           `gorm:"primaryKey" json:"id"`
-	SenderID   uint           `gorm:"not null" json:"sender_id"`   // ID of the user sending the message
-	ReceiverID uint           `gorm:"not null" json:"receiver_id"` // ID of the user receiving the message
-	Content    string         `gorm:"type:text;not null" json:"content"`
-	Read       bool           `gorm:"default:false" json:"read"` // Whether the message has been read
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	SenderID    uint           `gorm:"not null" json:"sender_id"`   // ID of the user sending the message
+	ReceiverID  uint           `gorm:"not null" json:"receiver_id"` // ID of the user receiving the message
+	Content     string         `gorm:"type:text;not null" json:"content"`
+	Read        bool           `gorm:"default:false" json:"read"`    // Whether the message has been read
+	DeliveredAt *time.Time     `json:"delivered_at"`                 // When the receiver's client first fetched the message
+	ReadAt      *time.Time     `json:"read_at"`                      // When the receiver marked the message as read
+	Shadowed    bool           `gorm:"default:false;index" json:"-"` // Sent while the sender was shadow-banned; never shown to the receiver
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
-// SendMessageRequest defines the structure for sending a message
+// SendMessageRequest defines the structure for sending a message.
+// Content may be omitted when PromptID names one of the pair's icebreakers.
 type SendMessageRequest struct {
 	ReceiverID uint   `json:"receiver_id" binding:"required"`
-	Content    string `json:"content" binding:"required,min=1,max=500"`
+	Content    string `json:"content" binding:"omitempty,max=500"`
+	PromptID   string `json:"prompt_id,omitempty"`
+}
+
+// MarkMessagesReadRequest marks every message from a partner up to and including a message ID as read
+type MarkMessagesReadRequest struct {
+	PartnerID     uint `json:"partner_id" binding:"required"`
+	UpToMessageID uint `json:"up_to_message_id" binding:"required"`
 }
 
 // validatePhotos ensures at least one photo is provided
@@ -186,11 +209,14 @@ type UpdatePreferencesRequest struct {
 
 // UpdateSettingsRequest defines fields for updating user settings
 type UpdateSettingsRequest struct {
-	NotificationSettings *NotificationSettings `json:"notificationSettings,omitempty"`
-	PrivacySettings      *PrivacySettings      `json:"privacySettings,omitempty"`
-	City                 string                `json:"city,omitempty"`
-	Country              string                `json:"country,omitempty"`
-	Phone                string                `json:"phone,omitempty"`
+	NotificationSettings *NotificationSettingsUpdate `json:"notificationSettings,omitempty"`
+	PrivacySettings      *PrivacySettings            `json:"privacySettings,omitempty"`
+	City                 string                      `json:"city,omitempty"`
+	Country              string                      `json:"country,omitempty"`
+	Phone                string                      `json:"phone,omitempty"`
+	Timezone             string                      `json:"timezone,omitempty"`
+	Locale               string                      `json:"locale,omitempty"` // e.g. "es" or "es-MX"; normalized to a supported locale
+	DigestFrequency      DigestFrequency             `json:"digestFrequency,omitempty" binding:"omitempty,oneof=off daily weekly"`
 }
 
 // HashPassword hashes the user's password using bcrypt
@@ -254,17 +280,3 @@ func LogActivity(db *gorm.DB, userID uint, event, message string, targetID *uint
 	}
 	return db.Create(&activity).Error
 }
-
-// Report represents a user report for inappropriate behavior
-type Report struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	CreatedAt  time.Time `json:"createdAt"`
-	ReporterID uint      `gorm:"not null" json:"reporterId"`       // The user submitting the report
-	TargetID   uint      `gorm:"not null" json:"targetId"`         // The user being reported
-	Reason     string    `gorm:"type:text;not null" json:"reason"` // Why the report was made
-}
-
-// ReportRequest defines the structure for a report submission
-type ReportRequest struct {
-	Reason string `json:"reason" binding:"required"` // Required field for the report reason
-}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+// VerificationStatus tracks a selfie verification request
+type VerificationStatus string
+
+const (
+	VerificationAwaitingSelfie VerificationStatus = "awaiting_selfie" // Pose issued; the selfie hasn't been uploaded yet
+	VerificationPending        VerificationStatus = "pending"         // Selfie waiting for a moderator
+	VerificationApproved       VerificationStatus = "approved"        // The selfie matches the profile; the user is verified
+	VerificationRejected       VerificationStatus = "rejected"        // It didn't; the user may try again
+)
+
+// VerificationChallengeTTL is how long a user has to upload a selfie after getting their pose,
+// so the selfie is taken on the spot rather than picked from a camera roll
+const VerificationChallengeTTL = 15 * time.Minute
+
+// VerificationPose is a pose the user must strike in their selfie
+type VerificationPose struct {
+	Code        string `json:"code"`
+	Instruction string `json:"instruction"`
+}
+
+// VerificationPoses are the poses a challenge is picked from
+var VerificationPoses = []VerificationPose{
+	{Code: "thumbs_up", Instruction: "Give a thumbs up with your right hand"},
+	{Code: "peace_sign", Instruction: "Make a peace sign next to your face"},
+	{Code: "touch_nose", Instruction: "Touch your nose with your index finger"},
+	{Code: "hand_on_head", Instruction: "Put your left hand on top of your head"},
+	{Code: "three_fingers", Instruction: "Hold up three fingers next to your chin"},
+	{Code: "cover_eye", Instruction: "Cover your left eye with your hand"},
+}
+
+// PoseByCode looks up a pose by its code
+func PoseByCode(code string) (VerificationPose, bool) {
+	for _, pose := range VerificationPoses {
+		if pose.Code == code {
+			return pose, true
+		}
+	}
+	return VerificationPose{}, false
+}
+
+// VerificationRequest is one attempt by a user to get the verified badge: a pose challenge, the
+// selfie taken for it and a moderator's decision after comparing it with the profile photos.
+// Selfies are only ever shown to moderators.
+type VerificationRequest struct {
+	ID                 uint               `gorm:"primaryKey" json:"id"`
+	UserID             uint               `gorm:"not null;index" json:"userId"`
+	Pose               string             `gorm:"type:varchar(50);not null" json:"pose"` // A VerificationPose code
+	Status             VerificationStatus `gorm:"type:varchar(20);not null;default:'awaiting_selfie';index" json:"status"`
+	ChallengeExpiresAt time.Time          `gorm:"not null" json:"challengeExpiresAt"`
+	SelfieURL          string             `gorm:"type:text" json:"selfieUrl,omitempty"`
+	SubmittedAt        *time.Time         `json:"submittedAt,omitempty"`
+	RejectionReason    string             `gorm:"type:text" json:"rejectionReason,omitempty"`
+	ReviewedBy         *uint              `json:"reviewedBy,omitempty"`
+	ReviewedAt         *time.Time         `json:"reviewedAt,omitempty"`
+	CreatedAt          time.Time          `json:"createdAt"`
+	UpdatedAt          time.Time          `json:"updatedAt"`
+}
+
+// ChallengeExpired reports whether the pose challenge ran out before a selfie was uploaded
+func (r VerificationRequest) ChallengeExpired(now time.Time) bool {
+	return r.Status == VerificationAwaitingSelfie && !now.Before(r.ChallengeExpiresAt)
+}
+
+// ReviewVerificationRequest is a moderator's decision on a selfie. A reason is required to reject.
+type ReviewVerificationRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Reason   string `json:"reason" binding:"max=500"`
+}
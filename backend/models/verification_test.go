@@ -0,0 +1,41 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationChallengeExpiry(t *testing.T) {
+	now := time.Now()
+	request := VerificationRequest{Status: VerificationAwaitingSelfie, ChallengeExpiresAt: now.Add(VerificationChallengeTTL)}
+	assert.False(t, request.ChallengeExpired(now))
+	assert.True(t, request.ChallengeExpired(now.Add(VerificationChallengeTTL)))
+
+	// Once a selfie is in, the challenge no longer matters
+	request.Status = VerificationPending
+	assert.False(t, request.ChallengeExpired(now.Add(time.Hour)))
+}
+
+func TestVerificationPoses(t *testing.T) {
+	seen := map[string]bool{}
+	for _, pose := range VerificationPoses {
+		assert.False(t, seen[pose.Code], "duplicate pose %s", pose.Code)
+		seen[pose.Code] = true
+		found, ok := PoseByCode(pose.Code)
+		assert.True(t, ok)
+		assert.Equal(t, pose, found)
+	}
+	_, ok := PoseByCode("handstand")
+	assert.False(t, ok)
+}
+
+func TestVerificationDataValidate(t *testing.T) {
+	_, err := EncodeNotificationData(VerificationData{RequestID: 1, Status: VerificationApproved, Action: "view_verification"})
+	assert.NoError(t, err)
+	_, err = EncodeNotificationData(VerificationData{RequestID: 1, Status: VerificationRejected, Action: "view_verification"})
+	assert.Error(t, err, "rejections need a reason")
+	_, err = EncodeNotificationData(VerificationData{RequestID: 1, Status: VerificationPending, Action: "view_verification"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package photoscreen
+
+import (
+	"bytes"
+	"datingapp/imagehash"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Verdict is what happens to an uploaded photo before a moderator sees it
+type Verdict string
+
+const (
+	VerdictApprove Verdict = "approve" // Show the photo to other users straight away
+	VerdictReview  Verdict = "review"  // Hide it until a moderator approves it
+	VerdictReject  Verdict = "reject"  // Refuse the upload
+)
+
+// severity orders verdicts so the strictest finding wins
+var severity = map[Verdict]int{
+	VerdictApprove: 0,
+	VerdictReview:  1,
+	VerdictReject:  2,
+}
+
+// Rule names reported by the built-in screeners
+const (
+	RuleDimensions = "dimensions"
+	RuleFormat     = "format"
+)
+
+// Input is everything a screener may look at for one photo. Width and Height are zero when the
+// image couldn't be decoded, or when only the URL is known, e.g. for photos added before
+// moderation existed.
+type Input struct {
+	UserID      uint // Zero for uploads made during registration
+	URL         string
+	ContentType string
+	Size        int64
+	Width       int
+	Height      int
+	Data        []byte         // The image itself, when the upload is being screened
+	Hash        imagehash.Hash // Perceptual hash of the image; only set when Hashed, which needs a hash with enough detail
+	Hashed      bool
+}
+
+// MaxDecodePixels caps the images ImageInput decodes. A small file can declare enormous
+// dimensions, so larger images are only measured from their header and are left unhashed.
+const MaxDecodePixels = 40_000_000
+
+// ImageInput describes an uploaded image, reading its dimensions from the header and decoding it
+// for its perceptual hash when it is within MaxDecodePixels
+func ImageInput(data []byte, contentType string) Input {
+	input := Input{ContentType: contentType, Size: int64(len(data)), Data: data}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return input
+	}
+	if int64(config.Width)*int64(config.Height) > MaxDecodePixels {
+		input.Width, input.Height = config.Width, config.Height
+		return input
+	}
+	if img, _, err := image.Decode(bytes.NewReader(data)); err == nil {
+		input.Width, input.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		// Flat images have no usable hash and are treated as unhashed
+		if hash := imagehash.DHash(img); hash.Informative() {
+			input.Hash, input.Hashed = hash, true
+		}
+	}
+	return input
+}
+
+// Finding is a single rule hit
+type Finding struct {
+	Rule    string  `json:"rule"`
+	Reason  string  `json:"reason"`
+	Verdict Verdict `json:"verdict"`
+	MatchID uint    `json:"matchId,omitempty"` // A related photo, e.g. the one this is a copy of
+}
+
+// Screener inspects a photo and reports rule hits. A finding with VerdictApprove vouches for the
+// photo, e.g. from an external classifier; the photo is only approved automatically when some
+// screener vouches for it and none asks for more.
+type Screener interface {
+	Screen(input Input) []Finding
+}
+
+// Result is the combined outcome of every screener
+type Result struct {
+	Verdict  Verdict   `json:"verdict"`
+	Findings []Finding `json:"findings"`
+}
+
+// Reason returns the reasons of the findings behind the verdict, for the uploader or a moderator
+func (r Result) Reason() string {
+	var reasons []string
+	for _, finding := range r.Findings {
+		if finding.Verdict == r.Verdict {
+			reasons = append(reasons, finding.Reason)
+		}
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Pipeline runs a photo through a list of screeners
+type Pipeline struct {
+	autoApprove bool
+	screeners   []Screener
+}
+
+// NewPipeline creates a pipeline. With autoApprove, photos no screener objects to are approved
+// without a moderator; otherwise they wait for review unless a screener vouches for them.
+func NewPipeline(autoApprove bool, screeners ...Screener) *Pipeline {
+	return &Pipeline{autoApprove: autoApprove, screeners: screeners}
+}
+
+// Register adds a screener to the pipeline
+func (p *Pipeline) Register(screener Screener) {
+	p.screeners = append(p.screeners, screener)
+}
+
+// Evaluate runs every screener and returns the strictest verdict along with all findings
+func (p *Pipeline) Evaluate(input Input) Result {
+	result := Result{Verdict: VerdictApprove}
+	vouched := p.autoApprove
+	for _, screener := range p.screeners {
+		for _, finding := range screener.Screen(input) {
+			result.Findings = append(result.Findings, finding)
+			if finding.Verdict == VerdictApprove {
+				vouched = true
+			}
+			if severity[finding.Verdict] > severity[result.Verdict] {
+				result.Verdict = finding.Verdict
+			}
+		}
+	}
+	if result.Verdict == VerdictApprove && !vouched {
+		result.Verdict = VerdictReview
+	}
+	return result
+}
+
+// DimensionsScreener rejects images too small to show a face and sends ones it can't read to a
+// moderator
+type DimensionsScreener struct {
+	MinWidth  int
+	MinHeight int
+}
+
+// Screen implements Screener
+func (d *DimensionsScreener) Screen(input Input) []Finding {
+	if input.Data == nil {
+		return nil
+	}
+	if input.Width == 0 || input.Height == 0 {
+		return []Finding{{Rule: RuleFormat, Reason: "Image format couldn't be read", Verdict: VerdictReview}}
+	}
+	if input.Width < d.MinWidth || input.Height < d.MinHeight {
+		return []Finding{{
+			Rule:    RuleDimensions,
+			Reason:  "Photo is too small (at least " + strconv.Itoa(d.MinWidth) + "x" + strconv.Itoa(d.MinHeight) + " pixels)",
+			Verdict: VerdictReject,
+		}}
+	}
+	return nil
+}
+
+const defaultMinDimension = 200
+
+// NewPipelineFromEnv builds the default pipeline. PHOTO_AUTO_APPROVE=true publishes photos no
+// screener objects to without review, and PHOTO_MIN_DIMENSION sets the smallest width and height
+// accepted in pixels.
+func NewPipelineFromEnv() *Pipeline {
+	autoApprove, _ := strconv.ParseBool(os.Getenv("PHOTO_AUTO_APPROVE"))
+
+	minDimension := defaultMinDimension
+	if value := os.Getenv("PHOTO_MIN_DIMENSION"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			minDimension = n
+		}
+	}
+
+	return NewPipeline(autoApprove, &DimensionsScreener{MinWidth: minDimension, MinHeight: minDimension})
+}
@@ -0,0 +1,100 @@
+package photoscreen
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type vouchScreener struct{}
+
+func (vouchScreener) Screen(Input) []Finding {
+	return []Finding{{Rule: "classifier", Reason: "Looks fine", Verdict: VerdictApprove}}
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageInputReadsDimensions(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 320, 240))
+	for y := 0; y < 240; y++ {
+		for x := 0; x < 320; x++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(x ^ y)})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	input := ImageInput(buf.Bytes(), "image/png")
+	assert.Equal(t, 320, input.Width)
+	assert.Equal(t, 240, input.Height)
+	assert.True(t, input.Hashed)
+
+	// A flat image has no usable hash
+	input = ImageInput(encodePNG(t, 320, 240), "image/png")
+	assert.Equal(t, 320, input.Width)
+	assert.False(t, input.Hashed)
+
+	input = ImageInput([]byte("not an image"), "image/heic")
+	assert.Zero(t, input.Width)
+	assert.False(t, input.Hashed)
+	assert.EqualValues(t, 12, input.Size)
+}
+
+func TestImageInputSkipsDecodingHugeImages(t *testing.T) {
+	// A tiny PNG whose header claims 100000x100000 pixels, which would take 40 GB to decode
+	data := encodePNG(t, 1, 1)
+	ihdr := data[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:8], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:12], 100000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(ihdr))
+
+	input := ImageInput(data, "image/png")
+	assert.Equal(t, 100000, input.Width)
+	assert.Equal(t, 100000, input.Height)
+	assert.False(t, input.Hashed)
+}
+
+func TestPipelineWaitsForReviewUnlessVouched(t *testing.T) {
+	screener := &DimensionsScreener{MinWidth: 200, MinHeight: 200}
+	big := ImageInput(encodePNG(t, 400, 400), "image/png")
+
+	assert.Equal(t, VerdictReview, NewPipeline(false, screener).Evaluate(big).Verdict)
+	assert.Equal(t, VerdictApprove, NewPipeline(true, screener).Evaluate(big).Verdict)
+
+	pipeline := NewPipeline(false, screener)
+	pipeline.Register(vouchScreener{})
+	result := pipeline.Evaluate(big)
+	assert.Equal(t, VerdictApprove, result.Verdict)
+	assert.Equal(t, "Looks fine", result.Reason())
+}
+
+func TestPipelineTakesStrictestVerdict(t *testing.T) {
+	pipeline := NewPipeline(true, &DimensionsScreener{MinWidth: 200, MinHeight: 200}, vouchScreener{})
+
+	result := pipeline.Evaluate(ImageInput(encodePNG(t, 100, 400), "image/png"))
+	assert.Equal(t, VerdictReject, result.Verdict)
+	assert.Len(t, result.Findings, 2)
+	assert.Equal(t, "Photo is too small (at least 200x200 pixels)", result.Reason())
+
+	result = pipeline.Evaluate(ImageInput([]byte("not an image"), "image/heic"))
+	assert.Equal(t, VerdictReview, result.Verdict)
+	assert.Equal(t, RuleFormat, result.Findings[0].Rule)
+}
+
+func TestDimensionsSkipsPhotosKnownOnlyByURL(t *testing.T) {
+	screener := &DimensionsScreener{MinWidth: 200, MinHeight: 200}
+	assert.Empty(t, screener.Screen(Input{URL: "https://example.com/a.jpg"}))
+}
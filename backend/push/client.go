@@ -0,0 +1,182 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Urgency hints how soon the push service should wake the device (RFC 8030 section 5.3)
+type Urgency string
+
+const (
+	UrgencyVeryLow Urgency = "very-low"
+	UrgencyLow     Urgency = "low"
+	UrgencyNormal  Urgency = "normal"
+	UrgencyHigh    Urgency = "high"
+)
+
+// Subscription is where and how to deliver to one browser
+type Subscription struct {
+	Endpoint string
+	Keys     Keys
+}
+
+// Options are per-message delivery hints
+type Options struct {
+	TTL     time.Duration // How long the push service keeps an undelivered message
+	Urgency Urgency
+	Topic   string // Replaces any pending message with the same topic
+}
+
+// StatusError is returned when the push service rejects a message
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("push service returned %d: %s", e.StatusCode, e.Body)
+}
+
+// IsGone reports whether the subscription no longer exists and should be deleted
+func IsGone(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone)
+}
+
+// IsRetryable reports whether sending again later might succeed
+func IsRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	// Network failures and timeouts
+	return err != nil && !errors.Is(err, context.Canceled)
+}
+
+// Retry controls SendWithRetry's exponential backoff
+type Retry struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetry is used by the notification delivery worker
+var DefaultRetry = Retry{Attempts: 5, BaseDelay: 2 * time.Second, MaxDelay: 5 * time.Minute}
+
+// delay returns the wait before the given retry (1-based)
+func (r Retry) delay(retry int) time.Duration {
+	if retry > 30 {
+		return r.MaxDelay
+	}
+	delay := r.BaseDelay << (retry - 1)
+	if delay <= 0 || (r.MaxDelay > 0 && delay > r.MaxDelay) {
+		delay = r.MaxDelay
+	}
+	return delay
+}
+
+// wait returns the wait before the given retry (1-based) after err. A Retry-After from the push
+// service is honoured when it asks for longer, up to MaxDelay, so a service can't park a worker
+// for hours.
+func (r Retry) wait(retry int, err error) time.Duration {
+	wait := r.delay(retry)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+		wait = statusErr.RetryAfter
+		if r.MaxDelay > 0 && wait > r.MaxDelay {
+			wait = r.MaxDelay
+		}
+	}
+	return wait
+}
+
+// Client sends encrypted messages to push services
+type Client struct {
+	HTTP  *http.Client
+	VAPID *VAPID
+	Now   func() time.Time
+}
+
+// NewClient returns a client with sensible timeouts
+func NewClient(vapid *VAPID) *Client {
+	return &Client{
+		HTTP:  &http.Client{Timeout: 30 * time.Second},
+		VAPID: vapid,
+		Now:   time.Now,
+	}
+}
+
+// Send encrypts payload and posts it to the subscription's endpoint once
+func (c *Client) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	body, err := Encrypt(sub.Keys, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := c.VAPID.Authorization(sub.Endpoint, c.Now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL.Seconds())))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", string(opts.Urgency))
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	statusErr := &StatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(message))}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return statusErr
+}
+
+// SendWithRetry calls Send, backing off exponentially on retryable failures.
+// A Retry-After from the push service is honoured when it asks for a longer wait, up to the
+// policy's MaxDelay.
+func (c *Client) SendWithRetry(ctx context.Context, sub Subscription, payload []byte, opts Options, retry Retry) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.Send(ctx, sub, payload, opts)
+		if err == nil || !IsRetryable(err) || attempt >= retry.Attempts {
+			return err
+		}
+
+		timer := time.NewTimer(retry.wait(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
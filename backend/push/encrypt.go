@@ -0,0 +1,181 @@
+package push
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// recordSize is the aes128gcm record size; the whole payload is sent as a single record
+	recordSize     = 4096
+	authSecretSize = 16
+	saltSize       = 16
+	// headerSize is the aes128gcm header: salt, record size, key ID length and a P-256 public key
+	headerSize = saltSize + 4 + 1 + 65
+	// MaxPayloadSize is the largest plaintext that fits in a 4096-byte push message after the
+	// header, the 16-byte GCM tag and the record delimiter (RFC 8291 section 4)
+	MaxPayloadSize = recordSize - headerSize - 16 - 1
+)
+
+// Keys are the client keys from a browser PushSubscription
+type Keys struct {
+	P256dh []byte // Uncompressed P-256 public key of the user agent
+	Auth   []byte // 16-byte authentication secret
+}
+
+// ParseKeys decodes the base64url keys sent by PushSubscription.toJSON()
+func ParseKeys(p256dh, auth string) (Keys, error) {
+	public, err := decodeBase64URL(p256dh)
+	if err != nil {
+		return Keys{}, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+	if _, err := ecdh.P256().NewPublicKey(public); err != nil {
+		return Keys{}, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+	secret, err := decodeBase64URL(auth)
+	if err != nil {
+		return Keys{}, fmt.Errorf("invalid auth secret: %v", err)
+	}
+	if len(secret) != authSecretSize {
+		return Keys{}, fmt.Errorf("invalid auth secret: expected %d bytes, got %d", authSecretSize, len(secret))
+	}
+	return Keys{P256dh: public, Auth: secret}, nil
+}
+
+// Encrypt encrypts payload for a subscription using the aes128gcm content coding (RFC 8291, RFC 8188)
+func Encrypt(keys Keys, payload []byte) ([]byte, error) {
+	// A fresh application server key pair and salt for every message
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return encrypt(asPrivate, salt, keys, payload)
+}
+
+// encrypt is Encrypt with a given application server key and salt
+func encrypt(asPrivate *ecdh.PrivateKey, salt []byte, keys Keys, payload []byte) ([]byte, error) {
+	if len(payload) > MaxPayloadSize {
+		return nil, fmt.Errorf("payload is %d bytes; the maximum is %d", len(payload), MaxPayloadSize)
+	}
+
+	uaPublic, err := ecdh.P256().NewPublicKey(keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %v", err)
+	}
+	if len(keys.Auth) != authSecretSize {
+		return nil, errors.New("invalid auth secret")
+	}
+
+	cek, nonce, err := deriveKeys(asPrivate, uaPublic, keys.Auth, salt, asPrivate.PublicKey().Bytes(), keys.P256dh)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// The single record is the last one, so it ends with the 0x02 delimiter and no padding
+	plaintext := make([]byte, 0, len(payload)+1)
+	plaintext = append(plaintext, payload...)
+	plaintext = append(plaintext, 0x02)
+
+	asPublic := asPrivate.PublicKey().Bytes()
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(recordSize))
+	body.WriteByte(byte(len(asPublic)))
+	body.Write(asPublic)
+	body.Write(gcm.Seal(nil, nonce, plaintext, nil))
+	return body.Bytes(), nil
+}
+
+// deriveKeys computes the content encryption key and nonce from the ECDH shared secret.
+// local is our private key; remote is the other side's public key.
+func deriveKeys(local *ecdh.PrivateKey, remote *ecdh.PublicKey, authSecret, salt, asPublic, uaPublic []byte) (cek, nonce []byte, err error) {
+	shared, err := local.ECDH(remote)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, authSecret, keyInfo), ikm); err != nil {
+		return nil, nil, err
+	}
+
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek = make([]byte, 16)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: aes128gcm\x00")), cek); err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, 12)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte("Content-Encoding: nonce\x00")), nonce); err != nil {
+		return nil, nil, err
+	}
+	return cek, nonce, nil
+}
+
+// Decrypt reverses Encrypt using the user agent's private key. Push services never do this;
+// it exists so tests and local tooling can act as a browser.
+func Decrypt(uaPrivate *ecdh.PrivateKey, authSecret, body []byte) ([]byte, error) {
+	if len(body) < saltSize+4+1 {
+		return nil, errors.New("body too short")
+	}
+	salt := body[:saltSize]
+	idLen := int(body[saltSize+4])
+	headerLen := saltSize + 4 + 1 + idLen
+	if len(body) < headerLen {
+		return nil, errors.New("body too short")
+	}
+	asPublicBytes := body[saltSize+5 : headerLen]
+
+	asPublic, err := ecdh.P256().NewPublicKey(asPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key id: %v", err)
+	}
+	cek, nonce, err := deriveKeys(uaPrivate, asPublic, authSecret, salt, asPublicBytes, uaPrivate.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, nonce, body[headerLen:], nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Strip padding back to the record delimiter
+	plaintext = bytes.TrimRight(plaintext, "\x00")
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("missing record delimiter")
+	}
+	return plaintext[:len(plaintext)-1], nil
+}
@@ -0,0 +1,263 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePushService plays both the push service and the browser behind it: it checks the VAPID
+// header, decrypts the payload with the browser's keys and answers with scripted status codes.
+type fakePushService struct {
+	t         *testing.T
+	server    *httptest.Server
+	browser   *ecdh.PrivateKey
+	auth      []byte
+	mu        sync.Mutex
+	responses []int
+	received  [][]byte
+	requests  int
+}
+
+func newFakePushService(t *testing.T, responses ...int) *fakePushService {
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	_, err = rand.Read(auth)
+	require.NoError(t, err)
+
+	f := &fakePushService{t: t, browser: browser, auth: auth, responses: responses}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakePushService) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests++
+
+	assert.Equal(f.t, "aes128gcm", r.Header.Get("Content-Encoding"))
+	assert.NotEmpty(f.t, r.Header.Get("TTL"))
+	f.verifyVAPID(r.Header.Get("Authorization"))
+
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+	plaintext, err := Decrypt(f.browser, f.auth, body)
+	require.NoError(f.t, err)
+	f.received = append(f.received, plaintext)
+
+	status := http.StatusCreated
+	if len(f.responses) > 0 {
+		status, f.responses = f.responses[0], f.responses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (f *fakePushService) verifyVAPID(header string) {
+	require.True(f.t, strings.HasPrefix(header, "vapid t="), "unexpected Authorization %q", header)
+	parts := strings.SplitN(strings.TrimPrefix(header, "vapid t="), ", k=", 2)
+	require.Len(f.t, parts, 2)
+
+	public, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(f.t, err)
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(public[1:33]),
+		Y:     new(big.Int).SetBytes(public[33:]),
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(parts[0], claims, func(*jwt.Token) (interface{}, error) { return key, nil },
+		jwt.WithValidMethods([]string{"ES256"}), jwt.WithAudience(f.server.URL), jwt.WithExpirationRequired())
+	require.NoError(f.t, err)
+	assert.Equal(f.t, "mailto:push@campuscupid.test", claims["sub"])
+}
+
+func (f *fakePushService) subscription() Subscription {
+	return Subscription{
+		Endpoint: f.server.URL + "/push/abc123",
+		Keys:     Keys{P256dh: f.browser.PublicKey().Bytes(), Auth: f.auth},
+	}
+}
+
+func newTestClient(t *testing.T) *Client {
+	_, privateKey, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+	vapid, err := NewVAPID("mailto:push@campuscupid.test", privateKey)
+	require.NoError(t, err)
+	return NewClient(vapid)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	browser, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	rand.Read(auth)
+	keys := Keys{P256dh: browser.PublicKey().Bytes(), Auth: auth}
+
+	payload := []byte(`{"title":"New Match! 💕"}`)
+	body, err := Encrypt(keys, payload)
+	require.NoError(t, err)
+
+	decrypted, err := Decrypt(browser, auth, body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decrypted)
+
+	// Each message uses a fresh salt and key, so identical payloads never repeat
+	again, err := Encrypt(keys, payload)
+	require.NoError(t, err)
+	assert.NotEqual(t, body, again)
+
+	_, err = Decrypt(browser, make([]byte, 16), body)
+	assert.Error(t, err, "wrong auth secret must not decrypt")
+
+	// The largest payload still fits in a 4096-byte message
+	body, err = Encrypt(keys, make([]byte, MaxPayloadSize))
+	require.NoError(t, err)
+	assert.Len(t, body, 4096)
+	_, err = Encrypt(keys, make([]byte, MaxPayloadSize+1))
+	assert.Error(t, err)
+}
+
+// TestEncryptRFC8291Vector checks the worked example from RFC 8291 Appendix A
+func TestEncryptRFC8291Vector(t *testing.T) {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		require.NoError(t, err)
+		return b
+	}
+	asPrivate, err := ecdh.P256().NewPrivateKey(decode("yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	require.NoError(t, err)
+	assert.Equal(t, decode("BP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A8"), asPrivate.PublicKey().Bytes())
+	uaPrivate, err := ecdh.P256().NewPrivateKey(decode("q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"))
+	require.NoError(t, err)
+	keys, err := ParseKeys("BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4", "BTBZMqHH6r4Tts7J_aSIgg")
+	require.NoError(t, err)
+	assert.Equal(t, uaPrivate.PublicKey().Bytes(), keys.P256dh)
+
+	plaintext := []byte("When I grow up, I want to be a watermelon")
+	want := decode("DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+
+	body, err := encrypt(asPrivate, decode("DGv6ra1nlYgDCS1FRnbzlw"), keys, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, want, body)
+
+	decrypted, err := Decrypt(uaPrivate, keys.Auth, want)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+}
+
+func TestParseKeys(t *testing.T) {
+	browser, _ := ecdh.P256().GenerateKey(rand.Reader)
+	p256dh := base64.RawURLEncoding.EncodeToString(browser.PublicKey().Bytes())
+
+	keys, err := ParseKeys(p256dh, base64.URLEncoding.EncodeToString(make([]byte, 16)))
+	require.NoError(t, err)
+	assert.Len(t, keys.Auth, 16)
+
+	_, err = ParseKeys("not-a-key", base64.RawURLEncoding.EncodeToString(make([]byte, 16)))
+	assert.Error(t, err)
+	_, err = ParseKeys(p256dh, base64.RawURLEncoding.EncodeToString(make([]byte, 8)))
+	assert.Error(t, err)
+}
+
+func TestVAPIDFromEnv(t *testing.T) {
+	t.Setenv("VAPID_PRIVATE_KEY", "")
+	_, err := LoadVAPIDFromEnv()
+	assert.ErrorIs(t, err, ErrVAPIDNotConfigured)
+
+	publicKey, privateKey, err := GenerateVAPIDKeys()
+	require.NoError(t, err)
+	t.Setenv("VAPID_PRIVATE_KEY", privateKey)
+	t.Setenv("VAPID_PUBLIC_KEY", publicKey)
+	t.Setenv("VAPID_SUBJECT", "mailto:admin@campuscupid.com")
+	vapid, err := LoadVAPIDFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, publicKey, vapid.PublicKey)
+
+	otherPublic, _, _ := GenerateVAPIDKeys()
+	t.Setenv("VAPID_PUBLIC_KEY", otherPublic)
+	_, err = LoadVAPIDFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("VAPID_PUBLIC_KEY", "")
+	t.Setenv("VAPID_SUBJECT", "admin@campuscupid.com")
+	_, err = LoadVAPIDFromEnv()
+	assert.Error(t, err, "subject must be a mailto: or https: URL")
+}
+
+func TestSendDeliversToFakePushService(t *testing.T) {
+	service := newFakePushService(t)
+	client := newTestClient(t)
+
+	err := client.Send(context.Background(), service.subscription(), []byte("hello"), Options{TTL: time.Hour, Urgency: UrgencyHigh})
+	require.NoError(t, err)
+	require.Len(t, service.received, 1)
+	assert.Equal(t, "hello", string(service.received[0]))
+}
+
+func TestSendWithRetry(t *testing.T) {
+	fast := Retry{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	t.Run("retries server errors then succeeds", func(t *testing.T) {
+		service := newFakePushService(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		err := newTestClient(t).SendWithRetry(context.Background(), service.subscription(), []byte("hi"), Options{}, fast)
+		require.NoError(t, err)
+		assert.Equal(t, 3, service.requests)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		service := newFakePushService(t, 500, 500, 500, 500)
+		err := newTestClient(t).SendWithRetry(context.Background(), service.subscription(), []byte("hi"), Options{}, fast)
+		assert.Error(t, err)
+		assert.False(t, IsGone(err))
+		assert.Equal(t, 3, service.requests)
+	})
+
+	t.Run("gone subscriptions are not retried", func(t *testing.T) {
+		for _, status := range []int{http.StatusNotFound, http.StatusGone} {
+			service := newFakePushService(t, status)
+			err := newTestClient(t).SendWithRetry(context.Background(), service.subscription(), []byte("hi"), Options{}, fast)
+			assert.True(t, IsGone(err), "status %d", status)
+			assert.Equal(t, 1, service.requests)
+		}
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		service := newFakePushService(t, http.StatusBadRequest)
+		err := newTestClient(t).SendWithRetry(context.Background(), service.subscription(), []byte("hi"), Options{}, fast)
+		assert.Error(t, err)
+		assert.Equal(t, 1, service.requests)
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	retry := Retry{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Second, retry.delay(1))
+	assert.Equal(t, 2*time.Second, retry.delay(2))
+	assert.Equal(t, 4*time.Second, retry.delay(3))
+	assert.Equal(t, 5*time.Second, retry.delay(4))
+	assert.Equal(t, 5*time.Second, retry.delay(40))
+
+	// Retry-After is honoured when longer than the backoff, up to MaxDelay
+	assert.Equal(t, 3*time.Second, retry.wait(1, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 3 * time.Second}))
+	assert.Equal(t, 2*time.Second, retry.wait(2, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Second}))
+	assert.Equal(t, 5*time.Second, retry.wait(1, &StatusError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 24 * time.Hour}))
+	assert.Equal(t, DefaultRetry.MaxDelay, DefaultRetry.wait(1, &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 24 * time.Hour}))
+}
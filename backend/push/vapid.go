@@ -0,0 +1,116 @@
+package push
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrVAPIDNotConfigured is returned by LoadVAPIDFromEnv when no keys are set
+var ErrVAPIDNotConfigured = errors.New("VAPID keys are not configured")
+
+// vapidTokenLifetime is how long a signed VAPID token is valid; RFC 8292 caps it at 24 hours
+const vapidTokenLifetime = 12 * time.Hour
+
+// VAPID identifies this application server to push services (RFC 8292)
+type VAPID struct {
+	Subject    string // mailto: or https: contact for the push service operator
+	PublicKey  string // Uncompressed P-256 point, base64url without padding; shared with browsers
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewVAPID builds a VAPID identity from a base64url-encoded P-256 private key
+func NewVAPID(subject, privateKey string) (*VAPID, error) {
+	if subject == "" {
+		return nil, errors.New("VAPID subject is required")
+	}
+	if !strings.HasPrefix(subject, "mailto:") && !strings.HasPrefix(subject, "https:") {
+		return nil, fmt.Errorf("VAPID subject must be a mailto: or https: URL, got %q", subject)
+	}
+
+	d, err := decodeBase64URL(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %v", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(d)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %v", err)
+	}
+
+	public := key.PublicKey().Bytes()
+	return &VAPID{
+		Subject:   subject,
+		PublicKey: base64.RawURLEncoding.EncodeToString(public),
+		privateKey: &ecdsa.PrivateKey{
+			PublicKey: ecdsa.PublicKey{
+				Curve: elliptic.P256(),
+				X:     new(big.Int).SetBytes(public[1:33]),
+				Y:     new(big.Int).SetBytes(public[33:]),
+			},
+			D: new(big.Int).SetBytes(d),
+		},
+	}, nil
+}
+
+// LoadVAPIDFromEnv reads VAPID_PRIVATE_KEY and VAPID_SUBJECT. When VAPID_PUBLIC_KEY is also set
+// it must match the private key, which catches keys pasted from different pairs.
+func LoadVAPIDFromEnv() (*VAPID, error) {
+	privateKey := os.Getenv("VAPID_PRIVATE_KEY")
+	if privateKey == "" {
+		return nil, ErrVAPIDNotConfigured
+	}
+
+	vapid, err := NewVAPID(os.Getenv("VAPID_SUBJECT"), privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if publicKey := os.Getenv("VAPID_PUBLIC_KEY"); publicKey != "" && publicKey != vapid.PublicKey {
+		return nil, errors.New("VAPID_PUBLIC_KEY does not match VAPID_PRIVATE_KEY")
+	}
+	return vapid, nil
+}
+
+// GenerateVAPIDKeys creates a new key pair, both base64url-encoded
+func GenerateVAPIDKeys() (publicKey, privateKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(key.Bytes()), nil
+}
+
+// Authorization returns the Authorization header value for a request to endpoint
+func (v *VAPID) Authorization(endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint %q", endpoint)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(vapidTokenLifetime).Unix(),
+		"sub": v.Subject,
+	})
+	signed, err := token.SignedString(v.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign VAPID token: %v", err)
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", signed, v.PublicKey), nil
+}
+
+// decodeBase64URL accepts base64url with or without padding, as browsers and key tools vary
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.TrimSpace(s), "="))
+}
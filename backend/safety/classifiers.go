@@ -0,0 +1,134 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Rule names reported by the built-in classifiers
+const (
+	RuleKeyword           = "keyword"
+	RuleLink              = "link"
+	RulePhone             = "phone"
+	RuleHarassmentInsult  = "harassment_insult"
+	RuleHarassmentShout   = "harassment_shouting"
+	RuleHarassmentFlood   = "harassment_flood"
+	RuleHarassmentRepeats = "harassment_repeat"
+)
+
+const defaultContactWindow = 5
+
+// KeywordClassifier flags messages containing listed words or matching any pattern
+type KeywordClassifier struct {
+	Words    []string
+	Patterns []*regexp.Regexp
+}
+
+// DefaultKeywords returns the built-in keyword list
+func DefaultKeywords() *KeywordClassifier {
+	return &KeywordClassifier{
+		Words: []string{"cashapp", "venmo me", "send nudes", "onlyfans", "bitcoin wallet", "gift card"},
+		Patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\bwire\s+(me\s+)?money\b`),
+			regexp.MustCompile(`(?i)\b(crypto|forex)\s+invest(ment|ing)?\b`),
+		},
+	}
+}
+
+// Classify implements Classifier
+func (k *KeywordClassifier) Classify(input Input) []Finding {
+	lower := strings.ToLower(input.Content)
+	for _, word := range k.Words {
+		if word != "" && strings.Contains(lower, strings.ToLower(word)) {
+			return []Finding{{Rule: RuleKeyword, Reason: "Contains blocked phrase \"" + word + "\""}}
+		}
+	}
+	for _, pattern := range k.Patterns {
+		if pattern.MatchString(input.Content) {
+			return []Finding{{Rule: RuleKeyword, Reason: "Matches blocked pattern"}}
+		}
+	}
+	return nil
+}
+
+var (
+	linkPattern  = regexp.MustCompile(`(?i)\b(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|me|ly|co|xyz|link)(/\S*)?\b`)
+	phonePattern = regexp.MustCompile(`(\+?\d[\s.\-()]*){9,}`)
+)
+
+// ContactInfoClassifier flags links and phone numbers in the first Window messages of a conversation,
+// when scammers typically try to move victims off the platform
+type ContactInfoClassifier struct {
+	Window int64
+}
+
+// Classify implements Classifier
+func (c *ContactInfoClassifier) Classify(input Input) []Finding {
+	if input.PriorMessages >= c.Window {
+		return nil
+	}
+	var findings []Finding
+	if linkPattern.MatchString(input.Content) {
+		findings = append(findings, Finding{Rule: RuleLink, Reason: "Contains a link early in the conversation"})
+	}
+	if phonePattern.MatchString(input.Content) {
+		findings = append(findings, Finding{Rule: RulePhone, Reason: "Contains a phone number early in the conversation"})
+	}
+	return findings
+}
+
+// HarassmentClassifier applies simple heuristics for abusive behaviour
+type HarassmentClassifier struct {
+	Insults        []string
+	FloodThreshold int64 // Unanswered messages before flagging
+	ShoutMinLength int   // Minimum letters before caps ratio is considered
+}
+
+// DefaultHarassment returns the built-in harassment heuristics
+func DefaultHarassment() *HarassmentClassifier {
+	return &HarassmentClassifier{
+		Insults:        []string{"idiot", "loser", "worthless", "slut", "whore", "bitch", "kill yourself", "kys"},
+		FloodThreshold: 10,
+		ShoutMinLength: 12,
+	}
+}
+
+// Classify implements Classifier
+func (h *HarassmentClassifier) Classify(input Input) []Finding {
+	var findings []Finding
+
+	words := strings.FieldsFunc(strings.ToLower(input.Content), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	joined := " " + strings.Join(words, " ") + " "
+	for _, insult := range h.Insults {
+		if strings.Contains(joined, " "+insult+" ") {
+			findings = append(findings, Finding{Rule: RuleHarassmentInsult, Reason: "Contains abusive language"})
+			break
+		}
+	}
+
+	letters, upper := 0, 0
+	for _, r := range input.Content {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= h.ShoutMinLength && upper*10 >= letters*8 {
+		findings = append(findings, Finding{Rule: RuleHarassmentShout, Reason: "Message is mostly in capitals"})
+	}
+
+	if h.FloodThreshold > 0 && input.UnansweredCount >= h.FloodThreshold {
+		findings = append(findings, Finding{Rule: RuleHarassmentFlood, Reason: "Many messages sent without a reply"})
+	}
+
+	if input.PreviousContent != "" && strings.EqualFold(strings.TrimSpace(input.PreviousContent), strings.TrimSpace(input.Content)) {
+		findings = append(findings, Finding{Rule: RuleHarassmentRepeats, Reason: "Same message sent repeatedly"})
+	}
+
+	return findings
+}
@@ -0,0 +1,164 @@
+package safety
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Action is what happens to a message that trips a rule
+type Action string
+
+const (
+	ActionAllow Action = "allow" // Deliver as normal
+	ActionWarn  Action = "warn"  // Deliver, but warn the sender
+	ActionHold  Action = "hold"  // Store for moderator review before delivery
+	ActionBlock Action = "block" // Reject outright
+)
+
+// severity orders actions so the strictest finding wins
+var severity = map[Action]int{
+	ActionAllow: 0,
+	ActionWarn:  1,
+	ActionHold:  2,
+	ActionBlock: 3,
+}
+
+// ParseAction converts a configuration string to an Action
+func ParseAction(s string) (Action, bool) {
+	action := Action(strings.ToLower(strings.TrimSpace(s)))
+	_, ok := severity[action]
+	return action, ok
+}
+
+// Input is everything a classifier may look at for one outgoing message
+type Input struct {
+	SenderID        uint
+	ReceiverID      uint
+	Content         string
+	PriorMessages   int64  // Messages the sender has already sent to this receiver
+	UnansweredCount int64  // Consecutive messages from the sender since the receiver last replied
+	PreviousContent string // The sender's previous message to this receiver, if any
+}
+
+// Finding is a single rule hit
+type Finding struct {
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+	Action Action `json:"action"`
+}
+
+// Classifier inspects a message and reports rule hits. The pipeline decides the action for each rule.
+type Classifier interface {
+	Classify(input Input) []Finding
+}
+
+// Result is the combined outcome of every classifier
+type Result struct {
+	Action   Action    `json:"action"`
+	Findings []Finding `json:"findings"`
+}
+
+// Config maps rule names to the action taken when they fire
+type Config struct {
+	Actions       map[string]Action
+	DefaultAction Action
+}
+
+// ActionFor returns the configured action for a rule
+func (c Config) ActionFor(rule string) Action {
+	if action, ok := c.Actions[rule]; ok {
+		return action
+	}
+	if c.DefaultAction != "" {
+		return c.DefaultAction
+	}
+	return ActionWarn
+}
+
+// Pipeline runs a message through a list of classifiers
+type Pipeline struct {
+	config      Config
+	classifiers []Classifier
+}
+
+// NewPipeline creates a pipeline with the given rule actions and classifiers
+func NewPipeline(config Config, classifiers ...Classifier) *Pipeline {
+	return &Pipeline{config: config, classifiers: classifiers}
+}
+
+// Register adds a classifier to the pipeline
+func (p *Pipeline) Register(classifier Classifier) {
+	p.classifiers = append(p.classifiers, classifier)
+}
+
+// Evaluate runs every classifier and returns the strictest action along with all findings
+func (p *Pipeline) Evaluate(input Input) Result {
+	result := Result{Action: ActionAllow}
+	for _, classifier := range p.classifiers {
+		for _, finding := range classifier.Classify(input) {
+			finding.Action = p.config.ActionFor(finding.Rule)
+			if finding.Action == ActionAllow {
+				continue
+			}
+			result.Findings = append(result.Findings, finding)
+			if severity[finding.Action] > severity[result.Action] {
+				result.Action = finding.Action
+			}
+		}
+	}
+	return result
+}
+
+// DefaultConfig returns the built-in rule actions
+func DefaultConfig() Config {
+	return Config{
+		Actions: map[string]Action{
+			RuleKeyword:           ActionBlock,
+			RuleLink:              ActionWarn,
+			RulePhone:             ActionWarn,
+			RuleHarassmentInsult:  ActionHold,
+			RuleHarassmentShout:   ActionWarn,
+			RuleHarassmentFlood:   ActionHold,
+			RuleHarassmentRepeats: ActionWarn,
+		},
+		DefaultAction: ActionWarn,
+	}
+}
+
+// NewPipelineFromEnv builds the default pipeline, letting SAFETY_ACTION_<RULE> override rule actions,
+// SAFETY_KEYWORDS add comma-separated blocked words and SAFETY_CONTACT_WINDOW set how many opening
+// messages are checked for links and phone numbers.
+func NewPipelineFromEnv() *Pipeline {
+	config := DefaultConfig()
+	for rule := range config.Actions {
+		envVar := "SAFETY_ACTION_" + strings.ToUpper(rule)
+		if value := os.Getenv(envVar); value != "" {
+			if action, ok := ParseAction(value); ok {
+				config.Actions[rule] = action
+			}
+		}
+	}
+
+	keywords := DefaultKeywords()
+	if extra := os.Getenv("SAFETY_KEYWORDS"); extra != "" {
+		for _, word := range strings.Split(extra, ",") {
+			if word = strings.TrimSpace(word); word != "" {
+				keywords.Words = append(keywords.Words, word)
+			}
+		}
+	}
+
+	contactWindow := int64(defaultContactWindow)
+	if value := os.Getenv("SAFETY_CONTACT_WINDOW"); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n >= 0 {
+			contactWindow = n
+		}
+	}
+
+	return NewPipeline(config,
+		keywords,
+		&ContactInfoClassifier{Window: contactWindow},
+		DefaultHarassment(),
+	)
+}
@@ -0,0 +1,57 @@
+package safety
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineTakesStrictestAction(t *testing.T) {
+	pipeline := NewPipeline(DefaultConfig(), DefaultKeywords(), &ContactInfoClassifier{Window: 5}, DefaultHarassment())
+
+	result := pipeline.Evaluate(Input{Content: "Hey! How was your weekend?"})
+	assert.Equal(t, ActionAllow, result.Action)
+	assert.Empty(t, result.Findings)
+
+	result = pipeline.Evaluate(Input{Content: "text me at +1 (352) 555-0123"})
+	assert.Equal(t, ActionWarn, result.Action)
+	assert.Equal(t, RulePhone, result.Findings[0].Rule)
+
+	result = pipeline.Evaluate(Input{Content: "check www.example.com and send me a gift card"})
+	assert.Equal(t, ActionBlock, result.Action)
+	assert.Len(t, result.Findings, 2)
+}
+
+func TestContactInfoOnlyEarlyInConversation(t *testing.T) {
+	classifier := &ContactInfoClassifier{Window: 3}
+	assert.NotEmpty(t, classifier.Classify(Input{Content: "https://example.com", PriorMessages: 2}))
+	assert.Empty(t, classifier.Classify(Input{Content: "https://example.com", PriorMessages: 3}))
+}
+
+func TestHarassmentHeuristics(t *testing.T) {
+	classifier := DefaultHarassment()
+
+	rules := func(findings []Finding) []string {
+		var names []string
+		for _, f := range findings {
+			names = append(names, f.Rule)
+		}
+		return names
+	}
+
+	assert.Contains(t, rules(classifier.Classify(Input{Content: "you're such a loser"})), RuleHarassmentInsult)
+	assert.NotContains(t, rules(classifier.Classify(Input{Content: "closer to campus"})), RuleHarassmentInsult)
+	assert.Contains(t, rules(classifier.Classify(Input{Content: "WHY ARE YOU IGNORING ME"})), RuleHarassmentShout)
+	assert.Contains(t, rules(classifier.Classify(Input{Content: "hi", UnansweredCount: 10})), RuleHarassmentFlood)
+	assert.Contains(t, rules(classifier.Classify(Input{Content: "hello?", PreviousContent: "Hello?"})), RuleHarassmentRepeats)
+}
+
+func TestConfigOverridesAction(t *testing.T) {
+	config := DefaultConfig()
+	config.Actions[RuleLink] = ActionAllow
+	pipeline := NewPipeline(config, &ContactInfoClassifier{Window: 5})
+
+	result := pipeline.Evaluate(Input{Content: "https://example.com"})
+	assert.Equal(t, ActionAllow, result.Action)
+	assert.Empty(t, result.Findings)
+}